@@ -22,9 +22,12 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"path/filepath"
 	"runtime"
 
 	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/walletdb"
 )
 
 var (
@@ -69,12 +72,52 @@ func walletMain() error {
 
 	// Load the wallet database.  It must have been created with the
 	// --create option already or this will return an appropriate error.
-	wallet, db, err := openWallet()
-	if err != nil {
-		log.Errorf("%v", err)
-		return err
+	// When the initial load is disabled, no default wallet is used and
+	// wallets are instead created and loaded by RPC clients.
+	var w *wallet.Wallet
+	if !cfg.NoInitialLoad {
+		var db walletdb.DB
+
+		// With --createtemp, a temporary simulation wallet is created
+		// in memory if no wallet exists.
+		netDir := networkDir(cfg.DataDir, activeNet.Params)
+		if cfg.CreateTemp && !fileExists(filepath.Join(netDir, walletDbName)) {
+			w, db, err = createSimulationWallet()
+		} else {
+			w, db, err = openWallet()
+		}
+		if err != nil {
+			log.Errorf("%v", err)
+			return err
+		}
+		defer db.Close()
+	}
+
+	// Open the outbox for webhook events and subscribe to wallet
+	// notifications, if any receivers are configured.  Any events which
+	// were not delivered by a previous run are sent once the dispatcher is
+	// started.
+	var webhooks *webhookDispatcher
+	if len(cfg.WebhookURLs) != 0 && w == nil {
+		log.Warnf("Webhooks are only sent for the default wallet, " +
+			"which is not loaded")
+	} else if len(cfg.WebhookURLs) != 0 {
+		netDir := networkDir(cfg.DataDir, activeNet.Params)
+		webhookDb, err := openWebhookDb(filepath.Join(netDir, webhookDbName))
+		if err != nil {
+			log.Errorf("Unable to open webhook database: %v", err)
+			return err
+		}
+		defer webhookDb.Close()
+		webhooks, err = newWebhookDispatcher(webhookDb, cfg.WebhookURLs,
+			[]byte(cfg.WebhookSecret), cfg.WebhookMaxAttempts)
+		if err != nil {
+			log.Errorf("Unable to create webhook dispatcher: %v", err)
+			return err
+		}
+		webhooks.Start(w)
+		addInterruptHandler(webhooks.Stop)
 	}
-	defer db.Close()
 
 	// Create and start HTTP server to serve wallet client connections.
 	// This will be updated with the wallet and chain server RPC client
@@ -86,7 +129,7 @@ func walletMain() error {
 		return err
 	}
 	server.Start()
-	server.SetWallet(wallet)
+	server.SetWallet(w)
 
 	// Shutdown the server if an interrupt signal is received.
 	addInterruptHandler(server.Stop)
@@ -135,7 +178,11 @@ func walletMain() error {
 		// if the chain server connection was opened.
 		select {
 		case chainSvr := <-chainSvrChan:
-			wallet.Start(chainSvr)
+			// The connection is shared so further wallets
+			// may be loaded with the loadwallet method.
+			if w != nil {
+				w.Start(chainSvr.Share())
+			}
 		case <-server.quit:
 		}
 	}()
@@ -143,6 +190,10 @@ func walletMain() error {
 	// Wait for the server to shutdown either due to a stop RPC request
 	// or an interrupt.
 	server.WaitForShutdown()
+	if webhooks != nil {
+		webhooks.Stop()
+		webhooks.WaitForShutdown()
+	}
 	log.Info("Shutdown complete")
 	return nil
 }
@@ -40,6 +40,23 @@ type Client struct {
 	dequeueNotification chan interface{}
 	currentBlock        chan *waddrmgr.BlockStamp
 
+	// parent is the client which owns the connection used by a client
+	// created by Share, or nil if this client owns its own connection.
+	parent *Client
+
+	// Clients which share this client's connection, and the state needed
+	// to route notifications to them.  Rescans are serialized so rescan
+	// notifications are only sent to the client which requested the
+	// rescan.
+	shareOnce    sync.Once
+	sharedMu     sync.Mutex
+	shared       map[*Client]struct{}
+	sharedClosed bool
+	connected    bool
+	rescanMu     sync.Mutex
+	rescanning   *Client
+	rescanDone   chan struct{}
+
 	quit    chan struct{}
 	wg      sync.WaitGroup
 	started bool
@@ -118,7 +135,8 @@ func (c *Client) Start() error {
 }
 
 // Stop disconnects the client and signals the shutdown of all goroutines
-// started by Start.
+// started by Start.  Stopping a client created by Share only stops the
+// delivery of notifications to it, and the shared connection remains open.
 func (c *Client) Stop() {
 	c.quitMtx.Lock()
 	defer c.quitMtx.Unlock()
@@ -127,6 +145,10 @@ func (c *Client) Stop() {
 	case <-c.quit:
 	default:
 		close(c.quit)
+		if c.parent != nil {
+			c.parent.unshare(c)
+			return
+		}
 		c.Client.Shutdown()
 
 		if !c.started {
@@ -138,7 +160,9 @@ func (c *Client) Stop() {
 // WaitForShutdown blocks until both the client has finished disconnecting
 // and all handlers have exited.
 func (c *Client) WaitForShutdown() {
-	c.Client.WaitForShutdown()
+	if c.parent == nil {
+		c.Client.WaitForShutdown()
+	}
 	c.wg.Wait()
 }
 
@@ -194,6 +218,10 @@ func (c *Client) Notifications() <-chan interface{} {
 // BlockStamp returns the latest block notified by the client, or an error
 // if the client has been shut down.
 func (c *Client) BlockStamp() (*waddrmgr.BlockStamp, error) {
+	if c.parent != nil {
+		return c.parent.BlockStamp()
+	}
+
 	select {
 	case bs := <-c.currentBlock:
 		return bs, nil
@@ -0,0 +1,190 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package chain
+
+import (
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// Share returns a new Client which shares the RPC connection of c.  This
+// allows a single chain server connection to serve several wallets.  Every
+// shared client receives its own copy of each chain notification, except for
+// rescan notifications, which are only delivered to the shared client which
+// requested the rescan.
+//
+// Stopping a shared client only stops the delivery of notifications to it.
+// The connection remains open until c itself is stopped.  After the first
+// call to Share, notifications from c are read by the shared clients and c's
+// own Notifications channel must not be read.
+func (c *Client) Share() *Client {
+	if c.parent != nil {
+		return c.parent.Share()
+	}
+
+	c.shareOnce.Do(func() {
+		c.shared = make(map[*Client]struct{})
+		c.wg.Add(1)
+		go c.distributeNotifications()
+	})
+
+	s := &Client{
+		Client:              c.Client,
+		chainParams:         c.chainParams,
+		enqueueNotification: make(chan interface{}),
+		dequeueNotification: make(chan interface{}),
+		parent:              c,
+		quit:                make(chan struct{}),
+		started:             true,
+	}
+	s.wg.Add(1)
+	go s.sharedHandler()
+
+	c.sharedMu.Lock()
+	if c.sharedClosed {
+		close(s.enqueueNotification)
+	} else {
+		c.shared[s] = struct{}{}
+
+		// A client created after the connection was established must
+		// still be notified of it so it can begin syncing.
+		if c.connected {
+			s.enqueueNotification <- ClientConnected{}
+		}
+	}
+	c.sharedMu.Unlock()
+
+	return s
+}
+
+// unshare stops delivering notifications to a shared client.
+func (c *Client) unshare(s *Client) {
+	c.sharedMu.Lock()
+	if _, ok := c.shared[s]; ok {
+		delete(c.shared, s)
+		close(s.enqueueNotification)
+	}
+	c.sharedMu.Unlock()
+}
+
+// distributeNotifications reads every notification from the shared connection
+// and copies it to each shared client.  Rescan notifications are only sent to
+// the client performing the current rescan.
+func (c *Client) distributeNotifications() {
+	for n := range c.dequeueNotification {
+		c.sharedMu.Lock()
+		switch n.(type) {
+		case *RescanProgress, *RescanFinished:
+			if _, ok := c.shared[c.rescanning]; ok {
+				c.rescanning.enqueueNotification <- n
+			}
+			if _, ok := n.(*RescanFinished); ok && c.rescanDone != nil {
+				close(c.rescanDone)
+				c.rescanDone = nil
+			}
+
+		default:
+			if _, ok := n.(ClientConnected); ok {
+				c.connected = true
+			}
+			for s := range c.shared {
+				s.enqueueNotification <- n
+			}
+		}
+		c.sharedMu.Unlock()
+	}
+
+	c.sharedMu.Lock()
+	for s := range c.shared {
+		delete(c.shared, s)
+		close(s.enqueueNotification)
+	}
+	c.sharedClosed = true
+	c.sharedMu.Unlock()
+	c.wg.Done()
+}
+
+// sharedHandler maintains the queue of notifications for a shared client.  It
+// never blocks the distribution of notifications to other shared clients.
+func (c *Client) sharedHandler() {
+	var notifications []interface{}
+	var dequeue chan interface{}
+	var next interface{}
+out:
+	for {
+		select {
+		case n, ok := <-c.enqueueNotification:
+			if !ok {
+				break out
+			}
+			if len(notifications) == 0 {
+				next = n
+				dequeue = c.dequeueNotification
+			}
+			notifications = append(notifications, n)
+
+		case dequeue <- next:
+			notifications[0] = nil
+			notifications = notifications[1:]
+			if len(notifications) != 0 {
+				next = notifications[0]
+			} else {
+				dequeue = nil
+			}
+		}
+	}
+	close(c.dequeueNotification)
+	c.wg.Done()
+}
+
+// Rescan wraps the RPC client's Rescan method.  Rescans performed by shared
+// clients are serialized so the progress and finished notifications are only
+// delivered to the client which requested the rescan.
+func (c *Client) Rescan(startBlock *wire.ShaHash, addresses []btcutil.Address,
+	outPoints []*wire.OutPoint) error {
+
+	p := c.parent
+	if p == nil {
+		return c.Client.Rescan(startBlock, addresses, outPoints)
+	}
+
+	p.rescanMu.Lock()
+	defer p.rescanMu.Unlock()
+
+	done := make(chan struct{})
+	p.sharedMu.Lock()
+	p.rescanning = c
+	p.rescanDone = done
+	p.sharedMu.Unlock()
+
+	err := c.Client.Rescan(startBlock, addresses, outPoints)
+	if err == nil {
+		// The rescan finished notification may still be queued.  Wait
+		// for it to be delivered before another client may begin a
+		// rescan.
+		select {
+		case <-done:
+		case <-c.quit:
+		case <-p.quit:
+		}
+	}
+
+	p.sharedMu.Lock()
+	p.rescanning = nil
+	p.rescanDone = nil
+	p.sharedMu.Unlock()
+
+	return err
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/cryptdb"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/btcsuite/go-flags"
+)
+
+var datadir = btcutil.AppDataDir("btcwallet", false)
+
+// Flags.
+var opts = struct {
+	Repair     bool   `long:"repair" description:"Repair problems which can be fixed from the remaining data"`
+	DbPath     string `long:"db" description:"Path to wallet database (default mainnet wallet)"`
+	DbPass     string `long:"dbpass" default-mask:"-" description:"Database passphrase, if the wallet database is encrypted"`
+	PubPass    string `long:"pubpass" description:"Public wallet passphrase" default:"public"`
+	TestNet3   bool   `long:"testnet" description:"Use the test network"`
+	SimNet     bool   `long:"simnet" description:"Use the simulation test network"`
+	chainParam *chaincfg.Params
+}{
+	chainParam: &chaincfg.MainNetParams,
+}
+
+func init() {
+	_, err := flags.Parse(&opts)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// Use the network directory of the selected network for the default
+	// database path.  The testnet directory is always named "testnet".
+	netname := "mainnet"
+	switch {
+	case opts.TestNet3 && opts.SimNet:
+		fmt.Println("The testnet and simnet params can't be used together")
+		os.Exit(1)
+	case opts.TestNet3:
+		opts.chainParam = &chaincfg.TestNet3Params
+		netname = "testnet"
+	case opts.SimNet:
+		opts.chainParam = &chaincfg.SimNetParams
+		netname = opts.chainParam.Name
+	}
+	if opts.DbPath == "" {
+		opts.DbPath = filepath.Join(datadir, netname, "wallet.db")
+	}
+}
+
+// Namespace keys.
+var (
+	waddrmgrNamespace = []byte("waddrmgr")
+	wtxmgrNamespace   = []byte("wtxmgr")
+)
+
+// problem is a problem found by the address manager or transaction store
+// checks.
+type problem struct {
+	desc     string
+	repaired bool
+}
+
+// report prints the problems found in a namespace and returns the number of
+// problems which were not repaired.
+func report(name string, problems []problem) int {
+	if len(problems) == 0 {
+		fmt.Printf("%s: no problems found\n", name)
+		return 0
+	}
+
+	unrepaired := 0
+	fmt.Printf("%s: %d problems found\n", name, len(problems))
+	for _, p := range problems {
+		if p.repaired {
+			fmt.Printf("  %s (repaired)\n", p.desc)
+			continue
+		}
+		fmt.Printf("  %s\n", p.desc)
+		unrepaired++
+	}
+	return unrepaired
+}
+
+// dbArgs returns the database type and arguments used to open the wallet
+// database, which is decrypted with the database passphrase when one is set.
+func dbArgs() (string, []interface{}) {
+	if opts.DbPass == "" {
+		return "bdb", []interface{}{opts.DbPath}
+	}
+	return "cryptdb", []interface{}{"bdb", []byte(opts.DbPass), opts.DbPath}
+}
+
+func main() {
+	os.Exit(mainInt())
+}
+
+func mainInt() int {
+	fmt.Println("Database path:", opts.DbPath)
+	_, err := os.Stat(opts.DbPath)
+	if os.IsNotExist(err) {
+		fmt.Println("Database file does not exist")
+		return 1
+	}
+
+	// The database is only opened for writing when repairing it, so it
+	// may be checked while in use by other readers.
+	open := walletdb.OpenReadOnly
+	if opts.Repair {
+		open = walletdb.Open
+	}
+	dbType, args := dbArgs()
+	db, err := open(dbType, args...)
+	if err != nil {
+		fmt.Println("Failed to open database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	// The address manager and transaction store are upgraded to their
+	// latest versions when opened, which requires write access.  Without
+	// --repair, report that an out of date database must be upgraded
+	// rather than failing to open it.
+	if !opts.Repair {
+		schemas := []*migration.Schema{
+			waddrmgr.MigrationSchema(waddrmgrNamespace,
+				[]byte(opts.PubPass), opts.chainParam, nil),
+			wtxmgr.MigrationSchema(wtxmgrNamespace),
+		}
+		pending, err := migration.Pending(db, schemas)
+		if err != nil {
+			fmt.Println("Failed to read database versions:", err)
+			return 1
+		}
+		if len(pending) != 0 {
+			fmt.Println("Database needs upgrade before it can be " +
+				"checked:")
+			for _, a := range pending {
+				fmt.Printf("  %v\n", a)
+			}
+			fmt.Println("Open the wallet with btcwallet, or run " +
+				"with --repair, to upgrade it")
+			return 1
+		}
+	}
+
+	addrMgrNS, err := db.Namespace(waddrmgrNamespace)
+	if err != nil {
+		fmt.Println("Failed to open waddrmgr namespace:", err)
+		return 1
+	}
+	txMgrNS, err := db.Namespace(wtxmgrNamespace)
+	if err != nil {
+		fmt.Println("Failed to open wtxmgr namespace:", err)
+		return 1
+	}
+
+	unrepaired := 0
+
+	mgr, err := waddrmgr.Open(addrMgrNS, []byte(opts.PubPass),
+		opts.chainParam, nil)
+	if err != nil {
+		fmt.Println("Failed to open address manager:", err)
+		return 1
+	}
+	defer mgr.Close()
+	mgrProblems, err := mgr.Check(opts.Repair)
+	if err != nil {
+		fmt.Println("Failed to check address manager:", err)
+		return 1
+	}
+	problems := make([]problem, 0, len(mgrProblems))
+	for _, p := range mgrProblems {
+		problems = append(problems, problem{p.Desc, p.Repaired})
+	}
+	unrepaired += report("waddrmgr", problems)
+
+	txStore, err := wtxmgr.Open(txMgrNS)
+	if err != nil {
+		fmt.Println("Failed to open transaction store:", err)
+		return 1
+	}
+	storeProblems, err := txStore.Check(opts.Repair)
+	if err != nil {
+		fmt.Println("Failed to check transaction store:", err)
+		return 1
+	}
+	problems = make([]problem, 0, len(storeProblems))
+	for _, p := range storeProblems {
+		problems = append(problems, problem{p.Desc, p.Repaired})
+	}
+	unrepaired += report("wtxmgr", problems)
+
+	if unrepaired != 0 {
+		return 1
+	}
+	return 0
+}
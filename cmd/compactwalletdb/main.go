@@ -0,0 +1,175 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/btcsuite/go-flags"
+)
+
+const defaultNet = "mainnet"
+
+var datadir = btcutil.AppDataDir("btcwallet", false)
+
+// Flags.
+var opts = struct {
+	Force     bool   `short:"f" description:"Force compaction without prompt"`
+	StatsOnly bool   `long:"statsonly" description:"Only display database statistics"`
+	DbPath    string `long:"db" description:"Path to wallet database"`
+}{
+	Force:  false,
+	DbPath: filepath.Join(datadir, defaultNet, "wallet.db"),
+}
+
+func init() {
+	_, err := flags.Parse(&opts)
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+func yes(s string) bool {
+	switch s {
+	case "y", "Y", "yes", "Yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func no(s string) bool {
+	switch s {
+	case "n", "N", "no", "No":
+		return true
+	default:
+		return false
+	}
+}
+
+// printBucket writes the statistics of a bucket and all of its nested buckets,
+// indenting each nested bucket beneath its parent.
+func printBucket(b *bdb.BucketStats, depth int) {
+	keys, n := b.Total()
+	fmt.Printf("%s%-*q %8d keys %12d bytes (%d keys, %d bytes total)\n",
+		strings.Repeat("  ", depth), 24-2*depth, b.Name, b.Keys,
+		b.KeyBytes+b.ValueBytes, keys, n)
+	for _, nested := range b.Buckets {
+		printBucket(nested, depth+1)
+	}
+}
+
+// printStats opens the database read-only and writes its statistics.
+func printStats() error {
+	db, err := walletdb.OpenReadOnly("bdb", opts.DbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	stats, err := bdb.Stats(db)
+	if err != nil {
+		return err
+	}
+	for _, ns := range stats.Namespaces {
+		printBucket(ns, 0)
+	}
+	fmt.Printf("Data size: %d bytes, free pages: %d (%d bytes)\n",
+		stats.Size, stats.FreePages, stats.FreePages*stats.PageSize)
+	return nil
+}
+
+func fileSize(path string) (int64, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func main() {
+	os.Exit(mainInt())
+}
+
+func mainInt() int {
+	fmt.Println("Database path:", opts.DbPath)
+	before, err := fileSize(opts.DbPath)
+	if os.IsNotExist(err) {
+		fmt.Println("Database file does not exist")
+		return 1
+	}
+	if err != nil {
+		fmt.Println("Failed to stat database:", err)
+		return 1
+	}
+
+	err = printStats()
+	if err != nil {
+		fmt.Println("Failed to read database statistics:", err)
+		return 1
+	}
+	fmt.Printf("File size: %d bytes\n", before)
+	if opts.StatsOnly {
+		return 0
+	}
+
+	for !opts.Force {
+		fmt.Print("Compact the wallet database?  btcwallet must not be " +
+			"running. [y/N] ")
+
+		scanner := bufio.NewScanner(bufio.NewReader(os.Stdin))
+		if !scanner.Scan() {
+			// Exit on EOF.
+			return 0
+		}
+		err := scanner.Err()
+		if err != nil {
+			fmt.Println()
+			fmt.Println(err)
+			return 1
+		}
+		resp := scanner.Text()
+		if yes(resp) {
+			break
+		}
+		if no(resp) || resp == "" {
+			return 0
+		}
+
+		fmt.Println("Enter yes or no.")
+	}
+
+	fmt.Println("Compacting database")
+	err = bdb.Compact(opts.DbPath)
+	if err != nil {
+		fmt.Println("Failed to compact database:", err)
+		return 1
+	}
+	after, err := fileSize(opts.DbPath)
+	if err != nil {
+		fmt.Println("Failed to stat database:", err)
+		return 1
+	}
+	fmt.Printf("File size: %d bytes (was %d bytes)\n", after, before)
+
+	return 0
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/walletdb"
 	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/cryptdb"
 	"github.com/btcsuite/go-flags"
 )
 
@@ -34,6 +35,7 @@ var datadir = btcutil.AppDataDir("btcwallet", false)
 var opts = struct {
 	Force  bool   `short:"f" description:"Force removal without prompt"`
 	DbPath string `long:"db" description:"Path to wallet database"`
+	DbPass string `long:"dbpass" default-mask:"-" description:"Database passphrase, if the wallet database is encrypted"`
 }{
 	Force:  false,
 	DbPath: filepath.Join(datadir, defaultNet, "wallet.db"),
@@ -69,6 +71,15 @@ func no(s string) bool {
 	}
 }
 
+// dbArgs returns the database type and arguments used to open the wallet
+// database, which is decrypted with the database passphrase when one is set.
+func dbArgs() (string, []interface{}) {
+	if opts.DbPass == "" {
+		return "bdb", []interface{}{opts.DbPath}
+	}
+	return "cryptdb", []interface{}{"bdb", []byte(opts.DbPass), opts.DbPath}
+}
+
 func main() {
 	os.Exit(mainInt())
 }
@@ -106,7 +117,8 @@ func mainInt() int {
 		fmt.Println("Enter yes or no.")
 	}
 
-	db, err := walletdb.Open("bdb", opts.DbPath)
+	dbType, args := dbArgs()
+	db, err := walletdb.Open(dbType, args...)
 	if err != nil {
 		fmt.Println("Failed to open database:", err)
 		return 1
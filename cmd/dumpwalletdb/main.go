@@ -0,0 +1,163 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/dump"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/cryptdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+	"github.com/btcsuite/go-flags"
+)
+
+var datadir = btcutil.AppDataDir("btcwallet", false)
+
+// Flags.
+var opts = struct {
+	DbPath   string `long:"db" description:"Path to wallet database (default mainnet wallet)"`
+	DbPass   string `long:"dbpass" default-mask:"-" description:"Database passphrase, if the wallet database is encrypted or a restored database is to be encrypted"`
+	Out      string `short:"o" long:"out" description:"Write the dump to this file instead of stdout"`
+	Restore  string `long:"restore" description:"Create the wallet database from this dump file instead of dumping it"`
+	TestNet3 bool   `long:"testnet" description:"Use the test network"`
+	SimNet   bool   `long:"simnet" description:"Use the simulation test network"`
+}{}
+
+func init() {
+	_, err := flags.Parse(&opts)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// Use the network directory of the selected network for the default
+	// database path.  The testnet directory is always named "testnet".
+	netname := "mainnet"
+	switch {
+	case opts.TestNet3 && opts.SimNet:
+		fmt.Fprintln(os.Stderr, "The testnet and simnet params can't "+
+			"be used together")
+		os.Exit(1)
+	case opts.TestNet3:
+		netname = "testnet"
+	case opts.SimNet:
+		netname = "simnet"
+	}
+	if opts.DbPath == "" {
+		opts.DbPath = filepath.Join(datadir, netname, "wallet.db")
+	}
+}
+
+// dbArgs returns the database type and arguments used to open or restore the
+// wallet database.  The cryptdb driver is used when a database passphrase is
+// set.
+func dbArgs() (string, []interface{}) {
+	if opts.DbPass == "" {
+		return "bdb", []interface{}{opts.DbPath}
+	}
+	return "cryptdb", []interface{}{"bdb", []byte(opts.DbPass), opts.DbPath}
+}
+
+func main() {
+	os.Exit(mainInt())
+}
+
+func mainInt() int {
+	fmt.Fprintln(os.Stderr, "Database path:", opts.DbPath)
+	if opts.Restore != "" {
+		return restore()
+	}
+
+	_, err := os.Stat(opts.DbPath)
+	if os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "Database file does not exist")
+		return 1
+	}
+	dbType, args := dbArgs()
+	db, err := walletdb.OpenReadOnly(dbType, args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open database:", err)
+		return 1
+	}
+	defer db.Close()
+
+	var w io.Writer = os.Stdout
+	if opts.Out != "" {
+		fi, err := os.OpenFile(opts.Out, os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+			0600)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to create dump file:", err)
+			return 1
+		}
+		defer fi.Close()
+		w = fi
+	}
+
+	// The database is opened read-only, so an out of date database is
+	// reported rather than upgraded.
+	err = dump.Dump(db, w)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to dump database:", err)
+		txErr, ok := err.(wtxmgr.Error)
+		if waddrmgr.IsError(err, waddrmgr.ErrUpgrade) ||
+			(ok && txErr.Code == wtxmgr.ErrUnknownVersion) {
+			fmt.Fprintln(os.Stderr, "Open the wallet with "+
+				"btcwallet to upgrade it")
+		}
+		return 1
+	}
+	return 0
+}
+
+// restore creates a new wallet database from the dump file.  The database is
+// removed again if it can not be restored.
+func restore() int {
+	fi, err := os.Open(opts.Restore)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open dump file:", err)
+		return 1
+	}
+	defer fi.Close()
+
+	_, err = os.Stat(opts.DbPath)
+	if !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "Database file already exists")
+		return 1
+	}
+	dbType, args := dbArgs()
+	db, err := walletdb.Create(dbType, args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to create database:", err)
+		return 1
+	}
+	err = dump.Restore(db, fi)
+	closeErr := db.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(opts.DbPath)
+		fmt.Fprintln(os.Stderr, "Failed to restore database:", err)
+		return 1
+	}
+	fmt.Fprintln(os.Stderr, "Restored database from", opts.Restore)
+	return 0
+}
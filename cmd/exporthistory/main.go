@@ -0,0 +1,208 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/cryptdb"
+	"github.com/btcsuite/go-flags"
+)
+
+var datadir = btcutil.AppDataDir("btcwallet", false)
+
+// dateFormat is the format of the start and end date flags.
+const dateFormat = "2006-01-02"
+
+// Flags.
+var opts = struct {
+	DbPath      string `long:"db" description:"Path to wallet database (default mainnet wallet)"`
+	DbPass      string `long:"dbpass" default-mask:"-" description:"Database passphrase, if the wallet database is encrypted"`
+	PubPass     string `long:"pubpass" description:"Public wallet passphrase" default:"public"`
+	Out         string `short:"o" long:"out" description:"Write the export to this file instead of stdout"`
+	Format      string `long:"format" description:"Export format (csv or ofx)" default:"csv"`
+	Account     string `long:"account" description:"Only export transactions of this account (default all accounts)"`
+	StartHeight int32  `long:"startheight" description:"Height of the first block to export transactions from"`
+	EndHeight   int32  `long:"endheight" description:"Height of the last block to export transactions from (default newest block)" default:"-1"`
+	StartDate   string `long:"startdate" description:"First day (YYYY-MM-DD, UTC) to export transactions from"`
+	EndDate     string `long:"enddate" description:"Last day (YYYY-MM-DD, UTC) to export transactions from"`
+	TestNet3    bool   `long:"testnet" description:"Use the test network"`
+	SimNet      bool   `long:"simnet" description:"Use the simulation test network"`
+	chainParam  *chaincfg.Params
+}{
+	chainParam: &chaincfg.MainNetParams,
+}
+
+func init() {
+	_, err := flags.Parse(&opts)
+	if err != nil {
+		os.Exit(1)
+	}
+
+	// Use the network directory of the selected network for the default
+	// database path.  The testnet directory is always named "testnet".
+	netname := "mainnet"
+	switch {
+	case opts.TestNet3 && opts.SimNet:
+		fmt.Fprintln(os.Stderr, "The testnet and simnet params can't "+
+			"be used together")
+		os.Exit(1)
+	case opts.TestNet3:
+		opts.chainParam = &chaincfg.TestNet3Params
+		netname = "testnet"
+	case opts.SimNet:
+		opts.chainParam = &chaincfg.SimNetParams
+		netname = opts.chainParam.Name
+	}
+	if opts.DbPath == "" {
+		opts.DbPath = filepath.Join(datadir, netname, "wallet.db")
+	}
+}
+
+// Namespace keys.
+var (
+	waddrmgrNamespace = []byte("waddrmgr")
+	wtxmgrNamespace   = []byte("wtxmgr")
+)
+
+// dbArgs returns the database type and arguments used to open the wallet
+// database, which is decrypted with the database passphrase when one is set.
+func dbArgs() (string, []interface{}) {
+	if opts.DbPass == "" {
+		return "bdb", []interface{}{opts.DbPath}
+	}
+	return "cryptdb", []interface{}{"bdb", []byte(opts.DbPass), opts.DbPath}
+}
+
+func main() {
+	os.Exit(mainInt())
+}
+
+func mainInt() int {
+	filter := wallet.LedgerFilter{
+		StartHeight: opts.StartHeight,
+		EndHeight:   opts.EndHeight,
+	}
+	if opts.StartDate != "" {
+		t, err := time.Parse(dateFormat, opts.StartDate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid start date:", err)
+			return 1
+		}
+		filter.StartTime = t
+	}
+	if opts.EndDate != "" {
+		t, err := time.Parse(dateFormat, opts.EndDate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Invalid end date:", err)
+			return 1
+		}
+		// Include every transaction mined on the end date.
+		filter.EndTime = t.AddDate(0, 0, 1)
+	}
+
+	var write func(io.Writer, []wallet.LedgerEntry) error
+	switch opts.Format {
+	case "csv":
+		write = wallet.WriteLedgerCSV
+	case "ofx":
+		acctID := opts.Account
+		if acctID == "" {
+			acctID = "*"
+		}
+		write = func(w io.Writer, entries []wallet.LedgerEntry) error {
+			return wallet.WriteLedgerOFX(w, entries, acctID)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown export format %q\n", opts.Format)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stderr, "Database path:", opts.DbPath)
+	_, err := os.Stat(opts.DbPath)
+	if os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "Database file does not exist")
+		return 1
+	}
+
+	// The database is opened read-only, so the export may be created while
+	// the wallet is in use, or from a read-only copy of the database.
+	dbType, args := dbArgs()
+	db, err := walletdb.OpenReadOnly(dbType, args...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open database:", err)
+		return 1
+	}
+	defer db.Close()
+	addrMgrNS, err := db.Namespace(waddrmgrNamespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open waddrmgr namespace:", err)
+		return 1
+	}
+	txMgrNS, err := db.Namespace(wtxmgrNamespace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open wtxmgr namespace:", err)
+		return 1
+	}
+	w, err := wallet.OpenReadOnly([]byte(opts.PubPass), opts.chainParam,
+		db, addrMgrNS, txMgrNS)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to open wallet:", err)
+		return 1
+	}
+	defer w.Manager.Close()
+
+	if opts.Account != "" {
+		acct, err := w.Manager.LookupAccount(opts.Account)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to look up account:", err)
+			return 1
+		}
+		filter.Account = &acct
+	}
+
+	entries, err := w.Ledger(&filter)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to read transactions:", err)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.Out != "" {
+		fi, err := os.OpenFile(opts.Out, os.O_CREATE|os.O_EXCL|os.O_WRONLY,
+			0600)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Failed to create export file:", err)
+			return 1
+		}
+		defer fi.Close()
+		out = fi
+	}
+	err = write(out, entries)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Failed to write export:", err)
+		return 1
+	}
+	return 0
+}
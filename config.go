@@ -19,13 +19,16 @@ package main
 import (
 	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/internal/legacy/keystore"
+	"github.com/btcsuite/btcwallet/walletdb/bdb"
 	flags "github.com/btcsuite/go-flags"
 )
 
@@ -67,7 +70,8 @@ var (
 type config struct {
 	ShowVersion      bool     `short:"V" long:"version" description:"Display version information and exit"`
 	Create           bool     `long:"create" description:"Create the wallet if it does not exist"`
-	CreateTemp       bool     `long:"createtemp" description:"Create a temporary simulation wallet (pass=password) in the data directory indicated; must call with --datadir"`
+	CreateTemp       bool     `long:"createtemp" description:"Create a temporary in-memory simulation wallet (pass=password) unless a wallet exists in the data directory indicated; must call with --datadir"`
+	NoInitialLoad    bool     `long:"noinitialload" description:"Start without loading the default wallet; wallets are created and loaded with the createwallet, restorewallet, and loadwallet methods"`
 	CAFile           string   `long:"cafile" description:"File containing root certificates to authenticate a TLS connections with btcd"`
 	RPCConnect       string   `short:"c" long:"rpcconnect" description:"Hostname/IP and port of btcd RPC server to connect to (default localhost:18334, mainnet: localhost:8334, simnet: localhost:18556)"`
 	DebugLevel       string   `short:"d" long:"debuglevel" description:"Logging level {trace, debug, info, warn, error, critical}"`
@@ -79,6 +83,8 @@ type config struct {
 	Password         string   `short:"P" long:"password" default-mask:"-" description:"Password for client and btcd authorization"`
 	BtcdUsername     string   `long:"btcdusername" description:"Alternative username for btcd authorization"`
 	BtcdPassword     string   `long:"btcdpassword" default-mask:"-" description:"Alternative password for btcd authorization"`
+	AdminUsername    string   `long:"adminusername" description:"Username for client authorization of administrative requests, such as managing spending policies"`
+	AdminPassword    string   `long:"adminpassword" default-mask:"-" description:"Password for client authorization of administrative requests"`
 	WalletPass       string   `long:"walletpass" default-mask:"-" description:"The public wallet password -- Only required if the wallet was created with one"`
 	RPCCert          string   `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey           string   `long:"rpckey" description:"File containing the certificate key"`
@@ -94,6 +100,17 @@ type config struct {
 	ProxyUser        string   `long:"proxyuser" description:"Username for proxy server"`
 	ProxyPass        string   `long:"proxypass" default-mask:"-" description:"Password for proxy server"`
 	Profile          string   `long:"profile" description:"Enable HTTP profiling on given port -- NOTE port must be between 1024 and 65536"`
+
+	// Webhook options
+	WebhookURLs        []string `long:"webhookurl" description:"POST wallet transaction and lock state events to this URL (may be specified multiple times)"`
+	WebhookSecret      string   `long:"webhooksecret" default-mask:"-" description:"Secret key used to sign webhook payloads with HMAC-SHA256"`
+	WebhookMaxAttempts uint32   `long:"webhookmaxattempts" description:"Number of failed attempts to deliver a webhook event before it is dropped"`
+
+	// Database options
+	DbTimeout time.Duration `long:"dbtimeout" description:"Time to wait for the wallet database lock held by another process before failing (0 waits forever)"`
+	DbNoSync  bool          `long:"dbnosync" description:"Do not sync the wallet database to disk after every change -- NOTE: Recent changes may be lost on a crash"`
+	DbPass    string        `long:"dbpass" default-mask:"-" description:"Passphrase used to encrypt the wallet database -- New wallets are encrypted when set, and it is prompted for when opening an encrypted wallet without it"`
+	EncryptDb bool          `long:"encryptdb" description:"Encrypt the existing wallet database with the database passphrase and exit"`
 }
 
 // cleanAndExpandPath expands environement variables and leading ~ in the
@@ -263,6 +280,10 @@ func loadConfig() (*config, []string, error) {
 		DisallowFree:     defaultDisallowFree,
 		RPCMaxClients:    defaultRPCMaxClients,
 		RPCMaxWebsockets: defaultRPCMaxWebsockets,
+
+		WebhookMaxAttempts: defaultWebhookMaxAttempts,
+
+		DbTimeout: bdb.DefaultOptions.Timeout,
 	}
 
 	// A config file in the current directory takes precedence.
@@ -402,15 +423,22 @@ func loadConfig() (*config, []string, error) {
 		fmt.Fprintln(os.Stderr, err)
 		return nil, nil, err
 	}
+	if cfg.EncryptDb && (cfg.Create || cfg.CreateTemp) {
+		err := fmt.Errorf("The flag --encryptdb can not be specified " +
+			"with --create or --createtemp. Use --help for more " +
+			"information.")
+		fmt.Fprintln(os.Stderr, err)
+		return nil, nil, err
+	}
 
 	if cfg.CreateTemp {
-		tempWalletExists := false
-
+		// An existing wallet is loaded instead of the temporary
+		// simulation wallet, which is otherwise created in memory once
+		// the wallet is started.
 		if fileExists(dbPath) {
 			str := fmt.Sprintf("The wallet already exists. Loading this " +
 				"wallet instead.")
 			fmt.Fprintln(os.Stdout, str)
-			tempWalletExists = true
 		}
 
 		// Ensure the data directory for the network exists.
@@ -418,14 +446,6 @@ func loadConfig() (*config, []string, error) {
 			fmt.Fprintln(os.Stderr, err)
 			return nil, nil, err
 		}
-
-		if !tempWalletExists {
-			// Perform the initial wallet creation wizard.
-			if err := createSimulationWallet(&cfg); err != nil {
-				fmt.Fprintln(os.Stderr, "Unable to create wallet:", err)
-				return nil, nil, err
-			}
-		}
 	} else if cfg.Create {
 		// Error if the create flag is set and the wallet already
 		// exists.
@@ -449,7 +469,23 @@ func loadConfig() (*config, []string, error) {
 
 		// Created successfully, so exit now with success.
 		os.Exit(0)
-	} else if !fileExists(dbPath) {
+	} else if cfg.EncryptDb {
+		if !fileExists(dbPath) {
+			err := fmt.Errorf("The wallet does not exist.")
+			fmt.Fprintln(os.Stderr, err)
+			return nil, nil, err
+		}
+
+		// Replace the wallet database with an encrypted copy.
+		if err := encryptWalletDb(&cfg, netDir); err != nil {
+			fmt.Fprintln(os.Stderr, "Unable to encrypt wallet "+
+				"database:", err)
+			return nil, nil, err
+		}
+
+		// Encrypted successfully, so exit now with success.
+		os.Exit(0)
+	} else if !fileExists(dbPath) && !cfg.NoInitialLoad {
 		var err error
 		keystorePath := filepath.Join(netDir, keystore.Filename)
 		if !fileExists(keystorePath) {
@@ -562,5 +598,65 @@ func loadConfig() (*config, []string, error) {
 		cfg.BtcdPassword = cfg.Password
 	}
 
+	// Administrative requests are only allowed when both the admin
+	// username and password are set, and the admin auth must differ from
+	// the client auth so that ordinary clients are not administrators.
+	if (cfg.AdminUsername == "") != (cfg.AdminPassword == "") {
+		str := "%s: the --adminusername and --adminpassword options " +
+			"must be set together"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.AdminUsername != "" && cfg.AdminUsername == cfg.Username &&
+		cfg.AdminPassword == cfg.Password {
+		str := "%s: the admin username and password may not be the " +
+			"same as the client username and password"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	if cfg.DbTimeout < 0 {
+		str := "%s: the --dbtimeout option may not be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Webhook receivers must be able to authenticate events, so require a
+	// secret and only allow HTTP(S) URLs.
+	if len(cfg.WebhookURLs) != 0 {
+		if cfg.WebhookSecret == "" {
+			str := "%s: the --webhooksecret option must be set " +
+				"when using --webhookurl"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+		for _, webhookURL := range cfg.WebhookURLs {
+			u, err := url.Parse(webhookURL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				str := "%s: webhook URL '%s' is invalid"
+				err := fmt.Errorf(str, funcName, webhookURL)
+				fmt.Fprintln(os.Stderr, err)
+				fmt.Fprintln(os.Stderr, usageMessage)
+				return nil, nil, err
+			}
+		}
+		if cfg.WebhookMaxAttempts == 0 {
+			str := "%s: the --webhookmaxattempts option must be " +
+				"positive"
+			err := fmt.Errorf(str, funcName)
+			fmt.Fprintln(os.Stderr, err)
+			fmt.Fprintln(os.Stderr, usageMessage)
+			return nil, nil, err
+		}
+	}
+
 	return &cfg, remainingArgs, nil
 }
@@ -0,0 +1,220 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package bip39 implements the mnemonic sentences of BIP0039 for encoding
+// wallet generation seeds.
+//
+// A mnemonic encodes between 128 and 256 bits of entropy, along with a
+// checksum, as 12 to 24 words from the English wordlist.  The wallet
+// generation seed is derived from the mnemonic and an optional passphrase,
+// so the same mnemonic with a different passphrase results in a different
+// wallet.
+//
+// Passphrases are limited to ASCII characters, since the Unicode
+// normalization required by BIP0039 for other characters is not performed.
+package bip39
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/btcsuite/golangcrypto/pbkdf2"
+)
+
+const (
+	// MinEntropyBits is the minimum number of bits of entropy which may
+	// be encoded by a mnemonic.
+	MinEntropyBits = 128
+
+	// MaxEntropyBits is the maximum number of bits of entropy which may
+	// be encoded by a mnemonic.
+	MaxEntropyBits = 256
+
+	// RecommendedEntropyBits is the recommended number of bits of entropy
+	// for a new mnemonic, which is encoded as 24 words.
+	RecommendedEntropyBits = 256
+
+	// SeedLen is the length in bytes of a seed derived from a mnemonic.
+	SeedLen = 64
+
+	// seedIterations is the number of PBKDF2 iterations used to derive
+	// a seed.
+	seedIterations = 2048
+
+	// bitsPerWord is the number of bits encoded by each mnemonic word.
+	bitsPerWord = 11
+)
+
+var (
+	// ErrInvalidEntropyLength describes an error where the entropy to
+	// encode is not between MinEntropyBits and MaxEntropyBits, or is not a
+	// multiple of 32 bits.
+	ErrInvalidEntropyLength = fmt.Errorf("entropy must be %d to %d bits "+
+		"and a multiple of 32 bits", MinEntropyBits, MaxEntropyBits)
+
+	// ErrInvalidWordCount describes an error where a mnemonic does not
+	// have 12, 15, 18, 21, or 24 words.
+	ErrInvalidWordCount = errors.New("mnemonic must have 12, 15, 18, 21, " +
+		"or 24 words")
+
+	// ErrChecksumMismatch describes an error where the checksum encoded by
+	// a mnemonic does not match its entropy.  This usually means that a
+	// word was mistyped or the words are out of order.
+	ErrChecksumMismatch = errors.New("mnemonic checksum mismatch")
+
+	// ErrNonASCIIPassphrase describes an error where a passphrase contains
+	// characters which are not ASCII.
+	ErrNonASCIIPassphrase = errors.New("mnemonic passphrase must only " +
+		"contain ASCII characters")
+)
+
+// UnknownWordError describes an error where a mnemonic contains a word which
+// is not in the wordlist.
+type UnknownWordError string
+
+// Error satisfies the error interface.
+func (e UnknownWordError) Error() string {
+	return fmt.Sprintf("unknown mnemonic word %q", string(e))
+}
+
+// wordList is the wordlist indexed by the value each word encodes, and
+// wordIndexes maps each word back to the value.
+var (
+	wordList    = strings.Split(strings.TrimSpace(englishWords), "\n")
+	wordIndexes = func() map[string]uint16 {
+		m := make(map[string]uint16, len(wordList))
+		for i, w := range wordList {
+			m[w] = uint16(i)
+		}
+		return m
+	}()
+)
+
+// validEntropyBits returns whether bits of entropy may be encoded by a
+// mnemonic.
+func validEntropyBits(bits int) bool {
+	return bits >= MinEntropyBits && bits <= MaxEntropyBits && bits%32 == 0
+}
+
+// NewEntropy returns bits of random entropy to encode as a new mnemonic.  The
+// number of bits must be a multiple of 32 between MinEntropyBits and
+// MaxEntropyBits.
+func NewEntropy(bits int) ([]byte, error) {
+	if !validEntropyBits(bits) {
+		return nil, ErrInvalidEntropyLength
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return entropy, nil
+}
+
+// NewMnemonic encodes entropy and its checksum as a mnemonic sentence, with
+// each word separated by a single space.
+func NewMnemonic(entropy []byte) (string, error) {
+	entropyBits := len(entropy) * 8
+	if !validEntropyBits(entropyBits) {
+		return "", ErrInvalidEntropyLength
+	}
+
+	// The checksum is the first bit of the SHA256 hash of the entropy for
+	// every 32 bits of entropy, and is appended to the entropy.  The
+	// result is split into 11 bit values which each select a word.
+	checksum := sha256.Sum256(entropy)
+	bit := func(pos int) uint16 {
+		b := entropy
+		if pos >= entropyBits {
+			b = checksum[:]
+			pos -= entropyBits
+		}
+		return uint16(b[pos/8]>>uint(7-pos%8)) & 1
+	}
+
+	words := make([]string, (entropyBits+entropyBits/32)/bitsPerWord)
+	for i := range words {
+		var index uint16
+		for j := 0; j < bitsPerWord; j++ {
+			index = index<<1 | bit(i*bitsPerWord+j)
+		}
+		words[i] = wordList[index]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic decodes the entropy of a mnemonic sentence, checking
+// that every word is in the wordlist and that the checksum is correct.  Words
+// may be separated by any whitespace and are not case sensitive.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words)%3 != 0 || len(words) < 12 || len(words) > 24 {
+		return nil, ErrInvalidWordCount
+	}
+
+	totalBits := len(words) * bitsPerWord
+	checksumBits := totalBits / 33
+	entropyBits := totalBits - checksumBits
+	entropy := make([]byte, entropyBits/8)
+	var checksum byte
+	for i, w := range words {
+		index, ok := wordIndexes[strings.ToLower(w)]
+		if !ok {
+			return nil, UnknownWordError(w)
+		}
+		for j := 0; j < bitsPerWord; j++ {
+			pos := i*bitsPerWord + j
+			b := byte(index>>uint(bitsPerWord-1-j)) & 1
+			if pos < entropyBits {
+				entropy[pos/8] |= b << uint(7-pos%8)
+			} else {
+				checksum = checksum<<1 | b
+			}
+		}
+	}
+
+	hash := sha256.Sum256(entropy)
+	if hash[0]>>uint(8-checksumBits) != checksum {
+		return nil, ErrChecksumMismatch
+	}
+	return entropy, nil
+}
+
+// Seed validates a mnemonic and derives the SeedLen byte wallet generation
+// seed from it and an optional passphrase.  An empty passphrase is used when
+// none was chosen.
+func Seed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	for i := 0; i < len(passphrase); i++ {
+		if passphrase[i] > 0x7f {
+			return nil, ErrNonASCIIPassphrase
+		}
+	}
+
+	sentence := strings.Join(strings.Fields(strings.ToLower(mnemonic)), " ")
+	salt := "mnemonic" + passphrase
+	return pbkdf2.Key([]byte(sentence), []byte(salt), seedIterations,
+		SeedLen, sha512.New), nil
+}
+
+// IsMnemonic returns whether the input looks like a mnemonic sentence rather
+// than a hex encoded seed.  The input is not validated.
+func IsMnemonic(s string) bool {
+	return len(strings.Fields(s)) > 1
+}
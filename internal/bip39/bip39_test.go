@@ -0,0 +1,249 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package bip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash/crc32"
+	"testing"
+)
+
+// TestWordList ensures the wordlist matches the list of the BIP0039
+// specification, which has the CRC32 checksum c1dbd296.
+func TestWordList(t *testing.T) {
+	if len(wordList) != 2048 {
+		t.Fatalf("wordlist has %d words, want 2048", len(wordList))
+	}
+	if sum := crc32.ChecksumIEEE([]byte(englishWords)); sum != 0xc1dbd296 {
+		t.Fatalf("wordlist checksum %08x, want c1dbd296", sum)
+	}
+}
+
+// TestVectors checks the mnemonics and seeds of the BIP0039 test vectors,
+// which all use the passphrase "TREZOR".
+func TestVectors(t *testing.T) {
+	tests := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon agent",
+			"035895f2f481b1b0f01fcf8c289c794660b289981a78f8106447707fdd9666ca06da5a9a565181599b79f53b844d8a71dd9f439c52a3d7b3e8a79c906ac845fa",
+		},
+		{
+			"ffffffffffffffffffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo when",
+			"0cd6e5d827bb62eb8fc1e262254223817fd068a74b5b449cc2f667c3f1f985a76379b43348d952e2265b4cd129090758b3e3c2c49103b5051aac2eaeb890a528",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+		{
+			"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+			"dd48c104698c30cfe2b6142103248622fb7bb0ff692eebb00089b32d22484e1613912f0a5b694407be899ffd31ed3992c456cdf60f5d4564b8ba3f05a69890ad",
+		},
+		{
+			"77c2b00716cec7213839159e404db50d",
+			"jelly better achieve collect unaware mountain thought cargo oxygen act hood bridge",
+			"b5b6d0127db1a9d2226af0c3346031d77af31e918dba64287a1b44b8ebf63cdd52676f672a290aae502472cf2d602c051f3e6f18055e84e4c43897fc4e51a6ff",
+		},
+		{
+			"b63a9c59a6e641f288ebc103017f1da9f8290b3da6bdef7b",
+			"renew stay biology evidence goat welcome casual join adapt armor shuffle fault little machine walk stumble urge swap",
+			"9248d83e06f4cd98debf5b6f010542760df925ce46cf38a1bdb4e4de7d21f5c39366941c69e1bdbf2966e0f6e6dbece898a0e2f0a4c2b3e640953dfe8b7bbdc5",
+		},
+		{
+			"3e141609b97933b66a060dcddc71fad1d91677db872031e85f4c015c5e7e8982",
+			"dignity pass list indicate nasty swamp pool script soccer toe leaf photo multiply desk host tomato cradle drill spread actor shine dismiss champion exotic",
+			"ff7f3184df8696d8bef94b6c03114dbee0ef89ff938712301d27ed8336ca89ef9635da20af07d4175f2bf5f3de130f39c9d9e8dd0472489c19b1a020a940da67",
+		},
+		{
+			"0460ef47585604c5660618db2e6a7e7f",
+			"afford alter spike radar gate glance object seek swamp infant panel yellow",
+			"65f93a9f36b6c85cbe634ffc1f99f2b82cbb10b31edc7f087b4f6cb9e976e9faf76ff41f8f27c99afdf38f7a303ba1136ee48a4c1e7fcd3dba7aa876113a36e4",
+		},
+		{
+			"72f60ebac5dd8add8d2a25a797102c3ce21bc029c200076f",
+			"indicate race push merry suffer human cruise dwarf pole review arch keep canvas theme poem divorce alter left",
+			"3bbf9daa0dfad8229786ace5ddb4e00fa98a044ae4c4975ffd5e094dba9e0bb289349dbe2091761f30f382d4e35c4a670ee8ab50758d2c55881be69e327117ba",
+		},
+		{
+			"2c85efc7f24ee4573d2b81a6ec66cee209b2dcbd09d8eddc51e0215b0b68e416",
+			"clutch control vehicle tonight unusual clog visa ice plunge glimpse recipe series open hour vintage deposit universe tip job dress radar refuse motion taste",
+			"fe908f96f46668b2d5b37d82f558c77ed0d69dd0e7e043a5b0511c48c2f1064694a956f86360c93dd04052a8899497ce9e985ebe0c8c52b955e6ae86d4ff4449",
+		},
+		{
+			"eaebabb2383351fd31d703840b32e9e2",
+			"turtle front uncle idea crush write shrug there lottery flower risk shell",
+			"bdfb76a0759f301b0b899a1e3985227e53b3f51e67e3f2a65363caedf3e32fde42a66c404f18d7b05818c95ef3ca1e5146646856c461c073169467511680876c",
+		},
+		{
+			"7ac45cfe7722ee6c7ba84fbc2d5bd61b45cb2fe5eb65aa78",
+			"kiss carry display unusual confirm curtain upgrade antique rotate hello void custom frequent obey nut hole price segment",
+			"ed56ff6c833c07982eb7119a8f48fd363c4a9b1601cd2de736b01045c5eb8ab4f57b079403485d1c4924f0790dc10a971763337cb9f9c62226f64fff26397c79",
+		},
+		{
+			"4fa1a8bc3e6d80ee1316050e862c1812031493212b7ec3f3bb1b08f168cabeef",
+			"exile ask congress lamp submit jacket era scheme attend cousin alcohol catch course end lucky hurt sentence oven short ball bird grab wing top",
+			"095ee6f817b4c2cb30a5a797360a81a40ab0f9a4e25ecd672a3f58a0b5ba0687c096a6b14d2c0deb3bdefce4f61d01ae07417d502429352e27695163f7447a8c",
+		},
+		{
+			"18ab19a9f54a9274f03e5209a2ac8a91",
+			"board flee heavy tunnel powder denial science ski answer betray cargo cat",
+			"6eff1bb21562918509c73cb990260db07c0ce34ff0e3cc4a8cb3276129fbcb300bddfe005831350efd633909f476c45c88253276d9fd0df6ef48609e8bb7dca8",
+		},
+		{
+			"18a2e1d81b8ecfb2a333adcb0c17a5b9eb76cc5d05db91a4",
+			"board blade invite damage undo sun mimic interest slam gaze truly inherit resist great inject rocket museum chief",
+			"f84521c777a13b61564234bf8f8b62b3afce27fc4062b51bb5e62bdfecb23864ee6ecf07c1d5a97c0834307c5c852d8ceb88e7c97923c0a3b496bedd4e5f88a9",
+		},
+		{
+			"15da872c95a13dd738fbf50e427583ad61f18fd99f628c417a61cf8343c90419",
+			"beyond stage sleep clip because twist token leaf atom beauty genius food business side grid unable middle armed observe pair crouch tonight away coconut",
+			"b15509eaa2d09d3efd3e006ef42151b30367dc6e3aa5e44caba3fe4d3e352e65101fbdb86a96776b91946ff06f8eac594dc6ee1d3e82a42dfe1b40fef6bcc3fd",
+		},
+	}
+
+	for i, test := range tests {
+		entropy, _ := hex.DecodeString(test.entropy)
+		mnemonic, err := NewMnemonic(entropy)
+		if err != nil {
+			t.Errorf("test %d: NewMnemonic: %v", i, err)
+			continue
+		}
+		if mnemonic != test.mnemonic {
+			t.Errorf("test %d: mnemonic %q, want %q", i, mnemonic,
+				test.mnemonic)
+			continue
+		}
+
+		decoded, err := EntropyFromMnemonic(mnemonic)
+		if err != nil {
+			t.Errorf("test %d: EntropyFromMnemonic: %v", i, err)
+			continue
+		}
+		if !bytes.Equal(decoded, entropy) {
+			t.Errorf("test %d: decoded entropy %x, want %x", i,
+				decoded, entropy)
+			continue
+		}
+
+		seed, err := Seed(mnemonic, "TREZOR")
+		if err != nil {
+			t.Errorf("test %d: Seed: %v", i, err)
+			continue
+		}
+		if hex.EncodeToString(seed) != test.seed {
+			t.Errorf("test %d: seed %x, want %s", i, seed, test.seed)
+		}
+	}
+}
+
+func TestInvalidMnemonics(t *testing.T) {
+	tests := []struct {
+		mnemonic string
+		err      error
+	}{
+		{
+			"abandon abandon abandon abandon abandon abandon " +
+				"abandon abandon abandon abandon abandon",
+			ErrInvalidWordCount,
+		},
+		{
+			"abandon abandon abandon abandon abandon abandon " +
+				"abandon abandon abandon abandon abandon abandon",
+			ErrChecksumMismatch,
+		},
+		{
+			"abandon abandon abandon abandon abandon abandon " +
+				"abandon abandon abandon abandon abandon abuot",
+			UnknownWordError("abuot"),
+		},
+	}
+	for i, test := range tests {
+		_, err := EntropyFromMnemonic(test.mnemonic)
+		if err != test.err {
+			t.Errorf("test %d: got error %v, want %v", i, err, test.err)
+		}
+		if _, err := Seed(test.mnemonic, ""); err != test.err {
+			t.Errorf("test %d: Seed: got error %v, want %v", i, err,
+				test.err)
+		}
+	}
+}
+
+// TestNormalization ensures that the whitespace and case of a mnemonic do
+// not change the seed, and that non-ASCII passphrases are rejected.
+func TestNormalization(t *testing.T) {
+	const mnemonic = "legal winner thank year wave sausage worth " +
+		"useful legal winner thank yellow"
+	want, err := Seed(mnemonic, "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Seed(" Legal  winner\tthank year wave sausage worth "+
+		"useful legal winner THANK yellow\n", "TREZOR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("seed %x, want %x", got, want)
+	}
+
+	if _, err := Seed(mnemonic, "caf\u00e9"); err != ErrNonASCIIPassphrase {
+		t.Errorf("got error %v, want %v", err, ErrNonASCIIPassphrase)
+	}
+}
+
+func TestNewEntropy(t *testing.T) {
+	for bits := 0; bits <= 512; bits += 8 {
+		entropy, err := NewEntropy(bits)
+		valid := bits >= MinEntropyBits && bits <= MaxEntropyBits &&
+			bits%32 == 0
+		if !valid {
+			if err != ErrInvalidEntropyLength {
+				t.Errorf("%d bits: got error %v, want %v", bits,
+					err, ErrInvalidEntropyLength)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d bits: %v", bits, err)
+			continue
+		}
+		if len(entropy) != bits/8 {
+			t.Errorf("%d bits: got %d bytes", bits, len(entropy))
+		}
+		if _, err := NewMnemonic(entropy); err != nil {
+			t.Errorf("%d bits: NewMnemonic: %v", bits, err)
+		}
+	}
+}
@@ -34,9 +34,10 @@ var helpDescsEnUS = map[string]string{
 	"createmultisigresult-redeemScript": "The script required to redeem outputs paid to the multisig address",
 
 	// DumpPrivKeyCmd help.
-	"dumpprivkey--synopsis": "Returns the private key in WIF encoding that controls some wallet address.",
-	"dumpprivkey-address":   "The address to return a private key for",
-	"dumpprivkey--result0":  "The WIF-encoded private key",
+	"dumpprivkey--synopsis": "Returns the private key in WIF encoding that controls some wallet address.\n" +
+		"When the admin username and password are set, or when any account has a spending policy, this request requires the admin username and password.",
+	"dumpprivkey-address":  "The address to return a private key for",
+	"dumpprivkey--result0": "The WIF-encoded private key",
 
 	// GetAccountCmd help.
 	"getaccount--synopsis": "DEPRECATED -- Lookup the account name that some wallet address belongs to.",
@@ -55,14 +56,20 @@ var helpDescsEnUS = map[string]string{
 	"getaddressesbyaccount--result0":  "All addresses controlled by 'account'",
 
 	// GetBalanceCmd help.
-	"getbalance--synopsis":   "Calculates and returns the balance of one or all accounts.",
+	"getbalance--synopsis": "Calculates and returns the balance of one or all accounts.  Immature coinbase outputs are not included in the balance.\n" +
+		"An optional third parameter, verbose, is a boolean.  If true, a JSON object is returned which also includes the balance of immature coinbase outputs.",
 	"getbalance-minconf":     "Minimum number of block confirmations required before an unspent output's value is included in the balance",
 	"getbalance-account":     "DEPRECATED -- The account name to query the balance for, or \"*\" to consider all accounts (default=\"*\")",
 	"getbalance--condition0": "account != \"*\"",
 	"getbalance--condition1": "account = \"*\"",
+	"getbalance--condition2": "verbose=true",
 	"getbalance--result0":    "The balance of 'account' valued in bitcoin",
 	"getbalance--result1":    "The balance of all accounts valued in bitcoin",
 
+	// GetBalanceResult help.
+	"getbalanceresult-balance":          "The balance of 'account', or of all accounts, valued in bitcoin",
+	"getbalanceresult-immature_balance": "The value of the unspent coinbase outputs of 'account', or of all accounts, which have not reached maturity",
+
 	// GetBestBlockHashCmd help.
 	"getbestblockhash--synopsis": "Returns the hash of the newest block in the best chain that wallet has finished syncing with.",
 	"getbestblockhash--result0":  "The hash of the most recent synced-to block",
@@ -129,13 +136,13 @@ var helpDescsEnUS = map[string]string{
 
 	// GetTransactionResult help.
 	"gettransactionresult-amount":          "The total amount this transaction credits to the wallet, valued in bitcoin",
-	"gettransactionresult-fee":             "The total input value minus the total output value, or 0 if 'txid' is not a sent transaction",
-	"gettransactionresult-confirmations":   "The number of block confirmations of the transaction",
+	"gettransactionresult-fee":             "The total output value minus the total input value (the negative fee), or 0 if 'txid' is not a sent transaction or the fee is unknown",
+	"gettransactionresult-confirmations":   "The number of block confirmations of the transaction, or, for a transaction removed because it conflicted with a mined transaction or was abandoned, the negative depth of the block at which the conflict was detected",
 	"gettransactionresult-blockhash":       "The hash of the block this transaction is mined in, or the empty string if unmined",
 	"gettransactionresult-blockindex":      "Unset",
 	"gettransactionresult-blocktime":       "The Unix time of the block header this transaction is mined in, or 0 if unmined",
 	"gettransactionresult-txid":            "The transaction hash",
-	"gettransactionresult-walletconflicts": "Unset",
+	"gettransactionresult-walletconflicts": "Hashes of the transactions conflicting with this transaction: the winning mined transaction of a conflicted transaction, or the transactions removed because they conflicted with a mined transaction",
 	"gettransactionresult-time":            "The earliest Unix time this transaction was known to exist",
 	"gettransactionresult-timereceived":    "The earliest Unix time this transaction was known to exist",
 	"gettransactionresult-details":         "Additional details for each recorded wallet credit and debit",
@@ -144,12 +151,22 @@ var helpDescsEnUS = map[string]string{
 	// GetTransactionDetailsResult help.
 	"gettransactiondetailsresult-account":           "DEPRECATED -- Unset",
 	"gettransactiondetailsresult-address":           "The address an output was paid to, or the empty string if the output is nonstandard or this detail is regarding a transaction input",
-	"gettransactiondetailsresult-category":          `The kind of detail: "send" for sent transactions, "immature" for immature coinbase outputs, "generate" for mature coinbase outputs, or "recv" for all other received outputs`,
-	"gettransactiondetailsresult-amount":            "The amount of a received output",
-	"gettransactiondetailsresult-fee":               "The included fee for a sent transaction",
+	"gettransactiondetailsresult-category":          `The kind of detail: "send" for sent transactions, "immature" for immature coinbase outputs, "generate" for mature coinbase outputs, "orphan" for outputs of coinbase transactions removed by a reorganize, or "recv" for all other received outputs`,
+	"gettransactiondetailsresult-amount":            "The amount of a received output, or the negative amount of a send detail",
+	"gettransactiondetailsresult-fee":               "The included fee for a sent transaction, as a negative number",
 	"gettransactiondetailsresult-vout":              "The transaction output index",
 	"gettransactiondetailsresult-involveswatchonly": "Unset",
 
+	// GetWalletInfoCmd help.
+	"getwalletinfo--synopsis": "Returns a JSON object containing the balances and other state of the wallet.",
+
+	// GetWalletInfoResult help.
+	"getwalletinforesult-walletversion":       "The version of the address manager database",
+	"getwalletinforesult-balance":             "The balance of all accounts calculated with one block confirmation",
+	"getwalletinforesult-unconfirmed_balance": "The value of all unspent outputs of unmined transactions",
+	"getwalletinforesult-immature_balance":    "The value of all unspent coinbase outputs which have not reached maturity",
+	"getwalletinforesult-paytxfee":            "The increment used each time more fee is required for an authored transaction",
+
 	// ImportPrivKeyCmd help.
 	"importprivkey--synopsis": "Imports a WIF-encoded private key to the 'imported' account.",
 	"importprivkey-privkey":   "The WIF-encoded private key",
@@ -168,7 +185,13 @@ var helpDescsEnUS = map[string]string{
 	"listaccounts--result0--value": "The account balance valued in bitcoin",
 
 	// ListLockUnspentCmd help.
-	"listlockunspent--synopsis": "Returns a JSON array of outpoints marked as locked (with lockunspent) for this wallet session.",
+	"listlockunspent--synopsis": "Returns a JSON array of outpoints marked as locked (with lockunspent or lockoutpoints) which have not expired.",
+
+	// ListLockUnspentResult help.
+	"listlockunspentresult-txid":   "The transaction hash of the referenced output",
+	"listlockunspentresult-vout":   "The output index of the referenced output",
+	"listlockunspentresult-expiry": "The Unix time the lock expires, or unset if the lock does not expire",
+	"listlockunspentresult-reason": "The reason the output was locked, if any",
 
 	// TransactionInput help.
 	"transactioninput-txid": "The transaction hash of the referenced output",
@@ -216,17 +239,17 @@ var helpDescsEnUS = map[string]string{
 	// ListTransactionsResult help.
 	"listtransactionsresult-account":           "DEPRECATED -- Unset",
 	"listtransactionsresult-address":           "Payment address for a transaction output",
-	"listtransactionsresult-category":          `The kind of transaction: "send" for sent transactions, "immature" for immature coinbase outputs, "generate" for mature coinbase outputs, or "recv" for all other received outputs.  Note: A single output may be included multiple times under different categories`,
+	"listtransactionsresult-category":          `The kind of transaction: "send" for sent transactions (only outputs paying a requested recipient for transactions created by this wallet), "immature" for immature coinbase outputs, "generate" for mature coinbase outputs, "orphan" for outputs of coinbase transactions removed by a reorganize, or "recv" for all other received outputs.  Note: A single output may be included multiple times under different categories`,
 	"listtransactionsresult-amount":            "The value of the transaction output valued in bitcoin",
-	"listtransactionsresult-fee":               "The total input value minus the total output value for sent transactions",
-	"listtransactionsresult-confirmations":     "The number of block confirmations of the transaction",
+	"listtransactionsresult-fee":               "The total output value minus the total input value (the negative fee) for sent transactions",
+	"listtransactionsresult-confirmations":     "The number of block confirmations of the transaction, or, for a transaction removed because it conflicted with a mined transaction or was abandoned, the negative depth of the block at which the conflict was detected",
 	"listtransactionsresult-generated":         "Whether the transaction output is a coinbase output",
 	"listtransactionsresult-blockhash":         "The hash of the block this transaction is mined in, or the empty string if unmined",
 	"listtransactionsresult-blockindex":        "Unset",
 	"listtransactionsresult-blocktime":         "The Unix time of the block header this transaction is mined in, or 0 if unmined",
 	"listtransactionsresult-txid":              "The hash of the transaction",
 	"listtransactionsresult-vout":              "The transaction output index",
-	"listtransactionsresult-walletconflicts":   "Unset",
+	"listtransactionsresult-walletconflicts":   "The hash of the winning mined transaction if this transaction was removed because it conflicted with it",
 	"listtransactionsresult-time":              "The earliest Unix time this transaction was known to exist",
 	"listtransactionsresult-timereceived":      "The earliest Unix time this transaction was known to exist",
 	"listtransactionsresult-involveswatchonly": "Unset",
@@ -234,7 +257,8 @@ var helpDescsEnUS = map[string]string{
 	"listtransactionsresult-otheraccount":      "Unset",
 
 	// ListTransactionsCmd help.
-	"listtransactions--synopsis":        "Returns a JSON array of objects containing verbose details for wallet transactions.",
+	"listtransactions--synopsis": "Returns a JSON array of objects containing verbose details for wallet transactions.\n" +
+		"Transactions removed because they conflicted with a mined transaction or were abandoned are included with negative confirmations, before the transactions of the block at which the conflict was detected.",
 	"listtransactions-account":          "DEPRECATED -- Unused (must be unset or \"*\")",
 	"listtransactions-count":            "Maximum number of transactions to create results from",
 	"listtransactions-from":             "Number of transactions to skip before results are created",
@@ -260,7 +284,7 @@ var helpDescsEnUS = map[string]string{
 	// LockUnspentCmd help.
 	"lockunspent--synopsis": "Locks or unlocks an unspent output.\n" +
 		"Locked outputs are not chosen for transaction inputs of authored transactions and are not included in 'listunspent' results.\n" +
-		"Locked outputs are volatile and are not saved across wallet restarts.\n" +
+		"Locked outputs are saved across wallet restarts and are unlocked when spent by a mined transaction.\n" +
 		"If unlock is true and no transaction outputs are specified, all locked outputs are marked unlocked.",
 	"lockunspent-unlock":       "True to unlock outputs, false to lock",
 	"lockunspent-transactions": "Transaction outputs to lock or unlock",
@@ -279,7 +303,8 @@ var helpDescsEnUS = map[string]string{
 
 	// SendManyCmd help.
 	"sendmany--synopsis": "Authors, signs, and sends a transaction that outputs to many payment addresses.\n" +
-		"A change output is automatically included to send extra output value back to the original account.",
+		"A change output is automatically included to send extra output value back to the original account.\n" +
+		"An optional fifth parameter, subtractfeefrom, is a JSON array of payment addresses.  If set, the fee is subtracted from the amounts paid to these addresses in proportion to their amounts, rather than paid in addition to the amounts.",
 	"sendmany-fromaccount":    "DEPRECATED -- Account to pick unspent outputs from",
 	"sendmany-amounts":        "Pairs of payment addresses and the output amount to pay each",
 	"sendmany-amounts--desc":  "JSON object using payment addresses as keys and output amounts valued in bitcoin to send to each address",
@@ -292,7 +317,8 @@ var helpDescsEnUS = map[string]string{
 	// SendToAddressCmd help.
 	"sendtoaddress--synopsis": "Authors, signs, and sends a transaction that outputs some amount to a payment address.\n" +
 		"Unlike sendfrom, outputs are always chosen from the default account.\n" +
-		"A change output is automatically included to send extra output value back to the original account.",
+		"A change output is automatically included to send extra output value back to the original account.\n" +
+		"An optional fifth parameter, subtractfeefromamount, is a boolean.  If true, the fee is subtracted from the amount paid rather than paid in addition to it.",
 	"sendtoaddress-address":   "Address to pay",
 	"sendtoaddress-amount":    "Amount to send to the payment address valued in bitcoin",
 	"sendtoaddress-comment":   "Unused",
@@ -312,7 +338,8 @@ var helpDescsEnUS = map[string]string{
 
 	// SignRawTransactionCmd help.
 	"signrawtransaction--synopsis": "Signs transaction inputs using private keys from this wallet and request.\n" +
-		"The valid flags options are ALL, NONE, SINGLE, ALL|ANYONECANPAY, NONE|ANYONECANPAY, and SINGLE|ANYONECANPAY.",
+		"The valid flags options are ALL, NONE, SINGLE, ALL|ANYONECANPAY, NONE|ANYONECANPAY, and SINGLE|ANYONECANPAY.\n" +
+		"When the admin username and password are set, or when any account has a spending policy, this request requires the admin username and password.",
 	"signrawtransaction-rawtx":    "Unsigned or partially unsigned transaction to sign encoded as a hexadecimal string",
 	"signrawtransaction-inputs":   "Additional data regarding inputs that this wallet may not be tracking",
 	"signrawtransaction-privkeys": "Additional WIF-encoded private keys to use when creating signatures",
@@ -373,17 +400,49 @@ var helpDescsEnUS = map[string]string{
 	"walletpassphrasechange-oldpassphrase": "The old wallet passphrase",
 	"walletpassphrasechange-newpassphrase": "The new wallet passphrase",
 
+	// AbandonTransactionCmd help.
+	"abandontransaction--synopsis": "Removes an unmined wallet transaction which is not expected to be mined, and every wallet transaction spending its outputs, so the outputs it spends may be spent again.\n" +
+		"Abandoned transactions are reported with negative confirmations by 'listtransactions' and 'gettransaction'.\n" +
+		"The transaction is recorded as mined again if it is later mined.",
+	"abandontransaction-txid": "Hash of the unmined transaction to abandon",
+
 	// CreateNewAccountCmd help.
 	"createnewaccount--synopsis": "Creates a new account.\n" +
 		"The wallet must be unlocked for this request to succeed.",
 	"createnewaccount-account": "Name of the new account",
 
+	// ExportTransactionsCmd help.
+	"exporttransactions--synopsis": "Returns a ledger of mined transactions as a CSV or OFX document, with one entry for each account whose balance a transaction changed.\n" +
+		"CSV records contain the date, block height, transaction hash, account, counterparty addresses, amounts received and sent, fee, running balance and the accounts owning the counterparty addresses (if any).",
+	"exporttransactions-format":      "Either \"csv\" or \"ofx\"",
+	"exporttransactions-account":     "Only include transactions changing the balance of this account, or \"*\" to include every account",
+	"exporttransactions-startheight": "Height of the first block to include transactions from",
+	"exporttransactions-endheight":   "Height of the last block to include transactions from, or unset to include every newer block",
+	"exporttransactions-starttime":   "If set, only include transactions mined at or after this Unix time",
+	"exporttransactions-endtime":     "If set, only include transactions mined before this Unix time",
+	"exporttransactions--result0":    "The exported document",
+
 	// ExportWatchingWalletCmd help.
 	"exportwatchingwallet--synopsis": "Creates and returns a duplicate of the wallet database without any private keys to be used as a watching-only wallet.",
 	"exportwatchingwallet-account":   "Unused (must be unset or \"*\")",
 	"exportwatchingwallet-download":  "Unused",
 	"exportwatchingwallet--result0":  "The watching-only database encoded as a base64 string",
 
+	// GetBalanceHistoryCmd help.
+	"getbalancehistory--synopsis": "Returns the balance of mined transaction outputs following the first block and each later block that changed it, or at the end of every day, between two block heights.\n" +
+		"Unmined transactions are not included, and immature coinbase outputs are included.  The balance is unchanged for every block not reported.",
+	"getbalancehistory-account":     "Only include outputs paid to addresses of this account, or \"*\" to include every output",
+	"getbalancehistory-startheight": "Height of the first block to report the balance after",
+	"getbalancehistory-endheight":   "Height of the last block to report the balance after, or unset to end at the block the wallet is synced to",
+	"getbalancehistory-interval":    "Either \"block\" to report the balance after the first block and each block that changed it, or \"day\" to report the balance at the end of every day (in UTC) from the day of the first block to the day of the last",
+
+	// GetBalanceHistoryResult help.
+	"getbalancehistoryresult-height":    "The height of the block, which for the \"day\" interval is the first block or the last block on or before the day that changed the balance",
+	"getbalancehistoryresult-blockhash": "The hash of the block",
+	"getbalancehistoryresult-blocktime": "The Unix time of the block",
+	"getbalancehistoryresult-date":      "The day in YYYY-MM-DD format (UTC), only set when the interval is \"day\"",
+	"getbalancehistoryresult-balance":   "The balance after the block, or at the end of the day, valued in bitcoin",
+
 	// GetBestBlockCmd help.
 	"getbestblock--synopsis": "Returns the hash and height of the newest block in the best chain that wallet has finished syncing with.",
 
@@ -405,6 +464,72 @@ var helpDescsEnUS = map[string]string{
 	"listalltransactions--synopsis": "Returns a JSON array of objects in the same format as 'listtransactions' without limiting the number of returned objects.",
 	"listalltransactions-account":   "Unused (must be unset or \"*\")",
 
+	// ListSpendPoliciesCmd help.
+	"listspendpolicies--synopsis": "Returns the spending policy of every account with one, and the amount sent from each account which counts towards its spend limit.",
+
+	// SpendPolicyResult help.
+	"spendpolicyresult-account":          "The name of the account",
+	"spendpolicyresult-spendlimit":       "The maximum total value of payments sent from the account within any limit period valued in bitcoin, or 0 for no limit",
+	"spendpolicyresult-limitperiod":      "The number of seconds in the rolling period the spend limit applies to",
+	"spendpolicyresult-spent":            "The total value of payments sent from the account within the current limit period valued in bitcoin",
+	"spendpolicyresult-maxpayment":       "The maximum value of any single payment valued in bitcoin, or 0 for no maximum",
+	"spendpolicyresult-allowedaddresses": "The only addresses payments may be sent to, or empty if payments may be sent to any address",
+	"spendpolicyresult-minconf":          "The minimum number of confirmations of every output spent by the account",
+
+	// LockOutpointsCmd help.
+	"lockoutpoints--synopsis":    "Locks unspent outputs in the same manner as 'lockunspent', recording a reason for each lock and optionally unlocking the outputs after a timeout.",
+	"lockoutpoints-transactions": "Transaction outputs to lock",
+	"lockoutpoints-reason":       "The reason the outputs are locked, reported by 'listlockunspent'",
+	"lockoutpoints-timeout":      "The number of seconds after which the outputs are automatically unlocked, or 0 to never unlock them automatically",
+	"lockoutpoints--result0":     "The boolean 'true'",
+
+	// RemoveSpendPolicyCmd help.
+	"removespendpolicy--synopsis": "Removes the spending policy of an account, along with the record of payments counted towards its spend limit.\n" +
+		"This request requires the admin username and password.",
+	"removespendpolicy-account": "The account to remove the policy of",
+
+	// SendAllCmd help.
+	"sendall--synopsis": "Authors, signs, and sends a transaction spending every unspent output of an account eligible to be spent to a single payment address.\n" +
+		"The fee is subtracted from the amount paid, and no change output is created.  Locked outputs are not spent.",
+	"sendall-fromaccount": "Account to spend all unspent outputs of",
+	"sendall-toaddress":   "Address to pay",
+	"sendall-minconf":     "Minimum number of block confirmations required before a transaction output is eligible to be spent",
+	"sendall--result0":    "The transaction hash of the sent transaction",
+
+	// SetSpendPolicyCmd help.
+	"setspendpolicy--synopsis": "Sets the spending policy of an account, replacing any previous policy.\n" +
+		"Transactions sending payments which violate the policy are refused with error code -100.\n" +
+		"While any account has a policy, private keys may not be dumped and raw transactions may not be signed without the admin username and password.\n" +
+		"This request requires the admin username and password.",
+	"setspendpolicy-account":          "The account to set the policy of",
+	"setspendpolicy-spendlimit":       "The maximum total value of payments sent from the account within any limit period valued in bitcoin, or 0 for no limit",
+	"setspendpolicy-limitperiod":      "The number of seconds in the rolling period the spend limit applies to",
+	"setspendpolicy-maxpayment":       "The maximum value of any single payment valued in bitcoin, or 0 for no maximum",
+	"setspendpolicy-allowedaddresses": "If set and not empty, the only addresses payments may be sent to",
+	"setspendpolicy-minconf":          "The minimum number of confirmations of every output spent by the account, used instead of any lower minconf of a request",
+
+	"listtransactionspage--synopsis": "Returns a page of the results of 'listtransactions', newest transactions first, and the token to request the next page with.\n" +
+		"Only the transactions of each page are read, so the full history of wallets with many transactions may be returned over many requests.",
+	"listtransactionspage-account": "Only include transactions crediting or debiting addresses of this account, or \"*\" to include every transaction",
+	"listtransactionspage-count":   "Maximum number of transactions to create results from",
+	"listtransactionspage-token":   "The next page token of the previous page, or unset to begin at the newest transaction",
+
+	// ListTransactionsPageResult help.
+	"listtransactionspageresult-transactions": "Results in the same format as 'listtransactions'",
+	"listtransactionspageresult-nexttoken":    "The token to request the next page with, or the empty string if there are no more transactions",
+
+	// ListUnspentPageCmd help.
+	"listunspentpage--synopsis": "Returns a page of the results of 'listunspent', in order of their outpoints rather than sorted, and the token to request the next page with.",
+	"listunspentpage-minconf":   "Minimum number of block confirmations required before a transaction output is considered",
+	"listunspentpage-maxconf":   "Maximum number of block confirmations required before a transaction output is excluded",
+	"listunspentpage-addresses": "If set, limits the returned details to unspent outputs received by any of these payment addresses",
+	"listunspentpage-count":     "Maximum number of unspent outputs to return",
+	"listunspentpage-token":     "The next page token of the previous page, or unset to begin at the first output",
+
+	// ListUnspentPageResult help.
+	"listunspentpageresult-unspent":   "Results in the same format as 'listunspent'",
+	"listunspentpageresult-nexttoken": "The token to request the next page with, or the empty string if there are no more outputs",
+
 	// RenameAccountCmd help.
 	"renameaccount--synopsis":  "Renames an account.",
 	"renameaccount-oldaccount": "The old account name to rename",
@@ -413,4 +538,40 @@ var helpDescsEnUS = map[string]string{
 	// WalletIsLockedCmd help.
 	"walletislocked--synopsis": "Returns whether or not the wallet is locked.",
 	"walletislocked--result0":  "Whether the wallet is locked",
+
+	// CreateWalletCmd help.
+	"createwallet--synopsis": "Creates a new wallet in the wallets directory from a newly generated BIP0039 mnemonic and loads it.\n" +
+		"The mnemonic is only returned by this request and must be kept in a safe place, along with any mnemonic passphrase, to restore the wallet with restorewallet.",
+	"createwallet-name":               "Name of the wallet (letters, digits, underscores, and dashes)",
+	"createwallet-privpassphrase":     "The private passphrase used to unlock the wallet",
+	"createwallet-pubpassphrase":      "The public passphrase of the wallet (default=\"public\")",
+	"createwallet-mnemonicpassphrase": "Optional passphrase (ASCII only) to derive the seed from the mnemonic with",
+
+	// CreateWalletResult help.
+	"createwalletresult-mnemonic": "The 24 word mnemonic the seed is derived from",
+	"createwalletresult-seed":     "The hex encoded wallet generation seed, which may be used instead of the mnemonic and passphrase",
+
+	// ListWalletsCmd help.
+	"listwallets--synopsis": "Returns the names of all wallets loaded with loadwallet.\n" +
+		"The default wallet is not included.",
+	"listwallets--result0": "Sorted names of the loaded wallets",
+
+	// LoadWalletCmd help.
+	"loadwallet--synopsis": "Loads a wallet created in the wallets directory and starts it using the chain server connection of the default wallet.\n" +
+		"HTTP POST requests to the wallet are made to the /wallet/<name> endpoint and websocket clients connect to /ws/<name>.\n" +
+		"Notifications are only sent to websocket clients of the default wallet.",
+	"loadwallet-name":          "Name of the wallet (letters, digits, underscores, and dashes)",
+	"loadwallet-pubpassphrase": "The public passphrase of the wallet (default=\"public\")",
+
+	// RestoreWalletCmd help.
+	"restorewallet--synopsis":          "Creates a new wallet in the wallets directory from an existing wallet generation seed and loads it.",
+	"restorewallet-name":               "Name of the wallet (letters, digits, underscores, and dashes)",
+	"restorewallet-seed":               "The BIP0039 mnemonic or the hex encoded wallet generation seed",
+	"restorewallet-privpassphrase":     "The private passphrase used to unlock the wallet",
+	"restorewallet-pubpassphrase":      "The public passphrase of the wallet (default=\"public\")",
+	"restorewallet-mnemonicpassphrase": "The passphrase the seed was derived from the mnemonic with, if any",
+
+	// UnloadWalletCmd help.
+	"unloadwallet--synopsis": "Stops a wallet loaded with loadwallet and closes its database.",
+	"unloadwallet-name":      "Name of the wallet",
 }
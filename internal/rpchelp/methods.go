@@ -16,7 +16,10 @@
 
 package rpchelp
 
-import "github.com/btcsuite/btcd/btcjson"
+import (
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcwallet/walletjson"
+)
 
 // Common return types.
 var (
@@ -39,7 +42,7 @@ var Methods = []struct {
 	{"getaccount", returnsString},
 	{"getaccountaddress", returnsString},
 	{"getaddressesbyaccount", returnsStringArray},
-	{"getbalance", append(returnsNumber, returnsNumber[0])},
+	{"getbalance", []interface{}{(*float64)(nil), (*float64)(nil), (*walletjson.GetBalanceResult)(nil)}},
 	{"getbestblockhash", returnsString},
 	{"getblockcount", returnsNumber},
 	{"getinfo", []interface{}{(*btcjson.InfoWalletResult)(nil)}},
@@ -48,11 +51,12 @@ var Methods = []struct {
 	{"getreceivedbyaccount", returnsNumber},
 	{"getreceivedbyaddress", returnsNumber},
 	{"gettransaction", []interface{}{(*btcjson.GetTransactionResult)(nil)}},
+	{"getwalletinfo", []interface{}{(*walletjson.GetWalletInfoResult)(nil)}},
 	{"help", append(returnsString, returnsString[0])},
 	{"importprivkey", nil},
 	{"keypoolrefill", nil},
 	{"listaccounts", []interface{}{(*map[string]float64)(nil)}},
-	{"listlockunspent", []interface{}{(*[]btcjson.TransactionInput)(nil)}},
+	{"listlockunspent", []interface{}{(*[]walletjson.ListLockUnspentResult)(nil)}},
 	{"listreceivedbyaccount", []interface{}{(*[]btcjson.ListReceivedByAccountResult)(nil)}},
 	{"listreceivedbyaddress", []interface{}{(*[]btcjson.ListReceivedByAddressResult)(nil)}},
 	{"listsinceblock", []interface{}{(*btcjson.ListSinceBlockResult)(nil)}},
@@ -70,14 +74,29 @@ var Methods = []struct {
 	{"walletlock", nil},
 	{"walletpassphrase", nil},
 	{"walletpassphrasechange", nil},
+	{"abandontransaction", nil},
 	{"createnewaccount", nil},
+	{"exporttransactions", returnsString},
 	{"exportwatchingwallet", returnsString},
+	{"getbalancehistory", []interface{}{(*[]walletjson.GetBalanceHistoryResult)(nil)}},
 	{"getbestblock", []interface{}{(*btcjson.GetBestBlockResult)(nil)}},
 	{"getunconfirmedbalance", returnsNumber},
 	{"listaddresstransactions", returnsLTRArray},
 	{"listalltransactions", returnsLTRArray},
+	{"listtransactionspage", []interface{}{(*walletjson.ListTransactionsPageResult)(nil)}},
+	{"listspendpolicies", []interface{}{(*[]walletjson.SpendPolicyResult)(nil)}},
+	{"listunspentpage", []interface{}{(*walletjson.ListUnspentPageResult)(nil)}},
+	{"lockoutpoints", returnsBool},
+	{"removespendpolicy", nil},
 	{"renameaccount", nil},
+	{"sendall", returnsString},
+	{"setspendpolicy", nil},
 	{"walletislocked", returnsBool},
+	{"createwallet", []interface{}{(*walletjson.CreateWalletResult)(nil)}},
+	{"listwallets", returnsStringArray},
+	{"loadwallet", nil},
+	{"restorewallet", nil},
+	{"unloadwallet", nil},
 }
 
 var HelpDescs = []struct {
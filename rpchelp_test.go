@@ -29,6 +29,9 @@ func serverMethods() map[string]struct{} {
 			m[method] = struct{}{}
 		}
 	}
+	for method := range serverHandlers {
+		m[method] = struct{}{}
+	}
 	return m
 }
 
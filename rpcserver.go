@@ -33,6 +33,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -44,9 +46,15 @@ import (
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcrpcclient"
 	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/internal/bip39"
+	"github.com/btcsuite/btcwallet/internal/zero"
+	"github.com/btcsuite/btcwallet/spendpolicy"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/walletjson"
 	"github.com/btcsuite/btcwallet/wtxmgr"
 	"github.com/btcsuite/websocket"
 )
@@ -117,6 +125,57 @@ var (
 		Code:    btcjson.ErrRPCInvalidParameter,
 		Message: "Account name is reserved by RPC server",
 	}
+
+	// ErrWalletNotFound uses the same error code as bitcoind for requests
+	// to a wallet which is not loaded.
+	ErrWalletNotFound = btcjson.RPCError{
+		Code:    -18,
+		Message: "Requested wallet does not exist or is not loaded",
+	}
+
+	ErrWalletAlreadyLoaded = btcjson.RPCError{
+		Code:    btcjson.ErrRPCWallet,
+		Message: "Wallet is already loaded",
+	}
+
+	ErrWalletExists = btcjson.RPCError{
+		Code:    btcjson.ErrRPCWallet,
+		Message: "Wallet already exists",
+	}
+
+	ErrServerShuttingDown = btcjson.RPCError{
+		Code:    btcjson.ErrRPCWallet,
+		Message: "Server is shutting down",
+	}
+
+	ErrInvalidWalletName = InvalidParameterError{
+		errors.New("wallet names must be 1 to 64 letters, digits, " +
+			"underscores, or dashes"),
+	}
+
+	ErrChainServerDisconnected = btcjson.RPCError{
+		Code:    -1,
+		Message: "Chain server is disconnected",
+	}
+
+	ErrAdminAuthRequired = btcjson.RPCError{
+		Code:    -1,
+		Message: "Request requires the admin username and password",
+	}
+
+	// ErrSpendPolicyViolation uses a code outside of the range used by
+	// bitcoind so that clients can tell payments refused by a spending
+	// policy apart from other wallet errors.  The message is replaced by
+	// a description of the violation.
+	ErrSpendPolicyViolation = btcjson.RPCError{
+		Code:    -100,
+		Message: "Payments violate the spending policy of the account",
+	}
+
+	errNoSpendPolicies = btcjson.RPCError{
+		Code:    btcjson.ErrRPCWallet,
+		Message: "Wallet does not support spending policies",
+	}
 )
 
 // TODO(jrick): There are several error paths which 'replace' various errors
@@ -145,6 +204,8 @@ func confirms(txHeight, curHeight int32) int32 {
 type websocketClient struct {
 	conn          *websocket.Conn
 	authenticated bool
+	admin         bool   // authenticated with the admin auth
+	walletName    string // wallet loaded by name, or empty for default
 	remoteAddr    string
 	allRequests   chan []byte
 	responses     chan []byte
@@ -152,10 +213,12 @@ type websocketClient struct {
 	wg            sync.WaitGroup
 }
 
-func newWebsocketClient(c *websocket.Conn, authenticated bool, remoteAddr string) *websocketClient {
+func newWebsocketClient(c *websocket.Conn, authenticated, admin bool, walletName, remoteAddr string) *websocketClient {
 	return &websocketClient{
 		conn:          c,
 		authenticated: authenticated,
+		admin:         admin,
+		walletName:    walletName,
 		remoteAddr:    remoteAddr,
 		allRequests:   make(chan []byte),
 		responses:     make(chan []byte),
@@ -258,10 +321,26 @@ type rpcServer struct {
 	handlerLookup func(string) (requestHandler, bool)
 	handlerMu     sync.Mutex
 
+	// wallets holds each wallet loaded by name with the loadwallet
+	// method.  These are accessed with the /wallet/<name> endpoint and
+	// share the chain server connection with the default wallet.  After
+	// the server is stopped, walletsStopped is set and no more wallets
+	// may be loaded.  The names of wallets which are being opened or
+	// created, without the handler mutex held, are kept in loadingWallets
+	// so the same wallet is not loaded twice.
+	wallets        map[string]*loadedWallet
+	loadingWallets map[string]struct{}
+	walletsStopped bool
+
 	listeners []net.Listener
 	authsha   [sha256.Size]byte
 	upgrader  websocket.Upgrader
 
+	// adminsha is the hash of the HTTP Basic auth of administrative
+	// clients, and is only checked when adminAuth is set.
+	adminsha  [sha256.Size]byte
+	adminAuth bool
+
 	maxPostClients      int64 // Max concurrent HTTP POST clients.
 	maxWebsocketClients int64 // Max concurrent websocket clients.
 
@@ -303,6 +382,8 @@ func newRPCServer(listenAddrs []string, maxPost, maxWebsockets int64) (*rpcServe
 	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
 	s := rpcServer{
 		handlerLookup:       unloadedWalletHandlerFunc,
+		wallets:             make(map[string]*loadedWallet),
+		loadingWallets:      make(map[string]struct{}),
 		authsha:             sha256.Sum256([]byte(auth)),
 		maxPostClients:      maxPost,
 		maxWebsocketClients: maxWebsockets,
@@ -318,6 +399,12 @@ func newRPCServer(listenAddrs []string, maxPost, maxWebsockets int64) (*rpcServe
 		notificationHandlerQuit: make(chan struct{}),
 		quit: make(chan struct{}),
 	}
+	if cfg.AdminUsername != "" {
+		login := cfg.AdminUsername + ":" + cfg.AdminPassword
+		auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
+		s.adminsha = sha256.Sum256([]byte(auth))
+		s.adminAuth = true
+	}
 
 	// Setup TLS if not disabled.
 	listenFunc := net.Listen
@@ -403,47 +490,82 @@ func (s *rpcServer) Start() {
 		ReadTimeout: time.Second * rpcAuthTimeoutSeconds,
 	}
 
+	servePost := func(w http.ResponseWriter, r *http.Request, walletName string) {
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Content-Type", "application/json")
+		r.Close = true
+
+		admin, err := s.checkAuthHeader(r)
+		if err != nil {
+			log.Warnf("Unauthorized client connection attempt")
+			http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+		s.wg.Add(1)
+		s.PostClientRPC(w, r, walletName, admin)
+		s.wg.Done()
+	}
+
 	serveMux.Handle("/", throttledFn(s.maxPostClients,
 		func(w http.ResponseWriter, r *http.Request) {
-			w.Header().Set("Connection", "close")
-			w.Header().Set("Content-Type", "application/json")
-			r.Close = true
+			servePost(w, r, "")
+		}))
 
-			if err := s.checkAuthHeader(r); err != nil {
-				log.Warnf("Unauthorized client connection attempt")
-				http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
+	// Requests for wallets loaded by name are posted to /wallet/<name>.
+	serveMux.Handle("/wallet/", throttledFn(s.maxPostClients,
+		func(w http.ResponseWriter, r *http.Request) {
+			name := strings.TrimPrefix(r.URL.Path, "/wallet/")
+			if !validWalletName(name) {
+				http.NotFound(w, r)
 				return
 			}
-			s.wg.Add(1)
-			s.PostClientRPC(w, r)
-			s.wg.Done()
+			servePost(w, r, name)
 		}))
 
+	serveWS := func(w http.ResponseWriter, r *http.Request, walletName string) {
+		authenticated := false
+		admin, err := s.checkAuthHeader(r)
+		switch err {
+		case nil:
+			authenticated = true
+		case ErrNoAuth:
+			// nothing
+		default:
+			// If auth was supplied but incorrect, rather than simply
+			// being missing, immediately terminate the connection.
+			log.Warnf("Disconnecting improperly authorized " +
+				"websocket client")
+			http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
+			return
+		}
+
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Warnf("Cannot websocket upgrade client %s: %v",
+				r.RemoteAddr, err)
+			return
+		}
+		wsc := newWebsocketClient(conn, authenticated, admin,
+			walletName, r.RemoteAddr)
+		s.WebsocketClientRPC(wsc)
+	}
+
 	serveMux.Handle("/ws", throttledFn(s.maxWebsocketClients,
 		func(w http.ResponseWriter, r *http.Request) {
-			authenticated := false
-			switch s.checkAuthHeader(r) {
-			case nil:
-				authenticated = true
-			case ErrNoAuth:
-				// nothing
-			default:
-				// If auth was supplied but incorrect, rather than simply
-				// being missing, immediately terminate the connection.
-				log.Warnf("Disconnecting improperly authorized " +
-					"websocket client")
-				http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
-				return
-			}
+			serveWS(w, r, "")
+		}))
 
-			conn, err := s.upgrader.Upgrade(w, r, nil)
-			if err != nil {
-				log.Warnf("Cannot websocket upgrade client %s: %v",
-					r.RemoteAddr, err)
+	// Websocket clients of wallets loaded by name connect to /ws/<name>.
+	// Notifications are only sent for the default wallet, so these
+	// clients only receive responses to their requests.
+	serveMux.Handle("/ws/", throttledFn(s.maxWebsocketClients,
+		func(w http.ResponseWriter, r *http.Request) {
+			name := strings.TrimPrefix(r.URL.Path, "/ws/")
+			if !validWalletName(name) {
+				http.NotFound(w, r)
 				return
 			}
-			wsc := newWebsocketClient(conn, authenticated, r.RemoteAddr)
-			s.WebsocketClientRPC(wsc)
+			serveWS(w, r, name)
 		}))
 
 	for _, listener := range s.listeners {
@@ -472,11 +594,15 @@ func (s *rpcServer) Stop() {
 
 	log.Warn("Server shutting down")
 
-	// Stop the connected wallet and chain server, if any.
+	// Stop the connected wallets and chain server, if any.
 	s.handlerMu.Lock()
 	if s.wallet != nil {
 		s.wallet.Stop()
 	}
+	for _, lw := range s.wallets {
+		lw.wallet.Stop()
+	}
+	s.walletsStopped = true
 	if s.chainSvr != nil {
 		s.chainSvr.Stop()
 	}
@@ -496,12 +622,21 @@ func (s *rpcServer) Stop() {
 }
 
 func (s *rpcServer) WaitForShutdown() {
-	// First wait for the wallet and chain server to stop, if they
-	// were ever set.
+	// First wait for the wallets and chain server to stop, if they
+	// were ever set.  The databases of wallets loaded by name are closed
+	// after their wallet has stopped.
 	s.handlerMu.Lock()
 	if s.wallet != nil {
 		s.wallet.WaitForShutdown()
 	}
+	for name, lw := range s.wallets {
+		lw.wallet.WaitForShutdown()
+		if err := lw.db.Close(); err != nil {
+			log.Errorf("Cannot close database for wallet %s: %v",
+				name, err)
+		}
+		delete(s.wallets, name)
+	}
 	if s.chainSvr != nil {
 		s.chainSvr.WaitForShutdown()
 	}
@@ -555,21 +690,68 @@ func (s *rpcServer) SetChainServer(chainSvr *chain.Client) {
 // HandlerClosure creates a closure function for handling requests of the given
 // method.  This may be a request that is handled directly by btcwallet, or
 // a chain server request that is handled by passing the request down to btcd.
+// Wallet requests are handled by the wallet loaded with the name walletName,
+// or by the default wallet if walletName is empty.  Administrative requests
+// are refused unless admin is set.
 //
 // NOTE: These handlers do not handle special cases, such as the authenticate
 // method.  Each of these must be checked beforehand (the method is already
 // known) and handled accordingly.
-func (s *rpcServer) HandlerClosure(method string) requestHandlerClosure {
+func (s *rpcServer) HandlerClosure(walletName, method string, admin bool) requestHandlerClosure {
+	if _, ok := adminMethods[method]; ok && !admin {
+		return func(*btcjson.Request) (interface{}, *btcjson.RPCError) {
+			return nil, &ErrAdminAuthRequired
+		}
+	}
+	if _, ok := keyMethods[method]; ok && !admin && s.adminAuth {
+		return func(*btcjson.Request) (interface{}, *btcjson.RPCError) {
+			return nil, &ErrAdminAuthRequired
+		}
+	}
+
 	defer s.handlerMu.Unlock()
 	s.handlerMu.Lock()
 
+	// Requests which manage the loaded wallets are not handled by any
+	// single wallet.
+	if handler, ok := serverHandlers[method]; ok {
+		return func(req *btcjson.Request) (interface{}, *btcjson.RPCError) {
+			cmd, err := btcjson.UnmarshalCmd(req)
+			if err != nil {
+				return nil, btcjson.ErrRPCInvalidRequest
+			}
+			res, err := handler(s, cmd)
+			if err != nil {
+				return nil, jsonError(err)
+			}
+			return res, nil
+		}
+	}
+
 	// With the lock held, make copies of these pointers for the closure.
 	wallet := s.wallet
 	chainSvr := s.chainSvr
+	handlerLookup := s.handlerLookup
+
+	if walletName != "" {
+		// Wallets are only loaded by name when the chain server is
+		// set, so all handlers are ok to run for a loaded wallet.
+		if lw, ok := s.wallets[walletName]; ok {
+			wallet = lw.wallet
+			handlerLookup = lookupAnyHandler
+		} else {
+			wallet = nil
+			handlerLookup = unknownWalletHandlerFunc
+		}
+	}
 
-	if handler, ok := s.handlerLookup(method); ok {
+	if handler, ok := handlerLookup(method); ok {
+		_, keyMethod := keyMethods[method]
 		return func(req *btcjson.Request) (interface{}, *btcjson.RPCError) {
-			cmd, err := btcjson.UnmarshalCmd(req)
+			if keyMethod && !admin && hasSpendPolicy(wallet) {
+				return nil, &ErrAdminAuthRequired
+			}
+			cmd, err := walletjson.UnmarshalCmd(req)
 			if err != nil {
 				return nil, btcjson.ErrRPCInvalidRequest
 			}
@@ -583,10 +765,7 @@ func (s *rpcServer) HandlerClosure(method string) requestHandlerClosure {
 
 	return func(req *btcjson.Request) (interface{}, *btcjson.RPCError) {
 		if chainSvr == nil {
-			return nil, &btcjson.RPCError{
-				Code:    -1,
-				Message: "Chain server is disconnected",
-			}
+			return nil, &ErrChainServerDisconnected
 		}
 		res, err := chainSvr.RawRequest(req.Method, req.Params)
 		if err != nil {
@@ -601,23 +780,34 @@ func (s *rpcServer) HandlerClosure(method string) requestHandlerClosure {
 var ErrNoAuth = errors.New("no auth")
 
 // checkAuthHeader checks the HTTP Basic authentication supplied by a client
-// in the HTTP request r.  It errors with ErrNoAuth if the request does not
-// contain the Authorization header, or another non-nil error if the
-// authentication was provided but incorrect.
+// in the HTTP request r, returning whether the client authenticated with the
+// admin auth.  It errors with ErrNoAuth if the request does not contain the
+// Authorization header, or another non-nil error if the authentication was
+// provided but incorrect.
 //
 // This check is time-constant.
-func (s *rpcServer) checkAuthHeader(r *http.Request) error {
+func (s *rpcServer) checkAuthHeader(r *http.Request) (admin bool, err error) {
 	authhdr := r.Header["Authorization"]
 	if len(authhdr) == 0 {
-		return ErrNoAuth
+		return false, ErrNoAuth
 	}
 
 	authsha := sha256.Sum256([]byte(authhdr[0]))
-	cmp := subtle.ConstantTimeCompare(authsha[:], s.authsha[:])
-	if cmp != 1 {
-		return errors.New("bad auth")
+	admin, ok := s.checkAuthSha(&authsha)
+	if !ok {
+		return false, errors.New("bad auth")
 	}
-	return nil
+	return admin, nil
+}
+
+// checkAuthSha checks the hash of a client's HTTP Basic auth against the client
+// and admin auth, returning whether the auth is valid and whether it is the
+// admin auth.  Both hashes are always compared so the check is time-constant.
+func (s *rpcServer) checkAuthSha(authsha *[sha256.Size]byte) (admin, ok bool) {
+	client := subtle.ConstantTimeCompare(authsha[:], s.authsha[:]) == 1
+	admin = subtle.ConstantTimeCompare(authsha[:], s.adminsha[:]) == 1 &&
+		s.adminAuth
+	return admin, client || admin
 }
 
 // throttledFn wraps an http.HandlerFunc with throttling of concurrent active
@@ -651,9 +841,9 @@ func throttled(threshold int64, h http.Handler) http.Handler {
 func sanitizeRequest(r *btcjson.Request) string {
 	// These are considered unsafe to log, so sanitize parameters.
 	switch r.Method {
-	case "encryptwallet", "importprivkey", "importwallet",
-		"signrawtransaction", "walletpassphrase",
-		"walletpassphrasechange":
+	case "createwallet", "encryptwallet", "importprivkey", "importwallet",
+		"loadwallet", "restorewallet", "signrawtransaction",
+		"walletpassphrase", "walletpassphrasechange":
 
 		return fmt.Sprintf(`{"id":%v,"method":"%s","params":SANITIZED %d parameters}`,
 			r.ID, r.Method, len(r.Params))
@@ -676,21 +866,23 @@ func idPointer(id interface{}) (p *interface{}) {
 
 // invalidAuth checks whether a websocket request is a valid (parsable)
 // authenticate request and checks the supplied username and passphrase
-// against the server auth.
-func (s *rpcServer) invalidAuth(req *btcjson.Request) bool {
+// against the server auth.  For valid auth, it also returns whether the
+// client authenticated with the admin auth.
+func (s *rpcServer) invalidAuth(req *btcjson.Request) (invalid, admin bool) {
 	cmd, err := btcjson.UnmarshalCmd(req)
 	if err != nil {
-		return false
+		return false, false
 	}
 	authCmd, ok := cmd.(*btcjson.AuthenticateCmd)
 	if !ok {
-		return false
+		return false, false
 	}
 	// Check credentials.
 	login := authCmd.Username + ":" + authCmd.Passphrase
 	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(login))
 	authSha := sha256.Sum256([]byte(auth))
-	return subtle.ConstantTimeCompare(authSha[:], s.authsha[:]) != 1
+	admin, ok = s.checkAuthSha(&authSha)
+	return !ok, admin
 }
 
 func (s *rpcServer) WebsocketClientRead(wsc *websocketClient) {
@@ -747,11 +939,17 @@ out:
 			}
 
 			if req.Method == "authenticate" {
-				if wsc.authenticated || s.invalidAuth(&req) {
+				if wsc.authenticated {
+					// Disconnect immediately.
+					break out
+				}
+				invalid, admin := s.invalidAuth(&req)
+				if invalid {
 					// Disconnect immediately.
 					break out
 				}
 				wsc.authenticated = true
+				wsc.admin = admin
 				resp := makeResponse(req.ID, nil, nil)
 				// Expected to never fail.
 				mresp, err := json.Marshal(resp)
@@ -787,7 +985,8 @@ out:
 
 			default:
 				req := req // Copy for the closure
-				f := s.HandlerClosure(req.Method)
+				f := s.HandlerClosure(wsc.walletName, req.Method,
+					wsc.admin)
 				wsc.wg.Add(1)
 				go func() {
 					resp, jsonErr := f(&req)
@@ -890,8 +1089,11 @@ func (s *rpcServer) WebsocketClientRPC(wsc *websocketClient) {
 // that may be read from a client.  This is currently limited to 4MB.
 const maxRequestSize = 1024 * 1024 * 4
 
-// PostClientRPC processes and replies to a JSON-RPC client request.
-func (s *rpcServer) PostClientRPC(w http.ResponseWriter, r *http.Request) {
+// PostClientRPC processes and replies to a JSON-RPC client request.  Wallet
+// requests are handled by the wallet loaded with the name walletName, or by
+// the default wallet if walletName is empty.  Administrative requests are only
+// handled when admin is set.
+func (s *rpcServer) PostClientRPC(w http.ResponseWriter, r *http.Request, walletName string, admin bool) {
 	body := http.MaxBytesReader(w, r.Body, maxRequestSize)
 	rpcRequest, err := ioutil.ReadAll(body)
 	if err != nil {
@@ -935,7 +1137,7 @@ func (s *rpcServer) PostClientRPC(w http.ResponseWriter, r *http.Request) {
 		s.Stop()
 		res = "btcwallet stopping"
 	default:
-		res, jsonErr = s.HandlerClosure(req.Method)(&req)
+		res, jsonErr = s.HandlerClosure(walletName, req.Method, admin)(&req)
 	}
 
 	// Marshal and send.
@@ -1195,6 +1397,11 @@ out:
 					panic(err)
 				}
 				for _, c := range clients {
+					// Notifications are for the default
+					// wallet only.
+					if c.walletName != "" {
+						continue
+					}
 					if err := c.send(mn); err != nil {
 						delete(clients, c.quit)
 					}
@@ -1245,6 +1452,7 @@ var rpcHandlers = map[string]struct {
 	"getreceivedbyaccount":   {handler: GetReceivedByAccount},
 	"getreceivedbyaddress":   {handler: GetReceivedByAddress},
 	"gettransaction":         {handler: GetTransaction},
+	"getwalletinfo":          {handler: GetWalletInfo},
 	"help":                   {handler: Help},
 	"importprivkey":          {handler: ImportPrivKey},
 	"keypoolrefill":          {handler: KeypoolRefill},
@@ -1271,7 +1479,6 @@ var rpcHandlers = map[string]struct {
 	// Reference implementation methods (still unimplemented)
 	"backupwallet":         {handler: Unimplemented, noHelp: true},
 	"dumpwallet":           {handler: Unimplemented, noHelp: true},
-	"getwalletinfo":        {handler: Unimplemented, noHelp: true},
 	"importwallet":         {handler: Unimplemented, noHelp: true},
 	"listaddressgroupings": {handler: Unimplemented, noHelp: true},
 
@@ -1282,8 +1489,11 @@ var rpcHandlers = map[string]struct {
 	"setaccount":    {handler: Unsupported, noHelp: true},
 
 	// Extensions to the reference client JSON-RPC API
+	"abandontransaction":   {handler: AbandonTransaction},
 	"createnewaccount":     {handler: CreateNewAccount},
+	"exporttransactions":   {handler: ExportTransactions},
 	"exportwatchingwallet": {handler: ExportWatchingWallet},
+	"getbalancehistory":    {handler: GetBalanceHistory},
 	"getbestblock":         {handler: GetBestBlock},
 	// This was an extension but the reference implementation added it as
 	// well, but with a different API (no account parameter).  It's listed
@@ -1292,10 +1502,80 @@ var rpcHandlers = map[string]struct {
 	"getunconfirmedbalance":   {handler: GetUnconfirmedBalance},
 	"listaddresstransactions": {handler: ListAddressTransactions},
 	"listalltransactions":     {handler: ListAllTransactions},
+	"listtransactionspage":    {handler: ListTransactionsPage},
+	"listspendpolicies":       {handler: ListSpendPolicies},
+	"listunspentpage":         {handler: ListUnspentPage},
+	"lockoutpoints":           {handler: LockOutpoints},
+	"removespendpolicy":       {handler: RemoveSpendPolicy},
 	"renameaccount":           {handler: RenameAccount},
+	"sendall":                 {handler: SendAll},
+	"setspendpolicy":          {handler: SetSpendPolicy},
 	"walletislocked":          {handler: WalletIsLocked},
 }
 
+// serverRequestHandler is a handler function for requests which manage the
+// wallets loaded by the server rather than being handled by any single wallet.
+type serverRequestHandler func(*rpcServer, interface{}) (interface{}, error)
+
+// serverHandlers maps the methods which manage the loaded wallets to their
+// handlers.  These are run regardless of which wallet a request was made to.
+var serverHandlers = map[string]serverRequestHandler{
+	"createwallet":  CreateWallet,
+	"listwallets":   ListWallets,
+	"loadwallet":    LoadWallet,
+	"restorewallet": RestoreWallet,
+	"unloadwallet":  UnloadWallet,
+}
+
+// adminMethods are the methods which are only handled for clients that
+// authenticated with the admin username and password.  Clients authenticating
+// with the client username and password may not change the spending policies
+// which restrict them.
+var adminMethods = map[string]struct{}{
+	"removespendpolicy": {},
+	"setspendpolicy":    {},
+}
+
+// keyMethods are the methods which reveal private keys or sign transactions not
+// created by the wallet.  These could be used to send payments which are never
+// checked against the spending policies, so they are only handled for clients
+// that authenticated with the admin username and password when these are set,
+// or when any account of the wallet has a spending policy.  Without the admin
+// username and password, they are refused for every client of such a wallet.
+var keyMethods = map[string]struct{}{
+	"dumpprivkey":        {},
+	"dumpwallet":         {},
+	"signrawtransaction": {},
+}
+
+// hasSpendPolicy returns whether any account of the wallet has a spending
+// policy.  Policies which can not be read are assumed to exist so that key
+// methods are refused rather than handled around them.
+func hasSpendPolicy(w *wallet.Wallet) bool {
+	if w == nil || w.Policies == nil {
+		return false
+	}
+	policies, err := w.Policies.Policies()
+	if err != nil {
+		log.Errorf("Cannot read spending policies: %v", err)
+		return true
+	}
+	return len(policies) != 0
+}
+
+// loadedWallet is a wallet loaded by name with the loadwallet method, and the
+// database it was opened from.
+type loadedWallet struct {
+	wallet *wallet.Wallet
+	db     walletdb.DB
+}
+
+// errUpgradeNeedsConsole is returned by the open callbacks of wallets loaded
+// with the loadwallet method, since there is no way to prompt for the seed or
+// private passphrase over RPC.
+var errUpgradeNeedsConsole = errors.New("wallet requires an upgrade and " +
+	"must be opened at the console first")
+
 // Unimplemented handles an unimplemented RPC request with the
 // appropiate error.
 func Unimplemented(*wallet.Wallet, *chain.Client, interface{}) (interface{}, error) {
@@ -1320,17 +1600,23 @@ func UnloadedWallet(*wallet.Wallet, *chain.Client, interface{}) (interface{}, er
 	return nil, &ErrUnloadedWallet
 }
 
-// NoEncryptedWallet is the handler func that is run when no wallet has been
-// created by the user yet.
-// loaded yet when trying to execute a wallet RPC.
+// NoEncryptedWallet is the handler func that is run when no default wallet
+// has been created or loaded when trying to execute a wallet RPC.
 func NoEncryptedWallet(*wallet.Wallet, *chain.Client, interface{}) (interface{}, error) {
 	return nil, &btcjson.RPCError{
 		Code: btcjson.ErrRPCWallet,
-		Message: "Request requires a wallet but no wallet has been " +
-			"created -- use createencryptedwallet to recover",
+		Message: "Request requires a wallet but no default wallet has " +
+			"been loaded -- use createwallet, restorewallet, or " +
+			"loadwallet and make requests to /wallet/<name>",
 	}
 }
 
+// UnknownWallet is the handler func that is run when a wallet RPC is made to
+// a wallet name which has not been loaded.
+func UnknownWallet(*wallet.Wallet, *chain.Client, interface{}) (interface{}, error) {
+	return nil, &ErrWalletNotFound
+}
+
 // TODO(jrick): may be a good idea to add handlers for passthrough to the chain
 // server.  If a handler can not be looked up in one of the above maps, use this
 // passthrough handler instead.  This isn't done at the moment since all
@@ -1359,6 +1645,18 @@ func unloadedWalletHandlerFunc(method string) (f requestHandler, ok bool) {
 	return
 }
 
+// unknownWalletHandlerFunc looks up whether a request requires a wallet, and
+// if so, returns a specialized handler func to return errors for a request
+// made to a wallet which is not loaded.  If ok is false, the function is
+// invalid and should be passed through instead.
+func unknownWalletHandlerFunc(method string) (f requestHandler, ok bool) {
+	_, ok = rpcHandlers[method]
+	if ok {
+		f = UnknownWallet
+	}
+	return
+}
+
 // missingWalletHandlerFunc looks up whether a request requires a wallet, and
 // if so, returns a specialized handler func to return errors for no wallets
 // being created yet with the createencryptedwallet RPC.  If ok is false, the
@@ -1478,6 +1776,39 @@ func makeMultiSigScript(w *wallet.Wallet, keys []string, nRequired int) ([]byte,
 	return txscript.MultiSigScript(keysesPrecious, nRequired)
 }
 
+// AbandonTransaction handles an abandontransaction request by removing an
+// unmined transaction, and every transaction spending its outputs, from the
+// wallet so the outputs it spends may be spent again.
+func AbandonTransaction(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.AbandonTransactionCmd)
+
+	txSha, err := wire.NewShaHashFromStr(cmd.Txid)
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDecodeHexString,
+			Message: "Transaction hash string decode failed: " + err.Error(),
+		}
+	}
+
+	details, err := w.TxStore.TxDetails(txSha)
+	if err != nil {
+		return nil, err
+	}
+	if details == nil {
+		return nil, &ErrNoTransactionInfo
+	}
+
+	err = w.AbandonTransaction(txSha)
+	if err != nil {
+		if serr, ok := err.(wtxmgr.Error); ok && serr.Code == wtxmgr.ErrInput {
+			// The transaction is mined.
+			return nil, InvalidParameterError{err}
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
 // AddMultiSigAddress handles an addmultisigaddress request by adding a
 // multisig address to the given wallet.
 func AddMultiSigAddress(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
@@ -1529,6 +1860,82 @@ func CreateMultiSig(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{})
 	}, nil
 }
 
+// CreateWallet handles the createwallet command by creating a new wallet of
+// some name from a newly generated BIP0039 mnemonic and loading it.  The
+// mnemonic and the seed derived from it are returned to the caller, and are
+// not available by any other means, so one must be kept by the client to
+// restore the wallet with restorewallet.
+func CreateWallet(s *rpcServer, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.CreateWalletCmd)
+
+	var mnemonicPass string
+	if cmd.MnemonicPassphrase != nil {
+		mnemonicPass = *cmd.MnemonicPassphrase
+	}
+
+	entropy, err := bip39.NewEntropy(bip39.RecommendedEntropyBits)
+	if err != nil {
+		return nil, err
+	}
+	defer zero.Bytes(entropy)
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := bip39.Seed(mnemonic, mnemonicPass)
+	if err != nil {
+		return nil, InvalidParameterError{err}
+	}
+	defer zero.Bytes(seed)
+
+	err = s.createWallet(cmd.Name, seed, cmd.PrivPassphrase,
+		cmd.PubPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &walletjson.CreateWalletResult{
+		Mnemonic: mnemonic,
+		Seed:     hex.EncodeToString(seed),
+	}, nil
+}
+
+// createWallet creates the wallet database of some name from the seed and
+// passphrases and loads the wallet.  The default public passphrase is used if
+// pubPassphrase is nil.
+func (s *rpcServer) createWallet(name string, seed []byte, privPassphrase string,
+	pubPassphrase *string) error {
+
+	if !validWalletName(name) {
+		return ErrInvalidWalletName
+	}
+	if privPassphrase == "" {
+		return InvalidParameterError{
+			errors.New("private passphrase must not be empty"),
+		}
+	}
+	privPass := []byte(privPassphrase)
+	defer zero.Bytes(privPass)
+	pubPass := []byte(defaultPubPassphrase)
+	if pubPassphrase != nil {
+		pubPass = []byte(*pubPassphrase)
+	}
+
+	if err := s.beginLoadWallet(name); err != nil {
+		return err
+	}
+	dir := namedWalletDir(name)
+	if fileExists(filepath.Join(dir, walletDbName)) {
+		s.endLoadWallet(name, nil, nil)
+		return &ErrWalletExists
+	}
+	if err := createWalletDir(dir, seed, pubPass, privPass); err != nil {
+		s.endLoadWallet(name, nil, nil)
+		return err
+	}
+	log.Infof("Created wallet %s", name)
+	return s.loadWallet(name, pubPass)
+}
+
 // DumpPrivKey handles a dumpprivkey request with the private key
 // for a single address, or an appropiate error if the wallet
 // is locked.
@@ -1599,11 +2006,13 @@ func GetAddressesByAccount(w *wallet.Wallet, chainSvr *chain.Client, icmd interf
 
 // GetBalance handles a getbalance request by returning the balance for an
 // account (wallet), or an error if the requested account does not
-// exist.
+// exist.  Verbose requests also return the balance of immature coinbase
+// outputs.
 func GetBalance(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
-	cmd := icmd.(*btcjson.GetBalanceCmd)
+	cmd := icmd.(*walletjson.GetBalanceCmd)
+	verbose := cmd.Verbose != nil && *cmd.Verbose
 
-	var balance btcutil.Amount
+	var balance, immature btcutil.Amount
 	var err error
 	accountName := "*"
 	if cmd.Account != nil {
@@ -1611,6 +2020,9 @@ func GetBalance(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (int
 	}
 	if accountName == "*" {
 		balance, err = w.CalculateBalance(int32(*cmd.MinConf))
+		if err == nil && verbose {
+			immature, err = w.CalculateImmatureBalance()
+		}
 	} else {
 		var account uint32
 		account, err = w.Manager.LookupAccount(accountName)
@@ -1618,13 +2030,177 @@ func GetBalance(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (int
 			return nil, err
 		}
 		balance, err = w.CalculateAccountBalance(account, int32(*cmd.MinConf))
+		if err == nil && verbose {
+			immature, err = w.CalculateAccountImmatureBalance(account)
+		}
 	}
 	if err != nil {
 		return nil, err
 	}
+	if verbose {
+		return &walletjson.GetBalanceResult{
+			Balance:         balance.ToBTC(),
+			ImmatureBalance: immature.ToBTC(),
+		}, nil
+	}
 	return balance.ToBTC(), nil
 }
 
+// ExportTransactions handles an exporttransactions request by returning the
+// ledger of mined transactions, either for the whole wallet or a single
+// account, as a CSV or OFX document.
+func ExportTransactions(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.ExportTransactionsCmd)
+
+	filter := wallet.LedgerFilter{
+		StartHeight: *cmd.StartHeight,
+		EndHeight:   -1,
+	}
+	if *cmd.Account != "*" {
+		acct, err := w.Manager.LookupAccount(*cmd.Account)
+		if err != nil {
+			return nil, err
+		}
+		filter.Account = &acct
+	}
+	if cmd.EndHeight != nil {
+		filter.EndHeight = *cmd.EndHeight
+		if filter.EndHeight < filter.StartHeight {
+			return nil, InvalidParameterError{
+				errors.New("invalid height range"),
+			}
+		}
+	}
+	if cmd.StartTime != nil {
+		filter.StartTime = time.Unix(*cmd.StartTime, 0)
+	}
+	if cmd.EndTime != nil {
+		filter.EndTime = time.Unix(*cmd.EndTime, 0)
+	}
+
+	var write func(io.Writer, []wallet.LedgerEntry) error
+	switch *cmd.Format {
+	case "csv":
+		write = wallet.WriteLedgerCSV
+	case "ofx":
+		write = func(wr io.Writer, entries []wallet.LedgerEntry) error {
+			return wallet.WriteLedgerOFX(wr, entries, *cmd.Account)
+		}
+	default:
+		return nil, InvalidParameterError{
+			fmt.Errorf("unknown format %q", *cmd.Format),
+		}
+	}
+
+	entries, err := w.Ledger(&filter)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = write(&buf, entries)
+	if err != nil {
+		return nil, err
+	}
+	return buf.String(), nil
+}
+
+// GetBalanceHistory handles a getbalancehistory request by returning the
+// balance of mined outputs, either for the whole wallet or a single account,
+// following the first block and each later block which changed it, or at the
+// end of every day, between two heights.
+func GetBalanceHistory(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.GetBalanceHistoryCmd)
+
+	var account *uint32
+	if *cmd.Account != "*" {
+		acct, err := w.Manager.LookupAccount(*cmd.Account)
+		if err != nil {
+			return nil, err
+		}
+		account = &acct
+	}
+	var daily bool
+	switch *cmd.Interval {
+	case "block":
+	case "day":
+		daily = true
+	default:
+		return nil, InvalidParameterError{
+			fmt.Errorf("unknown interval %q", *cmd.Interval),
+		}
+	}
+	startHeight := *cmd.StartHeight
+	endHeight := w.Manager.SyncedTo().Height
+	if cmd.EndHeight != nil {
+		endHeight = *cmd.EndHeight
+	}
+	if startHeight < 0 || endHeight < startHeight {
+		return nil, InvalidParameterError{
+			errors.New("invalid height range"),
+		}
+	}
+
+	// The wallet only records blocks containing its transactions, so the
+	// hash and time of the first and last blocks are looked up from the
+	// chain server.
+	begin, err := mainChainBlock(chainSvr, startHeight)
+	if err != nil {
+		return nil, err
+	}
+	end, err := mainChainBlock(chainSvr, endHeight)
+	if err != nil {
+		return nil, err
+	}
+
+	newResult := func(b *wtxmgr.BlockBalance) walletjson.GetBalanceHistoryResult {
+		return walletjson.GetBalanceHistoryResult{
+			Height:    b.Block.Height,
+			BlockHash: b.Block.Hash.String(),
+			BlockTime: b.Block.Time.Unix(),
+			Balance:   b.Balance.ToBTC(),
+		}
+	}
+	if daily {
+		days, err := w.DailyBalanceHistory(account, begin, end)
+		if err != nil {
+			return nil, err
+		}
+		results := make([]walletjson.GetBalanceHistoryResult, 0, len(days))
+		for i := range days {
+			result := newResult(&days[i].BlockBalance)
+			result.Date = days[i].Date.Format("2006-01-02")
+			results = append(results, result)
+		}
+		return results, nil
+	}
+	history, err := w.BalanceHistory(account, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]walletjson.GetBalanceHistoryResult, 0, len(history))
+	for i := range history {
+		results = append(results, newResult(&history[i]))
+	}
+	return results, nil
+}
+
+// mainChainBlock returns the hash and time of the main chain block at height,
+// as reported by the chain server.
+func mainChainBlock(chainSvr *chain.Client, height int32) (*wtxmgr.BlockMeta, error) {
+	hash, err := chainSvr.GetBlockHash(int64(height))
+	if err != nil {
+		return nil, err
+	}
+	block, err := chainSvr.GetBlockVerbose(hash, false)
+	if err != nil {
+		return nil, err
+	}
+	return &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{Hash: *hash, Height: height},
+		Time:  time.Unix(block.Time, 0),
+	}, nil
+}
+
 // GetBestBlock handles a getbestblock request by returning a JSON object
 // with the height and hash of the most recently processed block.
 func GetBestBlock(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
@@ -1679,6 +2255,33 @@ func GetInfo(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interf
 	return info, nil
 }
 
+// GetWalletInfo handles a getwalletinfo request by returning a structure
+// containing the balances of the wallet, including the balance of immature
+// coinbase outputs which is not included in any other balance.
+func GetWalletInfo(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	confirmed, err := w.CalculateBalance(1)
+	if err != nil {
+		return nil, err
+	}
+	unconfirmed, err := w.CalculateBalance(0)
+	if err != nil {
+		return nil, err
+	}
+	immature, err := w.CalculateImmatureBalance()
+	if err != nil {
+		return nil, err
+	}
+
+	info := &walletjson.GetWalletInfoResult{
+		WalletVersion:      int32(waddrmgr.LatestMgrVersion),
+		Balance:            confirmed.ToBTC(),
+		UnconfirmedBalance: (unconfirmed - confirmed).ToBTC(),
+		ImmatureBalance:    immature.ToBTC(),
+		PayTxFee:           w.FeeIncrement.ToBTC(),
+	}
+	return info, nil
+}
+
 func decodeAddress(s string, params *chaincfg.Params) (btcutil.Address, error) {
 	addr, err := btcutil.DecodeAddress(s, params)
 	if err != nil {
@@ -1973,11 +2576,30 @@ func GetTransaction(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{})
 	if err != nil {
 		return nil, err
 	}
-	if details == nil {
-		return nil, &ErrNoTransactionInfo
-	}
 
-	syncBlock := w.Manager.SyncedTo()
+	// Transactions removed because they conflicted with a mined
+	// transaction are reported with negative confirmations.
+	var conflicted *wtxmgr.ConflictedTx
+	if details == nil {
+		conflicted, err = w.TxStore.ConflictedTx(txSha)
+		if err != nil {
+			return nil, err
+		}
+		if conflicted == nil {
+			return nil, &ErrNoTransactionInfo
+		}
+		details = &conflicted.TxDetails
+	}
+	conflicts, err := w.TxStore.WalletConflicts(txSha)
+	if err != nil {
+		return nil, err
+	}
+	walletConflicts := make([]string, len(conflicts))
+	for i := range conflicts {
+		walletConflicts[i] = conflicts[i].String()
+	}
+
+	syncBlock := w.Manager.SyncedTo()
 
 	// TODO: The serialized transaction is already in the DB, so
 	// reserializing can be avoided here.
@@ -1995,7 +2617,7 @@ func GetTransaction(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{})
 		Hex:             hex.EncodeToString(txBuf.Bytes()),
 		Time:            details.Received.Unix(),
 		TimeReceived:    details.Received.Unix(),
-		WalletConflicts: []string{}, // Not saved
+		WalletConflicts: walletConflicts,
 		//Generated:     blockchain.IsCoinBaseTx(&details.MsgTx),
 	}
 
@@ -2004,6 +2626,14 @@ func GetTransaction(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{})
 		ret.BlockTime = details.Block.Time.Unix()
 		ret.Confirmations = int64(confirms(details.Block.Height, syncBlock.Height))
 	}
+	if conflicted != nil {
+		// Like listtransactions, this is the negative depth of the
+		// block at which the conflict was detected.
+		ret.Confirmations = -1
+		if confs := confirms(conflicted.Height, syncBlock.Height); confs > 1 {
+			ret.Confirmations = -int64(confs)
+		}
+	}
 
 	var (
 		debitTotal  btcutil.Amount
@@ -2023,13 +2653,49 @@ func GetTransaction(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{})
 	for _, output := range details.MsgTx.TxOut {
 		outputTotal -= btcutil.Amount(output.Value)
 	}
-	// Fee can only be determined if every input is a debit.
-	if len(details.Debits) == len(details.MsgTx.TxIn) {
-		fee = debitTotal - outputTotal
+	// Fee can only be determined if the wallet recorded it when creating
+	// the transaction, or if every input is a debit.  The output total is
+	// negative, so adding it to the debits leaves the fee.
+	switch {
+	case details.Sent != nil:
+		fee = details.Sent.Fee
+		feeF64 = fee.ToBTC()
+	case len(details.Debits) == len(details.MsgTx.TxIn):
+		fee = debitTotal + outputTotal
 		feeF64 = fee.ToBTC()
 	}
 
-	if len(details.Debits) == 0 {
+	if details.Sent != nil {
+		// The recipients of transactions created by the wallet are
+		// known, so report a send detail for each recipient output.
+		ret.Details = make([]btcjson.GetTransactionDetailsResult, 0,
+			len(details.Sent.Recipients)+len(details.Credits))
+		for _, index := range details.Sent.Recipients {
+			if int(index) >= len(details.MsgTx.TxOut) {
+				continue
+			}
+			output := details.MsgTx.TxOut[index]
+
+			var addr string
+			_, addrs, _, err := txscript.ExtractPkScriptAddrs(
+				output.PkScript, activeNet.Params)
+			if err == nil && len(addrs) == 1 {
+				addr = addrs[0].EncodeAddress()
+			}
+
+			ret.Details = append(ret.Details, btcjson.GetTransactionDetailsResult{
+				// Fields left zeroed:
+				//   InvolvesWatchOnly
+				//   Account
+				Address:  addr,
+				Category: "send",
+				Amount:   (-btcutil.Amount(output.Value)).ToBTC(),
+				Vout:     index,
+				Fee:      &feeF64,
+			})
+		}
+		ret.Fee = feeF64
+	} else if len(details.Debits) == 0 {
 		// Credits must be set later, but since we know the full length
 		// of the details slice, allocate it with the correct cap.
 		ret.Details = make([]btcjson.GetTransactionDetailsResult, 0, len(details.Credits))
@@ -2236,9 +2902,25 @@ func ListAccounts(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (i
 }
 
 // ListLockUnspent handles a listlockunspent request by returning an slice of
-// all locked outpoints.
+// all locked outpoints, along with the expiry and reason of each lock.
 func ListLockUnspent(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
-	return w.LockedOutpoints(), nil
+	locks, err := w.LockedOutpoints()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]walletjson.ListLockUnspentResult, len(locks))
+	for i := range locks {
+		lock := &locks[i]
+		results[i] = walletjson.ListLockUnspentResult{
+			Txid:   lock.OutPoint.Hash.String(),
+			Vout:   lock.OutPoint.Index,
+			Reason: lock.Reason,
+		}
+		if !lock.Expiry.IsZero() {
+			results[i].Expiry = lock.Expiry.Unix()
+		}
+	}
+	return results, nil
 }
 
 // ListReceivedByAccount handles a listreceivedbyaccount request by returning
@@ -2314,57 +2996,42 @@ func ListReceivedByAddress(w *wallet.Wallet, chainSvr *chain.Client, icmd interf
 
 	// Intermediate data for all addresses.
 	allAddrData := make(map[string]AddrData)
-	// Create an AddrData entry for each active address in the account.
-	// Otherwise we'll just get addresses from transactions later.
+	// Create an AddrData entry for each active address in the account,
+	// totaling the credits found in the address index.
 	sortedAddrs, err := w.SortedActivePaymentAddresses()
 	if err != nil {
 		return nil, err
 	}
+	minConf := int32(*cmd.MinConf)
 	for _, address := range sortedAddrs {
-		// There might be duplicates, just overwrite them.
-		allAddrData[address] = AddrData{}
-	}
+		addr, err := btcutil.DecodeAddress(address, activeNet.Params)
+		if err != nil {
+			return nil, err
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		credits, err := w.TxStore.AddressCredits(pkScript)
+		if err != nil {
+			return nil, err
+		}
 
-	minConf := *cmd.MinConf
-	var endHeight int32
-	if minConf == 0 {
-		endHeight = -1
-	} else {
-		endHeight = syncBlock.Height - int32(minConf) + 1
-	}
-	err = w.TxStore.RangeTransactions(0, endHeight, func(details []wtxmgr.TxDetails) (bool, error) {
-		confirmations := confirms(details[0].Block.Height, syncBlock.Height)
-		for _, tx := range details {
-			for _, cred := range tx.Credits {
-				pkScript := tx.MsgTx.TxOut[cred.Index].PkScript
-				_, addrs, _, err := txscript.ExtractPkScriptAddrs(
-					pkScript, activeNet.Params)
-				if err != nil {
-					// Non standard script, skip.
-					continue
-				}
-				for _, addr := range addrs {
-					addrStr := addr.EncodeAddress()
-					addrData, ok := allAddrData[addrStr]
-					if ok {
-						addrData.amount += cred.Amount
-						// Always overwrite confirmations with newer ones.
-						addrData.confirmations = confirmations
-					} else {
-						addrData = AddrData{
-							amount:        cred.Amount,
-							confirmations: confirmations,
-						}
-					}
-					addrData.tx = append(addrData.tx, tx.Hash.String())
-					allAddrData[addrStr] = addrData
-				}
+		// Credits are ordered by block height, so the confirmations
+		// are always overwritten with those of newer credits.
+		var addrData AddrData
+		for i := range credits {
+			height := credits[i].Block.Height
+			if !confirmed(minConf, height, syncBlock.Height) {
+				continue
 			}
+			addrData.amount += credits[i].Amount
+			addrData.confirmations = confirms(height, syncBlock.Height)
+			addrData.tx = append(addrData.tx,
+				credits[i].OutPoint.Hash.String())
 		}
-		return false, nil
-	})
-	if err != nil {
-		return nil, err
+		// There might be duplicates, just overwrite them.
+		allAddrData[address] = addrData
 	}
 
 	// Massage address data into output format.
@@ -2383,6 +3050,60 @@ func ListReceivedByAddress(w *wallet.Wallet, chainSvr *chain.Client, icmd interf
 	return ret, nil
 }
 
+// accountSlice satisfies the sort.Interface interface to sort account
+// numbers in increasing order.
+type accountSlice []uint32
+
+func (s accountSlice) Len() int           { return len(s) }
+func (s accountSlice) Less(i, j int) bool { return s[i] < s[j] }
+func (s accountSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// ListSpendPolicies handles a listspendpolicies request by returning the
+// spending policy of every account with one, along with the amount currently
+// counted towards the spend limit of each.
+func ListSpendPolicies(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	if w.Policies == nil {
+		return nil, &errNoSpendPolicies
+	}
+	policies, err := w.Policies.Policies()
+	if err != nil {
+		return nil, err
+	}
+	accounts := make([]uint32, 0, len(policies))
+	for account := range policies {
+		accounts = append(accounts, account)
+	}
+	sort.Sort(accountSlice(accounts))
+
+	now := time.Now()
+	results := make([]walletjson.SpendPolicyResult, 0, len(accounts))
+	for _, account := range accounts {
+		p := policies[account]
+		acctName, err := w.Manager.AccountName(account)
+		if err != nil {
+			return nil, err
+		}
+		spent, err := w.Policies.Spent(account, now)
+		if err != nil {
+			return nil, err
+		}
+		allowed := p.AllowedAddresses
+		if allowed == nil {
+			allowed = []string{}
+		}
+		results = append(results, walletjson.SpendPolicyResult{
+			Account:          acctName,
+			SpendLimit:       p.SpendLimit.ToBTC(),
+			LimitPeriod:      int64(p.Period() / time.Second),
+			Spent:            spent.ToBTC(),
+			MaxPayment:       p.MaxPayment.ToBTC(),
+			AllowedAddresses: allowed,
+			MinConf:          p.MinConf,
+		})
+	}
+	return results, nil
+}
+
 // ListSinceBlock handles a listsinceblock request by returning an array of maps
 // with details of sent and received wallet transactions since the given block.
 func ListSinceBlock(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
@@ -2514,13 +3235,214 @@ func ListUnspent(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (in
 	return w.ListUnspent(int32(*cmd.MinConf), int32(*cmd.MaxConf), addresses)
 }
 
+// ListTransactionsPage handles a listtransactionspage request by returning a
+// page of the results of listtransactions and the token of the next page.
+func ListTransactionsPage(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.ListTransactionsPageCmd)
+
+	var account *uint32
+	if *cmd.Account != "*" {
+		acct, err := w.Manager.LookupAccount(*cmd.Account)
+		if err != nil {
+			return nil, err
+		}
+		account = &acct
+	}
+	if *cmd.Count < 0 {
+		return nil, InvalidParameterError{
+			errors.New("count must be non-negative"),
+		}
+	}
+	var token wtxmgr.PageToken
+	if cmd.Token != nil {
+		token = wtxmgr.PageToken(*cmd.Token)
+	}
+
+	txList, next, err := w.ListTransactionsPage(account, *cmd.Count, token)
+	if err != nil {
+		if serr, ok := err.(wtxmgr.Error); ok && serr.Code == wtxmgr.ErrInput {
+			// The page token could not be decoded.
+			return nil, InvalidParameterError{err}
+		}
+		return nil, err
+	}
+	return &walletjson.ListTransactionsPageResult{
+		Transactions: txList,
+		NextToken:    string(next),
+	}, nil
+}
+
+// ListUnspentPage handles a listunspentpage request by returning a page of
+// unspent outputs in outpoint order and the token of the next page.
+func ListUnspentPage(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.ListUnspentPageCmd)
+
+	var addresses map[string]struct{}
+	if cmd.Addresses != nil {
+		addresses = make(map[string]struct{})
+		for _, as := range *cmd.Addresses {
+			a, err := decodeAddress(as, activeNet.Params)
+			if err != nil {
+				return nil, err
+			}
+			addresses[a.EncodeAddress()] = struct{}{}
+		}
+	}
+	if *cmd.Count < 0 {
+		return nil, InvalidParameterError{
+			errors.New("count must be non-negative"),
+		}
+	}
+	var token wtxmgr.PageToken
+	if cmd.Token != nil {
+		token = wtxmgr.PageToken(*cmd.Token)
+	}
+
+	unspent, next, err := w.ListUnspentPage(int32(*cmd.MinConf),
+		int32(*cmd.MaxConf), addresses, *cmd.Count, token)
+	if err != nil {
+		if serr, ok := err.(wtxmgr.Error); ok && serr.Code == wtxmgr.ErrInput {
+			// The page token could not be decoded.
+			return nil, InvalidParameterError{err}
+		}
+		return nil, err
+	}
+	result := &walletjson.ListUnspentPageResult{
+		Unspent:   make([]btcjson.ListUnspentResult, len(unspent)),
+		NextToken: string(next),
+	}
+	for i, u := range unspent {
+		result.Unspent[i] = *u
+	}
+	return result, nil
+}
+
+// ListWallets handles the listwallets command by returning the sorted names of
+// all wallets loaded by name.  The default wallet is not included.
+func ListWallets(s *rpcServer, icmd interface{}) (interface{}, error) {
+	s.handlerMu.Lock()
+	names := make([]string, 0, len(s.wallets))
+	for name := range s.wallets {
+		names = append(names, name)
+	}
+	s.handlerMu.Unlock()
+
+	sort.Strings(names)
+	return names, nil
+}
+
+// LoadWallet handles the loadwallet command by opening the wallet database of
+// some name and starting the wallet with a connection shared with the default
+// wallet.  Requests may then be made to the wallet at the /wallet/<name>
+// endpoint.
+func LoadWallet(s *rpcServer, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.LoadWalletCmd)
+
+	if !validWalletName(cmd.Name) {
+		return nil, ErrInvalidWalletName
+	}
+	pubPass := []byte(defaultPubPassphrase)
+	if cmd.PubPassphrase != nil {
+		pubPass = []byte(*cmd.PubPassphrase)
+	}
+
+	if err := s.beginLoadWallet(cmd.Name); err != nil {
+		return nil, err
+	}
+	dir := namedWalletDir(cmd.Name)
+	if !fileExists(filepath.Join(dir, walletDbName)) {
+		s.endLoadWallet(cmd.Name, nil, nil)
+		return nil, &ErrWalletNotFound
+	}
+	return nil, s.loadWallet(cmd.Name, pubPass)
+}
+
+// beginLoadWallet returns an error if a wallet of some name can not be loaded
+// by the server, either because the server is shutting down, there is no
+// chain server to share, or a wallet of the same name is already loaded or
+// being loaded.  Otherwise, the name is reserved until endLoadWallet is
+// called, so the wallet database may be opened or created without holding
+// the handler mutex.
+func (s *rpcServer) beginLoadWallet(name string) error {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	if s.walletsStopped {
+		return &ErrServerShuttingDown
+	}
+	if s.chainSvr == nil {
+		return &ErrChainServerDisconnected
+	}
+	if _, ok := s.wallets[name]; ok {
+		return &ErrWalletAlreadyLoaded
+	}
+	if _, ok := s.loadingWallets[name]; ok {
+		return &ErrWalletAlreadyLoaded
+	}
+	s.loadingWallets[name] = struct{}{}
+	return nil
+}
+
+// endLoadWallet releases the name reserved by beginLoadWallet.  If the wallet
+// was opened, it is started with a connection shared with the default wallet
+// and added to the loaded wallets, unless the server was stopped in the
+// meantime, in which case the wallet database is closed and an error is
+// returned.
+func (s *rpcServer) endLoadWallet(name string, w *wallet.Wallet, db walletdb.DB) error {
+	s.handlerMu.Lock()
+	defer s.handlerMu.Unlock()
+
+	delete(s.loadingWallets, name)
+	if w == nil {
+		return nil
+	}
+	if s.walletsStopped || s.chainSvr == nil {
+		if err := db.Close(); err != nil {
+			log.Errorf("Cannot close database for wallet %s: %v",
+				name, err)
+		}
+		return &ErrServerShuttingDown
+	}
+	w.Start(s.chainSvr.Share())
+
+	s.wallets[name] = &loadedWallet{wallet: w, db: db}
+	log.Infof("Loaded wallet %s", name)
+	return nil
+}
+
+// loadWallet opens the wallet database of some name reserved by
+// beginLoadWallet and adds the wallet to the loaded wallets.  The handler
+// mutex must not be held, as opening the database may take some time.
+func (s *rpcServer) loadWallet(name string, pubPass []byte) error {
+	cbs := &waddrmgr.OpenCallbacks{
+		ObtainSeed: func() ([]byte, error) {
+			return nil, errUpgradeNeedsConsole
+		},
+		ObtainPrivatePass: func() ([]byte, error) {
+			return nil, errUpgradeNeedsConsole
+		},
+	}
+	obtainDbPass := func() ([]byte, error) {
+		return nil, errDbPassRequired
+	}
+	w, db, err := openWalletDir(namedWalletDir(name), pubPass, cbs,
+		obtainDbPass)
+	if err != nil {
+		s.endLoadWallet(name, nil, nil)
+		return err
+	}
+	return s.endLoadWallet(name, w, db)
+}
+
 // LockUnspent handles the lockunspent command.
 func LockUnspent(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
 	cmd := icmd.(*btcjson.LockUnspentCmd)
 
 	switch {
 	case cmd.Unlock && len(cmd.Transactions) == 0:
-		w.ResetLockedOutpoints()
+		if err := w.ResetLockedOutpoints(); err != nil {
+			return nil, err
+		}
 	default:
 		for _, input := range cmd.Transactions {
 			txSha, err := wire.NewShaHashFromStr(input.Txid)
@@ -2529,10 +3451,48 @@ func LockUnspent(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (in
 			}
 			op := wire.OutPoint{Hash: *txSha, Index: input.Vout}
 			if cmd.Unlock {
-				w.UnlockOutpoint(op)
+				err = w.UnlockOutpoint(op)
 			} else {
-				w.LockOutpoint(op)
+				err = w.LockOutpoint(op, time.Time{}, "")
 			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return true, nil
+}
+
+// LockOutpoints handles the lockoutpoints command, which extends lockunspent
+// with a reason and an expiry for the locks.
+func LockOutpoints(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.LockOutpointsCmd)
+
+	if *cmd.Timeout < 0 {
+		return nil, InvalidParameterError{
+			errors.New("timeout must be non-negative"),
+		}
+	}
+	var expiry time.Time
+	if *cmd.Timeout != 0 {
+		expiry = time.Now().Add(time.Duration(*cmd.Timeout) * time.Second)
+	}
+	var reason string
+	if cmd.Reason != nil {
+		reason = *cmd.Reason
+	}
+
+	ops := make([]wire.OutPoint, 0, len(cmd.Transactions))
+	for _, input := range cmd.Transactions {
+		txSha, err := wire.NewShaHashFromStr(input.Txid)
+		if err != nil {
+			return nil, ParseError{err}
+		}
+		ops = append(ops, wire.OutPoint{Hash: *txSha, Index: input.Vout})
+	}
+	for _, op := range ops {
+		if err := w.LockOutpoint(op, expiry, reason); err != nil {
+			return nil, err
 		}
 	}
 	return true, nil
@@ -2542,15 +3502,35 @@ func LockUnspent(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (in
 // It returns the transaction hash in string format upon success
 // All errors are returned in btcjson.RPCError format
 func sendPairs(w *wallet.Wallet, amounts map[string]btcutil.Amount,
-	account uint32, minconf int32) (string, error) {
-	txSha, err := w.SendPairs(amounts, account, minconf)
+	account uint32, minconf int32, subtractFeeFrom []string) (string, error) {
+	txSha, err := w.SendPairs(amounts, account, minconf, subtractFeeFrom)
+	return sendResult(txSha, err)
+}
+
+// sendResult returns the hash of a sent transaction in string format, or the
+// error sending it in btcjson.RPCError format.
+func sendResult(txSha *wire.ShaHash, err error) (string, error) {
 	if err != nil {
-		if err == wallet.ErrNonPositiveAmount {
+		switch err {
+		case wallet.ErrNonPositiveAmount:
 			return "", ErrNeedPositiveAmount
+		case wallet.ErrFeeExceedsOutputs, wallet.ErrSubtractFeeAddress:
+			return "", InvalidParameterError{err}
+		case wallet.ErrNoEligibleOutputs:
+			return "", &btcjson.RPCError{
+				Code:    btcjson.ErrRPCWalletInsufficientFunds,
+				Message: err.Error(),
+			}
 		}
 		if waddrmgr.IsError(err, waddrmgr.ErrLocked) {
 			return "", &ErrWalletUnlockNeeded
 		}
+		if spendpolicy.IsViolation(err) {
+			return "", &btcjson.RPCError{
+				Code:    ErrSpendPolicyViolation.Code,
+				Message: err.Error(),
+			}
+		}
 		switch err.(type) {
 		case btcjson.RPCError:
 			return "", err
@@ -2567,6 +3547,112 @@ func sendPairs(w *wallet.Wallet, amounts map[string]btcutil.Amount,
 	return txShaStr, nil
 }
 
+// RemoveSpendPolicy handles a removespendpolicy request by removing the spending
+// policy of an account.  This is an administrative request.
+func RemoveSpendPolicy(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.RemoveSpendPolicyCmd)
+
+	if w.Policies == nil {
+		return nil, &errNoSpendPolicies
+	}
+	account, err := w.Manager.LookupAccount(cmd.Account)
+	if err != nil {
+		return nil, err
+	}
+	err = w.Policies.RemovePolicy(account)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Removed spending policy of account %q", cmd.Account)
+	return nil, nil
+}
+
+// RestoreWallet handles the restorewallet command by creating a new wallet
+// of some name from an existing BIP0039 mnemonic or hex encoded seed and
+// loading it.
+func RestoreWallet(s *rpcServer, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.RestoreWalletCmd)
+
+	seed, err := decodeSeed(cmd.Seed, cmd.MnemonicPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	defer zero.Bytes(seed)
+
+	err = s.createWallet(cmd.Name, seed, cmd.PrivPassphrase,
+		cmd.PubPassphrase)
+	return nil, err
+}
+
+// decodeSeed returns the wallet generation seed for a BIP0039 mnemonic and
+// optional mnemonic passphrase, or for a hex encoded seed.  A passphrase may
+// not be used with a hex encoded seed.
+func decodeSeed(seedStr string, mnemonicPass *string) ([]byte, error) {
+	if bip39.IsMnemonic(seedStr) {
+		var pass string
+		if mnemonicPass != nil {
+			pass = *mnemonicPass
+		}
+		seed, err := bip39.Seed(seedStr, pass)
+		if err != nil {
+			return nil, InvalidParameterError{err}
+		}
+		return seed, nil
+	}
+
+	if mnemonicPass != nil {
+		return nil, InvalidParameterError{
+			errors.New("mnemonic passphrase may only be used " +
+				"with a mnemonic seed"),
+		}
+	}
+
+	// Unlike other hex parameters, odd length seeds are not padded since
+	// the seed would not be the same as the one the wallet was created
+	// from.
+	seed, err := hex.DecodeString(strings.TrimSpace(seedStr))
+	if err != nil {
+		return nil, &btcjson.RPCError{
+			Code:    btcjson.ErrRPCDecodeHexString,
+			Message: "Hex string decode failed: " + err.Error(),
+		}
+	}
+	if len(seed) < hdkeychain.MinSeedBytes ||
+		len(seed) > hdkeychain.MaxSeedBytes {
+
+		zero.Bytes(seed)
+		return nil, InvalidParameterError{
+			fmt.Errorf("seed must be at least %d bits and at most "+
+				"%d bits", hdkeychain.MinSeedBytes*8,
+				hdkeychain.MaxSeedBytes*8),
+		}
+	}
+	return seed, nil
+}
+
+// SendAll handles a sendall request by creating a new transaction spending
+// every eligible unspent output of an account to a single payment address.
+// The fee is subtracted from the amount paid, so no change is created.  Upon
+// success, the TxID for the created transaction is returned.
+func SendAll(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.SendAllCmd)
+
+	account, err := w.Manager.LookupAccount(cmd.FromAccount)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := decodeAddress(cmd.ToAddress, activeNet.Params); err != nil {
+		return nil, err
+	}
+	minConf := *cmd.MinConf
+	if minConf < 0 {
+		return nil, ErrNeedPositiveMinconf
+	}
+
+	txSha, err := w.SendAll(account, cmd.ToAddress, minConf)
+	return sendResult(txSha, err)
+}
+
 // SendFrom handles a sendfrom RPC request by creating a new transaction
 // spending unspent transaction outputs for a wallet to another payment
 // address.  Leftover inputs not sent to the payment address or a fee for
@@ -2606,7 +3692,7 @@ func SendFrom(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (inter
 		cmd.ToAddress: amt,
 	}
 
-	return sendPairs(w, pairs, account, minConf)
+	return sendPairs(w, pairs, account, minConf, nil)
 }
 
 // SendMany handles a sendmany RPC request by creating a new transaction
@@ -2615,7 +3701,7 @@ func SendFrom(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (inter
 // or a fee for the miner are sent back to a new address in the wallet.
 // Upon success, the TxID for the created transaction is returned.
 func SendMany(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
-	cmd := icmd.(*btcjson.SendManyCmd)
+	cmd := icmd.(*walletjson.SendManyCmd)
 
 	// Transaction comments are not yet supported.  Error instead of
 	// pretending to save them.
@@ -2647,7 +3733,12 @@ func SendMany(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (inter
 		pairs[k] = amt
 	}
 
-	return sendPairs(w, pairs, account, minConf)
+	var subtractFeeFrom []string
+	if cmd.SubtractFeeFrom != nil {
+		subtractFeeFrom = *cmd.SubtractFeeFrom
+	}
+
+	return sendPairs(w, pairs, account, minConf, subtractFeeFrom)
 }
 
 // SendToAddress handles a sendtoaddress RPC request by creating a new
@@ -2656,7 +3747,7 @@ func SendMany(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (inter
 // for the miner are sent back to a new address in the wallet.  Upon success,
 // the TxID for the created transaction is returned.
 func SendToAddress(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
-	cmd := icmd.(*btcjson.SendToAddressCmd)
+	cmd := icmd.(*walletjson.SendToAddressCmd)
 
 	// Transaction comments are not yet supported.  Error instead of
 	// pretending to save them.
@@ -2682,8 +3773,77 @@ func SendToAddress(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (
 		cmd.Address: amt,
 	}
 
+	var subtractFeeFrom []string
+	if cmd.SubtractFeeFromAmount != nil && *cmd.SubtractFeeFromAmount {
+		subtractFeeFrom = []string{cmd.Address}
+	}
+
 	// sendtoaddress always spends from the default account, this matches bitcoind
-	return sendPairs(w, pairs, waddrmgr.DefaultAccountNum, 1)
+	return sendPairs(w, pairs, waddrmgr.DefaultAccountNum, 1, subtractFeeFrom)
+}
+
+// SetSpendPolicy handles a setspendpolicy request by setting the spending policy
+// of an account, replacing any previous policy.  Payments already sent from the
+// account continue to count towards the new spend limit.  This is an
+// administrative request.
+func SetSpendPolicy(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.SetSpendPolicyCmd)
+
+	if w.Policies == nil {
+		return nil, &errNoSpendPolicies
+	}
+	account, err := w.Manager.LookupAccount(cmd.Account)
+	if err != nil {
+		return nil, err
+	}
+
+	spendLimit, err := btcutil.NewAmount(*cmd.SpendLimit)
+	if err != nil {
+		return nil, err
+	}
+	maxPayment, err := btcutil.NewAmount(*cmd.MaxPayment)
+	if err != nil {
+		return nil, err
+	}
+	if spendLimit < 0 || maxPayment < 0 {
+		return nil, InvalidParameterError{
+			errors.New("limits may not be negative"),
+		}
+	}
+	if *cmd.LimitPeriod <= 0 {
+		return nil, InvalidParameterError{
+			errors.New("limit period must be positive"),
+		}
+	}
+	if *cmd.MinConf < 0 {
+		return nil, ErrNeedPositiveMinconf
+	}
+
+	policy := &spendpolicy.Policy{
+		SpendLimit:  spendLimit,
+		LimitPeriod: time.Duration(*cmd.LimitPeriod) * time.Second,
+		MaxPayment:  maxPayment,
+		MinConf:     *cmd.MinConf,
+	}
+	if cmd.AllowedAddresses != nil {
+		// Save the canonical encoding of each address, since the
+		// addresses of payments are compared as strings.
+		for _, a := range *cmd.AllowedAddresses {
+			addr, err := decodeAddress(a, activeNet.Params)
+			if err != nil {
+				return nil, err
+			}
+			policy.AllowedAddresses = append(policy.AllowedAddresses,
+				addr.EncodeAddress())
+		}
+	}
+
+	err = w.Policies.SetPolicy(account, policy)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Set spending policy of account %q", cmd.Account)
+	return nil, nil
 }
 
 // SetTxFee sets the transaction fee per kilobyte added to transactions.
@@ -3040,6 +4200,30 @@ func SignRawTransaction(w *wallet.Wallet, chainSvr *chain.Client, icmd interface
 	}, nil
 }
 
+// UnloadWallet handles the unloadwallet command by stopping a wallet loaded
+// by name and closing its database.
+func UnloadWallet(s *rpcServer, icmd interface{}) (interface{}, error) {
+	cmd := icmd.(*walletjson.UnloadWalletCmd)
+
+	s.handlerMu.Lock()
+	lw, ok := s.wallets[cmd.Name]
+	if ok {
+		delete(s.wallets, cmd.Name)
+	}
+	s.handlerMu.Unlock()
+	if !ok {
+		return nil, &ErrWalletNotFound
+	}
+
+	lw.wallet.Stop()
+	lw.wallet.WaitForShutdown()
+	if err := lw.db.Close(); err != nil {
+		return nil, err
+	}
+	log.Infof("Unloaded wallet %s", cmd.Name)
+	return nil, nil
+}
+
 // ValidateAddress handles the validateaddress command.
 func ValidateAddress(w *wallet.Wallet, chainSvr *chain.Client, icmd interface{}) (interface{}, error) {
 	cmd := icmd.(*btcjson.ValidateAddressCmd)
@@ -0,0 +1,329 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package spendpolicy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// LatestVersion is the most recent store version.
+const LatestVersion = 1
+
+// byteOrder is the byte order used for all serialized integers.
+var byteOrder = binary.BigEndian
+
+// Bucket names and root bucket keys.
+var (
+	bucketPolicies = []byte("p")
+	bucketSpends   = []byte("s")
+
+	rootVersion = []byte("vers")
+)
+
+// The policy of each account is saved in the policies bucket keyed by the
+// account number (4 bytes).  Values are serialized as such:
+//
+//   [0:8]   Spend limit (8 bytes)
+//   [8:16]  Limit period in seconds (8 bytes)
+//   [16:24] Maximum payment (8 bytes)
+//   [24:28] Minimum confirmations (4 bytes)
+//   [28:32] Number of allowed addresses (4 bytes)
+//   [32:]   For each allowed address:
+//             Length of encoded address (1 byte)
+//             Encoded address (varies)
+
+func keyPolicy(account uint32) []byte {
+	k := make([]byte, 4)
+	byteOrder.PutUint32(k, account)
+	return k
+}
+
+func valuePolicy(p *Policy) ([]byte, error) {
+	size := 32
+	for _, addr := range p.AllowedAddresses {
+		if len(addr) > 0xff {
+			str := fmt.Sprintf("address %q is too long", addr)
+			return nil, policyError(ErrInput, str, nil)
+		}
+		size += 1 + len(addr)
+	}
+	v := make([]byte, size)
+	byteOrder.PutUint64(v[0:8], uint64(p.SpendLimit))
+	byteOrder.PutUint64(v[8:16], uint64(p.LimitPeriod/time.Second))
+	byteOrder.PutUint64(v[16:24], uint64(p.MaxPayment))
+	byteOrder.PutUint32(v[24:28], uint32(p.MinConf))
+	byteOrder.PutUint32(v[28:32], uint32(len(p.AllowedAddresses)))
+	off := 32
+	for _, addr := range p.AllowedAddresses {
+		v[off] = byte(len(addr))
+		off++
+		off += copy(v[off:], addr)
+	}
+	return v, nil
+}
+
+func readPolicy(v []byte) (*Policy, error) {
+	if len(v) < 32 {
+		str := fmt.Sprintf("%s: short read (expected %d bytes, read %d)",
+			bucketPolicies, 32, len(v))
+		return nil, policyError(ErrData, str, nil)
+	}
+	p := &Policy{
+		SpendLimit:  btcutil.Amount(byteOrder.Uint64(v[0:8])),
+		LimitPeriod: time.Duration(byteOrder.Uint64(v[8:16])) * time.Second,
+		MaxPayment:  btcutil.Amount(byteOrder.Uint64(v[16:24])),
+		MinConf:     int32(byteOrder.Uint32(v[24:28])),
+	}
+	numAddrs := byteOrder.Uint32(v[28:32])
+	off := 32
+	for i := uint32(0); i < numAddrs; i++ {
+		if len(v) < off+1 || len(v) < off+1+int(v[off]) {
+			str := fmt.Sprintf("%s: short read of allowed addresses",
+				bucketPolicies)
+			return nil, policyError(ErrData, str, nil)
+		}
+		addrLen := int(v[off])
+		off++
+		p.AllowedAddresses = append(p.AllowedAddresses,
+			string(v[off:off+addrLen]))
+		off += addrLen
+	}
+	return p, nil
+}
+
+func fetchPolicy(ns walletdb.Bucket, account uint32) (*Policy, error) {
+	v := ns.Bucket(bucketPolicies).Get(keyPolicy(account))
+	if v == nil {
+		return nil, nil
+	}
+	return readPolicy(v)
+}
+
+func putPolicy(ns walletdb.Bucket, account uint32, p *Policy) error {
+	v, err := valuePolicy(p)
+	if err != nil {
+		return err
+	}
+	err = ns.Bucket(bucketPolicies).Put(keyPolicy(account), v)
+	if err != nil {
+		str := fmt.Sprintf("failed to put policy of account %d", account)
+		return policyError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func deletePolicy(ns walletdb.Bucket, account uint32) error {
+	err := ns.Bucket(bucketPolicies).Delete(keyPolicy(account))
+	if err != nil {
+		str := fmt.Sprintf("failed to delete policy of account %d", account)
+		return policyError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// Payments counted towards an account's spend limit are saved in the spends
+// bucket keyed by the account number, the time the payments were made, and the
+// hash of the transaction which made them:
+//
+//   [0:4]   Account (4 bytes)
+//   [4:12]  Unix time in nanoseconds (8 bytes)
+//   [12:44] Transaction hash (32 bytes)
+//
+// Keys of the same account are ordered by time, so the payments made since
+// any time are found by seeking a cursor to the account and time.  Values are
+// the total amount of the payments (8 bytes).
+
+func keySpend(account uint32, t time.Time, txHash *wire.ShaHash) []byte {
+	k := make([]byte, 44)
+	byteOrder.PutUint32(k[0:4], account)
+	byteOrder.PutUint64(k[4:12], uint64(t.UnixNano()))
+	copy(k[12:44], txHash[:])
+	return k
+}
+
+func putSpend(ns walletdb.Bucket, account uint32, t time.Time, txHash *wire.ShaHash, amount btcutil.Amount) error {
+	v := make([]byte, 8)
+	byteOrder.PutUint64(v, uint64(amount))
+	err := ns.Bucket(bucketSpends).Put(keySpend(account, t, txHash), v)
+	if err != nil {
+		str := fmt.Sprintf("failed to put spend of transaction %v", txHash)
+		return policyError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func deleteSpend(ns walletdb.Bucket, account uint32, t time.Time, txHash *wire.ShaHash) error {
+	err := ns.Bucket(bucketSpends).Delete(keySpend(account, t, txHash))
+	if err != nil {
+		str := fmt.Sprintf("failed to delete spend of transaction %v", txHash)
+		return policyError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// spentSince returns the total amount of all payments made by an account after
+// some time.
+func spentSince(ns walletdb.Bucket, account uint32, since time.Time) (btcutil.Amount, error) {
+	prefix := keyPolicy(account)
+	seek := keySpend(account, since.Add(time.Nanosecond), &wire.ShaHash{})
+	var total btcutil.Amount
+	c := ns.Bucket(bucketSpends).Cursor()
+	for k, v := c.Seek(seek); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if len(v) < 8 {
+			str := fmt.Sprintf("%s: short read (expected %d bytes, "+
+				"read %d)", bucketSpends, 8, len(v))
+			return 0, policyError(ErrData, str, nil)
+		}
+		total += btcutil.Amount(byteOrder.Uint64(v))
+	}
+	return total, nil
+}
+
+// deleteSpendsBefore removes every recorded payment of an account made before
+// some time.
+func deleteSpendsBefore(ns walletdb.Bucket, account uint32, before time.Time) error {
+	end := keySpend(account, before, &wire.ShaHash{})
+	return deleteSpends(ns, account, end)
+}
+
+// deleteSpends removes the recorded payments of an account with keys sorting
+// before end, or every recorded payment of the account if end is nil.
+func deleteSpends(ns walletdb.Bucket, account uint32, end []byte) error {
+	prefix := keyPolicy(account)
+	var keys [][]byte
+	c := ns.Bucket(bucketSpends).Cursor()
+	for k, _ := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		if end != nil && bytes.Compare(k, end) >= 0 {
+			break
+		}
+		keys = append(keys, append([]byte(nil), k...))
+	}
+	b := ns.Bucket(bucketSpends)
+	for _, k := range keys {
+		err := b.Delete(k)
+		if err != nil {
+			str := "failed to delete expired spend"
+			return policyError(ErrDatabase, str, err)
+		}
+	}
+	return nil
+}
+
+func openStore(namespace walletdb.Namespace) error {
+	var version uint32
+	err := scopedView(namespace, func(ns walletdb.Bucket) error {
+		v := ns.Get(rootVersion)
+		if len(v) == 4 {
+			version = byteOrder.Uint32(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		str := "no policy store exists in namespace"
+		return policyError(ErrNoExists, str, nil)
+	}
+	if version > LatestVersion {
+		str := fmt.Sprintf("recorded version %d is newer that latest "+
+			"understood version %d", version, LatestVersion)
+		return policyError(ErrUnknownVersion, str, nil)
+	}
+	return nil
+}
+
+func createStore(namespace walletdb.Namespace) error {
+	return scopedUpdate(namespace, initStore)
+}
+
+// initStore writes the version and creates the buckets of a new store in the
+// namespace bucket ns.
+func initStore(ns walletdb.Bucket) error {
+	if ns.Get(rootVersion) != nil {
+		str := "policy store already exists in namespace"
+		return policyError(ErrAlreadyExists, str, nil)
+	}
+
+	v := make([]byte, 4)
+	byteOrder.PutUint32(v, LatestVersion)
+	err := ns.Put(rootVersion, v)
+	if err != nil {
+		str := "failed to store latest database version"
+		return policyError(ErrDatabase, str, err)
+	}
+	for _, name := range [][]byte{bucketPolicies, bucketSpends} {
+		_, err := ns.CreateBucketIfNotExists(name)
+		if err != nil {
+			str := fmt.Sprintf("failed to create bucket %s", name)
+			return policyError(ErrDatabase, str, err)
+		}
+	}
+	return nil
+}
+
+func scopedUpdate(ns walletdb.Namespace, f func(walletdb.Bucket) error) error {
+	tx, err := ns.Begin(true)
+	if err != nil {
+		str := "cannot begin update"
+		return policyError(ErrDatabase, str, err)
+	}
+	err = f(tx.RootBucket())
+	if err != nil {
+		rbErr := tx.Rollback()
+		if rbErr != nil {
+			const desc = "rollback failed"
+			serr, ok := err.(Error)
+			if !ok {
+				// This really shouldn't happen.
+				return policyError(ErrDatabase, desc, rbErr)
+			}
+			serr.Desc = desc + ": " + serr.Desc
+			return serr
+		}
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		str := "commit failed"
+		return policyError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func scopedView(ns walletdb.Namespace, f func(walletdb.Bucket) error) error {
+	tx, err := ns.Begin(false)
+	if err != nil {
+		str := "cannot begin view"
+		return policyError(ErrDatabase, str, err)
+	}
+	err = f(tx.RootBucket())
+	rbErr := tx.Rollback()
+	if err != nil {
+		return err
+	}
+	if rbErr != nil {
+		str := "cannot close view"
+		return policyError(ErrDatabase, str, rbErr)
+	}
+	return nil
+}
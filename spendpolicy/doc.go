@@ -0,0 +1,39 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// Package spendpolicy provides persistent spending policies for the accounts
+// of a bitcoin wallet.
+//
+// A policy limits the payments which may be sent from a single account.  It may
+// limit the total value of the payments sent over a rolling period of time
+// (for example, a daily limit), the value of any single payment, and the
+// addresses payments may be sent to.  A policy may also require every spent
+// output to have a minimum number of confirmations.
+//
+// Policies are saved in a walletdb namespace along with a history of the
+// payments sent from each account with a spend limit.  Before a wallet creates
+// a transaction, the payments are checked against the policy of the sending
+// account with Store.Check, and after the transaction is created the payments
+// are saved with Store.RecordSpend so they count towards the spend limit of
+// later transactions.  Payments which violate a policy are reported as an Error
+// with the ErrViolation code.
+//
+// The policies and recorded payments of a store are exported with ReadDump and
+// imported into a new store with WriteDump, which are used by the wallet dump
+// package.
+//
+// This package does not decode addresses, and compares the addresses of a
+// policy to the addresses of payments as strings.  Callers should save the
+// canonical encoding of each allowed address.
+package spendpolicy
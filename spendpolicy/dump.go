@@ -0,0 +1,196 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package spendpolicy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// Dump is a logical description of a policy store, read with ReadDump and
+// restored with WriteDump.  It holds the policy of every account with one and
+// the payments recorded towards their spend limits.
+type Dump struct {
+	// Policies are the account policies in increasing account order.
+	Policies []PolicyDump `json:"policies"`
+
+	// Spends are the recorded payments, ordered by account and then by
+	// the time they were sent.
+	Spends []SpendDump `json:"spends"`
+}
+
+// PolicyDump describes the spending policy of an account of a dump.  The limit
+// period is in seconds.
+type PolicyDump struct {
+	Account          uint32         `json:"account"`
+	SpendLimit       btcutil.Amount `json:"spendLimit"`
+	LimitPeriod      int64          `json:"limitPeriod"`
+	MaxPayment       btcutil.Amount `json:"maxPayment"`
+	AllowedAddresses []string       `json:"allowedAddresses"`
+	MinConf          int32          `json:"minConf"`
+}
+
+// SpendDump describes the payments of a transaction counted towards the spend
+// limit of an account.  Time is the Unix time in nanoseconds at which the
+// payments were recorded, and is part of the record's key.
+type SpendDump struct {
+	Account uint32         `json:"account"`
+	Time    int64          `json:"time"`
+	Hash    string         `json:"hash"`
+	Amount  btcutil.Amount `json:"amount"`
+}
+
+// policy returns the policy described by a dump.
+func (d *PolicyDump) policy() *Policy {
+	return &Policy{
+		SpendLimit:       d.SpendLimit,
+		LimitPeriod:      time.Duration(d.LimitPeriod) * time.Second,
+		MaxPayment:       d.MaxPayment,
+		AllowedAddresses: d.AllowedAddresses,
+		MinConf:          d.MinConf,
+	}
+}
+
+// ReadDump returns the dump of the policy store in the namespace of tx.  The
+// store must be at the latest version.
+func ReadDump(tx walletdb.Tx) (*Dump, error) {
+	ns := tx.RootBucket()
+	v := ns.Get(rootVersion)
+	if len(v) != 4 {
+		str := "no policy store exists in namespace"
+		return nil, policyError(ErrNoExists, str, nil)
+	}
+	if version := byteOrder.Uint32(v); version != LatestVersion {
+		str := fmt.Sprintf("the store must be upgraded from version %d "+
+			"to version %d before it is dumped", version,
+			LatestVersion)
+		return nil, policyError(ErrUnknownVersion, str, nil)
+	}
+
+	d := &Dump{
+		Policies: []PolicyDump{},
+		Spends:   []SpendDump{},
+	}
+	err := ns.Bucket(bucketPolicies).ForEach(func(k, v []byte) error {
+		if len(k) != 4 {
+			str := fmt.Sprintf("%s: bad key length %d",
+				bucketPolicies, len(k))
+			return policyError(ErrData, str, nil)
+		}
+		p, err := readPolicy(v)
+		if err != nil {
+			return err
+		}
+		addrs := p.AllowedAddresses
+		if addrs == nil {
+			addrs = []string{}
+		}
+		d.Policies = append(d.Policies, PolicyDump{
+			Account:          byteOrder.Uint32(k),
+			SpendLimit:       p.SpendLimit,
+			LimitPeriod:      int64(p.LimitPeriod / time.Second),
+			MaxPayment:       p.MaxPayment,
+			AllowedAddresses: addrs,
+			MinConf:          p.MinConf,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = ns.Bucket(bucketSpends).ForEach(func(k, v []byte) error {
+		if len(k) != 44 {
+			str := fmt.Sprintf("%s: bad key length %d",
+				bucketSpends, len(k))
+			return policyError(ErrData, str, nil)
+		}
+		if len(v) < 8 {
+			str := fmt.Sprintf("%s: short read (expected %d bytes, "+
+				"read %d)", bucketSpends, 8, len(v))
+			return policyError(ErrData, str, nil)
+		}
+		var hash wire.ShaHash
+		copy(hash[:], k[12:44])
+		d.Spends = append(d.Spends, SpendDump{
+			Account: byteOrder.Uint32(k[0:4]),
+			Time:    int64(byteOrder.Uint64(k[4:12])),
+			Hash:    hash.String(),
+			Amount:  btcutil.Amount(byteOrder.Uint64(v)),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// WriteDump creates a policy store at the latest version in the empty
+// namespace of tx holding the policies and recorded payments described by d.
+// Recorded payments must be of accounts with a policy.
+func WriteDump(tx walletdb.Tx, d *Dump) error {
+	ns := tx.RootBucket()
+	if err := initStore(ns); err != nil {
+		return err
+	}
+
+	accounts := make(map[uint32]struct{}, len(d.Policies))
+	for i := range d.Policies {
+		pd := &d.Policies[i]
+		if _, ok := accounts[pd.Account]; ok {
+			str := fmt.Sprintf("duplicate policy of account %d",
+				pd.Account)
+			return policyError(ErrInput, str, nil)
+		}
+		accounts[pd.Account] = struct{}{}
+		p := pd.policy()
+		if err := p.check(); err != nil {
+			return err
+		}
+		if err := putPolicy(ns, pd.Account, p); err != nil {
+			return err
+		}
+	}
+
+	for i := range d.Spends {
+		sd := &d.Spends[i]
+		if _, ok := accounts[sd.Account]; !ok {
+			str := fmt.Sprintf("spend of transaction %v is recorded "+
+				"for account %d without a policy", sd.Hash,
+				sd.Account)
+			return policyError(ErrInput, str, nil)
+		}
+		hash, err := wire.NewShaHashFromStr(sd.Hash)
+		if err != nil {
+			str := fmt.Sprintf("invalid spend hash %q", sd.Hash)
+			return policyError(ErrInput, str, err)
+		}
+		if sd.Amount < 0 {
+			str := fmt.Sprintf("spend of transaction %v has "+
+				"negative amount", sd.Hash)
+			return policyError(ErrInput, str, nil)
+		}
+		err = putSpend(ns, sd.Account, time.Unix(0, sd.Time), hash,
+			sd.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
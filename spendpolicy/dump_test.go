@@ -0,0 +1,148 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package spendpolicy
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+
+	s, ns, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record policies of two accounts, and payments of the account with a
+	// spend limit.
+	now := time.Now()
+	limited := &Policy{
+		SpendLimit:       10e8,
+		LimitPeriod:      time.Hour,
+		MaxPayment:       5e8,
+		AllowedAddresses: []string{"1BitcoinEaterAddressDontSendf59kuE"},
+		MinConf:          6,
+	}
+	if err := s.SetPolicy(1, limited); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetPolicy(2, &Policy{MinConf: 1}); err != nil {
+		t.Fatal(err)
+	}
+	hash1 := wire.ShaHash{1}
+	hash2 := wire.ShaHash{2}
+	if err := s.RecordSpend(1, &hash1, 2e8, now.Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordSpend(1, &hash2, 3e8, now); err != nil {
+		t.Fatal(err)
+	}
+
+	var d *Dump
+	err = ns.View(func(tx walletdb.Tx) error {
+		var err error
+		d, err = ReadDump(tx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Policies) != 2 || len(d.Spends) != 2 {
+		t.Fatalf("ReadDump: dumped %d policies and %d spends, "+
+			"expected 2 and 2", len(d.Policies), len(d.Spends))
+	}
+
+	// Restore the dump, after encoding it as JSON, to a new namespace.
+	out, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored Dump
+	if err := json.Unmarshal(out, &restored); err != nil {
+		t.Fatal(err)
+	}
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns2, err := db.Namespace([]byte("restored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ns2.Update(func(tx walletdb.Tx) error {
+		return WriteDump(tx, &restored)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := Open(ns2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The restored store must dump identically and hold the same
+	// policies and spent amounts.
+	var d2 *Dump
+	err = ns2.View(func(tx walletdb.Tx) error {
+		var err error
+		d2, err = ReadDump(tx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, d2) {
+		t.Errorf("ReadDump: restored store dumps as %+v, expected %+v",
+			d2, d)
+	}
+	p, err := s2.Policy(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, limited) {
+		t.Errorf("Policy: restored policy is %+v, expected %+v", p,
+			limited)
+	}
+	spent, err := s2.Spent(1, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 5e8 {
+		t.Errorf("Spent: restored store has spent %v, expected 5 BTC",
+			spent)
+	}
+
+	// A spend must not be restored for an account without a policy.
+	restored.Spends[0].Account = 3
+	ns3, err := db.Namespace([]byte("invalid"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ns3.Update(func(tx walletdb.Tx) error {
+		return WriteDump(tx, &restored)
+	})
+	if serr, ok := err.(Error); !ok || serr.Code != ErrInput {
+		t.Errorf("WriteDump: expected ErrInput, got %v", err)
+	}
+}
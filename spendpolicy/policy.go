@@ -0,0 +1,272 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package spendpolicy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// DefaultLimitPeriod is the period of time over which the spend limit of a
+// policy applies when the policy does not set one.
+const DefaultLimitPeriod = 24 * time.Hour
+
+// Policy describes the payments which may be sent from an account.  The zero
+// value of each field disables that restriction.
+type Policy struct {
+	// SpendLimit is the maximum total value of all payments sent from the
+	// account within any LimitPeriod.
+	SpendLimit btcutil.Amount
+
+	// LimitPeriod is the rolling period of time the spend limit applies
+	// to.  It has a resolution of one second, and DefaultLimitPeriod is
+	// used when zero.
+	LimitPeriod time.Duration
+
+	// MaxPayment is the maximum value of any single payment.
+	MaxPayment btcutil.Amount
+
+	// AllowedAddresses, if not empty, is the only addresses payments may
+	// be sent to.
+	AllowedAddresses []string
+
+	// MinConf is the minimum number of confirmations of any output spent
+	// by a transaction sent from the account.
+	MinConf int32
+}
+
+// Period returns the period of time the spend limit of the policy applies to.
+func (p *Policy) Period() time.Duration {
+	if p.LimitPeriod <= 0 {
+		return DefaultLimitPeriod
+	}
+	return p.LimitPeriod
+}
+
+// check returns an error with the ErrInput code if any restriction of the
+// policy is negative.
+func (p *Policy) check() error {
+	switch {
+	case p.SpendLimit < 0:
+		str := "spend limit may not be negative"
+		return policyError(ErrInput, str, nil)
+	case p.LimitPeriod < 0:
+		str := "limit period may not be negative"
+		return policyError(ErrInput, str, nil)
+	case p.MaxPayment < 0:
+		str := "maximum payment may not be negative"
+		return policyError(ErrInput, str, nil)
+	case p.MinConf < 0:
+		str := "minimum confirmations may not be negative"
+		return policyError(ErrInput, str, nil)
+	}
+	return nil
+}
+
+// allows returns whether payments may be sent to an address.
+func (p *Policy) allows(addr string) bool {
+	if len(p.AllowedAddresses) == 0 {
+		return true
+	}
+	for _, a := range p.AllowedAddresses {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Store implements persistent storage of account spending policies and the
+// payments counted towards their spend limits.
+type Store struct {
+	namespace walletdb.Namespace
+}
+
+// Open opens the policy store from a walletdb namespace.  If the store does
+// not exist, ErrNoExists is returned.
+func Open(namespace walletdb.Namespace) (*Store, error) {
+	err := openStore(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{namespace}, nil
+}
+
+// Create creates a new persistent policy store in the walletdb namespace.
+// Creating the store when one already exists in this namespace will error
+// with ErrAlreadyExists.
+func Create(namespace walletdb.Namespace) (*Store, error) {
+	err := createStore(namespace)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{namespace}, nil
+}
+
+// Policy returns the spending policy of an account, or nil if the account has
+// no policy.
+func (s *Store) Policy(account uint32) (*Policy, error) {
+	var p *Policy
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		var err error
+		p, err = fetchPolicy(ns, account)
+		return err
+	})
+	return p, err
+}
+
+// Policies returns the spending policies of every account with a policy, keyed
+// by account number.
+func (s *Store) Policies() (map[uint32]*Policy, error) {
+	policies := make(map[uint32]*Policy)
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		return ns.Bucket(bucketPolicies).ForEach(func(k, v []byte) error {
+			if len(k) != 4 {
+				str := fmt.Sprintf("%s: bad key length %d",
+					bucketPolicies, len(k))
+				return policyError(ErrData, str, nil)
+			}
+			p, err := readPolicy(v)
+			if err != nil {
+				return err
+			}
+			policies[byteOrder.Uint32(k)] = p
+			return nil
+		})
+	})
+	return policies, err
+}
+
+// SetPolicy sets the spending policy of an account, replacing any previous
+// policy.  Payments already recorded for the account continue to count towards
+// the spend limit of the new policy.
+func (s *Store) SetPolicy(account uint32, p *Policy) error {
+	if err := p.check(); err != nil {
+		return err
+	}
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		return putPolicy(ns, account, p)
+	})
+}
+
+// RemovePolicy removes the spending policy of an account.  Removing the policy
+// of an account without one is not an error.
+func (s *Store) RemovePolicy(account uint32) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		err := deletePolicy(ns, account)
+		if err != nil {
+			return err
+		}
+		// Recorded payments are only used for the spend limit, and
+		// are removed with the policy so a later policy begins with
+		// no history.
+		return deleteSpends(ns, account, nil)
+	})
+}
+
+// Check checks whether payments, keyed by the encoded address they pay to, may
+// be sent from an account at some time.  If any payment violates the policy of
+// the account, an Error with the ErrViolation code is returned describing the
+// violation.  Accounts without a policy may send any payments.
+func (s *Store) Check(account uint32, payments map[string]btcutil.Amount, now time.Time) error {
+	return scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		p, err := fetchPolicy(ns, account)
+		if err != nil || p == nil {
+			return err
+		}
+
+		var total btcutil.Amount
+		for addr, amt := range payments {
+			if !p.allows(addr) {
+				str := fmt.Sprintf("address %s is not allowed by "+
+					"the policy of account %d", addr, account)
+				return policyError(ErrViolation, str, nil)
+			}
+			if p.MaxPayment != 0 && amt > p.MaxPayment {
+				str := fmt.Sprintf("payment of %v exceeds the "+
+					"maximum payment %v of account %d", amt,
+					p.MaxPayment, account)
+				return policyError(ErrViolation, str, nil)
+			}
+			total += amt
+		}
+
+		if p.SpendLimit == 0 {
+			return nil
+		}
+		period := p.Period()
+		spent, err := spentSince(ns, account, now.Add(-period))
+		if err != nil {
+			return err
+		}
+		if spent+total > p.SpendLimit {
+			str := fmt.Sprintf("payments of %v exceed the remaining "+
+				"spend limit %v of account %d (limit %v per %v)",
+				total, p.SpendLimit-spent, account, p.SpendLimit,
+				period)
+			return policyError(ErrViolation, str, nil)
+		}
+		return nil
+	})
+}
+
+// RecordSpend records the total amount of the payments sent from an account by
+// a transaction so that they count towards the spend limit of the account.
+// Nothing is recorded if the account has no spend limit.  Recorded payments
+// which are older than the limit period are removed.
+func (s *Store) RecordSpend(account uint32, txHash *wire.ShaHash, amount btcutil.Amount, t time.Time) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		p, err := fetchPolicy(ns, account)
+		if err != nil {
+			return err
+		}
+		if p == nil || p.SpendLimit == 0 {
+			return nil
+		}
+		err = deleteSpendsBefore(ns, account, t.Add(-p.Period()))
+		if err != nil {
+			return err
+		}
+		return putSpend(ns, account, t, txHash, amount)
+	})
+}
+
+// RemoveSpend removes the payments recorded by RecordSpend for a transaction
+// sent from an account at time t, so they no longer count towards the spend
+// limit of the account.  This is used when the transaction is never published.
+func (s *Store) RemoveSpend(account uint32, txHash *wire.ShaHash, t time.Time) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		return deleteSpend(ns, account, t, txHash)
+	})
+}
+
+// Spent returns the total amount of the payments sent from an account which
+// count towards its spend limit at some time.
+func (s *Store) Spent(account uint32, now time.Time) (btcutil.Amount, error) {
+	var spent btcutil.Amount
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		p, err := fetchPolicy(ns, account)
+		if err != nil || p == nil {
+			return err
+		}
+		spent, err = spentSince(ns, account, now.Add(-p.Period()))
+		return err
+	})
+	return spent, err
+}
@@ -0,0 +1,299 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package spendpolicy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
+)
+
+func testStore() (*Store, walletdb.Namespace, func(), error) {
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		return nil, nil, func() {}, err
+	}
+	teardown := func() {
+		db.Close()
+	}
+	ns, err := db.Namespace([]byte("spendpolicy"))
+	if err != nil {
+		return nil, nil, teardown, err
+	}
+	s, err := Create(ns)
+	return s, ns, teardown, err
+}
+
+func TestOpenCreate(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("spendpolicy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Open(ns)
+	if !IsNoExists(err) {
+		t.Fatalf("Open of empty namespace: expected ErrNoExists, got %v", err)
+	}
+	_, err = Create(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Create(ns)
+	if serr, ok := err.(Error); !ok || serr.Code != ErrAlreadyExists {
+		t.Fatalf("second Create: expected ErrAlreadyExists, got %v", err)
+	}
+	_, err = Open(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	t.Parallel()
+
+	s, ns, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := s.Policy(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != nil {
+		t.Fatalf("account without a policy returned policy %v", p)
+	}
+
+	policy := &Policy{
+		SpendLimit:       5e8,
+		LimitPeriod:      12 * time.Hour,
+		MaxPayment:       1e8,
+		AllowedAddresses: []string{"addr1", "addr2"},
+		MinConf:          6,
+	}
+	err = s.SetPolicy(1, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.SetPolicy(2, &Policy{MinConf: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reopen the store to check that the policies were saved.
+	s, err = Open(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = s.Policy(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, policy) {
+		t.Errorf("saved policy %v does not match %v", p, policy)
+	}
+	policies, err := s.Policies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policies) != 2 || policies[2] == nil || policies[2].MinConf != 3 {
+		t.Errorf("unexpected policies %v", policies)
+	}
+	if policies[2].Period() != DefaultLimitPeriod {
+		t.Errorf("policy without a period has period %v", policies[2].Period())
+	}
+
+	err = s.RemovePolicy(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err = s.Policy(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p != nil {
+		t.Errorf("removed policy still exists: %v", p)
+	}
+
+	err = s.SetPolicy(3, &Policy{SpendLimit: -1})
+	if serr, ok := err.(Error); !ok || serr.Code != ErrInput {
+		t.Errorf("negative spend limit: expected ErrInput, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	s, _, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = s.SetPolicy(0, &Policy{
+		SpendLimit:       3e8,
+		MaxPayment:       2e8,
+		AllowedAddresses: []string{"allowed1", "allowed2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1430000000, 0)
+	hash1 := wire.ShaHash{1}
+	hash2 := wire.ShaHash{2}
+
+	tests := []struct {
+		name      string
+		account   uint32
+		payments  map[string]btcutil.Amount
+		now       time.Time
+		violation bool
+	}{
+		{
+			name:     "no policy",
+			account:  1,
+			payments: map[string]btcutil.Amount{"other": 100e8},
+			now:      start,
+		},
+		{
+			name:     "allowed payments",
+			payments: map[string]btcutil.Amount{"allowed1": 2e8, "allowed2": 1e8},
+			now:      start,
+		},
+		{
+			name:      "address not allowed",
+			payments:  map[string]btcutil.Amount{"allowed1": 1e8, "other": 1e8},
+			now:       start,
+			violation: true,
+		},
+		{
+			name:      "payment over maximum",
+			payments:  map[string]btcutil.Amount{"allowed1": 2e8 + 1},
+			now:       start,
+			violation: true,
+		},
+		{
+			name:      "payments over spend limit",
+			payments:  map[string]btcutil.Amount{"allowed1": 2e8, "allowed2": 2e8},
+			now:       start,
+			violation: true,
+		},
+	}
+	for _, test := range tests {
+		err := s.Check(test.account, test.payments, test.now)
+		if test.violation && !IsViolation(err) {
+			t.Errorf("%s: expected violation, got %v", test.name, err)
+		}
+		if !test.violation && err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+	}
+
+	// Record spends an hour apart and check the rolling limit.
+	err = s.RecordSpend(0, &hash1, 2e8, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.RecordSpend(0, &hash2, 1e8, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Spends of accounts without a spend limit are not recorded.
+	err = s.RecordSpend(1, &hash1, 50e8, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payment := map[string]btcutil.Amount{"allowed1": 1}
+	spentTests := []struct {
+		now       time.Time
+		spent     btcutil.Amount
+		violation bool
+	}{
+		{start.Add(time.Hour), 3e8, true},
+		{start.Add(DefaultLimitPeriod - 1), 3e8, true},
+		{start.Add(DefaultLimitPeriod), 1e8, false},
+		{start.Add(DefaultLimitPeriod + time.Hour), 0, false},
+	}
+	for i, test := range spentTests {
+		spent, err := s.Spent(0, test.now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if spent != test.spent {
+			t.Errorf("test %d: spent %v, expected %v", i, spent, test.spent)
+		}
+		err = s.Check(0, payment, test.now)
+		if test.violation && !IsViolation(err) {
+			t.Errorf("test %d: expected violation, got %v", i, err)
+		}
+		if !test.violation && err != nil {
+			t.Errorf("test %d: unexpected error %v", i, err)
+		}
+	}
+	spent, err := s.Spent(1, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 0 {
+		t.Errorf("account without a policy has spent %v", spent)
+	}
+
+	// Removing a spend removes only the payments of that transaction.
+	err = s.RecordSpend(0, &hash2, 5e7, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.RemoveSpend(0, &hash2, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent, err = s.Spent(0, start.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 3e8 {
+		t.Errorf("spent %v after removing a spend, expected %v", spent,
+			btcutil.Amount(3e8))
+	}
+
+	// Recording a later spend removes the expired spends.
+	later := start.Add(DefaultLimitPeriod + 2*time.Hour)
+	err = s.RecordSpend(0, &hash1, 1e8, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent, err = s.Spent(0, start.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 1e8 {
+		t.Errorf("expired spends were not removed: spent %v", spent)
+	}
+}
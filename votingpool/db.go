@@ -27,6 +27,7 @@ import (
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/snacl"
 	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
 )
 
 // These constants define the serialized length for a given encrypted extended
@@ -46,10 +47,19 @@ const (
 	seriesMaxVersion = 1
 )
 
+const (
+	// LatestVersion is the most recent version of the voting pool data
+	// stored in a namespace.
+	LatestVersion = 1
+)
+
 var (
 	usedAddrsBucketName   = []byte("usedaddrs")
 	seriesBucketName      = []byte("series")
 	withdrawalsBucketName = []byte("withdrawals")
+	// key of the version of the namespace, stored in the root bucket
+	// alongside the pool buckets, so it can not be used as a pool ID
+	poolVersionName = []byte("vpversion")
 	// string representing a non-existent private key
 	seriesNullPrivKey = [seriesKeyLength]byte{}
 )
@@ -182,10 +192,91 @@ func getMaxUsedIdx(tx walletdb.Tx, poolID []byte, seriesID uint32, branch Branch
 	return maxIdx, nil
 }
 
+// fetchVersion returns the version of the voting pool data in the namespace.
+// Namespaces created before the version was recorded are at version 1 if they
+// contain any pools, while a version of 0 is returned for namespaces with no
+// pools.
+func fetchVersion(tx walletdb.Tx) (uint32, error) {
+	rootBucket := tx.RootBucket()
+	if v := rootBucket.Get(poolVersionName); v != nil {
+		if len(v) != 4 {
+			str := fmt.Sprintf("invalid voting pool version %x", v)
+			return 0, newError(ErrDatabase, str, nil)
+		}
+		return binary.LittleEndian.Uint32(v), nil
+	}
+
+	var version uint32
+	err := rootBucket.ForEach(func(k, v []byte) error {
+		// Pools are the only nested buckets of the root bucket.
+		if v == nil {
+			version = 1
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, newError(ErrDatabase, "failed to find voting pools", err)
+	}
+	return version, nil
+}
+
+// putVersion stores the version of the voting pool data in the namespace.
+func putVersion(tx walletdb.Tx, version uint32) error {
+	v := make([]byte, 4)
+	binary.LittleEndian.PutUint32(v, version)
+	err := tx.RootBucket().Put(poolVersionName, v)
+	if err != nil {
+		str := fmt.Sprintf("failed to store voting pool version %d", version)
+		return newError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// MigrationSchema returns the migration schema of the voting pools stored in
+// the namespace with the passed key.  It is used to upgrade the voting pools
+// with migration.Upgrade, along with the data of other packages, before they
+// are loaded.
+func MigrationSchema(namespaceKey []byte) *migration.Schema {
+	return &migration.Schema{
+		Name:          "votingpool",
+		Namespace:     namespaceKey,
+		LatestVersion: LatestVersion,
+		Version:       fetchVersion,
+		SetVersion:    putVersion,
+	}
+}
+
+// upgradePools upgrades the voting pool data in the namespace to the latest
+// version as needed.
+func upgradePools(namespace walletdb.Namespace) error {
+	_, err := migration.UpgradeNamespace(namespace, MigrationSchema(nil), false)
+	if err != nil {
+		if vpErr, ok := err.(Error); ok {
+			return vpErr
+		}
+		return newError(ErrDatabase, "failed to upgrade voting pools", err)
+	}
+	return nil
+}
+
 // putPool stores a voting pool in the database, creating a bucket named
 // after the voting pool id and two other buckets inside it to store series and
-// used addresses for that pool.
+// used addresses for that pool.  The version of the namespace is recorded when
+// the first pool is created.
 func putPool(tx walletdb.Tx, poolID []byte) error {
+	if bytes.Equal(poolID, poolVersionName) {
+		str := fmt.Sprintf("pool ID %v is reserved", poolID)
+		return newError(ErrDatabase, str, nil)
+	}
+	version, err := fetchVersion(tx)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		if err := putVersion(tx, LatestVersion); err != nil {
+			return err
+		}
+	}
 	poolBucket, err := tx.RootBucket().CreateBucket(poolID)
 	if err != nil {
 		return newError(ErrDatabase, fmt.Sprintf("cannot create pool %v", poolID), err)
@@ -155,3 +155,44 @@ func TestPutAndGetWithdrawal(t *testing.T) {
 		t.Fatalf("Wrong value retrieved from DB; got %x, want %x", retrieved, serialized)
 	}
 }
+
+func TestPoolVersion(t *testing.T) {
+	tearDown, _, pool := TstCreatePool(t)
+	defer tearDown()
+
+	var version uint32
+	err := pool.namespace.Update(
+		func(tx walletdb.Tx) error {
+			var err error
+			version, err = fetchVersion(tx)
+			if err != nil {
+				return err
+			}
+
+			// Namespaces with pools created before the version was
+			// recorded must be reported as version 1.
+			if err := tx.RootBucket().Delete(poolVersionName); err != nil {
+				return err
+			}
+			legacyVersion, err := fetchVersion(tx)
+			if err != nil {
+				return err
+			}
+			if legacyVersion != 1 {
+				t.Errorf("Wrong version of unversioned pools; got %d, want 1",
+					legacyVersion)
+			}
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestVersion {
+		t.Fatalf("Wrong version recorded by Create; got %d, want %d",
+			version, LatestVersion)
+	}
+
+	if _, err := Create(pool.namespace, pool.manager, poolVersionName); err == nil {
+		t.Fatal("Create succeeded with a reserved pool ID")
+	}
+}
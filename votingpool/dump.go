@@ -0,0 +1,437 @@
+/*
+ * Copyright (c) 2015 The btcsuite developers
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package votingpool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// Dump is a logical description of the voting pools stored in a namespace, read
+// with ReadDump and restored with WriteDump.  Series keys and used address
+// hashes are kept encrypted exactly as they are stored.
+type Dump struct {
+	Pools []PoolDump `json:"pools"`
+}
+
+// PoolDump describes a voting pool of a dump.
+type PoolDump struct {
+	ID          []byte           `json:"id"`
+	Series      []SeriesDump     `json:"series"`
+	UsedAddrs   []UsedAddrDump   `json:"usedAddrs"`
+	Withdrawals []WithdrawalDump `json:"withdrawals"`
+}
+
+// SeriesDump describes a series of a voting pool of a dump.  A nil private
+// key is not known.
+type SeriesDump struct {
+	ID                uint32   `json:"id"`
+	Version           uint32   `json:"version"`
+	Active            bool     `json:"active"`
+	ReqSigs           uint32   `json:"reqSigs"`
+	PubKeysEncrypted  [][]byte `json:"pubKeysEncrypted"`
+	PrivKeysEncrypted [][]byte `json:"privKeysEncrypted"`
+}
+
+// UsedAddrDump describes a used address of a voting pool of a dump.
+type UsedAddrDump struct {
+	SeriesID      uint32 `json:"seriesID"`
+	Branch        Branch `json:"branch"`
+	Index         Index  `json:"index"`
+	EncryptedHash []byte `json:"encryptedHash"`
+}
+
+// WithdrawalAddressDump describes a withdrawal address of a dump.
+type WithdrawalAddressDump struct {
+	SeriesID uint32 `json:"seriesID"`
+	Branch   Branch `json:"branch"`
+	Index    Index  `json:"index"`
+}
+
+// ChangeAddressDump describes a change address of a dump.
+type ChangeAddressDump struct {
+	SeriesID uint32 `json:"seriesID"`
+	Index    Index  `json:"index"`
+}
+
+// OutputRequestDump describes an output requested by a withdrawal of a dump.
+type OutputRequestDump struct {
+	Address     string         `json:"address"`
+	Amount      btcutil.Amount `json:"amount"`
+	Server      string         `json:"server"`
+	Transaction uint32         `json:"transaction"`
+}
+
+// WithdrawalOutputDump describes the status of a requested output of a
+// withdrawal of a dump.  Status is one of "success", "partial", or "split".
+type WithdrawalOutputDump struct {
+	Status    string                    `json:"status"`
+	Outpoints []OutBailmentOutpointDump `json:"outpoints"`
+}
+
+// OutBailmentOutpointDump describes an outpoint created to fulfill a
+// requested output of a withdrawal of a dump.
+type OutBailmentOutpointDump struct {
+	Ntxid  Ntxid          `json:"ntxid"`
+	Index  uint32         `json:"index"`
+	Amount btcutil.Amount `json:"amount"`
+}
+
+// WithdrawalTxDump describes a transaction of a withdrawal of a dump.  The
+// change index is -1 if the transaction has no change output.
+type WithdrawalTxDump struct {
+	Tx          []byte `json:"tx"`
+	ChangeIndex int32  `json:"changeIndex"`
+}
+
+// WithdrawalDump describes a withdrawal of a voting pool of a dump.
+type WithdrawalDump struct {
+	RoundID           uint32                                 `json:"roundID"`
+	Requests          []OutputRequestDump                    `json:"requests"`
+	StartAddress      WithdrawalAddressDump                  `json:"startAddress"`
+	ChangeStart       ChangeAddressDump                      `json:"changeStart"`
+	LastSeriesID      uint32                                 `json:"lastSeriesID"`
+	DustThreshold     btcutil.Amount                         `json:"dustThreshold"`
+	NextInputAddress  WithdrawalAddressDump                  `json:"nextInputAddress"`
+	NextChangeAddress ChangeAddressDump                      `json:"nextChangeAddress"`
+	Fees              btcutil.Amount                         `json:"fees"`
+	Outputs           map[OutBailmentID]WithdrawalOutputDump `json:"outputs"`
+	Sigs              map[Ntxid]TxSigs                       `json:"sigs"`
+	Transactions      map[Ntxid]WithdrawalTxDump             `json:"transactions"`
+}
+
+// outputStatusNames are the names of each output status written to dumps.
+var outputStatusNames = map[outputStatus]string{
+	statusSuccess: "success",
+	statusPartial: "partial",
+	statusSplit:   "split",
+}
+
+// dumpWithdrawal returns the dump description of a serialized withdrawal.
+func dumpWithdrawal(roundID uint32, serialized []byte) (*WithdrawalDump, error) {
+	var row dbWithdrawalRow
+	err := gob.NewDecoder(bytes.NewReader(serialized)).Decode(&row)
+	if err != nil {
+		return nil, newError(ErrWithdrawalStorage,
+			"cannot deserialize withdrawal information", err)
+	}
+	w := &WithdrawalDump{
+		RoundID:  roundID,
+		Requests: make([]OutputRequestDump, len(row.Requests)),
+		StartAddress: WithdrawalAddressDump{
+			SeriesID: row.StartAddress.SeriesID,
+			Branch:   row.StartAddress.Branch,
+			Index:    row.StartAddress.Index,
+		},
+		ChangeStart: ChangeAddressDump{
+			SeriesID: row.ChangeStart.SeriesID,
+			Index:    row.ChangeStart.Index,
+		},
+		LastSeriesID:  row.LastSeriesID,
+		DustThreshold: row.DustThreshold,
+		NextInputAddress: WithdrawalAddressDump{
+			SeriesID: row.Status.NextInputAddr.SeriesID,
+			Branch:   row.Status.NextInputAddr.Branch,
+			Index:    row.Status.NextInputAddr.Index,
+		},
+		NextChangeAddress: ChangeAddressDump{
+			SeriesID: row.Status.NextChangeAddr.SeriesID,
+			Index:    row.Status.NextChangeAddr.Index,
+		},
+		Fees:         row.Status.Fees,
+		Outputs:      make(map[OutBailmentID]WithdrawalOutputDump, len(row.Status.Outputs)),
+		Sigs:         row.Status.Sigs,
+		Transactions: make(map[Ntxid]WithdrawalTxDump, len(row.Status.Transactions)),
+	}
+	for i, req := range row.Requests {
+		w.Requests[i] = OutputRequestDump{
+			Address:     req.Addr,
+			Amount:      req.Amount,
+			Server:      req.Server,
+			Transaction: req.Transaction,
+		}
+	}
+	for oid, output := range row.Status.Outputs {
+		status, ok := outputStatusNames[output.Status]
+		if !ok {
+			str := fmt.Sprintf("unknown status %d of output %v",
+				output.Status, oid)
+			return nil, newError(ErrWithdrawalStorage, str, nil)
+		}
+		outpoints := make([]OutBailmentOutpointDump, len(output.Outpoints))
+		for i, op := range output.Outpoints {
+			outpoints[i] = OutBailmentOutpointDump{op.Ntxid, op.Index,
+				op.Amount}
+		}
+		w.Outputs[oid] = WithdrawalOutputDump{status, outpoints}
+	}
+	for ntxid, tx := range row.Status.Transactions {
+		w.Transactions[ntxid] = WithdrawalTxDump{tx.SerializedMsgTx,
+			tx.ChangeIdx}
+	}
+	return w, nil
+}
+
+// serialize returns the serialized withdrawal described by a dump.
+func (w *WithdrawalDump) serialize() ([]byte, error) {
+	row := dbWithdrawalRow{
+		Requests: make([]dbOutputRequest, len(w.Requests)),
+		StartAddress: dbWithdrawalAddress{
+			SeriesID: w.StartAddress.SeriesID,
+			Branch:   w.StartAddress.Branch,
+			Index:    w.StartAddress.Index,
+		},
+		ChangeStart: dbChangeAddress{
+			SeriesID: w.ChangeStart.SeriesID,
+			Index:    w.ChangeStart.Index,
+		},
+		LastSeriesID:  w.LastSeriesID,
+		DustThreshold: w.DustThreshold,
+		Status: dbWithdrawalStatus{
+			NextInputAddr: dbWithdrawalAddress{
+				SeriesID: w.NextInputAddress.SeriesID,
+				Branch:   w.NextInputAddress.Branch,
+				Index:    w.NextInputAddress.Index,
+			},
+			NextChangeAddr: dbChangeAddress{
+				SeriesID: w.NextChangeAddress.SeriesID,
+				Index:    w.NextChangeAddress.Index,
+			},
+			Fees:         w.Fees,
+			Outputs:      make(map[OutBailmentID]dbWithdrawalOutput, len(w.Outputs)),
+			Sigs:         w.Sigs,
+			Transactions: make(map[Ntxid]dbChangeAwareTx, len(w.Transactions)),
+		},
+	}
+	for i, req := range w.Requests {
+		row.Requests[i] = dbOutputRequest{
+			Addr:        req.Address,
+			Amount:      req.Amount,
+			Server:      req.Server,
+			Transaction: req.Transaction,
+		}
+	}
+	for oid, output := range w.Outputs {
+		status, ok := outputStatusFromName(output.Status)
+		if !ok {
+			str := fmt.Sprintf("unknown status %q of output %v",
+				output.Status, oid)
+			return nil, newError(ErrWithdrawalStorage, str, nil)
+		}
+		outpoints := make([]dbOutBailmentOutpoint, len(output.Outpoints))
+		for i, op := range output.Outpoints {
+			outpoints[i] = dbOutBailmentOutpoint{op.Ntxid, op.Index,
+				op.Amount}
+		}
+		row.Status.Outputs[oid] = dbWithdrawalOutput{
+			OutBailmentID: oid,
+			Status:        status,
+			Outpoints:     outpoints,
+		}
+	}
+	for ntxid, tx := range w.Transactions {
+		row.Status.Transactions[ntxid] = dbChangeAwareTx{tx.Tx,
+			tx.ChangeIndex}
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(row); err != nil {
+		return nil, newError(ErrWithdrawalStorage,
+			"cannot serialize withdrawal information", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// outputStatusFromName returns the output status with the passed dump name.
+func outputStatusFromName(name string) (outputStatus, bool) {
+	for status, n := range outputStatusNames {
+		if n == name {
+			return status, true
+		}
+	}
+	return 0, false
+}
+
+// ReadDump returns the dump of the voting pools in the namespace accessed by
+// tx.
+func ReadDump(tx walletdb.Tx) (*Dump, error) {
+	version, err := fetchVersion(tx)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && version != LatestVersion {
+		str := fmt.Sprintf("the voting pools must be upgraded from "+
+			"version %d to version %d before they are dumped",
+			version, LatestVersion)
+		return nil, newError(ErrDatabase, str, nil)
+	}
+
+	d := &Dump{Pools: []PoolDump{}}
+	rootBucket := tx.RootBucket()
+	err = rootBucket.ForEach(func(poolID, v []byte) error {
+		// Pools are the only nested buckets of the root bucket.
+		if v != nil {
+			return nil
+		}
+		pool, err := dumpPool(tx, poolID)
+		if err != nil {
+			return err
+		}
+		d.Pools = append(d.Pools, *pool)
+		return nil
+	})
+	if err != nil {
+		if vpErr, ok := err.(Error); ok {
+			return nil, vpErr
+		}
+		return nil, newError(ErrDatabase, "failed to dump voting pools", err)
+	}
+	return d, nil
+}
+
+// dumpPool returns the dump description of the voting pool with the passed ID.
+func dumpPool(tx walletdb.Tx, poolID []byte) (*PoolDump, error) {
+	pool := &PoolDump{
+		ID:          append([]byte{}, poolID...),
+		Series:      []SeriesDump{},
+		UsedAddrs:   []UsedAddrDump{},
+		Withdrawals: []WithdrawalDump{},
+	}
+	poolBucket := tx.RootBucket().Bucket(poolID)
+
+	allSeries, err := loadAllSeries(tx, poolID)
+	if err != nil {
+		return nil, err
+	}
+	err = poolBucket.Bucket(seriesBucketName).ForEach(func(k, v []byte) error {
+		// Series are added in the order of their IDs, which the keys
+		// are sorted by, rather than in the random map order.
+		seriesID := bytesToUint32(k)
+		row := allSeries[seriesID]
+		series := SeriesDump{
+			ID:                seriesID,
+			Version:           row.version,
+			Active:            row.active,
+			ReqSigs:           row.reqSigs,
+			PubKeysEncrypted:  make([][]byte, len(row.pubKeysEncrypted)),
+			PrivKeysEncrypted: make([][]byte, len(row.privKeysEncrypted)),
+		}
+		for i, key := range row.pubKeysEncrypted {
+			series.PubKeysEncrypted[i] = append([]byte{}, key...)
+		}
+		for i, key := range row.privKeysEncrypted {
+			if key != nil {
+				series.PrivKeysEncrypted[i] = append([]byte{}, key...)
+			}
+		}
+		pool.Series = append(pool.Series, series)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usedAddrs := poolBucket.Bucket(usedAddrsBucketName)
+	err = usedAddrs.ForEach(func(bucketID, v []byte) error {
+		if v != nil || len(bucketID) != 9 {
+			str := fmt.Sprintf("invalid used addresses bucket %x",
+				bucketID)
+			return newError(ErrDatabase, str, nil)
+		}
+		seriesID := binary.LittleEndian.Uint32(bucketID[0:4])
+		branch := Branch(binary.LittleEndian.Uint32(bucketID[5:9]))
+		return usedAddrs.Bucket(bucketID).ForEach(func(k, v []byte) error {
+			pool.UsedAddrs = append(pool.UsedAddrs, UsedAddrDump{
+				SeriesID:      seriesID,
+				Branch:        branch,
+				Index:         Index(bytesToUint32(k)),
+				EncryptedHash: append([]byte{}, v...),
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Withdrawals are stored in the pool bucket itself, keyed by their
+	// round ID, alongside the nested buckets of the pool.
+	err = poolBucket.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		w, err := dumpWithdrawal(bytesToUint32(k), v)
+		if err != nil {
+			return err
+		}
+		pool.Withdrawals = append(pool.Withdrawals, *w)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// WriteDump creates the voting pools of a dump in the namespace accessed by tx.
+// ErrPoolAlreadyExists is returned if any of the pools already exists.
+func WriteDump(tx walletdb.Tx, d *Dump) error {
+	for i := range d.Pools {
+		pool := &d.Pools[i]
+		if existsPool(tx, pool.ID) {
+			str := fmt.Sprintf("cannot create pool with ID %v, "+
+				"it already exists", pool.ID)
+			return newError(ErrPoolAlreadyExists, str, nil)
+		}
+		if err := putPool(tx, pool.ID); err != nil {
+			return err
+		}
+		for _, s := range pool.Series {
+			err := putSeries(tx, pool.ID, s.Version, s.ID, s.Active,
+				s.ReqSigs, s.PubKeysEncrypted, s.PrivKeysEncrypted)
+			if err != nil {
+				return err
+			}
+		}
+		for _, a := range pool.UsedAddrs {
+			err := putUsedAddrHash(tx, pool.ID, a.SeriesID, a.Branch,
+				a.Index, a.EncryptedHash)
+			if err != nil {
+				return err
+			}
+		}
+		for j := range pool.Withdrawals {
+			w := &pool.Withdrawals[j]
+			serialized, err := w.serialize()
+			if err != nil {
+				return err
+			}
+			err = putWithdrawal(tx, pool.ID, w.RoundID, serialized)
+			if err != nil {
+				str := fmt.Sprintf("cannot store withdrawal %d",
+					w.RoundID)
+				return newError(ErrWithdrawalStorage, str, err)
+			}
+		}
+	}
+	return nil
+}
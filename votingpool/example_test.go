@@ -19,9 +19,6 @@ package votingpool_test
 import (
 	"bytes"
 	"fmt"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
@@ -29,7 +26,7 @@ import (
 	"github.com/btcsuite/btcwallet/votingpool"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
-	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
 	"github.com/btcsuite/btcwallet/wtxmgr"
 )
 
@@ -240,17 +237,12 @@ func Example_startWithdrawal() {
 }
 
 func createWalletDB() (walletdb.DB, func(), error) {
-	dir, err := ioutil.TempDir("", "votingpool_example")
-	if err != nil {
-		return nil, nil, err
-	}
-	db, err := walletdb.Create("bdb", filepath.Join(dir, "wallet.db"))
+	db, err := walletdb.Create("mem")
 	if err != nil {
 		return nil, nil, err
 	}
 	dbTearDown := func() {
 		db.Close()
-		os.RemoveAll(dir)
 	}
 	return db, dbTearDown, nil
 }
@@ -319,11 +311,7 @@ func exampleCreatePoolAndSeries(mgr *waddrmgr.Manager, vpNamespace walletdb.Name
 }
 
 func exampleCreateTxStore() (*wtxmgr.Store, func(), error) {
-	dir, err := ioutil.TempDir("", "pool_test_txstore")
-	if err != nil {
-		return nil, nil, err
-	}
-	db, err := walletdb.Create("bdb", filepath.Join(dir, "txstore.db"))
+	db, err := walletdb.Create("mem")
 	if err != nil {
 		return nil, nil, err
 	}
@@ -335,5 +323,5 @@ func exampleCreateTxStore() (*wtxmgr.Store, func(), error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	return s, func() { os.RemoveAll(dir) }, nil
+	return s, func() { db.Close() }, nil
 }
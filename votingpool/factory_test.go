@@ -20,9 +20,6 @@ package votingpool
 
 import (
 	"bytes"
-	"io/ioutil"
-	"os"
-	"path/filepath"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -137,11 +134,7 @@ func TstCreatePkScript(t *testing.T, p *Pool, seriesID uint32, branch Branch, id
 }
 
 func TstCreateTxStore(t *testing.T) (store *wtxmgr.Store, tearDown func()) {
-	dir, err := ioutil.TempDir("", "pool_test_txstore")
-	if err != nil {
-		t.Fatalf("Failed to create txstore dir: %v", err)
-	}
-	db, err := walletdb.Create("bdb", filepath.Join(dir, "txstore.db"))
+	db, err := walletdb.Create("mem")
 	if err != nil {
 		t.Fatalf("Failed to create walletdb: %v", err)
 	}
@@ -153,7 +146,7 @@ func TstCreateTxStore(t *testing.T) (store *wtxmgr.Store, tearDown func()) {
 	if err != nil {
 		t.Fatalf("Failed to create txstore: %v", err)
 	}
-	return s, func() { os.RemoveAll(dir) }
+	return s, func() { db.Close() }
 }
 
 type TstSeriesDef struct {
@@ -332,11 +325,7 @@ func TstCreatePool(t *testing.T) (tearDownFunc func(), mgr *waddrmgr.Manager, po
 	t.Parallel()
 
 	// Create a new wallet DB and addr manager.
-	dir, err := ioutil.TempDir("", "pool_test")
-	if err != nil {
-		t.Fatalf("Failed to create db dir: %v", err)
-	}
-	db, err := walletdb.Create("bdb", filepath.Join(dir, "wallet.db"))
+	db, err := walletdb.Create("mem")
 	if err != nil {
 		t.Fatalf("Failed to create wallet DB: %v", err)
 	}
@@ -363,7 +352,6 @@ func TstCreatePool(t *testing.T) (tearDownFunc func(), mgr *waddrmgr.Manager, po
 	tearDownFunc = func() {
 		db.Close()
 		mgr.Close()
-		os.RemoveAll(dir)
 	}
 	return tearDownFunc, mgr, pool
 }
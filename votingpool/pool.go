@@ -96,6 +96,9 @@ type WithdrawalAddress struct {
 // Create creates a new entry in the database with the given ID
 // and returns the Pool representing it.
 func Create(namespace walletdb.Namespace, m *waddrmgr.Manager, poolID []byte) (*Pool, error) {
+	if err := upgradePools(namespace); err != nil {
+		return nil, err
+	}
 	err := namespace.Update(
 		func(tx walletdb.Tx) error {
 			return putPool(tx, poolID)
@@ -110,6 +113,9 @@ func Create(namespace walletdb.Namespace, m *waddrmgr.Manager, poolID []byte) (*
 // Load fetches the entry in the database with the given ID and returns the Pool
 // representing it.
 func Load(namespace walletdb.Namespace, m *waddrmgr.Manager, poolID []byte) (*Pool, error) {
+	if err := upgradePools(namespace); err != nil {
+		return nil, err
+	}
 	err := namespace.View(
 		func(tx walletdb.Tx) error {
 			if exists := existsPool(tx, poolID); !exists {
@@ -27,7 +27,7 @@ import (
 	vp "github.com/btcsuite/btcwallet/votingpool"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
-	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
 )
 
 func TestLoadPoolAndDepositScript(t *testing.T) {
@@ -0,0 +1,231 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package waddrmgr
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/fastsha256"
+)
+
+// Problem describes an inconsistency found in the address manager database by
+// Check.
+type Problem struct {
+	Desc     string // Human readable description of the problem
+	Repaired bool   // Whether the problem was repaired
+}
+
+// String returns a human-readable description of the problem.
+func (p Problem) String() string {
+	if p.Repaired {
+		return p.Desc + " (repaired)"
+	}
+	return p.Desc
+}
+
+// checker records the problems found by Check and the account rows which must
+// be rewritten to repair them.
+type checker struct {
+	problems    []Problem
+	repairable  []int
+	lastAccount uint32
+	fixLast     bool
+	accounts    map[uint32]*dbBIP0044AccountRow
+	fixAccounts map[uint32]struct{}
+}
+
+func (c *checker) report(format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Desc: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) reportRepairable(format string, args ...interface{}) {
+	c.repairable = append(c.repairable, len(c.problems))
+	c.report(format, args...)
+}
+
+// Check verifies that every address stored by the manager can be loaded,
+// including decrypting any encrypted public data, and that it is stored under
+// the key for its address.  It also verifies that the last account number, the
+// next index of each account branch, and the account name indexes are
+// consistent with the stored accounts and addresses.  Private data is not
+// checked since the manager may be locked.
+//
+// When repair is true, inconsistent account counters and name indexes are
+// rewritten from the account and address rows.  Addresses which can not be
+// loaded are only reported since they can not be repaired without the keys
+// used to create them.
+func (m *Manager) Check(repair bool) ([]Problem, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c := &checker{
+		accounts:    make(map[uint32]*dbBIP0044AccountRow),
+		fixAccounts: make(map[uint32]struct{}),
+	}
+	err := m.namespace.View(func(tx walletdb.Tx) error {
+		if err := m.checkAccounts(tx, c); err != nil {
+			return err
+		}
+		return m.checkAddresses(tx, c)
+	})
+	if err != nil {
+		return nil, maybeConvertDbError(err)
+	}
+
+	if !repair || len(c.repairable) == 0 {
+		return c.problems, nil
+	}
+	err = m.namespace.Update(func(tx walletdb.Tx) error {
+		if c.fixLast {
+			err := putLastAccount(tx, c.lastAccount)
+			if err != nil {
+				return err
+			}
+		}
+		for account := range c.fixAccounts {
+			row := c.accounts[account]
+			err := putAccountInfo(tx, account, row.pubKeyEncrypted,
+				row.privKeyEncrypted, row.nextExternalIndex,
+				row.nextInternalIndex, row.name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, maybeConvertDbError(err)
+	}
+
+	// The cached account information must be reloaded from the repaired
+	// account rows.
+	for account := range c.fixAccounts {
+		delete(m.acctInfo, account)
+	}
+	for _, i := range c.repairable {
+		c.problems[i].Repaired = true
+	}
+	return c.problems, nil
+}
+
+// checkAccounts checks the account rows, name indexes, and last account number
+// of the manager.
+func (m *Manager) checkAccounts(tx walletdb.Tx, c *checker) error {
+	lastAccount, err := fetchLastAccount(tx)
+	if err != nil {
+		return err
+	}
+	c.lastAccount = lastAccount
+
+	return forEachAccount(tx, func(account uint32) error {
+		rowInterface, err := fetchAccountInfo(tx, account)
+		if err != nil {
+			c.report("account %d can not be read: %v", account, err)
+			return nil
+		}
+		row, ok := rowInterface.(*dbBIP0044AccountRow)
+		if !ok {
+			c.report("account %d has unsupported type %T", account,
+				rowInterface)
+			return nil
+		}
+		c.accounts[account] = row
+
+		if account != ImportedAddrAccount && account > c.lastAccount {
+			c.reportRepairable("account %d is greater than the last "+
+				"account number %d", account, c.lastAccount)
+			c.lastAccount = account
+			c.fixLast = true
+		}
+
+		name, err := fetchAccountName(tx, account)
+		if err != nil || name != row.name {
+			c.reportRepairable("account %d is not indexed by its "+
+				"name %q", account, row.name)
+			c.fixAccounts[account] = struct{}{}
+		}
+		named, err := fetchAccountByName(tx, row.name)
+		if err != nil || named != account {
+			c.reportRepairable("account name %q does not map to "+
+				"account %d", row.name, account)
+			c.fixAccounts[account] = struct{}{}
+		}
+
+		// The imported account has no extended keys.
+		if account == ImportedAddrAccount {
+			return nil
+		}
+		if _, err := m.loadAccountInfo(account); err != nil {
+			c.report("account %d keys can not be loaded: %v",
+				account, err)
+		}
+		return nil
+	})
+}
+
+// checkAddresses checks that every address row can be loaded and is stored
+// under the key for its address, and that the next indexes of each account are
+// beyond every chained address of the account.
+func (m *Manager) checkAddresses(tx walletdb.Tx, c *checker) error {
+	bucket := tx.RootBucket().Bucket(addrBucketName)
+	return bucket.ForEach(func(k, v []byte) error {
+		// Skip buckets.
+		if v == nil {
+			return nil
+		}
+
+		rowInterface, err := fetchAddressByHash(tx, k)
+		if err != nil {
+			c.report("address %x can not be read: %v", k, err)
+			return nil
+		}
+		managedAddr, err := m.rowInterfaceToManaged(rowInterface)
+		if err != nil {
+			c.report("address %x can not be loaded: %v", k, err)
+			return nil
+		}
+		addrHash := fastsha256.Sum256(managedAddr.Address().ScriptAddress())
+		if !bytes.Equal(addrHash[:], k) {
+			c.report("address %v is stored under the wrong key %x",
+				managedAddr.Address(), k)
+		}
+
+		row, ok := rowInterface.(*dbChainAddressRow)
+		if !ok {
+			return nil
+		}
+		acctRow, ok := c.accounts[row.account]
+		if !ok {
+			c.report("address %v belongs to missing account %d",
+				managedAddr.Address(), row.account)
+			return nil
+		}
+		next := &acctRow.nextExternalIndex
+		if row.branch == internalBranch {
+			next = &acctRow.nextInternalIndex
+		}
+		if row.index >= *next {
+			c.reportRepairable("account %d branch %d next index %d "+
+				"is not beyond address %v at index %d",
+				row.account, row.branch, *next,
+				managedAddr.Address(), row.index)
+			*next = row.index + 1
+			c.fixAccounts[row.account] = struct{}{}
+		}
+		return nil
+	})
+}
@@ -25,7 +25,10 @@ import (
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/internal/zero"
+	"github.com/btcsuite/btcwallet/snacl"
 	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
 	"github.com/btcsuite/fastsha256"
 )
 
@@ -1536,84 +1539,88 @@ func managerExists(namespace walletdb.Namespace) (bool, error) {
 // manager data.  This includes things such as all of the buckets as well as the
 // version and creation date.
 func createManagerNS(namespace walletdb.Namespace) error {
-	err := namespace.Update(func(tx walletdb.Tx) error {
-		rootBucket := tx.RootBucket()
-		mainBucket, err := rootBucket.CreateBucket(mainBucketName)
-		if err != nil {
-			str := "failed to create main bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	err := namespace.Update(initManager)
+	if err != nil {
+		str := "failed to update database"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(addrBucketName)
-		if err != nil {
-			str := "failed to create address bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	return nil
+}
 
-		_, err = rootBucket.CreateBucket(acctBucketName)
-		if err != nil {
-			str := "failed to create account bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+// initManager creates all of the buckets of the manager, and records the
+// latest version and the creation date, using the passed transaction.
+func initManager(tx walletdb.Tx) error {
+	rootBucket := tx.RootBucket()
+	mainBucket, err := rootBucket.CreateBucket(mainBucketName)
+	if err != nil {
+		str := "failed to create main bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(addrAcctIdxBucketName)
-		if err != nil {
-			str := "failed to create address index bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	_, err = rootBucket.CreateBucket(addrBucketName)
+	if err != nil {
+		str := "failed to create address bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(syncBucketName)
-		if err != nil {
-			str := "failed to create sync bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	_, err = rootBucket.CreateBucket(acctBucketName)
+	if err != nil {
+		str := "failed to create account bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		// usedAddrBucketName bucket was added after manager version 1 release
-		_, err = rootBucket.CreateBucket(usedAddrBucketName)
-		if err != nil {
-			str := "failed to create used addresses bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	_, err = rootBucket.CreateBucket(addrAcctIdxBucketName)
+	if err != nil {
+		str := "failed to create address index bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(acctNameIdxBucketName)
-		if err != nil {
-			str := "failed to create an account name index bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	_, err = rootBucket.CreateBucket(syncBucketName)
+	if err != nil {
+		str := "failed to create sync bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(acctIDIdxBucketName)
-		if err != nil {
-			str := "failed to create an account id index bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	// usedAddrBucketName bucket was added after manager version 1 release
+	_, err = rootBucket.CreateBucket(usedAddrBucketName)
+	if err != nil {
+		str := "failed to create used addresses bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(metaBucketName)
-		if err != nil {
-			str := "failed to create a meta bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	_, err = rootBucket.CreateBucket(acctNameIdxBucketName)
+	if err != nil {
+		str := "failed to create an account name index bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		if err := putLastAccount(tx, DefaultAccountNum); err != nil {
-			return err
-		}
+	_, err = rootBucket.CreateBucket(acctIDIdxBucketName)
+	if err != nil {
+		str := "failed to create an account id index bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		if err := putManagerVersion(tx, latestMgrVersion); err != nil {
-			return err
-		}
+	_, err = rootBucket.CreateBucket(metaBucketName)
+	if err != nil {
+		str := "failed to create a meta bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		createDate := uint64(time.Now().Unix())
-		var dateBytes [8]byte
-		binary.LittleEndian.PutUint64(dateBytes[:], createDate)
-		err = mainBucket.Put(mgrCreateDateName, dateBytes[:])
-		if err != nil {
-			str := "failed to store database creation time"
-			return managerError(ErrDatabase, str, err)
-		}
+	if err := putLastAccount(tx, DefaultAccountNum); err != nil {
+		return err
+	}
 
-		return nil
-	})
+	if err := putManagerVersion(tx, latestMgrVersion); err != nil {
+		return err
+	}
+
+	createDate := uint64(time.Now().Unix())
+	var dateBytes [8]byte
+	binary.LittleEndian.PutUint64(dateBytes[:], createDate)
+	err = mainBucket.Put(mgrCreateDateName, dateBytes[:])
 	if err != nil {
-		str := "failed to update database"
+		str := "failed to store database creation time"
 		return managerError(ErrDatabase, str, err)
 	}
 
@@ -1623,126 +1630,152 @@ func createManagerNS(namespace walletdb.Namespace) error {
 // upgradeToVersion2 upgrades the database from version 1 to version 2
 // 'usedAddrBucketName' a bucket for storing addrs flagged as marked is
 // initialized and it will be updated on the next rescan.
-func upgradeToVersion2(namespace walletdb.Namespace) error {
-	err := namespace.Update(func(tx walletdb.Tx) error {
-		currentMgrVersion := uint32(2)
-		rootBucket := tx.RootBucket()
-
-		_, err := rootBucket.CreateBucket(usedAddrBucketName)
-		if err != nil {
-			str := "failed to create used addresses bucket"
-			return managerError(ErrDatabase, str, err)
-		}
-
-		if err := putManagerVersion(tx, currentMgrVersion); err != nil {
-			return err
-		}
-
-		return nil
-	})
+func upgradeToVersion2(tx walletdb.Tx) error {
+	_, err := tx.RootBucket().CreateBucket(usedAddrBucketName)
 	if err != nil {
-		return maybeConvertDbError(err)
+		str := "failed to create used addresses bucket"
+		return managerError(ErrDatabase, str, err)
 	}
 	return nil
 }
 
+// MigrationSchema returns the migration schema of an address manager stored in
+// the namespace with the passed key.  It is used to upgrade the manager with
+// migration.Upgrade, along with the data of other packages, before it is
+// opened.  The upgrade to version 3 requires the seed and private passphrase,
+// which are only requested from the callbacks when that upgrade is run.
+func MigrationSchema(namespaceKey, pubPassPhrase []byte, chainParams *chaincfg.Params, cbs *OpenCallbacks) *migration.Schema {
+	return &migration.Schema{
+		Name:          "waddrmgr",
+		Namespace:     namespaceKey,
+		LatestVersion: latestMgrVersion,
+		Version: func(tx walletdb.Tx) (uint32, error) {
+			// No version is recorded until the manager is created.
+			if tx.RootBucket().Bucket(mainBucketName) == nil {
+				return 0, nil
+			}
+			return fetchManagerVersion(tx)
+		},
+		SetVersion: putManagerVersion,
+		Migrations: []migration.Migration{{
+			Version: 2,
+			Name:    "add used addresses bucket",
+			Migrate: upgradeToVersion2,
+		}, {
+			Version: 3,
+			Name:    "add account names and cointype keys",
+			Migrate: func(tx walletdb.Tx) error {
+				if cbs == nil || cbs.ObtainSeed == nil || cbs.ObtainPrivatePass == nil {
+					str := "failed to obtain seed and private passphrase required for upgrade"
+					return managerError(ErrDatabase, str, nil)
+				}
+
+				seed, err := cbs.ObtainSeed()
+				if err != nil {
+					return err
+				}
+				privPassPhrase, err := cbs.ObtainPrivatePass()
+				if err != nil {
+					return err
+				}
+				return upgradeToVersion3(tx, seed, privPassPhrase,
+					pubPassPhrase, chainParams)
+			},
+		}, {
+			Version: 4,
+			Name:    "remove default account name alias",
+			Migrate: upgradeToVersion4,
+		}},
+	}
+}
+
 // upgradeManager upgrades the data in the provided manager namespace to newer
 // versions as neeeded.
 func upgradeManager(namespace walletdb.Namespace, pubPassPhrase []byte, chainParams *chaincfg.Params, cbs *OpenCallbacks) error {
-	var version uint32
-	err := namespace.View(func(tx walletdb.Tx) error {
-		var err error
-		version, err = fetchManagerVersion(tx)
-		return err
-	})
-	if err != nil {
-		str := "failed to fetch version for update"
-		return managerError(ErrDatabase, str, err)
-	}
-
-	// NOTE: There are currently no upgrades, but this is provided here as a
-	// template for how to properly do upgrades.  Each function to upgrade
-	// to the next version must include serializing the new version as a
-	// part of the same transaction so any failures in upgrades to later
-	// versions won't leave the database in an inconsistent state.  The
-	// putManagerVersion function provides a convenient mechanism for that
-	// purpose.
-	//
-	// Upgrade one version at a time so it is possible to upgrade across
-	// an aribtary number of versions without needing to write a bunch of
-	// additional code to go directly from version X to Y.
-	// if version < 2 {
-	// 	// Upgrade from version 1 to 2.
-	//	if err := upgradeToVersion2(namespace); err != nil {
-	//		return err
-	//	}
+	// Each upgrade to the next version is a migration of the schema, which
+	// are all run in a single transaction so any failures in upgrades to
+	// later versions won't leave the database in an inconsistent state.
+	// The new version is written by the migration package after each
+	// migration, so new upgrades only need to modify the data.
 	//
-	//	// The manager is now at version 2.
-	//	version = 2
-	// }
-	// if version < 3 {
-	// 	// Upgrade from version 2 to 3.
-	//	if err := upgradeToVersion3(namespace); err != nil {
-	//		return err
-	//	}
-	//
-	//	// The manager is now at version 3.
-	//	version = 3
-	// }
-
-	if version < 2 {
-		// Upgrade from version 1 to 2.
-		if err := upgradeToVersion2(namespace); err != nil {
-			return err
-		}
-
-		// The manager is now at version 2.
-		version = 2
+	// The namespace key of the schema is only used when upgrading the
+	// entire database and is not needed here.
+	schema := MigrationSchema(nil, pubPassPhrase, chainParams, cbs)
+	_, err := migration.UpgradeNamespace(namespace, schema, false)
+	if err == nil {
+		return nil
 	}
 
-	if version < 3 {
-		if cbs == nil || cbs.ObtainSeed == nil || cbs.ObtainPrivatePass == nil {
-			str := "failed to obtain seed and private passphrase required for upgrade"
-			return managerError(ErrDatabase, str, err)
-		}
-
-		seed, err := cbs.ObtainSeed()
-		if err != nil {
-			return err
-		}
-		privPassPhrase, err := cbs.ObtainPrivatePass()
-		if err != nil {
-			return err
-		}
-		// Upgrade from version 2 to 3.
-		if err := upgradeToVersion3(namespace, seed, privPassPhrase, pubPassPhrase, chainParams); err != nil {
-			return err
+	// Ensure the manager is upgraded to the latest version.  The missing
+	// migration error intentionally causes a failure if the manager
+	// version is updated without writing code to handle the upgrade.
+	if merr, ok := err.(migration.Error); ok {
+		switch merr.Code {
+		case migration.ErrMissingMigration, migration.ErrUnknownVersion:
+			return managerError(ErrUpgrade, merr.Desc, nil)
 		}
+		return managerError(ErrDatabase, merr.Desc, merr.Err)
+	}
+	return maybeConvertDbError(err)
+}
 
-		// The manager is now at version 3.
-		version = 3
+// upgradeCryptoKeys derives the master keys from the passed passphrases and
+// returns the decrypted crypto public and private keys.  The returned keys must
+// be zeroed by the caller.
+func upgradeCryptoKeys(tx walletdb.Tx, privPassPhrase, pubPassPhrase []byte) (*cryptoKey, *cryptoKey, error) {
+	masterKeyPubParams, masterKeyPrivParams, err := fetchMasterKeyParams(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	cryptoKeyPubEnc, cryptoKeyPrivEnc, _, err := fetchCryptoKeys(tx)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if version < 4 {
-		if err := upgradeToVersion4(namespace, pubPassPhrase); err != nil {
-			return err
+	var masterKeyPub, masterKeyPriv snacl.SecretKey
+	defer masterKeyPub.Zero()
+	defer masterKeyPriv.Zero()
+	if err := masterKeyPub.Unmarshal(masterKeyPubParams); err != nil {
+		str := "failed to unmarshal master public key"
+		return nil, nil, managerError(ErrCrypto, str, err)
+	}
+	if err := masterKeyPub.DeriveKey(&pubPassPhrase); err != nil {
+		str := "invalid passphrase for master public key"
+		return nil, nil, managerError(ErrWrongPassphrase, str, nil)
+	}
+	if err := masterKeyPriv.Unmarshal(masterKeyPrivParams); err != nil {
+		str := "failed to unmarshal master private key"
+		return nil, nil, managerError(ErrCrypto, str, err)
+	}
+	if err := masterKeyPriv.DeriveKey(&privPassPhrase); err != nil {
+		if err == snacl.ErrInvalidPassword {
+			str := "invalid passphrase for master private key"
+			return nil, nil, managerError(ErrWrongPassphrase, str, nil)
 		}
+		str := "failed to derive master private key"
+		return nil, nil, managerError(ErrCrypto, str, err)
+	}
 
-		// The manager is now at version 4.
-		version = 4
+	cryptoKeyPubCT, err := masterKeyPub.Decrypt(cryptoKeyPubEnc)
+	if err != nil {
+		str := "failed to decrypt crypto public key"
+		return nil, nil, managerError(ErrCrypto, str, err)
 	}
+	cryptoKeyPub := &cryptoKey{snacl.CryptoKey{}}
+	cryptoKeyPub.CopyBytes(cryptoKeyPubCT)
+	zero.Bytes(cryptoKeyPubCT)
 
-	// Ensure the manager is upraded to the latest version.  This check is
-	// to intentionally cause a failure if the manager version is updated
-	// without writing code to handle the upgrade.
-	if version < latestMgrVersion {
-		str := fmt.Sprintf("the latest manager version is %d, but the "+
-			"current version after upgrades is only %d",
-			latestMgrVersion, version)
-		return managerError(ErrUpgrade, str, nil)
+	cryptoKeyPrivCT, err := masterKeyPriv.Decrypt(cryptoKeyPrivEnc)
+	if err != nil {
+		cryptoKeyPub.Zero()
+		str := "failed to decrypt crypto private key"
+		return nil, nil, managerError(ErrCrypto, str, err)
 	}
+	cryptoKeyPriv := &cryptoKey{snacl.CryptoKey{}}
+	cryptoKeyPriv.CopyBytes(cryptoKeyPrivCT)
+	zero.Bytes(cryptoKeyPrivCT)
 
-	return nil
+	return cryptoKeyPub, cryptoKeyPriv, nil
 }
 
 // upgradeToVersion3 upgrades the database from version 2 to version 3
@@ -1750,209 +1783,180 @@ func upgradeManager(namespace walletdb.Namespace, pubPassPhrase []byte, chainPar
 // * acctNameIdxBucketName
 // * acctIDIdxBucketName
 // * metaBucketName
-func upgradeToVersion3(namespace walletdb.Namespace, seed, privPassPhrase, pubPassPhrase []byte, chainParams *chaincfg.Params) error {
-	err := namespace.Update(func(tx walletdb.Tx) error {
-		currentMgrVersion := uint32(3)
-		rootBucket := tx.RootBucket()
+func upgradeToVersion3(tx walletdb.Tx, seed, privPassPhrase, pubPassPhrase []byte, chainParams *chaincfg.Params) error {
+	rootBucket := tx.RootBucket()
 
-		woMgr, err := loadManager(namespace, pubPassPhrase, chainParams)
-		if err != nil {
-			return err
-		}
-		defer woMgr.Close()
-
-		err = woMgr.Unlock(privPassPhrase)
-		if err != nil {
-			return err
-		}
-
-		// Derive the master extended key from the seed.
-		root, err := hdkeychain.NewMaster(seed)
-		if err != nil {
-			str := "failed to derive master extended key"
-			return managerError(ErrKeyChain, str, err)
-		}
-
-		// Derive the cointype key according to BIP0044.
-		coinTypeKeyPriv, err := deriveCoinTypeKey(root, chainParams.HDCoinType)
-		if err != nil {
-			str := "failed to derive cointype extended key"
-			return managerError(ErrKeyChain, str, err)
-		}
+	cryptoKeyPub, cryptoKeyPriv, err := upgradeCryptoKeys(tx,
+		privPassPhrase, pubPassPhrase)
+	if err != nil {
+		return err
+	}
+	defer cryptoKeyPub.Zero()
+	defer cryptoKeyPriv.Zero()
 
-		cryptoKeyPub := woMgr.cryptoKeyPub
-		cryptoKeyPriv := woMgr.cryptoKeyPriv
-		// Encrypt the cointype keys with the associated crypto keys.
-		coinTypeKeyPub, err := coinTypeKeyPriv.Neuter()
-		if err != nil {
-			str := "failed to convert cointype private key"
-			return managerError(ErrKeyChain, str, err)
-		}
-		coinTypePubEnc, err := cryptoKeyPub.Encrypt([]byte(coinTypeKeyPub.String()))
-		if err != nil {
-			str := "failed to encrypt cointype public key"
-			return managerError(ErrCrypto, str, err)
-		}
-		coinTypePrivEnc, err := cryptoKeyPriv.Encrypt([]byte(coinTypeKeyPriv.String()))
-		if err != nil {
-			str := "failed to encrypt cointype private key"
-			return managerError(ErrCrypto, str, err)
-		}
+	// Derive the master extended key from the seed.
+	root, err := hdkeychain.NewMaster(seed)
+	if err != nil {
+		str := "failed to derive master extended key"
+		return managerError(ErrKeyChain, str, err)
+	}
 
-		// Save the encrypted cointype keys to the database.
-		err = putCoinTypeKeys(tx, coinTypePubEnc, coinTypePrivEnc)
-		if err != nil {
-			return err
-		}
+	// Derive the cointype key according to BIP0044.
+	coinTypeKeyPriv, err := deriveCoinTypeKey(root, chainParams.HDCoinType)
+	if err != nil {
+		str := "failed to derive cointype extended key"
+		return managerError(ErrKeyChain, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(acctNameIdxBucketName)
-		if err != nil {
-			str := "failed to create an account name index bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	// Encrypt the cointype keys with the associated crypto keys.
+	coinTypeKeyPub, err := coinTypeKeyPriv.Neuter()
+	if err != nil {
+		str := "failed to convert cointype private key"
+		return managerError(ErrKeyChain, str, err)
+	}
+	coinTypePubEnc, err := cryptoKeyPub.Encrypt([]byte(coinTypeKeyPub.String()))
+	if err != nil {
+		str := "failed to encrypt cointype public key"
+		return managerError(ErrCrypto, str, err)
+	}
+	coinTypePrivEnc, err := cryptoKeyPriv.Encrypt([]byte(coinTypeKeyPriv.String()))
+	if err != nil {
+		str := "failed to encrypt cointype private key"
+		return managerError(ErrCrypto, str, err)
+	}
 
-		_, err = rootBucket.CreateBucket(acctIDIdxBucketName)
-		if err != nil {
-			str := "failed to create an account id index bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	// Save the encrypted cointype keys to the database.
+	err = putCoinTypeKeys(tx, coinTypePubEnc, coinTypePrivEnc)
+	if err != nil {
+		return err
+	}
 
-		_, err = rootBucket.CreateBucket(metaBucketName)
-		if err != nil {
-			str := "failed to create a meta bucket"
-			return managerError(ErrDatabase, str, err)
-		}
+	_, err = rootBucket.CreateBucket(acctNameIdxBucketName)
+	if err != nil {
+		str := "failed to create an account name index bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		// Initialize metadata for all keys
-		if err := putLastAccount(tx, DefaultAccountNum); err != nil {
-			return err
-		}
+	_, err = rootBucket.CreateBucket(acctIDIdxBucketName)
+	if err != nil {
+		str := "failed to create an account id index bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		// Update default account indexes
-		if err := putAccountIDIndex(tx, DefaultAccountNum, defaultAccountName); err != nil {
-			return err
-		}
-		if err := putAccountNameIndex(tx, DefaultAccountNum, defaultAccountName); err != nil {
-			return err
-		}
-		// Update imported account indexes
-		if err := putAccountIDIndex(tx, ImportedAddrAccount, ImportedAddrAccountName); err != nil {
-			return err
-		}
-		if err := putAccountNameIndex(tx, ImportedAddrAccount, ImportedAddrAccountName); err != nil {
-			return err
-		}
+	_, err = rootBucket.CreateBucket(metaBucketName)
+	if err != nil {
+		str := "failed to create a meta bucket"
+		return managerError(ErrDatabase, str, err)
+	}
 
-		// Write current manager version
-		if err := putManagerVersion(tx, currentMgrVersion); err != nil {
-			return err
-		}
+	// Initialize metadata for all keys
+	if err := putLastAccount(tx, DefaultAccountNum); err != nil {
+		return err
+	}
 
-		// Save "" alias for default account name for backward compat
-		return putAccountNameIndex(tx, DefaultAccountNum, "")
-	})
-	if err != nil {
-		return maybeConvertDbError(err)
+	// Update default account indexes
+	if err := putAccountIDIndex(tx, DefaultAccountNum, defaultAccountName); err != nil {
+		return err
 	}
-	return nil
+	if err := putAccountNameIndex(tx, DefaultAccountNum, defaultAccountName); err != nil {
+		return err
+	}
+	// Update imported account indexes
+	if err := putAccountIDIndex(tx, ImportedAddrAccount, ImportedAddrAccountName); err != nil {
+		return err
+	}
+	if err := putAccountNameIndex(tx, ImportedAddrAccount, ImportedAddrAccountName); err != nil {
+		return err
+	}
+
+	// Save "" alias for default account name for backward compat
+	return putAccountNameIndex(tx, DefaultAccountNum, "")
 }
 
 // upgradeToVersion4 upgrades the database from version 3 to version 4.  The
 // default account remains unchanged (even if it was modified by the user), but
 // the empty string alias to the default account is removed.
-func upgradeToVersion4(namespace walletdb.Namespace, pubPassPhrase []byte) error {
-	err := namespace.Update(func(tx walletdb.Tx) error {
-		// Write new manager version.
-		err := putManagerVersion(tx, 4)
-		if err != nil {
-			return err
-		}
-
-		// Lookup the old account info to determine the real name of the
-		// default account.  All other names will be removed.
-		acctInfoIface, err := fetchAccountInfo(tx, DefaultAccountNum)
-		if err != nil {
-			return err
-		}
-		acctInfo, ok := acctInfoIface.(*dbBIP0044AccountRow)
-		if !ok {
-			str := fmt.Sprintf("unsupported account type %T", acctInfoIface)
-			return managerError(ErrDatabase, str, nil)
-		}
-
-		var oldName string
-
-		// Delete any other names for the default account.
-		c := tx.RootBucket().Bucket(acctNameIdxBucketName).Cursor()
-		for k, v := c.First(); k != nil; k, v = c.Next() {
-			// Skip nested buckets.
-			if v == nil {
-				continue
-			}
-
-			// Skip account names which aren't for the default account.
-			account := binary.LittleEndian.Uint32(v)
-			if account != DefaultAccountNum {
-				continue
-			}
+func upgradeToVersion4(tx walletdb.Tx) error {
+	// Lookup the old account info to determine the real name of the
+	// default account.  All other names will be removed.
+	acctInfoIface, err := fetchAccountInfo(tx, DefaultAccountNum)
+	if err != nil {
+		return err
+	}
+	acctInfo, ok := acctInfoIface.(*dbBIP0044AccountRow)
+	if !ok {
+		str := fmt.Sprintf("unsupported account type %T", acctInfoIface)
+		return managerError(ErrDatabase, str, nil)
+	}
 
-			if !bytes.Equal(k[4:], []byte(acctInfo.name)) {
-				err := c.Delete()
-				if err != nil {
-					const str = "error deleting default account alias"
-					return managerError(ErrUpgrade, str, err)
-				}
-				oldName = string(k[4:])
-				break
-			}
-		}
+	var oldName string
 
-		// The account number to name index may map to the wrong name,
-		// so rewrite the entry with the true name from the account row
-		// instead of leaving it set to an incorrect alias.
-		err = putAccountIDIndex(tx, DefaultAccountNum, acctInfo.name)
-		if err != nil {
-			const str = "account number to name index could not be " +
-				"rewritten with actual account name"
-			return managerError(ErrUpgrade, str, err)
+	// Delete any other names for the default account.
+	c := tx.RootBucket().Bucket(acctNameIdxBucketName).Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		// Skip nested buckets.
+		if v == nil {
+			continue
 		}
 
-		// Ensure that the true name for the default account maps
-		// forwards and backwards to the default account number.
-		name, err := fetchAccountName(tx, DefaultAccountNum)
-		if err != nil {
-			return err
-		}
-		if name != acctInfo.name {
-			const str = "account name index does not map default account number to correct name"
-			return managerError(ErrUpgrade, str, nil)
-		}
-		acct, err := fetchAccountByName(tx, acctInfo.name)
-		if err != nil {
-			return err
-		}
-		if acct != DefaultAccountNum {
-			const str = "default account not accessible under correct name"
-			return managerError(ErrUpgrade, str, nil)
+		// Skip account names which aren't for the default account.
+		account := binary.LittleEndian.Uint32(v)
+		if account != DefaultAccountNum {
+			continue
 		}
 
-		// Ensure that looking up the default account by the old name
-		// cannot succeed.
-		_, err = fetchAccountByName(tx, oldName)
-		if err == nil {
-			const str = "default account exists under old name"
-			return managerError(ErrUpgrade, str, nil)
-		} else {
-			merr, ok := err.(ManagerError)
-			if !ok || merr.ErrorCode != ErrAccountNotFound {
-				return err
+		if !bytes.Equal(k[4:], []byte(acctInfo.name)) {
+			err := c.Delete()
+			if err != nil {
+				const str = "error deleting default account alias"
+				return managerError(ErrUpgrade, str, err)
 			}
+			oldName = string(k[4:])
+			break
 		}
+	}
 
-		return nil
-	})
+	// The account number to name index may map to the wrong name,
+	// so rewrite the entry with the true name from the account row
+	// instead of leaving it set to an incorrect alias.
+	err = putAccountIDIndex(tx, DefaultAccountNum, acctInfo.name)
 	if err != nil {
-		return maybeConvertDbError(err)
+		const str = "account number to name index could not be " +
+			"rewritten with actual account name"
+		return managerError(ErrUpgrade, str, err)
 	}
+
+	// Ensure that the true name for the default account maps
+	// forwards and backwards to the default account number.
+	name, err := fetchAccountName(tx, DefaultAccountNum)
+	if err != nil {
+		return err
+	}
+	if name != acctInfo.name {
+		const str = "account name index does not map default account number to correct name"
+		return managerError(ErrUpgrade, str, nil)
+	}
+	acct, err := fetchAccountByName(tx, acctInfo.name)
+	if err != nil {
+		return err
+	}
+	if acct != DefaultAccountNum {
+		const str = "default account not accessible under correct name"
+		return managerError(ErrUpgrade, str, nil)
+	}
+
+	// Ensure that looking up the default account by the old name
+	// cannot succeed.
+	_, err = fetchAccountByName(tx, oldName)
+	if err == nil {
+		const str = "default account exists under old name"
+		return managerError(ErrUpgrade, str, nil)
+	} else {
+		merr, ok := err.(ManagerError)
+		if !ok || merr.ErrorCode != ErrAccountNotFound {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,375 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package waddrmgr
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// Address types written to dumps.
+const (
+	DumpChainedAddress  = "chained"
+	DumpImportedAddress = "imported"
+	DumpScriptAddress   = "script"
+)
+
+// Dump is a logical description of an address manager, read with ReadDump and
+// restored with WriteDump.  Keys, scripts, and script hashes are kept
+// encrypted exactly as they are stored, so a dump holds no more secrets than
+// the database it was read from.  Addresses are identified by the SHA256 hash
+// of their address ID, since the address itself is only stored encrypted or
+// derived from an account key.
+type Dump struct {
+	MasterKeyPubParams  []byte `json:"masterKeyPubParams"`
+	MasterKeyPrivParams []byte `json:"masterKeyPrivParams,omitempty"`
+	CryptoKeyPubEnc     []byte `json:"cryptoKeyPubEnc"`
+	CryptoKeyPrivEnc    []byte `json:"cryptoKeyPrivEnc,omitempty"`
+	CryptoKeyScriptEnc  []byte `json:"cryptoKeyScriptEnc,omitempty"`
+	CoinTypePubKeyEnc   []byte `json:"coinTypePubKeyEnc"`
+	CoinTypePrivKeyEnc  []byte `json:"coinTypePrivKeyEnc,omitempty"`
+	WatchingOnly        bool   `json:"watchingOnly"`
+
+	SyncedTo     BlockStampDump `json:"syncedTo"`
+	StartBlock   BlockStampDump `json:"startBlock"`
+	RecentHeight int32          `json:"recentHeight"`
+	RecentHashes []string       `json:"recentHashes"`
+
+	LastAccount uint32        `json:"lastAccount"`
+	Accounts    []AccountDump `json:"accounts"`
+	Addresses   []AddressDump `json:"addresses"`
+}
+
+// BlockStampDump describes a block stamp of a dump.
+type BlockStampDump struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+}
+
+// AccountDump describes an account of a dump.  The imported account has no
+// keys.
+type AccountDump struct {
+	Account           uint32 `json:"account"`
+	Name              string `json:"name"`
+	PubKeyEnc         []byte `json:"pubKeyEnc,omitempty"`
+	PrivKeyEnc        []byte `json:"privKeyEnc,omitempty"`
+	NextExternalIndex uint32 `json:"nextExternalIndex"`
+	NextInternalIndex uint32 `json:"nextInternalIndex"`
+}
+
+// AddressDump describes an address of a dump.  Type is one of
+// DumpChainedAddress, DumpImportedAddress, or DumpScriptAddress, and only the
+// fields of that type are set.
+type AddressDump struct {
+	Hash       []byte `json:"hash"`
+	Type       string `json:"type"`
+	Account    uint32 `json:"account"`
+	AddTime    uint64 `json:"addTime"`
+	SyncStatus uint8  `json:"syncStatus"`
+	Used       bool   `json:"used"`
+
+	// Chained addresses.
+	Branch uint32 `json:"branch,omitempty"`
+	Index  uint32 `json:"index,omitempty"`
+
+	// Imported addresses.
+	PubKeyEnc  []byte `json:"pubKeyEnc,omitempty"`
+	PrivKeyEnc []byte `json:"privKeyEnc,omitempty"`
+
+	// Script addresses.
+	ScriptHashEnc []byte `json:"scriptHashEnc,omitempty"`
+	ScriptEnc     []byte `json:"scriptEnc,omitempty"`
+}
+
+// dumpBlockStamp returns the dump description of a block stamp.
+func dumpBlockStamp(bs *BlockStamp) BlockStampDump {
+	return BlockStampDump{Height: bs.Height, Hash: bs.Hash.String()}
+}
+
+// blockStamp returns the block stamp described by a dump.
+func (bs *BlockStampDump) blockStamp() (*BlockStamp, error) {
+	hash, err := wire.NewShaHashFromStr(bs.Hash)
+	if err != nil {
+		str := fmt.Sprintf("invalid block hash %q", bs.Hash)
+		return nil, managerError(ErrDatabase, str, err)
+	}
+	return &BlockStamp{Height: bs.Height, Hash: *hash}, nil
+}
+
+// ReadDump returns the dump of the address manager in the namespace accessed by
+// tx.  ErrNoExist is returned when no manager has been created, and ErrUpgrade
+// when the manager must be upgraded to the latest version before it can be
+// dumped.
+func ReadDump(tx walletdb.Tx) (*Dump, error) {
+	mainBucket := tx.RootBucket().Bucket(mainBucketName)
+	if mainBucket == nil {
+		str := "the address manager does not exist"
+		return nil, managerError(ErrNoExist, str, nil)
+	}
+	version, err := fetchManagerVersion(tx)
+	if err != nil {
+		return nil, err
+	}
+	if version != latestMgrVersion {
+		str := fmt.Sprintf("the address manager must be upgraded from "+
+			"version %d to version %d before it is dumped", version,
+			latestMgrVersion)
+		return nil, managerError(ErrUpgrade, str, nil)
+	}
+
+	var d Dump
+	d.MasterKeyPubParams, d.MasterKeyPrivParams, err = fetchMasterKeyParams(tx)
+	if err != nil {
+		return nil, err
+	}
+	d.CryptoKeyPubEnc, d.CryptoKeyPrivEnc, d.CryptoKeyScriptEnc, err =
+		fetchCryptoKeys(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cointype private key is removed from watching-only managers, so
+	// it is read directly rather than with fetchCoinTypeKeys.
+	d.CoinTypePubKeyEnc = copyBytes(mainBucket.Get(coinTypePubKeyName))
+	d.CoinTypePrivKeyEnc = copyBytes(mainBucket.Get(coinTypePrivKeyName))
+	if d.CoinTypePubKeyEnc == nil {
+		str := "required encrypted cointype public key not stored in database"
+		return nil, managerError(ErrDatabase, str, nil)
+	}
+	d.WatchingOnly, err = fetchWatchingOnly(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	syncedTo, err := fetchSyncedTo(tx)
+	if err != nil {
+		return nil, err
+	}
+	d.SyncedTo = dumpBlockStamp(syncedTo)
+	startBlock, err := fetchStartBlock(tx)
+	if err != nil {
+		return nil, err
+	}
+	d.StartBlock = dumpBlockStamp(startBlock)
+	recentHeight, recentHashes, err := fetchRecentBlocks(tx)
+	if err != nil {
+		return nil, err
+	}
+	d.RecentHeight = recentHeight
+	d.RecentHashes = make([]string, len(recentHashes))
+	for i := range recentHashes {
+		d.RecentHashes[i] = recentHashes[i].String()
+	}
+
+	d.LastAccount, err = fetchLastAccount(tx)
+	if err != nil {
+		return nil, err
+	}
+	err = forEachAccount(tx, func(account uint32) error {
+		row, err := fetchAccountInfo(tx, account)
+		if err != nil {
+			return err
+		}
+		arow, ok := row.(*dbBIP0044AccountRow)
+		if !ok {
+			str := fmt.Sprintf("unsupported account type %T", row)
+			return managerError(ErrDatabase, str, nil)
+		}
+		d.Accounts = append(d.Accounts, AccountDump{
+			Account:           account,
+			Name:              arow.name,
+			PubKeyEnc:         copyBytes(arow.pubKeyEncrypted),
+			PrivKeyEnc:        copyBytes(arow.privKeyEncrypted),
+			NextExternalIndex: arow.nextExternalIndex,
+			NextInternalIndex: arow.nextInternalIndex,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, maybeConvertDbError(err)
+	}
+
+	usedBucket := tx.RootBucket().Bucket(usedAddrBucketName)
+	err = tx.RootBucket().Bucket(addrBucketName).ForEach(func(k, v []byte) error {
+		// Skip buckets.
+		if v == nil {
+			return nil
+		}
+		row, err := fetchAddressByHash(tx, k)
+		if err != nil {
+			return err
+		}
+		addr := AddressDump{
+			Hash: copyBytes(k),
+			Used: usedBucket.Get(k) != nil,
+		}
+		var common *dbAddressRow
+		switch row := row.(type) {
+		case *dbChainAddressRow:
+			common = &row.dbAddressRow
+			addr.Type = DumpChainedAddress
+			addr.Branch = row.branch
+			addr.Index = row.index
+		case *dbImportedAddressRow:
+			common = &row.dbAddressRow
+			addr.Type = DumpImportedAddress
+			addr.PubKeyEnc = copyBytes(row.encryptedPubKey)
+			addr.PrivKeyEnc = copyBytes(row.encryptedPrivKey)
+		case *dbScriptAddressRow:
+			common = &row.dbAddressRow
+			addr.Type = DumpScriptAddress
+			addr.ScriptHashEnc = copyBytes(row.encryptedHash)
+			addr.ScriptEnc = copyBytes(row.encryptedScript)
+		}
+		addr.Account = common.account
+		addr.AddTime = common.addTime
+		addr.SyncStatus = uint8(common.syncStatus)
+		d.Addresses = append(d.Addresses, addr)
+		return nil
+	})
+	if err != nil {
+		return nil, maybeConvertDbError(err)
+	}
+
+	return &d, nil
+}
+
+// WriteDump creates an address manager in the empty namespace accessed by tx
+// from a dump, at the latest version.  ErrAlreadyExists is returned if a
+// manager already exists in the namespace.
+func WriteDump(tx walletdb.Tx, d *Dump) error {
+	if tx.RootBucket().Bucket(mainBucketName) != nil {
+		str := "the address manager already exists"
+		return managerError(ErrAlreadyExists, str, nil)
+	}
+	if err := initManager(tx); err != nil {
+		return err
+	}
+
+	err := putMasterKeyParams(tx, d.MasterKeyPubParams, d.MasterKeyPrivParams)
+	if err != nil {
+		return err
+	}
+	err = putCryptoKeys(tx, d.CryptoKeyPubEnc, d.CryptoKeyPrivEnc,
+		d.CryptoKeyScriptEnc)
+	if err != nil {
+		return err
+	}
+	err = putCoinTypeKeys(tx, d.CoinTypePubKeyEnc, d.CoinTypePrivKeyEnc)
+	if err != nil {
+		return err
+	}
+	if err := putWatchingOnly(tx, d.WatchingOnly); err != nil {
+		return err
+	}
+
+	syncedTo, err := d.SyncedTo.blockStamp()
+	if err != nil {
+		return err
+	}
+	if err := putSyncedTo(tx, syncedTo); err != nil {
+		return err
+	}
+	startBlock, err := d.StartBlock.blockStamp()
+	if err != nil {
+		return err
+	}
+	if err := putStartBlock(tx, startBlock); err != nil {
+		return err
+	}
+	recentHashes := make([]wire.ShaHash, len(d.RecentHashes))
+	for i, s := range d.RecentHashes {
+		hash, err := wire.NewShaHashFromStr(s)
+		if err != nil {
+			str := fmt.Sprintf("invalid block hash %q", s)
+			return managerError(ErrDatabase, str, err)
+		}
+		recentHashes[i] = *hash
+	}
+	if err := putRecentBlocks(tx, d.RecentHeight, recentHashes); err != nil {
+		return err
+	}
+
+	if err := putLastAccount(tx, d.LastAccount); err != nil {
+		return err
+	}
+	for i := range d.Accounts {
+		a := &d.Accounts[i]
+		err := putAccountInfo(tx, a.Account, a.PubKeyEnc, a.PrivKeyEnc,
+			a.NextExternalIndex, a.NextInternalIndex, a.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	addrBucket := tx.RootBucket().Bucket(addrBucketName)
+	usedBucket := tx.RootBucket().Bucket(usedAddrBucketName)
+	for i := range d.Addresses {
+		a := &d.Addresses[i]
+		row := dbAddressRow{
+			account:    a.Account,
+			addTime:    a.AddTime,
+			syncStatus: syncStatus(a.SyncStatus),
+		}
+		switch a.Type {
+		case DumpChainedAddress:
+			row.addrType = adtChain
+			row.rawData = serializeChainedAddress(a.Branch, a.Index)
+		case DumpImportedAddress:
+			row.addrType = adtImport
+			row.rawData = serializeImportedAddress(a.PubKeyEnc,
+				a.PrivKeyEnc)
+		case DumpScriptAddress:
+			row.addrType = adtScript
+			row.rawData = serializeScriptAddress(a.ScriptHashEnc,
+				a.ScriptEnc)
+		default:
+			str := fmt.Sprintf("unsupported address type %q", a.Type)
+			return managerError(ErrDatabase, str, nil)
+		}
+
+		// Addresses are written by the hash of their ID, which is
+		// all that is known of them, rather than with putAddress.
+		err := addrBucket.Put(a.Hash, serializeAddressRow(&row))
+		if err != nil {
+			str := fmt.Sprintf("failed to store address hash %x", a.Hash)
+			return managerError(ErrDatabase, str, err)
+		}
+		if err := putAddrAccountIndex(tx, a.Account, a.Hash); err != nil {
+			return err
+		}
+		if a.Used {
+			err := usedBucket.Put(a.Hash, []byte{0})
+			if err != nil {
+				str := fmt.Sprintf("failed to mark address hash "+
+					"%x used", a.Hash)
+				return managerError(ErrDatabase, str, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// copyBytes returns a copy of b, or nil if b is nil, so values read from the
+// database remain valid after the transaction ends.
+func copyBytes(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
@@ -1365,6 +1365,24 @@ func testManagerAPI(tc *testContext) {
 	// Rename account 1 "acct-create"
 	tc.account = 1
 	testRenameAccount(tc)
+
+	testCheck(tc)
+}
+
+// testCheck tests that checking the consistency of the manager database finds
+// no problems after all other API tests have modified it.
+func testCheck(tc *testContext) bool {
+	prefix := testNamePrefix(tc) + " testCheck"
+	problems, err := tc.manager.Check(false)
+	if err != nil {
+		tc.t.Errorf("%s: unexpected error: %v", prefix, err)
+		return false
+	}
+	if len(problems) != 0 {
+		tc.t.Errorf("%s: unexpected problems: %v", prefix, problems)
+		return false
+	}
+	return true
 }
 
 // testWatchingOnly tests various facets of a watching-only address
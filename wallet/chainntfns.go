@@ -17,6 +17,7 @@
 package wallet
 
 import (
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcwallet/chain"
 	"github.com/btcsuite/btcwallet/waddrmgr"
@@ -80,6 +81,11 @@ func (w *Wallet) connectBlock(b wtxmgr.BlockMeta) {
 	w.notifyConnectedBlock(b)
 
 	w.notifyBalances(bs.Height)
+	w.notifyMaturedCoinbases(bs.Height)
+
+	// Expired outpoint locks no longer lock their outputs.  Remove them as
+	// each block is connected so they do not accumulate.
+	w.removeExpiredLocks()
 }
 
 // disconnectBlock handles a chain server reorganize by rolling back all
@@ -156,11 +162,16 @@ func (w *Wallet) addRelevantTx(rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) er
 	//      transactions bucket.  This currently seems like the best
 	//      solution.
 
-	// At the moment all notified transactions are assumed to actually be
-	// relevant.  This assumption will not hold true when SPV support is
-	// added, but until then, simply insert the transaction because there
-	// should either be one or more relevant inputs or outputs.
-	err := w.TxStore.InsertTx(rec, block)
+	// Several wallets may share a single chain server connection, in which
+	// case a notified transaction may only be relevant to another wallet.
+	// Skip the transaction unless it has one or more relevant inputs or
+	// outputs.
+	relevant, err := w.isRelevantTx(rec)
+	if err != nil || !relevant {
+		return err
+	}
+
+	err = w.TxStore.InsertTx(rec, block)
 	if err != nil {
 		return err
 	}
@@ -202,6 +213,8 @@ func (w *Wallet) addRelevantTx(rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) er
 	}
 
 	// TODO: Notify connected clients of the added transaction.
+	w.broadcaster.publish(NtfnRelevantTx,
+		RelevantTxNotification{TxRecord: rec, Block: block})
 
 	bs, err := w.chainSvr.BlockStamp()
 	if err == nil {
@@ -211,6 +224,40 @@ func (w *Wallet) addRelevantTx(rec *wtxmgr.TxRecord, block *wtxmgr.BlockMeta) er
 	return nil
 }
 
+// isRelevantTx returns whether a transaction spends any wallet output or pays
+// to any wallet address.
+func (w *Wallet) isRelevantTx(rec *wtxmgr.TxRecord) (bool, error) {
+	// Previous output scripts are only returned for inputs which spend
+	// unmined or unspent wallet credits.  Mined transactions are looked up
+	// as unmined since no debits are recorded for them yet.
+	pkScripts, err := w.TxStore.PreviousPkScripts(rec, nil)
+	if err != nil {
+		return false, err
+	}
+	if len(pkScripts) != 0 {
+		return true, nil
+	}
+
+	for _, output := range rec.MsgTx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(output.PkScript,
+			w.chainParams)
+		if err != nil {
+			// Non-standard outputs are skipped.
+			continue
+		}
+		for _, addr := range addrs {
+			_, err := w.Manager.Address(addr)
+			if err == nil {
+				return true, nil
+			}
+			if !waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound) {
+				return false, err
+			}
+		}
+	}
+	return false, nil
+}
+
 func (w *Wallet) notifyBalances(curHeight int32) {
 	// Don't notify unless wallet is synced to the chain server.
 	if !w.ChainSynced() {
@@ -231,3 +278,28 @@ func (w *Wallet) notifyBalances(curHeight int32) {
 	}
 	w.notifyUnconfirmedBalance(unconfirmed - confirmed)
 }
+
+// notifyMaturedCoinbases notifies subscribers of each coinbase transaction with
+// wallet credits which reaches maturity when the chain reaches height
+// curHeight.
+func (w *Wallet) notifyMaturedCoinbases(curHeight int32) {
+	height := curHeight - blockchain.CoinbaseMaturity + 1
+	if height < 0 {
+		return
+	}
+	err := w.TxStore.RangeTransactions(height, height, func(details []wtxmgr.TxDetails) (bool, error) {
+		for i := range details {
+			if len(details[i].Credits) == 0 ||
+				!blockchain.IsCoinBaseTx(&details[i].MsgTx) {
+				continue
+			}
+			w.broadcaster.publish(NtfnCoinbaseMatured,
+				CoinbaseMaturedNotification(details[i]))
+		}
+		return false, nil
+	})
+	if err != nil {
+		log.Errorf("Cannot determine matured coinbase transactions: %v",
+			err)
+	}
+}
@@ -17,6 +17,7 @@
 package wallet
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	badrand "math/rand"
@@ -102,16 +103,41 @@ var ErrNonPositiveAmount = errors.New("amount is not positive")
 // negative.
 var ErrNegativeFee = errors.New("fee is negative")
 
+// ErrFeeExceedsOutputs represents an error where the fee of a transaction
+// can not be subtracted from the chosen outputs without reducing an output
+// to a non-positive amount.
+var ErrFeeExceedsOutputs = errors.New("fee exceeds the amount of an output it is subtracted from")
+
+// ErrNoEligibleOutputs represents an error where an account can not be swept
+// because it has no outputs eligible to be spent.
+var ErrNoEligibleOutputs = errors.New("account has no outputs eligible to be spent")
+
+// ErrSubtractFeeAddress represents an error where the fee is to be subtracted
+// from the output paying an address which is not paid by the transaction.
+var ErrSubtractFeeAddress = errors.New("fee can only be subtracted from outputs of the transaction")
+
 // defaultFeeIncrement is the default minimum transation fee (0.00001 BTC,
 // measured in satoshis) added to transactions requiring a fee.
 const defaultFeeIncrement = 1e3
 
-// CreatedTx holds the state of a newly-created transaction and the change
-// output (if one was added).
+// CreatedTx holds the state of a newly-created transaction, the change
+// output (if one was added), and the fee paid by the transaction.
 type CreatedTx struct {
 	MsgTx       *wire.MsgTx
 	ChangeAddr  btcutil.Address
 	ChangeIndex int // negative if no change
+	Fee         btcutil.Amount
+
+	// spend is the payment recorded towards the spend limit of the sending
+	// account, or nil if the wallet has no spending policies.
+	spend *policySpend
+}
+
+// policySpend identifies the payments of a created transaction recorded with
+// spendpolicy.Store.RecordSpend.
+type policySpend struct {
+	account uint32
+	time    time.Time
 }
 
 // ByAmount defines the methods needed to satisify sort.Interface to
@@ -128,8 +154,16 @@ func (u ByAmount) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
 // unspent output is eligible for spending. Leftover input funds not sent
 // to addr or as a fee for the miner are sent to a newly generated
 // address. InsufficientFundsError is returned if there are not enough
-// eligible unspent outputs to create the transaction.
-func (w *Wallet) txToPairs(pairs map[string]btcutil.Amount, account uint32, minconf int32) (*CreatedTx, error) {
+// eligible unspent outputs to create the transaction.  The payments must be
+// allowed by the spending policy of the account, and are recorded against its
+// spend limit once the transaction is created.
+//
+// The fee is subtracted from the outputs paying the addresses of
+// subtractFeeFrom rather than added to the inputs, if any are given.  When
+// sweep is set, pairs must hold a single address, which is paid every eligible
+// output of the account less the fee.
+func (w *Wallet) txToPairs(pairs map[string]btcutil.Amount, account uint32,
+	minconf int32, subtractFeeFrom []string, sweep bool) (*CreatedTx, error) {
 
 	// Address manager must be unlocked to compose transaction.  Grab
 	// the unlock if possible (to prevent future unlocks), or return the
@@ -146,21 +180,132 @@ func (w *Wallet) txToPairs(pairs map[string]btcutil.Amount, account uint32, minc
 		return nil, err
 	}
 
+	// The spending policy of the account may require outputs with more
+	// confirmations than were requested.
+	now := time.Now()
+	if w.Policies != nil {
+		minconf, err = w.spendPolicyMinConf(account, minconf)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	eligible, err := w.findEligibleOutputs(account, minconf, bs)
 	if err != nil {
 		return nil, err
 	}
 
-	return createTx(eligible, pairs, bs, w.FeeIncrement, w.Manager, account, w.NewChangeAddress, w.chainParams, w.DisallowFree)
+	if sweep {
+		if len(pairs) != 1 {
+			return nil, errors.New("sweep requires a single address")
+		}
+		var total btcutil.Amount
+		for i := range eligible {
+			total += eligible[i].Amount
+		}
+		if total == 0 {
+			return nil, ErrNoEligibleOutputs
+		}
+		var addr string
+		for a := range pairs {
+			addr = a
+		}
+		pairs = map[string]btcutil.Amount{addr: total}
+		subtractFeeFrom = []string{addr}
+	}
+
+	// Check the payments against the spending policy before creating the
+	// transaction.  The requested amounts are checked, which are never
+	// less than the amounts paid after any fee is subtracted.
+	if w.Policies != nil {
+		err = w.checkSpendPolicy(pairs, account, now)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := createTx(eligible, pairs, subtractFeeFrom, bs, w.FeeIncrement, w.Manager, account, w.NewChangeAddress, w.chainParams, w.DisallowFree)
+	if err != nil {
+		return nil, err
+	}
+
+	// Transactions are created serially, so recording the payments before
+	// returning ensures the next transaction is checked against them.  The
+	// payments are removed again by sendCreatedTx if the transaction is
+	// not published.
+	if w.Policies != nil {
+		var total btcutil.Amount
+		for i, txOut := range tx.MsgTx.TxOut {
+			if i != tx.ChangeIndex {
+				total += btcutil.Amount(txOut.Value)
+			}
+		}
+		txHash := tx.MsgTx.TxSha()
+		err = w.Policies.RecordSpend(account, &txHash, total, now)
+		if err != nil {
+			return nil, err
+		}
+		tx.spend = &policySpend{account: account, time: now}
+	}
+	return tx, nil
+}
+
+// removePolicySpend removes the payments of a created transaction which was
+// not published from the spend limit of its account.
+func (w *Wallet) removePolicySpend(tx *CreatedTx) {
+	if tx.spend == nil {
+		return
+	}
+	txHash := tx.MsgTx.TxSha()
+	err := w.Policies.RemoveSpend(tx.spend.account, &txHash, tx.spend.time)
+	if err != nil {
+		log.Errorf("Cannot remove spend of unpublished transaction "+
+			"%v: %v", txHash, err)
+	}
+}
+
+// spendPolicyMinConf returns the minimum number of confirmations of outputs
+// spent by an account required by both the caller and the spending policy of
+// the account.
+func (w *Wallet) spendPolicyMinConf(account uint32, minconf int32) (int32, error) {
+	policy, err := w.Policies.Policy(account)
+	if err != nil || policy == nil {
+		return minconf, err
+	}
+	if minconf < policy.MinConf {
+		minconf = policy.MinConf
+	}
+	return minconf, nil
+}
+
+// checkSpendPolicy checks payments against the spending policy of an account.
+// Addresses are compared to the allowed addresses of the policy by their
+// canonical encoding.
+func (w *Wallet) checkSpendPolicy(pairs map[string]btcutil.Amount,
+	account uint32, now time.Time) error {
+
+	payments := make(map[string]btcutil.Amount, len(pairs))
+	for addrStr, amt := range pairs {
+		// Addresses which can not be decoded are rejected later when
+		// the outputs are added.
+		if addr, err := btcutil.DecodeAddress(addrStr, w.chainParams); err == nil {
+			addrStr = addr.EncodeAddress()
+		}
+		payments[addrStr] += amt
+	}
+	return w.Policies.Check(account, payments, now)
 }
 
 // createTx selects inputs (from the given slice of eligible utxos)
 // whose amount are sufficient to fulfil all the desired outputs plus
 // the mining fee. It then creates and returns a CreatedTx containing
 // the selected inputs and the given outputs, validating it (using
-// validateMsgTx) as well.
+// validateMsgTx) as well.  If subtractFeeFrom is not empty, the inputs
+// need only fulfil the outputs, and the fee is instead subtracted from the
+// outputs paying these addresses in proportion to their amounts.
 func createTx(eligible []wtxmgr.Credit,
-	outputs map[string]btcutil.Amount, bs *waddrmgr.BlockStamp,
+	outputs map[string]btcutil.Amount, subtractFeeFrom []string,
+	bs *waddrmgr.BlockStamp,
 	feeIncrement btcutil.Amount, mgr *waddrmgr.Manager, account uint32,
 	changeAddress func(account uint32) (btcutil.Address, error),
 	chainParams *chaincfg.Params, disallowFree bool) (*CreatedTx, error) {
@@ -170,6 +315,23 @@ func createTx(eligible []wtxmgr.Credit,
 	if err != nil {
 		return nil, err
 	}
+	feeOutputs, err := findOutputs(msgtx, subtractFeeFrom, chainParams)
+	if err != nil {
+		return nil, err
+	}
+	requested := make([]btcutil.Amount, len(feeOutputs))
+	for i, txOut := range feeOutputs {
+		requested[i] = btcutil.Amount(txOut.Value)
+	}
+
+	// inputFee returns the part of a fee which must be paid by the inputs
+	// rather than subtracted from outputs.
+	inputFee := func(fee btcutil.Amount) btcutil.Amount {
+		if len(feeOutputs) != 0 {
+			return 0
+		}
+		return fee
+	}
 
 	// Sort eligible inputs so that we first pick the ones with highest
 	// amount, thus reducing number of inputs.
@@ -198,7 +360,7 @@ func createTx(eligible []wtxmgr.Credit,
 	// Now make sure the sum amount of all our inputs is enough for the
 	// sum amount of all outputs plus the fee. If necessary we add more,
 	// inputs, but in that case we also need to recalculate the fee.
-	for totalAdded < minAmount+feeEst {
+	for totalAdded < minAmount+inputFee(feeEst) {
 		if len(eligible) == 0 {
 			return nil, InsufficientFundsError{totalAdded, minAmount, feeEst}
 		}
@@ -215,7 +377,14 @@ func createTx(eligible []wtxmgr.Credit,
 	changeIdx := -1
 
 	for {
-		change := totalAdded - minAmount - feeEst
+		if len(feeOutputs) != 0 {
+			err = subtractFee(feeOutputs, requested, feeEst)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		change := totalAdded - minAmount - inputFee(feeEst)
 		if change > 0 {
 			if changeAddr == nil {
 				changeAddr, err = changeAddress(account)
@@ -249,7 +418,7 @@ func createTx(eligible []wtxmgr.Credit,
 		}
 
 		feeEst += feeIncrement
-		for totalAdded < minAmount+feeEst {
+		for totalAdded < minAmount+inputFee(feeEst) {
 			if len(eligible) == 0 {
 				return nil, InsufficientFundsError{totalAdded, minAmount, feeEst}
 			}
@@ -266,10 +435,18 @@ func createTx(eligible []wtxmgr.Credit,
 		return nil, err
 	}
 
+	// The fee is whatever remains of the inputs after the outputs,
+	// including change, are paid.
+	fee := totalAdded
+	for _, txOut := range msgtx.TxOut {
+		fee -= btcutil.Amount(txOut.Value)
+	}
+
 	info := &CreatedTx{
 		MsgTx:       msgtx,
 		ChangeAddr:  changeAddr,
 		ChangeIndex: changeIdx,
+		Fee:         fee,
 	}
 	return info, nil
 }
@@ -316,11 +493,73 @@ func addOutputs(msgtx *wire.MsgTx, pairs map[string]btcutil.Amount, chainParams
 	return minAmount, nil
 }
 
+// findOutputs returns the outputs of msgtx paying to each address, ignoring
+// any repeated addresses.  Each address must be paid by an output.
+func findOutputs(msgtx *wire.MsgTx, addrs []string, chainParams *chaincfg.Params) ([]*wire.TxOut, error) {
+	found := make([]*wire.TxOut, 0, len(addrs))
+nextAddr:
+	for _, addrStr := range addrs {
+		addr, err := btcutil.DecodeAddress(addrStr, chainParams)
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode address: %s", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create txout script: %s", err)
+		}
+		var txOut *wire.TxOut
+		for _, out := range msgtx.TxOut {
+			if bytes.Equal(out.PkScript, pkScript) {
+				txOut = out
+				break
+			}
+		}
+		if txOut == nil {
+			return nil, ErrSubtractFeeAddress
+		}
+		for _, out := range found {
+			if out == txOut {
+				continue nextAddr
+			}
+		}
+		found = append(found, txOut)
+	}
+	return found, nil
+}
+
+// subtractFee sets the value of each output to its requested amount less its
+// share of the fee.  The fee is shared in proportion to the requested amounts,
+// and any remainder left from rounding is subtracted from the first output.
+func subtractFee(outputs []*wire.TxOut, requested []btcutil.Amount, fee btcutil.Amount) error {
+	var total btcutil.Amount
+	for _, amt := range requested {
+		total += amt
+	}
+	remaining := fee
+	for i := range outputs {
+		share := btcutil.Amount(float64(fee) * float64(requested[i]) /
+			float64(total))
+		outputs[i].Value = int64(requested[i] - share)
+		remaining -= share
+	}
+	outputs[0].Value -= int64(remaining)
+	for _, txOut := range outputs {
+		if txOut.Value <= 0 {
+			return ErrFeeExceedsOutputs
+		}
+	}
+	return nil
+}
+
 func (w *Wallet) findEligibleOutputs(account uint32, minconf int32, bs *waddrmgr.BlockStamp) ([]wtxmgr.Credit, error) {
 	unspent, err := w.TxStore.UnspentOutputs()
 	if err != nil {
 		return nil, err
 	}
+	locked, err := w.lockedOutpoints()
+	if err != nil {
+		return nil, err
+	}
 
 	// TODO: Eventually all of these filters (except perhaps output locking)
 	// should be handled by the call to UnspentOutputs (or similar).
@@ -345,7 +584,7 @@ func (w *Wallet) findEligibleOutputs(account uint32, minconf int32, bs *waddrmgr
 		}
 
 		// Locked unspent outputs are skipped.
-		if w.LockedOutpoint(output.OutPoint) {
+		if _, ok := locked[output.OutPoint]; ok {
 			continue
 		}
 
@@ -85,7 +85,7 @@ func TestCreateTx(t *testing.T) {
 	eligible := mockCredits(t, txInfo.hex, []uint32{1, 2, 3, 4, 5})
 	// Now create a new TX sending 25e6 satoshis to the following addresses:
 	outputs := map[string]btcutil.Amount{outAddr1: 15e6, outAddr2: 10e6}
-	tx, err := createTx(eligible, outputs, bs, defaultFeeIncrement, mgr, account, tstChangeAddress, &chaincfg.TestNet3Params, false)
+	tx, err := createTx(eligible, outputs, nil, bs, defaultFeeIncrement, mgr, account, tstChangeAddress, &chaincfg.TestNet3Params, false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,7 +132,7 @@ func TestCreateTxInsufficientFundsError(t *testing.T) {
 		return changeAddr, nil
 	}
 
-	_, err := createTx(eligible, outputs, bs, defaultFeeIncrement, nil, account, tstChangeAddress, &chaincfg.TestNet3Params, false)
+	_, err := createTx(eligible, outputs, nil, bs, defaultFeeIncrement, nil, account, tstChangeAddress, &chaincfg.TestNet3Params, false)
 
 	if err == nil {
 		t.Error("Expected InsufficientFundsError, got no error")
@@ -141,6 +141,84 @@ func TestCreateTxInsufficientFundsError(t *testing.T) {
 	}
 }
 
+func TestCreateTxSubtractFee(t *testing.T) {
+	bs := &waddrmgr.BlockStamp{Height: 11111}
+	mgr := newManager(t, txInfo.privKeys, bs)
+	account := uint32(0)
+	changeAddr, _ := btcutil.DecodeAddress("muqW4gcixv58tVbSKRC5q6CRKy8RmyLgZ5", &chaincfg.TestNet3Params)
+	var tstChangeAddress = func(account uint32) (btcutil.Address, error) {
+		return changeAddr, nil
+	}
+
+	// Send 25e6 satoshis with the fee subtracted from the first output.
+	// The utxos with indices 4 and 3 total exactly 25e6, so no other
+	// inputs are needed to pay the fee and there is no change.
+	eligible := mockCredits(t, txInfo.hex, []uint32{1, 2, 3, 4, 5})
+	outputs := map[string]btcutil.Amount{outAddr1: 15e6, outAddr2: 10e6}
+	tx, err := createTx(eligible, outputs, []string{outAddr1}, bs, defaultFeeIncrement, mgr, account, tstChangeAddress, &chaincfg.TestNet3Params, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msgTx := tx.MsgTx
+	if len(msgTx.TxIn) != 2 {
+		t.Fatalf("Unexpected number of inputs; got %d, want 2", len(msgTx.TxIn))
+	}
+	if len(msgTx.TxOut) != 2 || tx.ChangeIndex != -1 {
+		t.Fatalf("Unexpected change output; got %d outputs, change index %d",
+			len(msgTx.TxOut), tx.ChangeIndex)
+	}
+	minFee := feeForSize(defaultFeeIncrement, msgTx.SerializeSize())
+	if tx.Fee < minFee {
+		t.Fatalf("Fee (%v) lower than required fee for tx size (%v)", tx.Fee, minFee)
+	}
+	checkOutputsMatch(t, msgTx, map[string]btcutil.Amount{
+		outAddr1: 15e6 - tx.Fee,
+		outAddr2: 10e6,
+	})
+
+	// The fee can not be subtracted from an output too small to pay it,
+	// or from an address which is not paid.
+	outputs = map[string]btcutil.Amount{outAddr1: 15e6, outAddr2: 1}
+	_, err = createTx(eligible, outputs, []string{outAddr2}, bs, defaultFeeIncrement, mgr, account, tstChangeAddress, &chaincfg.TestNet3Params, false)
+	if err != ErrFeeExceedsOutputs {
+		t.Errorf("Unexpected error, got %v, want ErrFeeExceedsOutputs", err)
+	}
+	outputs = map[string]btcutil.Amount{outAddr1: 15e6}
+	_, err = createTx(eligible, outputs, []string{outAddr2}, bs, defaultFeeIncrement, mgr, account, tstChangeAddress, &chaincfg.TestNet3Params, false)
+	if err != ErrSubtractFeeAddress {
+		t.Errorf("Unexpected error, got %v, want ErrSubtractFeeAddress", err)
+	}
+}
+
+func Test_subtractFee(t *testing.T) {
+	outputs := []*wire.TxOut{{Value: 3e6}, {Value: 1e6}}
+	requested := []btcutil.Amount{3e6, 1e6}
+
+	// The fee is shared by the outputs in proportion to their amounts,
+	// with the remainder subtracted from the first output.
+	if err := subtractFee(outputs, requested, 1001); err != nil {
+		t.Fatal(err)
+	}
+	if outputs[0].Value != 3e6-751 || outputs[1].Value != 1e6-250 {
+		t.Fatalf("Unexpected output values %d and %d", outputs[0].Value,
+			outputs[1].Value)
+	}
+
+	// Subtracting again starts from the requested amounts.
+	if err := subtractFee(outputs, requested, 4); err != nil {
+		t.Fatal(err)
+	}
+	if outputs[0].Value != 3e6-3 || outputs[1].Value != 1e6-1 {
+		t.Fatalf("Unexpected output values %d and %d", outputs[0].Value,
+			outputs[1].Value)
+	}
+
+	if err := subtractFee(outputs, requested, 4e6); err != ErrFeeExceedsOutputs {
+		t.Fatalf("Unexpected error, got %v, want ErrFeeExceedsOutputs", err)
+	}
+}
+
 // checkOutputsMatch checks that the outputs in the tx match the expected ones.
 func checkOutputsMatch(t *testing.T, msgtx *wire.MsgTx, expected map[string]btcutil.Amount) {
 	// This is a bit convoluted because the index of the change output is randomized.
@@ -0,0 +1,66 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+/*
+Package dump provides a logical, versioned export of the data of a wallet to a
+JSON document, and the import of such a document into a new database.
+
+Unlike the Copy function of a database, which writes the files of a particular
+storage engine, a dump describes the accounts, addresses, and encrypted keys of
+the address manager, the transactions and credits of the transaction store, the
+series, used addresses, and withdrawals of the voting pools, and the spending
+policies of accounts along with the payments counted towards their limits.  A
+dump of a database opened with one driver can therefore be restored to a
+database opened with any other, and the wallet can be inspected with ordinary
+JSON tools.
+
+Format
+
+A dump is a single JSON object naming the format and its version, followed by
+an object for each package, which is omitted when the database holds no data
+for the package.  Restore refuses dumps of an unknown format or a newer
+version.  The objects of each package are the Dump types of the waddrmgr,
+wtxmgr, votingpool, and spendpolicy packages:
+
+	{
+	  "format": "btcwallet-dump",
+	  "version": 1,
+	  "waddrmgr": {
+	    "accounts": [...],
+	    "addresses": [...],
+	    ...
+	  },
+	  "wtxmgr": {
+	    "transactions": [...],
+	    ...
+	  },
+	  "votingpool": {
+	    "pools": [...]
+	  },
+	  "spendpolicy": {
+	    "policies": [...],
+	    "spends": [...]
+	  }
+	}
+
+Keys are written encrypted exactly as they are stored, so a dump holds no more
+secrets than the database it was read from.  Each package must be at its latest
+version to be dumped, which is done by opening the wallet, and is restored at
+that version.  Balances, indexes, and other data derived from the dumped
+information are recreated when a dump is restored.
+
+All packages are read and written in a single database transaction.  A restore
+which fails leaves the database unmodified.
+*/
+package dump
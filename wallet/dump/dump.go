@@ -0,0 +1,305 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package dump
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/btcsuite/btcwallet/spendpolicy"
+	"github.com/btcsuite/btcwallet/votingpool"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+const (
+	// Format identifies a dump written by this package.
+	Format = "btcwallet-dump"
+
+	// Version is the version of the dump format written by Dump.  It must
+	// be incremented whenever the format is changed in a way which older
+	// versions of Restore can not read.
+	Version = 1
+)
+
+// Namespace keys of the packages included in a dump.  These are the keys used
+// by btcwallet.
+var (
+	waddrmgrNamespaceKey    = []byte("waddrmgr")
+	wtxmgrNamespaceKey      = []byte("wtxmgr")
+	votingPoolNamespaceKey  = []byte("votingpool")
+	spendPolicyNamespaceKey = []byte("spendpolicy")
+)
+
+// Errors returned by Restore.
+var (
+	// ErrUnknownFormat is returned when the data being restored is not a
+	// dump written by this package.
+	ErrUnknownFormat = errors.New("unknown dump format")
+
+	// ErrUnknownVersion is returned when the dump was written with a newer
+	// version of the format than is understood by this package.
+	ErrUnknownVersion = errors.New("unknown dump format version")
+
+	// ErrNamespaceNotEmpty is returned when a namespace being restored
+	// already contains data in the destination database.
+	ErrNamespaceNotEmpty = errors.New("namespace is not empty")
+)
+
+// errManagedTx is returned when a package attempts to end the transaction
+// of a dump or restore.
+var errManagedTx = errors.New("the transaction of a dump can not be " +
+	"committed or rolled back")
+
+// File is the top level object of a dump.  Each package is described by the
+// dump type of the package, and is nil when the database holds no data for
+// it.
+type File struct {
+	Format      string            `json:"format"`
+	Version     uint32            `json:"version"`
+	Waddrmgr    *waddrmgr.Dump    `json:"waddrmgr,omitempty"`
+	Wtxmgr      *wtxmgr.Dump      `json:"wtxmgr,omitempty"`
+	VotingPool  *votingpool.Dump  `json:"votingpool,omitempty"`
+	SpendPolicy *spendpolicy.Dump `json:"spendpolicy,omitempty"`
+}
+
+// namespaceTx is the walletdb.Tx passed to the dump functions of each package.
+// It provides the root bucket of a single namespace of the transaction of a
+// dump or restore, which is ended by this package.
+type namespaceTx struct {
+	root walletdb.Bucket
+}
+
+// Enforce namespaceTx implements the walletdb.Tx interface.
+var _ walletdb.Tx = namespaceTx{}
+
+// RootBucket returns the root bucket of the namespace.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx namespaceTx) RootBucket() walletdb.Bucket {
+	return tx.root
+}
+
+// Commit always returns an error since the transaction is committed by this
+// package.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx namespaceTx) Commit() error {
+	return errManagedTx
+}
+
+// Rollback always returns an error since the transaction is rolled back by
+// this package.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx namespaceTx) Rollback() error {
+	return errManagedTx
+}
+
+// Read returns the dump of the address manager, transaction store, voting
+// pools, and account spending policies of db.  All packages are read in a single transaction, so the dump is
+// consistent.  Packages whose namespace does not exist or holds no data are
+// left nil.  Each package must be at its latest version, which is done by
+// opening the wallet, and an error is returned otherwise.
+func Read(db walletdb.DB) (*File, error) {
+	f := &File{
+		Format:  Format,
+		Version: Version,
+	}
+	err := db.View(func(tx walletdb.DBTx) error {
+		if root := tx.RootBucket(waddrmgrNamespaceKey); root != nil {
+			d, err := waddrmgr.ReadDump(namespaceTx{root})
+			if err != nil && !waddrmgr.IsError(err, waddrmgr.ErrNoExist) {
+				return err
+			}
+			f.Waddrmgr = d
+		}
+		if root := tx.RootBucket(wtxmgrNamespaceKey); root != nil {
+			d, err := wtxmgr.ReadDump(namespaceTx{root})
+			if err != nil && !wtxmgr.IsNoExists(err) {
+				return err
+			}
+			f.Wtxmgr = d
+		}
+		if root := tx.RootBucket(votingPoolNamespaceKey); root != nil {
+			d, err := votingpool.ReadDump(namespaceTx{root})
+			if err != nil {
+				return err
+			}
+			if len(d.Pools) != 0 {
+				f.VotingPool = d
+			}
+		}
+		if root := tx.RootBucket(spendPolicyNamespaceKey); root != nil {
+			d, err := spendpolicy.ReadDump(namespaceTx{root})
+			if err != nil && !spendpolicy.IsNoExists(err) {
+				return err
+			}
+			if d != nil && (len(d.Policies) != 0 || len(d.Spends) != 0) {
+				f.SpendPolicy = d
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Dump writes the dump of db to w as indented JSON.  See Read for details.
+func Dump(db walletdb.DB, w io.Writer) error {
+	f, err := Read(db)
+	if err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	out = append(out, '\n')
+	_, err = w.Write(out)
+	return err
+}
+
+// section is the namespace of a package included in a dump and the function
+// which restores the package to it.
+type section struct {
+	key   []byte
+	write func(walletdb.Tx) error
+}
+
+// sections returns the namespace and restore function of each package included
+// in the dump.
+func (f *File) sections() []section {
+	var sections []section
+	if f.Waddrmgr != nil {
+		sections = append(sections, section{waddrmgrNamespaceKey,
+			func(tx walletdb.Tx) error {
+				return waddrmgr.WriteDump(tx, f.Waddrmgr)
+			}})
+	}
+	if f.Wtxmgr != nil {
+		sections = append(sections, section{wtxmgrNamespaceKey,
+			func(tx walletdb.Tx) error {
+				return wtxmgr.WriteDump(tx, f.Wtxmgr)
+			}})
+	}
+	if f.VotingPool != nil {
+		sections = append(sections, section{votingPoolNamespaceKey,
+			func(tx walletdb.Tx) error {
+				return votingpool.WriteDump(tx, f.VotingPool)
+			}})
+	}
+	if f.SpendPolicy != nil {
+		sections = append(sections, section{spendPolicyNamespaceKey,
+			func(tx walletdb.Tx) error {
+				return spendpolicy.WriteDump(tx, f.SpendPolicy)
+			}})
+	}
+	return sections
+}
+
+// isEmpty returns whether the bucket holds no keys or nested buckets.
+func isEmpty(b walletdb.Bucket) (bool, error) {
+	empty := true
+	err := b.ForEach(func(k, v []byte) error {
+		empty = false
+		return nil
+	})
+	return empty, err
+}
+
+// Write restores the packages described by f to db.  The namespace of every
+// package being restored must either not exist in db or be empty, and
+// ErrNamespaceNotEmpty is returned otherwise.  All packages are written in a
+// single transaction, and namespaces created for the restore are deleted again
+// if it fails, so db is left unmodified on errors.
+func Write(db walletdb.DB, f *File) error {
+	if f.Format != Format {
+		return ErrUnknownFormat
+	}
+	if f.Version > Version {
+		return ErrUnknownVersion
+	}
+	sections := f.sections()
+
+	var missing [][]byte
+	err := db.View(func(tx walletdb.DBTx) error {
+		for _, s := range sections {
+			if tx.RootBucket(s.key) == nil {
+				missing = append(missing, s.key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// Namespaces can not be created by a database transaction, so create
+	// the missing namespaces first, and delete them again if the restore
+	// fails.
+	var created [][]byte
+	removeCreated := func() {
+		for _, key := range created {
+			db.DeleteNamespace(key)
+		}
+	}
+	for _, key := range missing {
+		if _, err := db.Namespace(key); err != nil {
+			removeCreated()
+			return err
+		}
+		created = append(created, key)
+	}
+
+	err = db.Update(func(tx walletdb.DBTx) error {
+		for _, s := range sections {
+			root := tx.RootBucket(s.key)
+			if root == nil {
+				return walletdb.ErrBucketNotFound
+			}
+			empty, err := isEmpty(root)
+			if err != nil {
+				return err
+			}
+			if !empty {
+				return ErrNamespaceNotEmpty
+			}
+			if err := s.write(namespaceTx{root}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		removeCreated()
+		return err
+	}
+	return nil
+}
+
+// Restore reads a dump written by Dump from r and restores it to db.  See Write
+// for details.
+func Restore(db walletdb.DB, r io.Reader) error {
+	var f File
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return err
+	}
+	return Write(db, &f)
+}
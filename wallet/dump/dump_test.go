@@ -0,0 +1,337 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package dump_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/spendpolicy"
+	"github.com/btcsuite/btcwallet/votingpool"
+	"github.com/btcsuite/btcwallet/waddrmgr"
+	"github.com/btcsuite/btcwallet/wallet/dump"
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+var (
+	seed = []byte{
+		0x2a, 0x64, 0xdf, 0x08, 0x5e, 0xef, 0xed, 0xd8, 0xbf,
+		0xdb, 0xb3, 0x31, 0x76, 0xb5, 0xba, 0x2e, 0x62, 0xe8,
+		0xbe, 0x8b, 0x56, 0xc8, 0x83, 0x77,
+	}
+	pubPassphrase  = []byte("public")
+	privPassphrase = []byte("private")
+	fastScrypt     = &waddrmgr.ScryptOptions{N: 16, R: 8, P: 1}
+
+	waddrmgrNamespaceKey    = []byte("waddrmgr")
+	wtxmgrNamespaceKey      = []byte("wtxmgr")
+	votingPoolNamespaceKey  = []byte("votingpool")
+	spendPolicyNamespaceKey = []byte("spendpolicy")
+
+	// testPolicy is the spending policy of the default account of the
+	// test database, and spendTime the time of its recorded payment.
+	testPolicy = &spendpolicy.Policy{
+		SpendLimit:       10e8,
+		LimitPeriod:      time.Hour,
+		AllowedAddresses: []string{"1BitcoinEaterAddressDontSendf59kuE"},
+	}
+	spendTime = time.Now()
+)
+
+// createTestDB returns a memory database holding an address manager with a
+// used address, a transaction store with a mined credit, a voting pool, and a
+// spending policy of the default account with a recorded payment.
+func createTestDB(t *testing.T) walletdb.DB {
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addrMgrNS, err := db.Namespace(waddrmgrNamespaceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := waddrmgr.Create(addrMgrNS, seed, pubPassphrase,
+		privPassphrase, &chaincfg.MainNetParams, fastScrypt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mgr.Close()
+	addrs, err := mgr.NextExternalAddresses(waddrmgr.DefaultAccountNum, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.MarkUsed(addrs[0].Address()); err != nil {
+		t.Fatal(err)
+	}
+
+	txMgrNS, err := db.Namespace(wtxmgrNamespaceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := wtxmgr.Create(txMgrNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgTx := &wire.MsgTx{
+		TxIn: []*wire.TxIn{{
+			PreviousOutPoint: wire.OutPoint{Index: ^uint32(0)},
+		}},
+		TxOut: []*wire.TxOut{{Value: 50e8}},
+	}
+	rec, err := wtxmgr.NewTxRecordFromMsgTx(msgTx, time.Unix(1431640000, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block := &wtxmgr.BlockMeta{
+		Block: wtxmgr.Block{Height: 100},
+		Time:  time.Unix(1431640100, 0),
+	}
+	if err := s.InsertTx(rec, block); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(rec, block, 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	poolNS, err := db.Namespace(votingPoolNamespaceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := votingpool.Create(poolNS, mgr, []byte("pool")); err != nil {
+		t.Fatal(err)
+	}
+
+	policyNS, err := db.Namespace(spendPolicyNamespaceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, err := spendpolicy.Create(policyNS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = policies.SetPolicy(waddrmgr.DefaultAccountNum, testPolicy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = policies.RecordSpend(waddrmgr.DefaultAccountNum, &rec.Hash, 4e8,
+		spendTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// TestDumpRestore ensures that a restored dump holds the same accounts,
+// addresses, transactions, pools, and spending policies as the dumped
+// database.
+func TestDumpRestore(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+
+	var buf bytes.Buffer
+	if err := dump.Dump(db, &buf); err != nil {
+		t.Fatalf("Dump: unexpected error: %v", err)
+	}
+	dumped := buf.String()
+
+	var f dump.File
+	if err := json.Unmarshal(buf.Bytes(), &f); err != nil {
+		t.Fatalf("Unmarshal: unexpected error: %v", err)
+	}
+	if f.Format != dump.Format || f.Version != dump.Version {
+		t.Errorf("Dump: unexpected format %q version %d", f.Format,
+			f.Version)
+	}
+	if f.Waddrmgr == nil || f.Wtxmgr == nil || f.VotingPool == nil ||
+		f.SpendPolicy == nil {
+		t.Fatalf("Dump: missing package dumps")
+	}
+	if len(f.Waddrmgr.Accounts) != 1 || len(f.Waddrmgr.Addresses) != 2 {
+		t.Errorf("Dump: got %d accounts and %d addresses, want 1 and 2",
+			len(f.Waddrmgr.Accounts), len(f.Waddrmgr.Addresses))
+	}
+	if len(f.Wtxmgr.Transactions) != 1 ||
+		len(f.Wtxmgr.Transactions[0].Credits) != 1 {
+		t.Errorf("Dump: unexpected transactions %v",
+			f.Wtxmgr.Transactions)
+	}
+	if len(f.VotingPool.Pools) != 1 {
+		t.Errorf("Dump: got %d pools, want 1", len(f.VotingPool.Pools))
+	}
+	if len(f.SpendPolicy.Policies) != 1 || len(f.SpendPolicy.Spends) != 1 {
+		t.Errorf("Dump: got %d policies and %d spends, want 1 and 1",
+			len(f.SpendPolicy.Policies), len(f.SpendPolicy.Spends))
+	}
+
+	restored, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if err := dump.Restore(restored, &buf); err != nil {
+		t.Fatalf("Restore: unexpected error: %v", err)
+	}
+
+	// Dumping the restored database must produce the same dump.
+	buf.Reset()
+	if err := dump.Dump(restored, &buf); err != nil {
+		t.Fatalf("Dump: unexpected error: %v", err)
+	}
+	if buf.String() != dumped {
+		t.Errorf("Dump of restored database differs:\n%s\nwant:\n%s",
+			buf.String(), dumped)
+	}
+
+	// The restored packages must open and hold the dumped data.
+	addrMgrNS, err := restored.Namespace(waddrmgrNamespaceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := waddrmgr.Open(addrMgrNS, pubPassphrase,
+		&chaincfg.MainNetParams, nil)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer mgr.Close()
+	if err := mgr.Unlock(privPassphrase); err != nil {
+		t.Errorf("Unlock: unexpected error: %v", err)
+	}
+	txMgrNS, err := restored.Namespace(wtxmgrNamespaceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := wtxmgr.Open(txMgrNS)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	bal, err := s.Balance(1, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal != 50e8 {
+		t.Errorf("Balance: got %v, want 50 BTC", bal)
+	}
+	policyNS, err := restored.Namespace(spendPolicyNamespaceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policies, err := spendpolicy.Open(policyNS)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	p, err := policies.Policy(waddrmgr.DefaultAccountNum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(p, testPolicy) {
+		t.Errorf("Policy: got %+v, want %+v", p, testPolicy)
+	}
+	spent, err := policies.Spent(waddrmgr.DefaultAccountNum, spendTime)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent != 4e8 {
+		t.Errorf("Spent: got %v, want 4 BTC", spent)
+	}
+
+	// Restoring to a database which already holds the packages must
+	// fail without modifying it.
+	err = dump.Restore(restored, bytes.NewBufferString(dumped))
+	if err != dump.ErrNamespaceNotEmpty {
+		t.Errorf("Restore: did not receive expected error - got %v, "+
+			"want %v", err, dump.ErrNamespaceNotEmpty)
+	}
+}
+
+// TestRestoreCleanup ensures that a restore which fails writes nothing and
+// removes the namespaces it created.
+func TestRestoreCleanup(t *testing.T) {
+	db := createTestDB(t)
+	defer db.Close()
+	f, err := dump.Read(db)
+	if err != nil {
+		t.Fatalf("Read: unexpected error: %v", err)
+	}
+
+	// A transaction with a credit index past its outputs can not be
+	// restored, which fails the restore after the address manager has
+	// been written.
+	f.Wtxmgr.Transactions[0].Credits[0].Index = 1
+
+	restored, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if err := dump.Write(restored, f); err == nil {
+		t.Fatal("Write: unexpected success restoring an invalid dump")
+	}
+	err = restored.View(func(tx walletdb.DBTx) error {
+		for _, key := range [][]byte{waddrmgrNamespaceKey,
+			wtxmgrNamespaceKey, votingPoolNamespaceKey,
+			spendPolicyNamespaceKey} {
+			if tx.RootBucket(key) != nil {
+				t.Errorf("Write: namespace %s left after failed "+
+					"restore", key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRestoreErrors ensures that dumps of an unknown format or version are
+// not restored.
+func TestRestoreErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file *dump.File
+		err  error
+	}{
+		{
+			name: "unknown format",
+			file: &dump.File{Format: "other", Version: dump.Version},
+			err:  dump.ErrUnknownFormat,
+		},
+		{
+			name: "newer version",
+			file: &dump.File{Format: dump.Format, Version: dump.Version + 1},
+			err:  dump.ErrUnknownVersion,
+		},
+	}
+	for _, test := range tests {
+		db, err := walletdb.Create("mem")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = dump.Write(db, test.file)
+		db.Close()
+		if err != test.err {
+			t.Errorf("%s: did not receive expected error - got %v, "+
+				"want %v", test.name, err, test.err)
+		}
+	}
+}
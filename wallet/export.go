@@ -0,0 +1,452 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wallet
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// LedgerFilter selects the entries returned by Ledger.  Heights are inclusive,
+// and a negative EndHeight ends at the newest block.  A zero StartTime or
+// EndTime does not limit the entries by block time.  EndTime is exclusive.
+type LedgerFilter struct {
+	Account     *uint32 // nil for every account
+	StartHeight int32
+	EndHeight   int32
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// LedgerEntry describes how a mined transaction changed the balance of a
+// single account, in a form suitable for accounting exports.
+//
+// A transaction changing the balance of more than one account, such as a
+// transfer between two accounts, is described by one entry for each account.
+// In is the value received by the account and Out is the value sent by it,
+// excluding the fee paid by the account, so In - Out - Fee is always the
+// change of the account's balance.
+type LedgerEntry struct {
+	Time        time.Time
+	Height      int32
+	TxHash      wire.ShaHash
+	Account     uint32
+	AccountName string
+
+	// Addresses are the addresses of the counterparty: the recipients of
+	// value sent by the account, or the account's addresses which value
+	// was received by.
+	Addresses []string
+
+	// Label is the name of each account owning any of the addresses, which
+	// identifies the counterparty of a transfer between wallet accounts.
+	Label string
+
+	In      btcutil.Amount
+	Out     btcutil.Amount
+	Fee     btcutil.Amount
+	Balance btcutil.Amount // Running balance after the entry
+}
+
+// ledgerAccount accumulates the changes of a single account's balance caused
+// by a transaction.
+type ledgerAccount struct {
+	net   btcutil.Amount
+	fee   btcutil.Amount
+	addrs []string
+}
+
+// scriptAccount returns the account of the first address of an output script
+// belonging to the wallet.  Outputs without any wallet address are recorded
+// under the default account.
+func (w *Wallet) scriptAccount(pkScript []byte) uint32 {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		w.chainParams)
+	if err != nil {
+		return 0
+	}
+	for _, addr := range addrs {
+		acct, err := w.Manager.AddrAccount(addr)
+		if err == nil {
+			return acct
+		}
+	}
+	return 0
+}
+
+// scriptAddresses returns the encoded addresses of an output script.
+func (w *Wallet) scriptAddresses(pkScript []byte) []string {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		w.chainParams)
+	if err != nil {
+		return nil
+	}
+	encoded := make([]string, 0, len(addrs))
+	for _, addr := range addrs {
+		encoded = append(encoded, addr.EncodeAddress())
+	}
+	return encoded
+}
+
+// ledgerAccounts returns the balance changes of every account affected by a
+// transaction.
+func (w *Wallet) ledgerAccounts(details *wtxmgr.TxDetails) (map[uint32]*ledgerAccount, error) {
+	accounts := make(map[uint32]*ledgerAccount)
+	account := func(acct uint32) *ledgerAccount {
+		a, ok := accounts[acct]
+		if !ok {
+			a = new(ledgerAccount)
+			accounts[acct] = a
+		}
+		return a
+	}
+
+	// Record the account of each credited output.
+	credited := make(map[uint32]uint32, len(details.Credits))
+	for _, cred := range details.Credits {
+		pkScript := details.MsgTx.TxOut[cred.Index].PkScript
+		acct := w.scriptAccount(pkScript)
+		a := account(acct)
+		a.net += cred.Amount
+		if !cred.Change {
+			a.addrs = append(a.addrs, w.scriptAddresses(pkScript)...)
+		}
+		credited[cred.Index] = acct
+	}
+
+	// The fee is paid by the account of the first debited output.
+	var payer *ledgerAccount
+	var payerAcct uint32
+	var debitTotal btcutil.Amount
+	for _, deb := range details.Debits {
+		prevOut := &details.MsgTx.TxIn[deb.Index].PreviousOutPoint
+		prev, err := w.TxStore.TxDetails(&prevOut.Hash)
+		if err != nil {
+			return nil, err
+		}
+		var acct uint32
+		if prev != nil && prevOut.Index < uint32(len(prev.MsgTx.TxOut)) {
+			acct = w.scriptAccount(prev.MsgTx.TxOut[prevOut.Index].PkScript)
+		}
+		a := account(acct)
+		a.net -= deb.Amount
+		if payer == nil {
+			payer, payerAcct = a, acct
+		}
+		debitTotal += deb.Amount
+	}
+	if payer == nil {
+		return accounts, nil
+	}
+
+	// The fee is only known when every input is spent from the wallet,
+	// unless it was recorded when the transaction was created.  Without
+	// recorded recipients, outputs paying back to the payer's account are
+	// assumed to be change and every other output is a recipient.
+	var recipients []uint32
+	switch {
+	case details.Sent != nil:
+		payer.fee = details.Sent.Fee
+		recipients = details.Sent.Recipients
+	case len(details.Debits) == len(details.MsgTx.TxIn):
+		var outputTotal btcutil.Amount
+		for _, output := range details.MsgTx.TxOut {
+			outputTotal += btcutil.Amount(output.Value)
+		}
+		payer.fee = debitTotal - outputTotal
+		fallthrough
+	default:
+		for i := range details.MsgTx.TxOut {
+			acct, ok := credited[uint32(i)]
+			if !ok || acct != payerAcct {
+				recipients = append(recipients, uint32(i))
+			}
+		}
+	}
+	payer.addrs = payer.addrs[:0]
+	for _, i := range recipients {
+		if int(i) >= len(details.MsgTx.TxOut) {
+			continue
+		}
+		pkScript := details.MsgTx.TxOut[i].PkScript
+		payer.addrs = append(payer.addrs, w.scriptAddresses(pkScript)...)
+	}
+	return accounts, nil
+}
+
+// label returns the names of the accounts owning any of the addresses.
+func (w *Wallet) label(addrs []string) string {
+	var names []string
+	seen := make(map[uint32]struct{})
+	for _, a := range addrs {
+		addr, err := btcutil.DecodeAddress(a, w.chainParams)
+		if err != nil {
+			continue
+		}
+		acct, err := w.Manager.AddrAccount(addr)
+		if err != nil {
+			continue
+		}
+		if _, ok := seen[acct]; ok {
+			continue
+		}
+		seen[acct] = struct{}{}
+		name, err := w.Manager.AccountName(acct)
+		if err == nil {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, " ")
+}
+
+// Ledger returns an entry for every change of an account's balance caused by
+// a mined transaction selected by the filter, in the order the transactions
+// were mined.  Unmined transactions are not included.  The running balance of
+// each entry includes every earlier transaction, whether or not it was
+// selected by the heights and times of the filter, so the balance of the last
+// entry matches the balance reported by BalanceHistory for the same block.
+//
+// Ledger only reads the wallet, so it may be used with a wallet opened by
+// OpenReadOnly.
+func (w *Wallet) Ledger(filter *LedgerFilter) ([]LedgerEntry, error) {
+	endHeight := filter.EndHeight
+	if endHeight < 0 {
+		endHeight = int32(^uint32(0) >> 1)
+	}
+
+	var entries []LedgerEntry
+	var balance btcutil.Amount
+	err := w.TxStore.RangeTransactions(0, endHeight, func(details []wtxmgr.TxDetails) (bool, error) {
+		for i := range details {
+			detail := &details[i]
+
+			accounts, err := w.ledgerAccounts(detail)
+			if err != nil {
+				return false, err
+			}
+			acctNums := make([]uint32, 0, len(accounts))
+			for acct := range accounts {
+				if filter.Account != nil && acct != *filter.Account {
+					continue
+				}
+				acctNums = append(acctNums, acct)
+			}
+			sort.Sort(accountSlice(acctNums))
+
+			blockTime := detail.Block.Time
+			selected := detail.Block.Height >= filter.StartHeight &&
+				(filter.StartTime.IsZero() || !blockTime.Before(filter.StartTime)) &&
+				(filter.EndTime.IsZero() || blockTime.Before(filter.EndTime))
+			for _, acct := range acctNums {
+				a := accounts[acct]
+				balance += a.net
+				if !selected {
+					continue
+				}
+
+				name, err := w.Manager.AccountName(acct)
+				if err != nil {
+					return false, err
+				}
+				entry := LedgerEntry{
+					Time:        blockTime,
+					Height:      detail.Block.Height,
+					TxHash:      detail.Hash,
+					Account:     acct,
+					AccountName: name,
+					Addresses:   a.addrs,
+					Label:       w.label(a.addrs),
+					Fee:         a.fee,
+					Balance:     balance,
+				}
+				if amount := a.net + a.fee; amount > 0 {
+					entry.In = amount
+				} else {
+					entry.Out = -amount
+				}
+				entries = append(entries, entry)
+			}
+		}
+		return false, nil
+	})
+	return entries, err
+}
+
+// accountSlice satisfies the sort.Interface interface to sort account
+// numbers in increasing order.
+type accountSlice []uint32
+
+func (s accountSlice) Len() int           { return len(s) }
+func (s accountSlice) Less(i, j int) bool { return s[i] < s[j] }
+func (s accountSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// formatLedgerAmount formats an amount in bitcoin with a fixed number of
+// decimal places, as expected by spreadsheets and accounting software.
+func formatLedgerAmount(amt btcutil.Amount) string {
+	return strconv.FormatFloat(amt.ToBTC(), 'f', 8, 64)
+}
+
+// ledgerCSVHeader is the first record written by WriteLedgerCSV.
+var ledgerCSVHeader = []string{"date", "height", "txid", "account",
+	"address", "in", "out", "fee", "balance", "label"}
+
+// WriteLedgerCSV writes ledger entries as CSV records, preceded by a header
+// record naming each field.  Dates are written in RFC 3339 format in UTC, and
+// amounts are valued in bitcoin.  Entries with more than one address separate
+// them with spaces.
+func WriteLedgerCSV(w io.Writer, entries []LedgerEntry) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write(ledgerCSVHeader)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		e := &entries[i]
+		record := []string{
+			e.Time.UTC().Format(time.RFC3339),
+			strconv.FormatInt(int64(e.Height), 10),
+			e.TxHash.String(),
+			e.AccountName,
+			strings.Join(e.Addresses, " "),
+			formatLedgerAmount(e.In),
+			formatLedgerAmount(e.Out),
+			formatLedgerAmount(e.Fee),
+			formatLedgerAmount(e.Balance),
+			e.Label,
+		}
+		err := cw.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ofxTime formats a time as an OFX date in UTC.
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405")
+}
+
+// OFX elements written by WriteLedgerOFX.  Only the elements of a bank
+// statement response which can be filled in from the ledger are included.
+type (
+	ofxDocument struct {
+		XMLName xml.Name     `xml:"OFX"`
+		Stmt    ofxStmtTrnRs `xml:"BANKMSGSRSV1>STMTTRNRS"`
+	}
+	ofxStmtTrnRs struct {
+		TrnUID   string    `xml:"TRNUID"`
+		Code     int       `xml:"STATUS>CODE"`
+		Severity string    `xml:"STATUS>SEVERITY"`
+		StmtRs   ofxStmtRs `xml:"STMTRS"`
+	}
+	ofxStmtRs struct {
+		CurDef   string       `xml:"CURDEF"`
+		BankID   string       `xml:"BANKACCTFROM>BANKID"`
+		AcctID   string       `xml:"BANKACCTFROM>ACCTID"`
+		AcctType string       `xml:"BANKACCTFROM>ACCTTYPE"`
+		DtStart  string       `xml:"BANKTRANLIST>DTSTART"`
+		DtEnd    string       `xml:"BANKTRANLIST>DTEND"`
+		Trns     []ofxStmtTrn `xml:"BANKTRANLIST>STMTTRN"`
+		BalAmt   string       `xml:"LEDGERBAL>BALAMT"`
+		DtAsOf   string       `xml:"LEDGERBAL>DTASOF"`
+	}
+	ofxStmtTrn struct {
+		TrnType  string `xml:"TRNTYPE"`
+		DtPosted string `xml:"DTPOSTED"`
+		TrnAmt   string `xml:"TRNAMT"`
+		FitID    string `xml:"FITID"`
+		Name     string `xml:"NAME,omitempty"`
+		Memo     string `xml:"MEMO,omitempty"`
+	}
+)
+
+// ofxHeader begins every document written by WriteLedgerOFX.
+const ofxHeader = xml.Header + `<?OFX OFXHEADER="200" VERSION="211" ` +
+	`SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+
+// WriteLedgerOFX writes ledger entries as an OFX bank statement for the
+// account named acctID, using the currency code XBT.  Each entry is written as
+// a transaction of its total balance change, including the fee, identified by
+// the transaction hash and account number.  The statement's ledger balance is
+// the balance of the last entry.
+func WriteLedgerOFX(w io.Writer, entries []LedgerEntry, acctID string) error {
+	stmt := ofxStmtRs{
+		CurDef:   "XBT",
+		BankID:   "btcwallet",
+		AcctID:   acctID,
+		AcctType: "CHECKING",
+		Trns:     make([]ofxStmtTrn, 0, len(entries)),
+	}
+	if len(entries) == 0 {
+		now := ofxTime(time.Now())
+		stmt.DtStart, stmt.DtEnd, stmt.DtAsOf = now, now, now
+		stmt.BalAmt = formatLedgerAmount(0)
+	} else {
+		first, last := &entries[0], &entries[len(entries)-1]
+		stmt.DtStart = ofxTime(first.Time)
+		stmt.DtEnd = ofxTime(last.Time)
+		stmt.DtAsOf = ofxTime(last.Time)
+		stmt.BalAmt = formatLedgerAmount(last.Balance)
+	}
+	for i := range entries {
+		e := &entries[i]
+		amount := e.In - e.Out - e.Fee
+		trnType := "CREDIT"
+		if amount < 0 {
+			trnType = "DEBIT"
+		}
+		stmt.Trns = append(stmt.Trns, ofxStmtTrn{
+			TrnType:  trnType,
+			DtPosted: ofxTime(e.Time),
+			TrnAmt:   formatLedgerAmount(amount),
+			FitID:    fmt.Sprintf("%v-%d", e.TxHash, e.Account),
+			Name:     e.Label,
+			Memo:     strings.Join(e.Addresses, " "),
+		})
+	}
+
+	doc := ofxDocument{
+		Stmt: ofxStmtTrnRs{
+			TrnUID:   "0",
+			Severity: "INFO",
+			StmtRs:   stmt,
+		},
+	}
+	_, err := io.WriteString(w, ofxHeader)
+	if err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", " ")
+	err = enc.Encode(&doc)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wallet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestWriteLedgerCSV(t *testing.T) {
+	txHash, err := wire.NewShaHashFromStr("59dc7e4fbe0b3bd9f1528c0274999e8eb60dd9113de5232f576865dc09a2ce56")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []LedgerEntry{
+		{
+			Time:        time.Unix(1420156800, 0),
+			Height:      101,
+			TxHash:      *txHash,
+			Account:     0,
+			AccountName: "default",
+			Addresses: []string{
+				"1N9hmWGfhwo7BMyGRrEtBzLkAX9CheRjq",
+				"1Gs2aNxFXi6admjCa8vutk1Jse7BAE3rq",
+			},
+			Label:   "savings",
+			Out:     3e8,
+			Fee:     1e7,
+			Balance: 19e7,
+		},
+		{
+			Time:        time.Unix(1420156800, 0),
+			Height:      101,
+			TxHash:      *txHash,
+			Account:     1,
+			AccountName: "savings, \"cold\"",
+			Addresses:   []string{"1Gs2aNxFXi6admjCa8vutk1Jse7BAE3rq"},
+			In:          1e8,
+			Balance:     29e7,
+		},
+	}
+	want := strings.Join([]string{
+		"date,height,txid,account,address,in,out,fee,balance,label",
+		"2015-01-02T00:00:00Z,101,59dc7e4fbe0b3bd9f1528c0274999e8eb60dd9113de5232f576865dc09a2ce56,default,1N9hmWGfhwo7BMyGRrEtBzLkAX9CheRjq 1Gs2aNxFXi6admjCa8vutk1Jse7BAE3rq,0.00000000,3.00000000,0.10000000,1.90000000,savings",
+		"2015-01-02T00:00:00Z,101,59dc7e4fbe0b3bd9f1528c0274999e8eb60dd9113de5232f576865dc09a2ce56,\"savings, \"\"cold\"\"\",1Gs2aNxFXi6admjCa8vutk1Jse7BAE3rq,1.00000000,0.00000000,0.00000000,2.90000000,",
+		"",
+	}, "\n")
+
+	var buf bytes.Buffer
+	err = WriteLedgerCSV(&buf, entries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("WriteLedgerCSV: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteLedgerOFX(t *testing.T) {
+	txHash, err := wire.NewShaHashFromStr("59dc7e4fbe0b3bd9f1528c0274999e8eb60dd9113de5232f576865dc09a2ce56")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := []LedgerEntry{
+		{
+			Time:        time.Unix(1420156800, 0),
+			Height:      101,
+			TxHash:      *txHash,
+			Account:     0,
+			AccountName: "default",
+			Addresses:   []string{"1N9hmWGfhwo7BMyGRrEtBzLkAX9CheRjq"},
+			Out:         3e8,
+			Fee:         1e7,
+			Balance:     19e7,
+		},
+	}
+
+	var buf bytes.Buffer
+	err = WriteLedgerOFX(&buf, entries, "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"<ACCTID>default</ACCTID>",
+		"<TRNTYPE>DEBIT</TRNTYPE>",
+		"<DTPOSTED>20150102000000</DTPOSTED>",
+		"<TRNAMT>-3.10000000</TRNAMT>",
+		"<FITID>59dc7e4fbe0b3bd9f1528c0274999e8eb60dd9113de5232f576865dc09a2ce56-0</FITID>",
+		"<MEMO>1N9hmWGfhwo7BMyGRrEtBzLkAX9CheRjq</MEMO>",
+		"<BALAMT>1.90000000</BALAMT>",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteLedgerOFX: missing %s in\n%s", want, got)
+		}
+	}
+}
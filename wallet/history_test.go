@@ -0,0 +1,72 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wallet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestDailyBalances(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2015, 6, d, 0, 0, 0, 0, time.UTC)
+	}
+	balance := func(height int32, at time.Time, amt btcutil.Amount) wtxmgr.BlockBalance {
+		return wtxmgr.BlockBalance{
+			Block: wtxmgr.BlockMeta{
+				Block: wtxmgr.Block{Height: height},
+				Time:  at,
+			},
+			Balance: amt,
+		}
+	}
+
+	// The opening balance on the 1st, two blocks changing the balance on
+	// the 3rd, none on the 4th, and a block on the 5th, with the range
+	// ending on the 6th.
+	opening := balance(100, day(1).Add(6*time.Hour), 1e8)
+	first := balance(110, day(3).Add(time.Hour), 2e8)
+	second := balance(120, day(3).Add(23*time.Hour), 3e8)
+	third := balance(130, day(5).Add(12*time.Hour), 4e8)
+	history := []wtxmgr.BlockBalance{opening, first, second, third}
+	end := day(6).Add(time.Hour)
+
+	exp := []DayBalance{
+		{Date: day(1), BlockBalance: opening},
+		{Date: day(2), BlockBalance: opening},
+		{Date: day(3), BlockBalance: second},
+		{Date: day(4), BlockBalance: second},
+		{Date: day(5), BlockBalance: third},
+		{Date: day(6), BlockBalance: third},
+	}
+	days := dailyBalances(history, opening.Block.Time, end)
+	if !reflect.DeepEqual(days, exp) {
+		t.Errorf("dailyBalances: got %v, expected %v", days, exp)
+	}
+
+	// Blocks timestamped after the day of the last block are included in
+	// the last day.
+	late := balance(131, day(7).Add(time.Hour), 5e8)
+	history = append(history, late)
+	exp[len(exp)-1].BlockBalance = late
+	days = dailyBalances(history, opening.Block.Time, end)
+	if !reflect.DeepEqual(days, exp) {
+		t.Errorf("dailyBalances: got %v, expected %v", days, exp)
+	}
+}
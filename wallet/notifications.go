@@ -0,0 +1,264 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wallet
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+// NotificationKind describes one or more kinds of wallet notifications.
+// Kinds may be combined with a bitwise OR to subscribe to several kinds of
+// notifications at once.
+type NotificationKind uint
+
+// Notification kinds which may be subscribed to.
+const (
+	NtfnBlockConnected NotificationKind = 1 << iota
+	NtfnBlockDisconnected
+	NtfnRelevantTx
+	NtfnLockState
+	NtfnConfirmedBalance
+	NtfnUnconfirmedBalance
+	NtfnCoinbaseMatured
+
+	// NtfnAll subscribes to every kind of notification.
+	NtfnAll = NtfnBlockConnected | NtfnBlockDisconnected | NtfnRelevantTx |
+		NtfnLockState | NtfnConfirmedBalance | NtfnUnconfirmedBalance |
+		NtfnCoinbaseMatured
+)
+
+// Notifications sent to subscribers.  Each kind of notification is sent as a
+// distinct type so subscribers to several kinds may use a type switch.
+type (
+	// BlockConnectedNotification is sent for each block the wallet has
+	// been marked in sync with.
+	BlockConnectedNotification wtxmgr.BlockMeta
+
+	// BlockDisconnectedNotification is sent for each block the wallet has
+	// detached.
+	BlockDisconnectedNotification wtxmgr.BlockMeta
+
+	// RelevantTxNotification is sent for each transaction relevant to the
+	// wallet, including metadata regarding the block it was mined in, if
+	// any.
+	RelevantTxNotification chain.RelevantTx
+
+	// LockStateNotification is sent whenever the lock state of the wallet
+	// changes.  The value is true for locked, and false for unlocked.
+	LockStateNotification bool
+
+	// ConfirmedBalanceNotification is sent with the new confirmed balance
+	// whenever it changes.
+	ConfirmedBalanceNotification btcutil.Amount
+
+	// UnconfirmedBalanceNotification is sent with the new unconfirmed
+	// balance whenever it changes.
+	UnconfirmedBalanceNotification btcutil.Amount
+
+	// CoinbaseMaturedNotification is sent for each coinbase transaction
+	// with wallet credits which reaches maturity, and can be spent, in a
+	// connected block.
+	CoinbaseMaturedNotification wtxmgr.TxDetails
+)
+
+// SlowConsumerPolicy describes what is done when a notification is published
+// and a subscriber's buffer is full.
+type SlowConsumerPolicy int
+
+const (
+	// DropNewest drops the notification being published for the slow
+	// subscriber only.
+	DropNewest SlowConsumerPolicy = iota
+
+	// DropOldest removes the oldest buffered notification to make room
+	// for the notification being published.
+	DropOldest
+
+	// Disconnect closes the subscription.  The subscriber observes this
+	// as a closed channel.
+	Disconnect
+)
+
+// Subscription is a registration for wallet notifications created by
+// Wallet.Subscribe.  Notifications are received from C, which is closed when
+// the subscription is closed, when a Disconnect policy subscriber falls
+// behind, or when the wallet is stopped.
+//
+// Unlike the channels returned by the Listen* methods, a wallet never blocks
+// sending to a subscription, so subscribers are not required to read
+// notifications as they happen.
+type Subscription struct {
+	C <-chan interface{}
+
+	c       chan interface{}
+	kinds   NotificationKind
+	policy  SlowConsumerPolicy
+	dropped uint64
+	closed  bool
+
+	b *notificationBroadcaster
+}
+
+// Dropped returns the number of notifications which were not delivered to
+// the subscriber because its buffer was full.
+func (s *Subscription) Dropped() uint64 {
+	s.b.mu.Lock()
+	dropped := s.dropped
+	s.b.mu.Unlock()
+	return dropped
+}
+
+// Close unregisters the subscription and closes its channel.  It is safe to
+// call Close more than once.
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	s.b.remove(s)
+	s.b.mu.Unlock()
+}
+
+// notificationBroadcaster fans out wallet notifications to any number of
+// subscriptions.  Publishing never blocks; a subscriber which does not keep
+// up is handled according to its slow consumer policy.
+type notificationBroadcaster struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func newNotificationBroadcaster() *notificationBroadcaster {
+	return &notificationBroadcaster{
+		subs: make(map[*Subscription]struct{}),
+	}
+}
+
+// subscribe registers a new subscription.  If the broadcaster was already
+// closed, the returned subscription's channel is closed.
+func (b *notificationBroadcaster) subscribe(kinds NotificationKind,
+	bufferSize int, policy SlowConsumerPolicy) *Subscription {
+
+	c := make(chan interface{}, bufferSize)
+	s := &Subscription{
+		C:      c,
+		c:      c,
+		kinds:  kinds,
+		policy: policy,
+		b:      b,
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		s.closed = true
+		close(c)
+	} else {
+		b.subs[s] = struct{}{}
+	}
+	b.mu.Unlock()
+
+	return s
+}
+
+// remove unregisters and closes a subscription.  The mutex must be held.
+func (b *notificationBroadcaster) remove(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(b.subs, s)
+	close(s.c)
+}
+
+// publish sends a notification to every subscriber of its kind without
+// blocking.  Publishing to a nil broadcaster, such as that of a Wallet not
+// created by Open, is a no-op.
+func (b *notificationBroadcaster) publish(kind NotificationKind, n interface{}) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subs {
+		if s.kinds&kind == 0 {
+			continue
+		}
+
+		select {
+		case s.c <- n:
+			continue
+		default:
+		}
+
+		switch s.policy {
+		case DropNewest:
+			s.dropped++
+
+		case DropOldest:
+			// Publishers are serialized by the mutex, so after
+			// removing the oldest notification there is always
+			// room for the new one unless the buffer has no
+			// capacity at all.
+			select {
+			case <-s.c:
+				s.dropped++
+			default:
+			}
+			select {
+			case s.c <- n:
+			default:
+				s.dropped++
+			}
+
+		case Disconnect:
+			log.Warnf("Disconnecting slow notification subscriber")
+			s.dropped++
+			b.remove(s)
+		}
+	}
+}
+
+// close closes every subscription.  Later subscriptions are closed
+// immediately and later notifications are not published.  Closing a nil
+// broadcaster is a no-op.
+func (b *notificationBroadcaster) close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	for s := range b.subs {
+		b.remove(s)
+	}
+	b.closed = true
+	b.mu.Unlock()
+}
+
+// Subscribe registers for all notifications described by kinds.  Up to
+// bufferSize notifications are buffered for the subscriber, and policy
+// describes what is done with further notifications when the buffer is full.
+// Any number of subscriptions may be created, and these may be created in
+// addition to the channels returned by the Listen* methods.
+//
+// The subscription should be closed when the caller is no longer interested
+// in notifications.
+func (w *Wallet) Subscribe(kinds NotificationKind, bufferSize int,
+	policy SlowConsumerPolicy) *Subscription {
+
+	return w.broadcaster.subscribe(kinds, bufferSize, policy)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wallet
+
+import (
+	"reflect"
+	"testing"
+)
+
+// drain returns all notifications buffered for a subscription.
+func drain(s *Subscription) []interface{} {
+	var ns []interface{}
+	for {
+		select {
+		case n, ok := <-s.C:
+			if !ok {
+				return ns
+			}
+			ns = append(ns, n)
+		default:
+			return ns
+		}
+	}
+}
+
+func TestBroadcasterFanOut(t *testing.T) {
+	b := newNotificationBroadcaster()
+	all := b.subscribe(NtfnAll, 10, DropNewest)
+	locks := b.subscribe(NtfnLockState, 10, DropNewest)
+
+	b.publish(NtfnLockState, LockStateNotification(true))
+	b.publish(NtfnConfirmedBalance, ConfirmedBalanceNotification(5))
+	b.publish(NtfnLockState, LockStateNotification(false))
+
+	want := []interface{}{
+		LockStateNotification(true),
+		ConfirmedBalanceNotification(5),
+		LockStateNotification(false),
+	}
+	if got := drain(all); !reflect.DeepEqual(got, want) {
+		t.Errorf("all kinds: got %v, want %v", got, want)
+	}
+	want = []interface{}{
+		LockStateNotification(true),
+		LockStateNotification(false),
+	}
+	if got := drain(locks); !reflect.DeepEqual(got, want) {
+		t.Errorf("lock state kind: got %v, want %v", got, want)
+	}
+}
+
+func TestBroadcasterSlowConsumers(t *testing.T) {
+	b := newNotificationBroadcaster()
+	dropNewest := b.subscribe(NtfnAll, 2, DropNewest)
+	dropOldest := b.subscribe(NtfnAll, 2, DropOldest)
+	disconnect := b.subscribe(NtfnAll, 2, Disconnect)
+
+	// Publishing must never block, even with no subscriber reading.
+	for i := 1; i <= 3; i++ {
+		b.publish(NtfnConfirmedBalance, ConfirmedBalanceNotification(i))
+	}
+
+	tests := []struct {
+		name    string
+		s       *Subscription
+		want    []interface{}
+		dropped uint64
+		closed  bool
+	}{
+		{
+			name: "drop newest",
+			s:    dropNewest,
+			want: []interface{}{
+				ConfirmedBalanceNotification(1),
+				ConfirmedBalanceNotification(2),
+			},
+			dropped: 1,
+		},
+		{
+			name: "drop oldest",
+			s:    dropOldest,
+			want: []interface{}{
+				ConfirmedBalanceNotification(2),
+				ConfirmedBalanceNotification(3),
+			},
+			dropped: 1,
+		},
+		{
+			name: "disconnect",
+			s:    disconnect,
+			want: []interface{}{
+				ConfirmedBalanceNotification(1),
+				ConfirmedBalanceNotification(2),
+			},
+			dropped: 1,
+			closed:  true,
+		},
+	}
+	for _, test := range tests {
+		got := drain(test.s)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+		if dropped := test.s.Dropped(); dropped != test.dropped {
+			t.Errorf("%s: dropped %d, want %d", test.name, dropped,
+				test.dropped)
+		}
+		closed := false
+		select {
+		case _, ok := <-test.s.C:
+			closed = !ok
+		default:
+		}
+		if closed != test.closed {
+			t.Errorf("%s: closed %v, want %v", test.name, closed,
+				test.closed)
+		}
+	}
+}
+
+func TestBroadcasterClose(t *testing.T) {
+	b := newNotificationBroadcaster()
+	s := b.subscribe(NtfnAll, 1, DropNewest)
+
+	// Closing a subscription twice must not panic.
+	s.Close()
+	s.Close()
+	if _, ok := <-s.C; ok {
+		t.Fatal("closed subscription's channel is open")
+	}
+
+	s = b.subscribe(NtfnAll, 1, DropNewest)
+	b.close()
+	if _, ok := <-s.C; ok {
+		t.Fatal("subscription not closed with broadcaster")
+	}
+	s.Close()
+
+	// Subscriptions made after the broadcaster is closed are closed
+	// immediately, and publishing is a no-op.
+	s = b.subscribe(NtfnAll, 1, DropNewest)
+	b.publish(NtfnLockState, LockStateNotification(true))
+	if _, ok := <-s.C; ok {
+		t.Fatal("subscription to closed broadcaster is open")
+	}
+}
+
+func TestNilBroadcaster(t *testing.T) {
+	// A wallet not created by Open has no broadcaster.  Notifying and
+	// stopping it must not panic.
+	w := &Wallet{}
+	w.notifyLockStateChange(true)
+	w.notifyConfirmedBalance(5)
+	w.broadcaster.close()
+}
@@ -19,6 +19,7 @@ package wallet
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -26,6 +27,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -36,6 +38,7 @@ import (
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/chain"
+	"github.com/btcsuite/btcwallet/spendpolicy"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/walletdb"
 	"github.com/btcsuite/btcwallet/wtxmgr"
@@ -52,8 +55,9 @@ var ErrNotSynced = errors.New("wallet is not synchronized with the chain server"
 
 // Namespace bucket keys.
 var (
-	waddrmgrNamespaceKey = []byte("waddrmgr")
-	wtxmgrNamespaceKey   = []byte("wtxmgr")
+	waddrmgrNamespaceKey    = []byte("waddrmgr")
+	wtxmgrNamespaceKey      = []byte("wtxmgr")
+	spendPolicyNamespaceKey = []byte("spendpolicy")
 )
 
 // Wallet is a structure containing all the components for a
@@ -61,18 +65,23 @@ var (
 // addresses and keys),
 type Wallet struct {
 	// Data stores
-	db      walletdb.DB
-	Manager *waddrmgr.Manager
-	TxStore *wtxmgr.Store
+	db       walletdb.DB
+	Manager  *waddrmgr.Manager
+	TxStore  *wtxmgr.Store
+	readOnly bool
+
+	// Policies holds the spending policies of the wallet's accounts,
+	// which are enforced for every transaction created by the wallet.
+	// This is nil for wallets opened with OpenReadOnly.
+	Policies *spendpolicy.Store
 
 	chainSvr        *chain.Client
 	chainSvrLock    sync.Mutex
 	chainSvrSynced  bool
 	chainSvrSyncMtx sync.Mutex
 
-	lockedOutpoints map[wire.OutPoint]struct{}
-	FeeIncrement    btcutil.Amount
-	DisallowFree    bool
+	FeeIncrement btcutil.Amount
+	DisallowFree bool
 
 	// Channels for rescan processing.  Requests are added and merged with
 	// any waiting requests, before being sent to another goroutine to
@@ -104,14 +113,18 @@ type Wallet struct {
 	unconfirmedBalance chan btcutil.Amount
 	notificationMu     sync.Mutex
 
+	// broadcaster fans out the same notifications to any number of
+	// subscriptions created by Subscribe.
+	broadcaster *notificationBroadcaster
+
 	chainParams *chaincfg.Params
 	wg          sync.WaitGroup
 	quit        chan struct{}
 }
 
 // ErrDuplicateListen is returned for any attempts to listen for the same
-// notification more than once.  If callers must pass along a notifiation to
-// multiple places, they must broadcast it themself.
+// notification more than once.  Callers which must pass along a notification
+// to multiple places should use Subscribe instead.
 var ErrDuplicateListen = errors.New("duplicate listen")
 
 // ListenConnectedBlocks returns a channel that passes all blocks that a wallet
@@ -212,6 +225,8 @@ func (w *Wallet) ListenRelevantTxs() (<-chan chain.RelevantTx, error) {
 }
 
 func (w *Wallet) notifyConnectedBlock(block wtxmgr.BlockMeta) {
+	w.broadcaster.publish(NtfnBlockConnected, BlockConnectedNotification(block))
+
 	w.notificationMu.Lock()
 	if w.connectedBlocks != nil {
 		w.connectedBlocks <- block
@@ -220,6 +235,8 @@ func (w *Wallet) notifyConnectedBlock(block wtxmgr.BlockMeta) {
 }
 
 func (w *Wallet) notifyDisconnectedBlock(block wtxmgr.BlockMeta) {
+	w.broadcaster.publish(NtfnBlockDisconnected, BlockDisconnectedNotification(block))
+
 	w.notificationMu.Lock()
 	if w.disconnectedBlocks != nil {
 		w.disconnectedBlocks <- block
@@ -228,6 +245,8 @@ func (w *Wallet) notifyDisconnectedBlock(block wtxmgr.BlockMeta) {
 }
 
 func (w *Wallet) notifyLockStateChange(locked bool) {
+	w.broadcaster.publish(NtfnLockState, LockStateNotification(locked))
+
 	w.notificationMu.Lock()
 	if w.lockStateChanges != nil {
 		w.lockStateChanges <- locked
@@ -236,6 +255,8 @@ func (w *Wallet) notifyLockStateChange(locked bool) {
 }
 
 func (w *Wallet) notifyConfirmedBalance(bal btcutil.Amount) {
+	w.broadcaster.publish(NtfnConfirmedBalance, ConfirmedBalanceNotification(bal))
+
 	w.notificationMu.Lock()
 	if w.confirmedBalance != nil {
 		w.confirmedBalance <- bal
@@ -244,6 +265,8 @@ func (w *Wallet) notifyConfirmedBalance(bal btcutil.Amount) {
 }
 
 func (w *Wallet) notifyUnconfirmedBalance(bal btcutil.Amount) {
+	w.broadcaster.publish(NtfnUnconfirmedBalance, UnconfirmedBalanceNotification(bal))
+
 	w.notificationMu.Lock()
 	if w.unconfirmedBalance != nil {
 		w.unconfirmedBalance <- bal
@@ -252,6 +275,8 @@ func (w *Wallet) notifyUnconfirmedBalance(bal btcutil.Amount) {
 }
 
 func (w *Wallet) notifyRelevantTx(relevantTx chain.RelevantTx) {
+	w.broadcaster.publish(NtfnRelevantTx, RelevantTxNotification(relevantTx))
+
 	w.notificationMu.Lock()
 	if w.relevantTxs != nil {
 		w.relevantTxs <- relevantTx
@@ -267,6 +292,13 @@ func (w *Wallet) Start(chainServer *chain.Client) {
 	default:
 	}
 
+	// A read-only wallet can not record anything received from the chain
+	// server.
+	if w.readOnly {
+		log.Warn("Not starting read-only wallet")
+		return
+	}
+
 	defer w.chainSvrLock.Unlock()
 	w.chainSvrLock.Lock()
 	w.chainSvr = chainServer
@@ -286,6 +318,7 @@ func (w *Wallet) Stop() {
 	case <-w.quit:
 	default:
 		close(w.quit)
+		w.broadcaster.close()
 		w.chainSvrLock.Lock()
 		if w.chainSvr != nil {
 			w.chainSvr.Stop()
@@ -423,10 +456,12 @@ func (w *Wallet) syncWithChain() error {
 
 type (
 	createTxRequest struct {
-		account uint32
-		pairs   map[string]btcutil.Amount
-		minconf int32
-		resp    chan createTxResponse
+		account         uint32
+		pairs           map[string]btcutil.Amount
+		minconf         int32
+		subtractFeeFrom []string
+		sweep           bool
+		resp            chan createTxResponse
 	}
 	createTxResponse struct {
 		tx  *CreatedTx
@@ -449,7 +484,8 @@ out:
 	for {
 		select {
 		case txr := <-w.createTxRequests:
-			tx, err := w.txToPairs(txr.pairs, txr.account, txr.minconf)
+			tx, err := w.txToPairs(txr.pairs, txr.account,
+				txr.minconf, txr.subtractFeeFrom, txr.sweep)
 			txr.resp <- createTxResponse{tx, err}
 
 		case <-w.quit:
@@ -465,13 +501,39 @@ out:
 // automatically included, if necessary.  All transaction creation through
 // this function is serialized to prevent the creation of many transactions
 // which spend the same outputs.
+//
+// The fee is normally paid by inputs in addition to the address/amount pairs.
+// If subtractFeeFrom lists any addresses of the pairs, the fee is instead
+// subtracted from the amounts paid to these addresses, in proportion to their
+// amounts, so no more than the sum of the pairs is spent.
 func (w *Wallet) CreateSimpleTx(account uint32, pairs map[string]btcutil.Amount,
+	minconf int32, subtractFeeFrom []string) (*CreatedTx, error) {
+
+	req := createTxRequest{
+		account:         account,
+		pairs:           pairs,
+		minconf:         minconf,
+		subtractFeeFrom: subtractFeeFrom,
+		resp:            make(chan createTxResponse),
+	}
+	w.createTxRequests <- req
+	resp := <-req.resp
+	return resp.tx, resp.err
+}
+
+// CreateSweepTx creates a new signed transaction spending every unspent P2PKH
+// output of an account with at least minconf confirmations to a single
+// address.  The fee is subtracted from the amount paid to the address, and the
+// transaction has no change output.  Like CreateSimpleTx, transaction creation
+// is serialized.
+func (w *Wallet) CreateSweepTx(account uint32, address string,
 	minconf int32) (*CreatedTx, error) {
 
 	req := createTxRequest{
 		account: account,
-		pairs:   pairs,
+		pairs:   map[string]btcutil.Amount{address: 0},
 		minconf: minconf,
+		sweep:   true,
 		resp:    make(chan createTxResponse),
 	}
 	w.createTxRequests <- req
@@ -577,8 +639,12 @@ out:
 // expired.  If the wallet is already unlocked and the new passphrase is
 // correct, the current timeout is replaced with the new one.  The wallet will
 // be locked if the passphrase is incorrect or any other error occurs during the
-// unlock.
+// unlock.  Wallets opened with OpenReadOnly can not be unlocked, and
+// walletdb.ErrDbReadOnly is returned.
 func (w *Wallet) Unlock(passphrase []byte, timeout time.Duration) error {
+	if w.readOnly {
+		return walletdb.ErrDbReadOnly
+	}
 	err := make(chan error, 1)
 	w.unlockRequests <- unlockRequest{
 		passphrase: passphrase,
@@ -590,11 +656,18 @@ func (w *Wallet) Unlock(passphrase []byte, timeout time.Duration) error {
 
 // Lock locks the wallet's address manager.
 func (w *Wallet) Lock() {
+	// Read-only wallets are never unlocked.
+	if w.readOnly {
+		return
+	}
 	w.lockRequests <- struct{}{}
 }
 
 // Locked returns whether the account manager for a wallet is locked.
 func (w *Wallet) Locked() bool {
+	if w.readOnly {
+		return true
+	}
 	return <-w.lockState
 }
 
@@ -605,9 +678,13 @@ func (w *Wallet) Locked() bool {
 // to the walletLocker goroutine and disallow callers from explicitly
 // handling the locking mechanism.
 func (w *Wallet) HoldUnlock() (HeldUnlock, error) {
-	req := make(chan HeldUnlock)
-	w.holdUnlockRequests <- req
-	hl, ok := <-req
+	var hl HeldUnlock
+	ok := false
+	if !w.readOnly {
+		req := make(chan HeldUnlock)
+		w.holdUnlockRequests <- req
+		hl, ok = <-req
+	}
 	if !ok {
 		// TODO(davec): This should be defined and exported from
 		// waddrmgr.
@@ -631,6 +708,9 @@ func (c HeldUnlock) Release() {
 // manager locking and unlocking.  The lock state will be the same as it was
 // before the password change.
 func (w *Wallet) ChangePassphrase(old, new []byte) error {
+	if w.readOnly {
+		return walletdb.ErrDbReadOnly
+	}
 	err := make(chan error, 1)
 	w.changePassphrase <- changePassphraseRequest{
 		old: old,
@@ -676,6 +756,104 @@ func (w *Wallet) CalculateBalance(confirms int32) (btcutil.Amount, error) {
 	return w.TxStore.Balance(confirms, blk.Height)
 }
 
+// CalculateImmatureBalance sums the amounts of all unspent coinbase outputs
+// to addresses of a wallet which have not yet reached maturity, and are
+// therefore not included in the balance returned by CalculateBalance.
+func (w *Wallet) CalculateImmatureBalance() (btcutil.Amount, error) {
+	blk := w.Manager.SyncedTo()
+	return w.TxStore.ImmatureBalance(blk.Height)
+}
+
+// BalanceHistory returns the balance of mined outputs following the main chain
+// block begin, followed by the balance following each later block up to and
+// including end which contains a wallet transaction, as reported by
+// wtxmgr.Store.RangeBalances.  The balance is unchanged for every block which
+// is not reported.  If account is non-nil, only outputs paid to addresses of
+// this account are included.
+func (w *Wallet) BalanceHistory(account *uint32, begin,
+	end *wtxmgr.BlockMeta) ([]wtxmgr.BlockBalance, error) {
+
+	var include func([]byte) bool
+	if account != nil {
+		acctScripts := make(map[string]bool)
+		include = func(pkScript []byte) bool {
+			inAcct, ok := acctScripts[string(pkScript)]
+			if !ok {
+				inAcct = w.pkScriptAccount(pkScript, *account)
+				acctScripts[string(pkScript)] = inAcct
+			}
+			return inAcct
+		}
+	}
+
+	var history []wtxmgr.BlockBalance
+	err := w.TxStore.RangeBalances(begin.Height, end.Height, include,
+		func(b wtxmgr.BlockBalance) (bool, error) {
+			// The store only knows the hash and time of blocks
+			// containing wallet transactions.
+			if len(history) == 0 && b.Block.Hash == (wire.ShaHash{}) {
+				b.Block = *begin
+			}
+			history = append(history, b)
+			return false, nil
+		})
+	return history, err
+}
+
+// DayBalance describes the balance of mined outputs at the end of a day.
+type DayBalance struct {
+	// Date is midnight (in UTC) at the start of the day.
+	Date time.Time
+
+	// Block and Balance are the balance following the last block reported
+	// by BalanceHistory which was mined on or before the day.
+	wtxmgr.BlockBalance
+}
+
+// DailyBalanceHistory returns the balance of mined outputs at the end of every
+// day (in UTC) between the days of the main chain blocks begin and end,
+// inclusive, including the days without any wallet transactions.  Only blocks
+// up to and including end are considered, so the balance of the final day is
+// the balance following end.  If account is non-nil, only outputs paid to
+// addresses of this account are included.
+func (w *Wallet) DailyBalanceHistory(account *uint32, begin,
+	end *wtxmgr.BlockMeta) ([]DayBalance, error) {
+
+	history, err := w.BalanceHistory(account, begin, end)
+	if err != nil {
+		return nil, err
+	}
+	return dailyBalances(history, begin.Time, end.Time), nil
+}
+
+// dailyBalances groups the balances of history, which must begin with the
+// opening balance, by the day of their block, and returns the balance at the
+// end of every day between the days of times begin and end.
+func dailyBalances(history []wtxmgr.BlockBalance, begin, end time.Time) []DayBalance {
+	utcDay := func(t time.Time) time.Time {
+		y, m, d := t.UTC().Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+	lastDay := utcDay(end)
+
+	var days []DayBalance
+	var bal wtxmgr.BlockBalance
+	i := 0
+	for day := utcDay(begin); !day.After(lastDay); {
+		next := day.AddDate(0, 0, 1)
+		// Block times are not strictly increasing, so any blocks
+		// timestamped after the last day are included in it.
+		for i < len(history) && (history[i].Block.Time.Before(next) ||
+			next.After(lastDay)) {
+			bal = history[i]
+			i++
+		}
+		days = append(days, DayBalance{Date: day, BlockBalance: bal})
+		day = next
+	}
+	return days
+}
+
 // CalculateAccountBalance sums the amounts of all unspent transaction
 // outputs to the given account of a wallet and returns the balance.
 func (w *Wallet) CalculateAccountBalance(account uint32, confirms int32) (btcutil.Amount, error) {
@@ -715,6 +893,34 @@ func (w *Wallet) CalculateAccountBalance(account uint32, confirms int32) (btcuti
 	return bal, nil
 }
 
+// CalculateAccountImmatureBalance sums the amounts of all unspent coinbase
+// outputs to the given account of a wallet which have not yet reached
+// maturity, and are therefore not included in the balance returned by
+// CalculateAccountBalance.
+func (w *Wallet) CalculateAccountImmatureBalance(account uint32) (btcutil.Amount, error) {
+	var bal btcutil.Amount
+
+	syncBlock := w.Manager.SyncedTo()
+
+	unspent, err := w.TxStore.UnspentOutputs()
+	if err != nil {
+		return 0, err
+	}
+	for i := range unspent {
+		output := &unspent[i]
+
+		const target = blockchain.CoinbaseMaturity
+		if !output.FromCoinBase || output.Height == -1 ||
+			confirmed(target, output.Height, syncBlock.Height) {
+			continue
+		}
+		if w.pkScriptAccount(output.PkScript, account) {
+			bal += output.Amount
+		}
+	}
+	return bal, nil
+}
+
 // CurrentAddress gets the most recently requested Bitcoin payment address
 // from a wallet.  If the address has already been used (there is at least
 // one transaction spending to it in the blockchain or btcd mempool), the next
@@ -753,6 +959,7 @@ const (
 	CreditReceive CreditCategory = iota
 	CreditGenerate
 	CreditImmature
+	CreditOrphan
 )
 
 // String returns the category as a string.  This string may be used as the
@@ -766,6 +973,8 @@ func (c CreditCategory) String() string {
 		return "generate"
 	case CreditImmature:
 		return "immature"
+	case CreditOrphan:
+		return "orphan"
 	default:
 		return "unknown"
 	}
@@ -773,12 +982,17 @@ func (c CreditCategory) String() string {
 
 // RecvCategory returns the category of received credit outputs from a
 // transaction record.  The passed block chain height is used to distinguish
-// immature from mature coinbase outputs.
+// immature from mature coinbase outputs.  Coinbase outputs of orphaned
+// coinbase transactions, which are reported as conflicted transactions with a
+// block height of -1, are categorized as orphans.
 //
 // TODO: This is intended for use by the RPC server and should be moved out of
 // this package at a later time.
 func RecvCategory(details *wtxmgr.TxDetails, syncHeight int32) CreditCategory {
 	if blockchain.IsCoinBaseTx(&details.MsgTx) {
+		if details.Block.Height == -1 {
+			return CreditOrphan
+		}
 		if confirmed(blockchain.CoinbaseMaturity, details.Block.Height, syncHeight) {
 			return CreditGenerate
 		}
@@ -812,9 +1026,12 @@ func ListTransactions(details *wtxmgr.TxDetails, syncHeight int32, net *chaincfg
 
 	send := len(details.Debits) != 0
 
-	// Fee can only be determined if every input is a debit.
+	// Fee can only be determined if the wallet recorded it when creating
+	// the transaction, or if every input is a debit.
 	var feeF64 float64
-	if len(details.Debits) == len(details.MsgTx.TxIn) {
+	if details.Sent != nil {
+		feeF64 = -details.Sent.Fee.ToBTC()
+	} else if len(details.Debits) == len(details.MsgTx.TxIn) {
 		var debitTotal btcutil.Amount
 		for _, deb := range details.Debits {
 			debitTotal += deb.Amount
@@ -831,6 +1048,23 @@ func ListTransactions(details *wtxmgr.TxDetails, syncHeight int32, net *chaincfg
 
 outputs:
 	for i, output := range details.MsgTx.TxOut {
+		// For transactions created by the wallet, only the recorded
+		// recipients are reported under the send category, and the
+		// change output is always ignored.
+		isRecipient := send
+		if details.Sent != nil {
+			if i == details.Sent.ChangeIndex {
+				continue
+			}
+			isRecipient = false
+			for _, index := range details.Sent.Recipients {
+				if index == uint32(i) {
+					isRecipient = true
+					break
+				}
+			}
+		}
+
 		// Determine if this output is a credit, and if so, determine
 		// its spentness.
 		var isCredit bool
@@ -885,9 +1119,11 @@ outputs:
 		//
 		// Since credits are not saved for outputs that are not
 		// controlled by this wallet, all non-credits from transactions
-		// with debits are grouped under the send category.
+		// with debits are grouped under the send category, unless the
+		// recipients were recorded when the wallet created the
+		// transaction.
 
-		if send || spentCredit {
+		if isRecipient || spentCredit {
 			result.Category = "send"
 			result.Amount = -amountF64
 			result.Fee = &feeF64
@@ -903,6 +1139,30 @@ outputs:
 	return results
 }
 
+// ListConflictedTransaction creates listtransactions results for a
+// transaction which was removed from the store because it conflicted with a
+// mined transaction or was abandoned.  As with bitcoind, the results report a
+// negative number of confirmations, which is the depth of the block at which
+// the conflict was detected, and the winning transaction, if any, as a wallet
+// conflict.
+func ListConflictedTransaction(c *wtxmgr.ConflictedTx, syncHeight int32, net *chaincfg.Params) []btcjson.ListTransactionsResult {
+	conflictConfs := confirms(c.Height, syncHeight)
+	if conflictConfs < 1 {
+		conflictConfs = 1
+	}
+	walletConflicts := []string{}
+	if c.WinningTx != (wire.ShaHash{}) {
+		walletConflicts = append(walletConflicts, c.WinningTx.String())
+	}
+
+	results := ListTransactions(&c.TxDetails, syncHeight, net)
+	for i := range results {
+		results[i].Confirmations = -int64(conflictConfs)
+		results[i].WalletConflicts = walletConflicts
+	}
+	return results
+}
+
 // ListSinceBlock returns a slice of objects with details about transactions
 // since the given block. If the block is -1 then all transactions are included.
 // This is intended to be used for listsinceblock RPC replies.
@@ -919,9 +1179,151 @@ func (w *Wallet) ListSinceBlock(start, end, syncHeight int32) ([]btcjson.ListTra
 	return txList, err
 }
 
+// historyIterator iterates over the transaction history of a wallet, newest
+// first, interleaving the conflicted transactions with the transactions of the
+// store by the height at which each conflict was detected.  Conflicts detected
+// at a height are returned after any unmined transactions and transactions
+// mined in later blocks, and before the transactions mined in the block at that
+// height.
+type historyIterator struct {
+	txs     *wtxmgr.TxIterator
+	pending *wtxmgr.TxDetails // Read from txs but not yet returned
+	txsDone bool
+
+	// Remaining conflicted transactions are conflicted[:next+1], which
+	// are returned from last to first.
+	conflicted []wtxmgr.ConflictedTx
+	next       int
+
+	storeToken wtxmgr.PageToken // Position after the last returned store tx
+	cursor     []byte           // Position of the last returned conflict
+
+	details  *wtxmgr.TxDetails
+	conflict *wtxmgr.ConflictedTx
+}
+
+// conflictPosition returns the position of a conflicted transaction recorded
+// in the tokens of a historyIterator.
+func conflictPosition(c *wtxmgr.ConflictedTx) []byte {
+	pos := make([]byte, 4+wire.HashSize)
+	binary.BigEndian.PutUint32(pos, uint32(c.Height))
+	copy(pos[4:], c.Hash[:])
+	return pos
+}
+
+// history returns an iterator over the transaction history of the wallet,
+// beginning after the position of token, which is either empty or returned by
+// the Token method of an earlier iterator.
+func (w *Wallet) history(token wtxmgr.PageToken) (*historyIterator, error) {
+	// Tokens are the token of the store iterator, followed by the position
+	// of the last returned conflict if any conflicts have been returned.
+	storeToken, cursor := token, []byte(nil)
+	if i := strings.IndexByte(string(token), '.'); i != -1 {
+		var err error
+		storeToken = token[:i]
+		cursor, err = hex.DecodeString(string(token[i+1:]))
+		if err != nil || len(cursor) != 4+wire.HashSize {
+			return nil, wtxmgr.Error{
+				Code: wtxmgr.ErrInput,
+				Desc: "invalid page token",
+			}
+		}
+	}
+
+	conflicted, err := w.TxStore.ConflictedTxs()
+	if err != nil {
+		return nil, err
+	}
+	it := &historyIterator{
+		txs:        w.TxStore.Transactions(-1, 0, storeToken),
+		conflicted: conflicted,
+		next:       len(conflicted) - 1,
+		storeToken: storeToken,
+		cursor:     cursor,
+	}
+
+	// Conflicts are returned in order of decreasing height and then hash,
+	// so skip those at or before the position of the token.
+	if cursor != nil {
+		for it.next >= 0 && bytes.Compare(
+			conflictPosition(&conflicted[it.next]), cursor) >= 0 {
+			it.next--
+		}
+	}
+	return it, nil
+}
+
+// Next advances the iterator to the next transaction, returning false when
+// there are no more transactions or an error occurred.
+func (it *historyIterator) Next() bool {
+	it.details, it.conflict = nil, nil
+	if it.pending == nil && !it.txsDone {
+		if it.txs.Next() {
+			details := *it.txs.Details()
+			it.pending = &details
+		} else {
+			it.txsDone = true
+			if it.txs.Err() != nil {
+				return false
+			}
+		}
+	}
+
+	if it.next >= 0 {
+		c := &it.conflicted[it.next]
+		if it.pending == nil || (it.pending.Block.Height != -1 &&
+			c.Height >= it.pending.Block.Height) {
+
+			it.conflict = c
+			it.cursor = conflictPosition(c)
+			it.next--
+			return true
+		}
+	}
+	if it.pending == nil {
+		return false
+	}
+	it.details = it.pending
+	it.pending = nil
+	it.storeToken = it.txs.Token()
+	return true
+}
+
+// Results returns the listtransactions results for the current transaction.
+func (it *historyIterator) Results(syncHeight int32, net *chaincfg.Params) []btcjson.ListTransactionsResult {
+	if it.conflict != nil {
+		return ListConflictedTransaction(it.conflict, syncHeight, net)
+	}
+	return ListTransactions(it.details, syncHeight, net)
+}
+
+// Details returns the details of the current transaction, which is either a
+// transaction of the store or a conflicted transaction.
+func (it *historyIterator) Details() *wtxmgr.TxDetails {
+	if it.conflict != nil {
+		return &it.conflict.TxDetails
+	}
+	return it.details
+}
+
+// Token returns a token positioned after the current transaction.
+func (it *historyIterator) Token() wtxmgr.PageToken {
+	if it.cursor == nil {
+		return it.storeToken
+	}
+	return it.storeToken + "." + wtxmgr.PageToken(hex.EncodeToString(it.cursor))
+}
+
+// Err returns the error, if any, which stopped iteration.
+func (it *historyIterator) Err() error {
+	return it.txs.Err()
+}
+
 // ListTransactions returns a slice of objects with details about a recorded
-// transaction.  This is intended to be used for listtransactions RPC
-// replies.
+// transaction.  Transactions removed because they conflicted with a mined
+// transaction are included with negative confirmations, ordered by the height
+// at which the conflict was detected.  This is intended to be used for
+// listtransactions RPC replies.
 func (w *Wallet) ListTransactions(from, count int) ([]btcjson.ListTransactionsResult, error) {
 	txList := []btcjson.ListTransactionsResult{}
 
@@ -929,38 +1331,117 @@ func (w *Wallet) ListTransactions(from, count int) ([]btcjson.ListTransactionsRe
 	// the number of tx confirmations.
 	syncBlock := w.Manager.SyncedTo()
 
-	// Need to skip the first from transactions, and after those, only
-	// include the next count transactions.
-	skipped := 0
-	n := 0
+	// Return newer results first, skipping the first from transactions
+	// and only including the next count transactions after those.
+	it, err := w.history("")
+	if err != nil {
+		return txList, err
+	}
+	for n := 0; n < from+count && it.Next(); n++ {
+		if n < from {
+			continue
+		}
+		jsonResults := it.Results(syncBlock.Height, w.chainParams)
+		txList = append(txList, jsonResults...)
+	}
+	return txList, it.Err()
+}
+
+// ListTransactionsPage returns a slice of objects with details about up to
+// count recorded transactions, newest first, beginning after the position of
+// token, and the token to request the next page of results with.  Conflicted
+// transactions are included as done by ListTransactions.  If account is not
+// nil, only transactions crediting or debiting an address of the account are
+// included.  Unlike ListTransactions, transactions before the page are not
+// read, which allows the history of wallets with very large numbers of
+// transactions to be returned over many requests.  The returned token is empty
+// once every transaction has been returned.  This is intended to be used for
+// listtransactionspage RPC replies.
+func (w *Wallet) ListTransactionsPage(account *uint32, count int,
+	token wtxmgr.PageToken) ([]btcjson.ListTransactionsResult,
+	wtxmgr.PageToken, error) {
+
+	txList := []btcjson.ListTransactionsResult{}
+
+	// Get current block.  The block height used for calculating
+	// the number of tx confirmations.
+	syncBlock := w.Manager.SyncedTo()
 
 	// Return newer results first by starting at mempool height and working
 	// down to the genesis block.
-	err := w.TxStore.RangeTransactions(-1, 0, func(details []wtxmgr.TxDetails) (bool, error) {
-		// Iterate over transactions at this height in reverse order.
-		// This does nothing for unmined transactions, which are
-		// unsorted, but it will process mined transactions in the
-		// reverse order they were marked mined.
-		for i := len(details) - 1; i >= 0; i-- {
-			if from > skipped {
-				skipped++
+	it, err := w.history(token)
+	if err != nil {
+		return nil, "", err
+	}
+	n := 0
+	for n < count && it.Next() {
+		if account != nil {
+			ok, err := w.txInvolvesAccount(it.Details(), *account)
+			if err != nil {
+				return nil, "", err
+			}
+			if !ok {
 				continue
 			}
+		}
 
-			n++
-			if n > count {
-				return true, nil
-			}
+		n++
+		jsonResults := it.Results(syncBlock.Height, w.chainParams)
+		txList = append(txList, jsonResults...)
+	}
+	if err := it.Err(); err != nil {
+		return nil, "", err
+	}
+	if n < count {
+		// Every remaining transaction was read.
+		return txList, "", nil
+	}
+	return txList, it.Token(), nil
+}
 
-			jsonResults := ListTransactions(&details[i],
-				syncBlock.Height, w.chainParams)
-			txList = append(txList, jsonResults...)
-		}
+// txInvolvesAccount returns whether a transaction credits an address of an
+// account, or debits an output previously paid to one.
+func (w *Wallet) txInvolvesAccount(details *wtxmgr.TxDetails,
+	account uint32) (bool, error) {
 
-		return false, nil
-	})
+	for _, cred := range details.Credits {
+		pkScript := details.MsgTx.TxOut[cred.Index].PkScript
+		if w.pkScriptAccount(pkScript, account) {
+			return true, nil
+		}
+	}
+	for _, deb := range details.Debits {
+		prevOut := &details.MsgTx.TxIn[deb.Index].PreviousOutPoint
+		prev, err := w.TxStore.TxDetails(&prevOut.Hash)
+		if err != nil {
+			return false, err
+		}
+		if prev == nil || prevOut.Index >= uint32(len(prev.MsgTx.TxOut)) {
+			continue
+		}
+		pkScript := prev.MsgTx.TxOut[prevOut.Index].PkScript
+		if w.pkScriptAccount(pkScript, account) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
-	return txList, err
+// pkScriptAccount returns whether any address of an output script belongs to
+// an account.
+func (w *Wallet) pkScriptAccount(pkScript []byte, account uint32) bool {
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		w.chainParams)
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		acct, err := w.Manager.AddrAccount(addr)
+		if err == nil && acct == account {
+			return true
+		}
+	}
+	return false
 }
 
 // ListAddressTransactions returns a slice of objects with details about
@@ -975,45 +1456,52 @@ func (w *Wallet) ListAddressTransactions(pkHashes map[string]struct{}) (
 	// the number of tx confirmations.
 	syncBlock := w.Manager.SyncedTo()
 
-	err := w.TxStore.RangeTransactions(0, -1, func(details []wtxmgr.TxDetails) (bool, error) {
-	loopDetails:
-		for i := range details {
-			detail := &details[i]
+	// Look up the credits of each pubkey hash's output script in the
+	// address index.
+	pkScripts := make([][]byte, 0, len(pkHashes))
+	for pkHash := range pkHashes {
+		addr, err := btcutil.NewAddressPubKeyHash([]byte(pkHash),
+			w.chainParams)
+		if err != nil {
+			continue
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, err
+		}
+		pkScripts = append(pkScripts, pkScript)
+	}
+	credits, err := w.TxStore.AddressCredits(pkScripts...)
+	if err != nil {
+		return nil, err
+	}
 
-			for _, cred := range detail.Credits {
-				pkScript := detail.MsgTx.TxOut[cred.Index].PkScript
-				_, addrs, _, err := txscript.ExtractPkScriptAddrs(
-					pkScript, w.chainParams)
-				if err != nil || len(addrs) != 1 {
-					continue
-				}
-				apkh, ok := addrs[0].(*btcutil.AddressPubKeyHash)
-				if !ok {
-					continue
-				}
-				_, ok = pkHashes[string(apkh.ScriptAddress())]
-				if !ok {
-					continue
-				}
+	seen := make(map[wire.ShaHash]struct{}, len(credits))
+	for i := range credits {
+		txHash := &credits[i].OutPoint.Hash
+		if _, ok := seen[*txHash]; ok {
+			continue
+		}
+		seen[*txHash] = struct{}{}
 
-				jsonResults := ListTransactions(detail,
-					syncBlock.Height, w.chainParams)
-				if err != nil {
-					return false, err
-				}
-				txList = append(txList, jsonResults...)
-				continue loopDetails
-			}
+		details, err := w.TxStore.TxDetails(txHash)
+		if err != nil {
+			return nil, err
 		}
-		return false, nil
-	})
+		if details == nil {
+			continue
+		}
+		jsonResults := ListTransactions(details, syncBlock.Height,
+			w.chainParams)
+		txList = append(txList, jsonResults...)
+	}
 
-	return txList, err
+	return txList, nil
 }
 
 // ListAllTransactions returns a slice of objects with details about a recorded
-// transaction.  This is intended to be used for listalltransactions RPC
-// replies.
+// transaction, including conflicted transactions as done by ListTransactions.
+// This is intended to be used for listalltransactions RPC replies.
 func (w *Wallet) ListAllTransactions() ([]btcjson.ListTransactionsResult, error) {
 	txList := []btcjson.ListTransactionsResult{}
 
@@ -1023,20 +1511,15 @@ func (w *Wallet) ListAllTransactions() ([]btcjson.ListTransactionsResult, error)
 
 	// Return newer results first by starting at mempool height and working
 	// down to the genesis block.
-	err := w.TxStore.RangeTransactions(-1, 0, func(details []wtxmgr.TxDetails) (bool, error) {
-		// Iterate over transactions at this height in reverse order.
-		// This does nothing for unmined transactions, which are
-		// unsorted, but it will process mined transactions in the
-		// reverse order they were marked mined.
-		for i := len(details) - 1; i >= 0; i-- {
-			jsonResults := ListTransactions(&details[i],
-				syncBlock.Height, w.chainParams)
-			txList = append(txList, jsonResults...)
-		}
-		return false, nil
-	})
-
-	return txList, err
+	it, err := w.history("")
+	if err != nil {
+		return txList, err
+	}
+	for it.Next() {
+		jsonResults := it.Results(syncBlock.Height, w.chainParams)
+		txList = append(txList, jsonResults...)
+	}
+	return txList, it.Err()
 }
 
 // creditSlice satisifies the sort.Interface interface to provide sorting
@@ -1086,8 +1569,6 @@ func (w *Wallet) ListUnspent(minconf, maxconf int32,
 
 	syncBlock := w.Manager.SyncedTo()
 
-	filter := len(addresses) != 0
-
 	unspent, err := w.TxStore.UnspentOutputs()
 	if err != nil {
 		return nil, err
@@ -1099,118 +1580,184 @@ func (w *Wallet) ListUnspent(minconf, maxconf int32,
 		return nil, err
 	}
 
+	locked, err := w.lockedOutpoints()
+	if err != nil {
+		return nil, err
+	}
+
 	results := make([]*btcjson.ListUnspentResult, 0, len(unspent))
 	for i := range unspent {
-		output := &unspent[i]
-
-		// Outputs with fewer confirmations than the minimum or more
-		// confs than the maximum are excluded.
-		confs := confirms(output.Height, syncBlock.Height)
-		if confs < minconf || confs > maxconf {
-			continue
+		result, err := w.unspentResult(&unspent[i], syncBlock.Height,
+			minconf, maxconf, addresses, locked, defaultAccountName)
+		if err != nil {
+			return nil, err
 		}
-
-		// Only mature coinbase outputs are included.
-		if output.FromCoinBase {
-			const target = blockchain.CoinbaseMaturity
-			if !confirmed(target, output.Height, syncBlock.Height) {
-				continue
-			}
+		if result != nil {
+			results = append(results, result)
 		}
+	}
 
-		// Exclude locked outputs from the result set.
-		if w.LockedOutpoint(output.OutPoint) {
-			continue
-		}
+	return results, nil
+}
 
-		// Lookup the associated account for the output.  Use the
-		// default account name in case there is no associated account
-		// for some reason, although this should never happen.
-		//
-		// This will be unnecessary once transactions and outputs are
-		// grouped under the associated account in the db.
-		acctName := defaultAccountName
-		sc, addrs, _, err := txscript.ExtractPkScriptAddrs(
-			output.PkScript, w.chainParams)
+// ListUnspentPage returns up to count objects representing the unspent wallet
+// transactions fitting the given criteria, beginning after the position of
+// token, and the token to request the next page of results with.  Unlike
+// ListUnspent, outputs are returned in order of their outpoints (mined outputs
+// first) rather than sorted, which allows very large numbers of outputs to be
+// returned over many requests without reading all of them for each request.
+// The returned token is empty once every output has been returned.  This is
+// intended to be used for listunspentpage RPC replies.
+func (w *Wallet) ListUnspentPage(minconf, maxconf int32,
+	addresses map[string]struct{}, count int, token wtxmgr.PageToken) (
+	[]*btcjson.ListUnspentResult, wtxmgr.PageToken, error) {
+
+	syncBlock := w.Manager.SyncedTo()
+
+	defaultAccountName, err := w.Manager.AccountName(waddrmgr.DefaultAccountNum)
+	if err != nil {
+		return nil, "", err
+	}
+
+	locked, err := w.lockedOutpoints()
+	if err != nil {
+		return nil, "", err
+	}
+
+	results := []*btcjson.ListUnspentResult{}
+	it := w.TxStore.Unspent(token)
+	for len(results) < count && it.Next() {
+		result, err := w.unspentResult(it.Credit(), syncBlock.Height,
+			minconf, maxconf, addresses, locked, defaultAccountName)
 		if err != nil {
-			continue
+			return nil, "", err
 		}
-		if len(addrs) > 0 {
-			acct, err := w.Manager.AddrAccount(addrs[0])
-			if err == nil {
-				s, err := w.Manager.AccountName(acct)
-				if err == nil {
-					acctName = s
-				}
-			}
+		if result != nil {
+			results = append(results, result)
 		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, "", err
+	}
+	if len(results) < count {
+		// Every remaining output was read.
+		return results, "", nil
+	}
+	return results, it.Token(), nil
+}
 
-		if filter {
-			for _, addr := range addrs {
-				_, ok := addresses[addr.EncodeAddress()]
-				if ok {
-					goto include
-				}
-			}
-			continue
+// unspentResult returns the listunspent result for an unspent output, or nil
+// if the output does not fit the given criteria or is locked.
+func (w *Wallet) unspentResult(output *wtxmgr.Credit, syncHeight int32,
+	minconf, maxconf int32, addresses map[string]struct{},
+	locked map[wire.OutPoint]struct{},
+	defaultAccountName string) (*btcjson.ListUnspentResult, error) {
+
+	// Outputs with fewer confirmations than the minimum or more
+	// confs than the maximum are excluded.
+	confs := confirms(output.Height, syncHeight)
+	if confs < minconf || confs > maxconf {
+		return nil, nil
+	}
+
+	// Only mature coinbase outputs are included.
+	if output.FromCoinBase {
+		const target = blockchain.CoinbaseMaturity
+		if !confirmed(target, output.Height, syncHeight) {
+			return nil, nil
 		}
+	}
 
-	include:
-		// At the moment watch-only addresses are not supported, so all
-		// recorded outputs that are not multisig are "spendable".
-		// Multisig outputs are only "spendable" if all keys are
-		// controlled by this wallet.
-		//
-		// TODO: Each case will need updates when watch-only addrs
-		// is added.  For P2PK, P2PKH, and P2SH, the address must be
-		// looked up and not be watching-only.  For multisig, all
-		// pubkeys must belong to the manager with the associated
-		// private key (currently it only checks whether the pubkey
-		// exists, since the private key is required at the moment).
-		var spendable bool
-	scSwitch:
-		switch sc {
-		case txscript.PubKeyHashTy:
-			spendable = true
-		case txscript.PubKeyTy:
-			spendable = true
-		case txscript.ScriptHashTy:
-			spendable = true
-		case txscript.MultiSigTy:
-			for _, a := range addrs {
-				_, err := w.Manager.Address(a)
-				if err == nil {
-					continue
-				}
-				if waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound) {
-					break scSwitch
-				}
-				return nil, err
+	// Exclude locked outputs from the result set.
+	if _, ok := locked[output.OutPoint]; ok {
+		return nil, nil
+	}
+
+	// Lookup the associated account for the output.  Use the
+	// default account name in case there is no associated account
+	// for some reason, although this should never happen.
+	//
+	// This will be unnecessary once transactions and outputs are
+	// grouped under the associated account in the db.
+	acctName := defaultAccountName
+	sc, addrs, _, err := txscript.ExtractPkScriptAddrs(
+		output.PkScript, w.chainParams)
+	if err != nil {
+		return nil, nil
+	}
+	if len(addrs) > 0 {
+		acct, err := w.Manager.AddrAccount(addrs[0])
+		if err == nil {
+			s, err := w.Manager.AccountName(acct)
+			if err == nil {
+				acctName = s
 			}
-			spendable = true
 		}
+	}
 
-		result := &btcjson.ListUnspentResult{
-			TxID:          output.OutPoint.Hash.String(),
-			Vout:          output.OutPoint.Index,
-			Account:       acctName,
-			ScriptPubKey:  hex.EncodeToString(output.PkScript),
-			Amount:        output.Amount.ToBTC(),
-			Confirmations: int64(confs),
-			Spendable:     spendable,
+	if len(addresses) != 0 {
+		for _, addr := range addrs {
+			_, ok := addresses[addr.EncodeAddress()]
+			if ok {
+				goto include
+			}
 		}
+		return nil, nil
+	}
 
-		// BUG: this should be a JSON array so that all
-		// addresses can be included, or removed (and the
-		// caller extracts addresses from the pkScript).
-		if len(addrs) > 0 {
-			result.Address = addrs[0].EncodeAddress()
+include:
+	// At the moment watch-only addresses are not supported, so all
+	// recorded outputs that are not multisig are "spendable".
+	// Multisig outputs are only "spendable" if all keys are
+	// controlled by this wallet.
+	//
+	// TODO: Each case will need updates when watch-only addrs
+	// is added.  For P2PK, P2PKH, and P2SH, the address must be
+	// looked up and not be watching-only.  For multisig, all
+	// pubkeys must belong to the manager with the associated
+	// private key (currently it only checks whether the pubkey
+	// exists, since the private key is required at the moment).
+	var spendable bool
+scSwitch:
+	switch sc {
+	case txscript.PubKeyHashTy:
+		spendable = true
+	case txscript.PubKeyTy:
+		spendable = true
+	case txscript.ScriptHashTy:
+		spendable = true
+	case txscript.MultiSigTy:
+		for _, a := range addrs {
+			_, err := w.Manager.Address(a)
+			if err == nil {
+				continue
+			}
+			if waddrmgr.IsError(err, waddrmgr.ErrAddressNotFound) {
+				break scSwitch
+			}
+			return nil, err
 		}
+		spendable = true
+	}
 
-		results = append(results, result)
+	result := &btcjson.ListUnspentResult{
+		TxID:          output.OutPoint.Hash.String(),
+		Vout:          output.OutPoint.Index,
+		Account:       acctName,
+		ScriptPubKey:  hex.EncodeToString(output.PkScript),
+		Amount:        output.Amount.ToBTC(),
+		Confirmations: int64(confs),
+		Spendable:     spendable,
 	}
 
-	return results, nil
+	// BUG: this should be a JSON array so that all
+	// addresses can be included, or removed (and the
+	// caller extracts addresses from the pkScript).
+	if len(addrs) > 0 {
+		result.Address = addrs[0].EncodeAddress()
+	}
+
+	return result, nil
 }
 
 // DumpPrivKeys returns the WIF-encoded private keys for all addresses with
@@ -1378,44 +1925,74 @@ func (w *Wallet) exportBase64() (string, error) {
 }
 
 // LockedOutpoint returns whether an outpoint has been marked as locked and
-// should not be used as an input for created transactions.
+// should not be used as an input for created transactions.  Outpoints whose
+// locks have expired are not locked.  The outpoint is reported as locked if the
+// lock could not be read, so it is never spent unintentionally.
 func (w *Wallet) LockedOutpoint(op wire.OutPoint) bool {
-	_, locked := w.lockedOutpoints[op]
+	locked, err := w.TxStore.IsLockedOutput(&op, time.Now())
+	if err != nil {
+		log.Errorf("Cannot determine whether outpoint %v is locked: %v",
+			op, err)
+		return true
+	}
 	return locked
 }
 
+// lockedOutpoints returns the set of outpoints which are currently locked.
+// Callers filtering many outputs read the locks once with this rather than
+// calling LockedOutpoint for each output.
+func (w *Wallet) lockedOutpoints() (map[wire.OutPoint]struct{}, error) {
+	locks, err := w.TxStore.LockedOutputs(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	locked := make(map[wire.OutPoint]struct{}, len(locks))
+	for i := range locks {
+		locked[locks[i].OutPoint] = struct{}{}
+	}
+	return locked, nil
+}
+
 // LockOutpoint marks an outpoint as locked, that is, it should not be used as
-// an input for newly created transactions.
-func (w *Wallet) LockOutpoint(op wire.OutPoint) {
-	w.lockedOutpoints[op] = struct{}{}
+// an input for newly created transactions.  Locks are saved in the wallet
+// database and are released when the outpoint is spent by a mined transaction.
+// If expiry is not the zero time, the outpoint is automatically unlocked at
+// that time.  The reason is saved with the lock to be reported by
+// LockedOutpoints.
+func (w *Wallet) LockOutpoint(op wire.OutPoint, expiry time.Time, reason string) error {
+	return w.TxStore.LockOutput(&op, expiry, reason)
 }
 
 // UnlockOutpoint marks an outpoint as unlocked, that is, it may be used as an
 // input for newly created transactions.
-func (w *Wallet) UnlockOutpoint(op wire.OutPoint) {
-	delete(w.lockedOutpoints, op)
+func (w *Wallet) UnlockOutpoint(op wire.OutPoint) error {
+	return w.TxStore.UnlockOutput(&op)
 }
 
 // ResetLockedOutpoints resets the set of locked outpoints so all may be used
 // as inputs for new transactions.
-func (w *Wallet) ResetLockedOutpoints() {
-	w.lockedOutpoints = map[wire.OutPoint]struct{}{}
+func (w *Wallet) ResetLockedOutpoints() error {
+	return w.TxStore.UnlockAllOutputs()
 }
 
-// LockedOutpoints returns a slice of currently locked outpoints.  This is
-// intended to be used by marshaling the result as a JSON array for
-// listlockunspent RPC results.
-func (w *Wallet) LockedOutpoints() []btcjson.TransactionInput {
-	locked := make([]btcjson.TransactionInput, len(w.lockedOutpoints))
-	i := 0
-	for op := range w.lockedOutpoints {
-		locked[i] = btcjson.TransactionInput{
-			Txid: op.Hash.String(),
-			Vout: op.Index,
-		}
-		i++
+// LockedOutpoints returns the locks of all currently locked outpoints.  This
+// is intended to be used for listlockunspent RPC results.
+func (w *Wallet) LockedOutpoints() ([]wtxmgr.LockedOutput, error) {
+	return w.TxStore.LockedOutputs(time.Now())
+}
+
+// removeExpiredLocks removes the locks of outpoints which have expired.
+// Expired locks no longer lock their outpoints, so this only reclaims their
+// space in the database.
+func (w *Wallet) removeExpiredLocks() {
+	n, err := w.TxStore.RemoveExpiredLocks(time.Now())
+	if err != nil {
+		log.Errorf("Cannot remove expired outpoint locks: %v", err)
+		return
+	}
+	if n != 0 {
+		log.Debugf("Removed %d expired outpoint locks", n)
 	}
-	return locked
 }
 
 // ResendUnminedTxs iterates through all transactions that spend from wallet
@@ -1430,16 +2007,60 @@ func (w *Wallet) ResendUnminedTxs() {
 	for _, tx := range txs {
 		resp, err := w.chainSvr.SendRawTransaction(tx, false)
 		if err != nil {
-			// TODO(jrick): Check error for if this tx is a double spend,
-			// remove it if so.
+			// A transaction spending an output already spent by
+			// another transaction will never be mined, so it is
+			// removed, along with the transactions spending its
+			// outputs.  Double spends of mined transactions are
+			// instead removed when the block is connected.
+			txSha := tx.TxSha()
+			if isDoubleSpendError(err) {
+				log.Infof("Removing unmined transaction %v "+
+					"rejected as a double spend: %v", txSha, err)
+				err = w.AbandonTransaction(&txSha)
+				if err != nil {
+					log.Errorf("Cannot remove double spending "+
+						"transaction %v: %v", txSha, err)
+				}
+				continue
+			}
 			log.Debugf("Could not resend transaction %v: %v",
-				tx.TxSha(), err)
+				txSha, err)
 			continue
 		}
 		log.Debugf("Resent unmined transaction %v", resp)
 	}
 }
 
+// errRPCVerifyRejected is the error code used by chain servers when rejecting
+// a transaction which conflicts with their memory pool.
+const errRPCVerifyRejected btcjson.RPCErrorCode = -26
+
+// isDoubleSpendError returns whether err is the error returned by the chain
+// server when rejecting a transaction which spends an output already spent by
+// another transaction.  Only the verification error codes are checked, so
+// errors reaching the server or decoding the transaction are never treated as
+// double spends.
+func isDoubleSpendError(err error) bool {
+	rpcErr, ok := err.(*btcjson.RPCError)
+	if !ok {
+		return false
+	}
+	switch rpcErr.Code {
+	case btcjson.ErrRPCVerify, errRPCVerifyRejected:
+		return true
+	}
+	return false
+}
+
+// AbandonTransaction removes an unmined transaction which is not expected to
+// be mined, and every transaction spending its outputs, from the wallet.  The
+// outputs spent by the removed transactions may be spent again, and the
+// removed transactions are reported as conflicted transactions.  This is
+// intended to be used for abandontransaction RPC replies.
+func (w *Wallet) AbandonTransaction(txHash *wire.ShaHash) error {
+	return w.TxStore.AbandonTx(txHash, w.Manager.SyncedTo().Height)
+}
+
 // SortedActivePaymentAddresses returns a slice of all active payment
 // addresses in a wallet.
 func (w *Wallet) SortedActivePaymentAddresses() ([]string, error) {
@@ -1555,72 +2176,96 @@ func (w *Wallet) TotalReceivedForAccount(account uint32, minConf int32) (btcutil
 	return amount, lastConf, err
 }
 
-// TotalReceivedForAddr iterates through a wallet's transaction history,
-// returning the total amount of bitcoins received for a single wallet
-// address.
+// TotalReceivedForAddr returns the total amount of bitcoins received for a
+// single wallet address, looking up the credits paying to the address's output
+// script in the transaction store's address index.
 func (w *Wallet) TotalReceivedForAddr(addr btcutil.Address, minConf int32) (btcutil.Amount, error) {
 	syncBlock := w.Manager.SyncedTo()
 
-	var (
-		addrStr    = addr.EncodeAddress()
-		amount     btcutil.Amount
-		stopHeight int32
-	)
-
-	if minConf > 0 {
-		stopHeight = syncBlock.Height - minConf + 1
-	} else {
-		stopHeight = -1
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return 0, err
 	}
-	err := w.TxStore.RangeTransactions(0, stopHeight, func(details []wtxmgr.TxDetails) (bool, error) {
-		for i := range details {
-			detail := &details[i]
-			for _, cred := range detail.Credits {
-				pkScript := detail.MsgTx.TxOut[cred.Index].PkScript
-				_, addrs, _, err := txscript.ExtractPkScriptAddrs(
-					pkScript, w.chainParams)
-				// An error creating addresses from the output script only
-				// indicates a non-standard script, so ignore this credit.
-				if err != nil {
-					continue
-				}
-				for _, a := range addrs {
-					if addrStr == a.EncodeAddress() {
-						amount += cred.Amount
-						break
-					}
-				}
-			}
+	credits, err := w.TxStore.AddressCredits(pkScript)
+	if err != nil {
+		return 0, err
+	}
+
+	var amount btcutil.Amount
+	for i := range credits {
+		if confirmed(minConf, credits[i].Block.Height, syncBlock.Height) {
+			amount += credits[i].Amount
 		}
-		return false, nil
-	})
-	return amount, err
+	}
+	return amount, nil
 }
 
 // SendPairs creates and sends payment transactions. It returns the transaction
-// hash upon success
+// hash upon success.  The fee is subtracted from the amounts paid to the
+// addresses of subtractFeeFrom, if any, as described by CreateSimpleTx.
 func (w *Wallet) SendPairs(amounts map[string]btcutil.Amount, account uint32,
-	minconf int32) (*wire.ShaHash, error) {
+	minconf int32, subtractFeeFrom []string) (*wire.ShaHash, error) {
 
 	// Create transaction, replying with an error if the creation
 	// was not successful.
-	createdTx, err := w.CreateSimpleTx(account, amounts, minconf)
+	createdTx, err := w.CreateSimpleTx(account, amounts, minconf,
+		subtractFeeFrom)
 	if err != nil {
 		return nil, err
 	}
+	return w.sendCreatedTx(createdTx)
+}
+
+// SendAll creates and sends a transaction spending every eligible output of an
+// account to a single address, as described by CreateSweepTx.  It returns the
+// transaction hash upon success.
+func (w *Wallet) SendAll(account uint32, address string,
+	minconf int32) (*wire.ShaHash, error) {
 
+	createdTx, err := w.CreateSweepTx(account, address, minconf)
+	if err != nil {
+		return nil, err
+	}
+	return w.sendCreatedTx(createdTx)
+}
+
+// sendCreatedTx records a transaction created by the wallet in the transaction
+// store and sends it to the chain server.  If the transaction is not recorded,
+// or fails verification by the chain server, its payments no longer count
+// towards the spend limit of its account, and a rejected transaction is
+// abandoned so it is not sent again.
+func (w *Wallet) sendCreatedTx(createdTx *CreatedTx) (*wire.ShaHash, error) {
 	// Create transaction record and insert into the db.
 	rec, err := wtxmgr.NewTxRecordFromMsgTx(createdTx.MsgTx, time.Now())
 	if err != nil {
 		log.Errorf("Cannot create record for created transaction: %v", err)
+		w.removePolicySpend(createdTx)
 		return nil, err
 	}
-	err = w.TxStore.InsertTx(rec, nil)
+
+	// Record the fee and recipients with the transaction, since the fee
+	// can not be calculated later if any spent credit is forgotten, and
+	// the change output can not be told apart from a payment to one of the
+	// wallet's own addresses.
+	sent := &wtxmgr.SentTxInfo{
+		Fee:         createdTx.Fee,
+		ChangeIndex: createdTx.ChangeIndex,
+	}
+	for i := range createdTx.MsgTx.TxOut {
+		if i != createdTx.ChangeIndex {
+			sent.Recipients = append(sent.Recipients, uint32(i))
+		}
+	}
+	err = w.TxStore.InsertSentTx(rec, sent)
 	if err != nil {
 		log.Errorf("Error adding sent tx history: %v", err)
+		w.removePolicySpend(createdTx)
 		return nil, err
 	}
 
+	// Once recorded, the transaction is resent with the other unmined
+	// transactions, so it continues to count towards the spend limit
+	// unless it is abandoned.
 	if createdTx.ChangeIndex >= 0 {
 		err = w.TxStore.AddCredit(rec, nil, uint32(createdTx.ChangeIndex), true)
 		if err != nil {
@@ -1632,7 +2277,21 @@ func (w *Wallet) SendPairs(amounts map[string]btcutil.Amount, account uint32,
 
 	// TODO: The record already has the serialized tx, so no need to
 	// serialize it again.
-	return w.chainSvr.SendRawTransaction(&rec.MsgTx, false)
+	txSha, err := w.chainSvr.SendRawTransaction(&rec.MsgTx, false)
+	if isDoubleSpendError(err) {
+		// The chain server rejected the transaction while verifying
+		// it, so it will never be mined.  Any other error, such as a
+		// lost connection, a server which is still starting, or a
+		// transaction the server already has, leaves the transaction
+		// and its spend recorded so it is resent later.
+		if err := w.AbandonTransaction(&rec.Hash); err != nil {
+			log.Errorf("Cannot remove rejected transaction %v: %v",
+				rec.Hash, err)
+		} else {
+			w.removePolicySpend(createdTx)
+		}
+	}
+	return txSha, err
 }
 
 // Open loads an already-created wallet from the passed database and namespaces.
@@ -1657,12 +2316,57 @@ func Open(pubPass []byte, params *chaincfg.Params, db walletdb.DB, waddrmgrNS, w
 		}
 	}
 
+	policyNS, err := db.Namespace(spendPolicyNamespaceKey)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := spendpolicy.Open(policyNS)
+	if err != nil {
+		if !spendpolicy.IsNoExists(err) {
+			return nil, err
+		}
+		policies, err = spendpolicy.Create(policyNS)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	log.Infof("Opened wallet") // TODO: log balance? last sync height?
-	w := &Wallet{
+	w := newWallet(params, db, addrMgr, txMgr)
+	w.Policies = policies
+	return w, nil
+}
+
+// OpenReadOnly loads an already-created wallet from a database opened with
+// walletdb.OpenReadOnly, for tools which audit or report on a wallet without
+// modifying it.  Unlike Open, this does not create a missing transaction store,
+// and returns an error if either namespace requires an upgrade.  The returned
+// wallet can not be started or unlocked, and any call which modifies the wallet
+// will fail with walletdb.ErrDbReadOnly.
+func OpenReadOnly(pubPass []byte, params *chaincfg.Params, db walletdb.DB, waddrmgrNS, wtxmgrNS walletdb.Namespace) (*Wallet, error) {
+	addrMgr, err := waddrmgr.Open(waddrmgrNS, pubPass, params, nil)
+	if err != nil {
+		return nil, err
+	}
+	txMgr, err := wtxmgr.Open(wtxmgrNS)
+	if err != nil {
+		addrMgr.Close()
+		return nil, err
+	}
+
+	log.Infof("Opened wallet read-only")
+	w := newWallet(params, db, addrMgr, txMgr)
+	w.readOnly = true
+	return w, nil
+}
+
+// newWallet returns a wallet for the opened address manager and transaction
+// store.
+func newWallet(params *chaincfg.Params, db walletdb.DB, addrMgr *waddrmgr.Manager, txMgr *wtxmgr.Store) *Wallet {
+	return &Wallet{
 		db:                  db,
 		Manager:             addrMgr,
 		TxStore:             txMgr,
-		lockedOutpoints:     map[wire.OutPoint]struct{}{},
 		FeeIncrement:        defaultFeeIncrement,
 		rescanAddJob:        make(chan *RescanJob),
 		rescanBatch:         make(chan *rescanBatch),
@@ -1675,8 +2379,8 @@ func Open(pubPass []byte, params *chaincfg.Params, db walletdb.DB, waddrmgrNS, w
 		holdUnlockRequests:  make(chan chan HeldUnlock),
 		lockState:           make(chan bool),
 		changePassphrase:    make(chan changePassphraseRequest),
+		broadcaster:         newNotificationBroadcaster(),
 		chainParams:         params,
 		quit:                make(chan struct{}),
 	}
-	return w, nil
 }
@@ -32,6 +32,10 @@ func convertErr(err error) error {
 		return walletdb.ErrDbNotOpen
 	case bolt.ErrInvalid:
 		return walletdb.ErrInvalid
+	case bolt.ErrDatabaseReadOnly:
+		return walletdb.ErrDbReadOnly
+	case bolt.ErrTimeout:
+		return walletdb.ErrDbLocked
 
 	// Transaction errors.
 	case bolt.ErrTxNotWritable:
@@ -272,6 +276,54 @@ func (tx *transaction) Rollback() error {
 	return convertErr(tx.boltTx.Rollback())
 }
 
+// dbTransaction represents a database transaction with access to every
+// namespace.  It can either by read-only or read-write and implements the
+// walletdb.DBTx interface.
+type dbTransaction bolt.Tx
+
+// Enforce dbTransaction implements the walletdb.DBTx interface.
+var _ walletdb.DBTx = (*dbTransaction)(nil)
+
+// RootBucket returns the top-most bucket for the namespace with the passed
+// key.  Returns nil if the namespace does not exist.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) RootBucket(namespaceKey []byte) walletdb.Bucket {
+	// This nil check is intentional so the return value can be checked
+	// against nil directly.
+	boltBucket := (*bolt.Tx)(tx).Bucket(namespaceKey)
+	if boltBucket == nil {
+		return nil
+	}
+	return (*bucket)(boltBucket)
+}
+
+// ForEachNamespace invokes the passed function with the key of every namespace
+// in the database.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) ForEachNamespace(fn func(namespaceKey []byte) error) error {
+	return convertErr((*bolt.Tx)(tx).ForEach(func(name []byte, _ *bolt.Bucket) error {
+		return fn(name)
+	}))
+}
+
+// Commit commits all changes that have been made through the root buckets and
+// all of their sub-buckets to persistent storage.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) Commit() error {
+	return convertErr((*bolt.Tx)(tx).Commit())
+}
+
+// Rollback undoes all changes that have been made to the root buckets and all
+// of their sub-buckets.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) Rollback() error {
+	return convertErr((*bolt.Tx)(tx).Rollback())
+}
+
 // namespace represents a database namespace that is inteded to support the
 // concept of a single entity that controls the opening, creating, and closing
 // of a database while providing other entities their own namespace to work in.
@@ -302,6 +354,7 @@ func (ns *namespace) Begin(writable bool) (walletdb.Tx, error) {
 
 	bucket := boltTx.Bucket(ns.key)
 	if bucket == nil {
+		boltTx.Rollback()
 		return nil, walletdb.ErrBucketNotFound
 	}
 
@@ -359,7 +412,9 @@ var _ walletdb.DB = (*db)(nil)
 // Namespace returns a Namespace interface for the provided key.  See the
 // Namespace interface documentation for more details.  Attempting to access a
 // Namespace on a database that is not open yet or has been closed will result
-// in ErrDbNotOpen.  Namespaces are created in the database on first access.
+// in ErrDbNotOpen.  Namespaces are created in the database on first access,
+// unless the database was opened read-only, in which case ErrBucketNotFound is
+// returned for a namespace which does not exist.
 //
 // This function is part of the walletdb.Db interface implementation.
 func (db *db) Namespace(key []byte) (walletdb.Namespace, error) {
@@ -380,6 +435,9 @@ func (db *db) Namespace(key []byte) (walletdb.Namespace, error) {
 
 	// Create the namespace if needed by using an writable update
 	// transaction.
+	if doCreate && (*bolt.DB)(db).IsReadOnly() {
+		return nil, walletdb.ErrBucketNotFound
+	}
 	if doCreate {
 		err := (*bolt.DB)(db).Update(func(tx *bolt.Tx) error {
 			_, err := tx.CreateBucket(key)
@@ -403,6 +461,54 @@ func (db *db) DeleteNamespace(key []byte) error {
 	}))
 }
 
+// BeginTx starts a transaction with access to every namespace which is either
+// read-only or read-write depending on the specified flag.  Multiple read-only
+// transactions can be started simultaneously while only a single read-write
+// transaction can be started at a time.  The call will block when starting a
+// read-write transaction when one is already open.
+//
+// NOTE: The transaction must be closed by calling Rollback or Commit on it when
+// it is no longer needed.  Failure to do so will result in unclaimed memory.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) BeginTx(writable bool) (walletdb.DBTx, error) {
+	boltTx, err := (*bolt.DB)(db).Begin(writable)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return (*dbTransaction)(boltTx), nil
+}
+
+// View invokes the passed function in the context of a managed read-only
+// transaction with access to every namespace.  Any errors returned from the
+// user-supplied function are returned from this function.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) View(fn func(walletdb.DBTx) error) error {
+	return convertErr((*bolt.DB)(db).View(func(boltTx *bolt.Tx) error {
+		return fn((*dbTransaction)(boltTx))
+	}))
+}
+
+// Update invokes the passed function in the context of a managed read-write
+// transaction with access to every namespace.  Any errors returned from the
+// user-supplied function will cause the transaction to be rolled back and are
+// returned from this function.  Otherwise, the transaction is commited when
+// the user-supplied function returns a nil error.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Update(fn func(walletdb.DBTx) error) error {
+	return convertErr((*bolt.DB)(db).Update(func(boltTx *bolt.Tx) error {
+		return fn((*dbTransaction)(boltTx))
+	}))
+}
+
 // Copy writes a copy of the database to the provided writer.  This call will
 // start a read-only transaction to perform all operations.
 //
@@ -430,13 +536,29 @@ func fileExists(name string) bool {
 	return true
 }
 
-// openDB opens the database at the provided path.  walletdb.ErrDbDoesNotExist
-// is returned if the database doesn't exist and the create flag is not set.
-func openDB(dbPath string, create bool) (walletdb.DB, error) {
+// openDB opens the database at the provided path with the passed options.
+// walletdb.ErrDbDoesNotExist is returned if the database doesn't exist and the
+// create flag is not set.  When the readOnly flag is set, the database is
+// opened with a shared lock and all writes fail with walletdb.ErrDbReadOnly.
+func openDB(dbPath string, opts *Options, create, readOnly bool) (walletdb.DB, error) {
 	if !create && !fileExists(dbPath) {
 		return nil, walletdb.ErrDbDoesNotExist
 	}
 
-	boltDB, err := bolt.Open(dbPath, 0600, nil)
-	return (*db)(boltDB), convertErr(err)
+	// Bolt waits forever for the file lock when the timeout is zero.
+	timeout := opts.Timeout
+	if timeout < 0 {
+		timeout = 0
+	}
+	boltDB, err := bolt.Open(dbPath, opts.FileMode, &bolt.Options{
+		Timeout:         timeout,
+		NoGrowSync:      opts.NoGrowSync,
+		ReadOnly:        readOnly,
+		InitialMmapSize: opts.InitialMmapSize,
+	})
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	boltDB.NoSync = opts.NoSync
+	return (*db)(boltDB), nil
 }
@@ -21,8 +21,13 @@ datastore.
 Usage
 
 This package is only a driver to the walletdb package and provides the database
-type of "bdb".  The only parameter the Open and Create functions take is the
-database path as a string:
+type of "bdb".  The first parameter the Open and Create functions take is the
+database path as a string.  An optional second parameter is an *Options which
+configures the file mode of a created database, syncing, the initial memory map
+size, and how long to wait for the file lock held by another process before
+failing with walletdb.ErrDbLocked.  DefaultOptions are used when it is omitted,
+and a zero Timeout or FileMode takes its default value.  A negative Timeout
+waits forever for the lock:
 
 	db, err := walletdb.Open("bdb", "path/to/database.db")
 	if err != nil {
@@ -33,5 +38,36 @@ database path as a string:
 	if err != nil {
 		// Handle error
 	}
+
+	db, err := walletdb.Open("bdb", "path/to/database.db", &bdb.Options{
+		Timeout:  10 * time.Second,
+		FileMode: 0600,
+	})
+	if err != nil {
+		// Handle error
+	}
+
+OpenReadOnly takes the same parameters and opens the database with a shared
+lock, so any number of processes may read the database at once.  A database
+can not be opened read-only while it is opened for writing elsewhere:
+
+	db, err := walletdb.OpenReadOnly("bdb", "path/to/database.db")
+	if err != nil {
+		// Handle error
+	}
+
+Statistics and Compaction
+
+Bolt never shrinks a database file, so pages freed by deleted data are only
+reused by later writes.  Stats reports the number of keys and the size of the
+keys and values stored in every namespace and nested bucket of an open
+database, along with the number of free pages.  Compact rewrites a closed
+database into a new file containing only the stored data, and atomically
+replaces the original with it:
+
+	err := bdb.Compact("path/to/database.db")
+	if err != nil {
+		// Handle error
+	}
 */
 package bdb
@@ -18,6 +18,8 @@ package bdb
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/btcsuite/btcwallet/walletdb"
 )
@@ -26,50 +28,117 @@ const (
 	dbType = "bdb"
 )
 
-// parseArgs parses the arguments from the walletdb Open/Create methods.
-func parseArgs(funcName string, args ...interface{}) (string, error) {
-	if len(args) != 1 {
-		return "", fmt.Errorf("invalid arguments to %s.%s -- "+
-			"expected database path", dbType, funcName)
+// Options specifies how the driver opens a database file.
+type Options struct {
+	// Timeout is the time to wait for the lock on a database file held by
+	// another process before failing with walletdb.ErrDbLocked.  Zero uses
+	// the default timeout and a negative timeout waits forever.
+	Timeout time.Duration
+
+	// NoSync skips syncing the file after every commit.  This is faster,
+	// but data committed shortly before a crash may be lost or corrupted.
+	NoSync bool
+
+	// NoGrowSync skips syncing the file after it is grown.  This is only
+	// safe on filesystems which do not require it, such as ext3 and ext4.
+	NoGrowSync bool
+
+	// InitialMmapSize is the initial size, in bytes, of the memory map of
+	// the file.  Read transactions do not block write transactions while
+	// the database fits within this size.  Zero uses the size of the file.
+	InitialMmapSize int
+
+	// FileMode is the permissions used when the database file is created.
+	// Zero uses the default file mode.
+	FileMode os.FileMode
+}
+
+// DefaultOptions are the options used when none are passed to Open, Create, or
+// OpenReadOnly.
+var DefaultOptions = Options{
+	Timeout:  time.Second,
+	FileMode: 0600,
+}
+
+// parseArgs parses the arguments from the walletdb Open/Create methods.  The
+// first argument is the database path, and an optional second argument is a
+// *Options.  DefaultOptions are returned when no options are passed, and any
+// zero Timeout or FileMode of the passed options is replaced by its default.
+func parseArgs(funcName string, args ...interface{}) (string, *Options, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return "", nil, fmt.Errorf("invalid arguments to %s.%s -- "+
+			"expected database path and optional options", dbType,
+			funcName)
 	}
 
 	dbPath, ok := args[0].(string)
 	if !ok {
-		return "", fmt.Errorf("first argument to %s.%s is invalid -- "+
+		return "", nil, fmt.Errorf("first argument to %s.%s is invalid -- "+
 			"expected database path string", dbType, funcName)
 	}
 
-	return dbPath, nil
+	opts := DefaultOptions
+	if len(args) == 2 {
+		o, ok := args[1].(*Options)
+		if !ok {
+			return "", nil, fmt.Errorf("second argument to %s.%s is "+
+				"invalid -- expected *%s.Options", dbType,
+				funcName, dbType)
+		}
+		if o != nil {
+			opts = *o
+			if opts.Timeout == 0 {
+				opts.Timeout = DefaultOptions.Timeout
+			}
+			if opts.FileMode == 0 {
+				opts.FileMode = DefaultOptions.FileMode
+			}
+		}
+	}
+
+	return dbPath, &opts, nil
 }
 
 // openDBDriver is the callback provided during driver registration that opens
 // an existing database for use.
 func openDBDriver(args ...interface{}) (walletdb.DB, error) {
-	dbPath, err := parseArgs("Open", args...)
+	dbPath, opts, err := parseArgs("Open", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return openDB(dbPath, opts, false, false)
+}
+
+// openReadOnlyDBDriver is the callback provided during driver registration
+// that opens an existing database without write access.
+func openReadOnlyDBDriver(args ...interface{}) (walletdb.DB, error) {
+	dbPath, opts, err := parseArgs("OpenReadOnly", args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return openDB(dbPath, false)
+	return openDB(dbPath, opts, false, true)
 }
 
 // createDBDriver is the callback provided during driver registration that
 // creates, initializes, and opens a database for use.
 func createDBDriver(args ...interface{}) (walletdb.DB, error) {
-	dbPath, err := parseArgs("Create", args...)
+	dbPath, opts, err := parseArgs("Create", args...)
 	if err != nil {
 		return nil, err
 	}
 
-	return openDB(dbPath, true)
+	return openDB(dbPath, opts, true, false)
 }
 
 func init() {
 	// Register the driver.
 	driver := walletdb.Driver{
-		DbType: dbType,
-		Create: createDBDriver,
-		Open:   openDBDriver,
+		DbType:       dbType,
+		Create:       createDBDriver,
+		Open:         openDBDriver,
+		OpenReadOnly: openReadOnlyDBDriver,
 	}
 	if err := walletdb.RegisterDriver(driver); err != nil {
 		panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
@@ -21,9 +21,10 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/btcsuite/btcwallet/walletdb"
-	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/btcsuite/btcwallet/walletdb/bdb"
 )
 
 // dbType is the database type name for this driver.
@@ -44,7 +45,7 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to open a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr = fmt.Errorf("invalid arguments to %s.Open -- expected "+
-		"database path", dbType)
+		"database path and optional options", dbType)
 	if _, err := walletdb.Open(dbType, 1, 2, 3); err.Error() != wantErr.Error() {
 		t.Errorf("Open: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -64,7 +65,7 @@ func TestCreateOpenFail(t *testing.T) {
 	// Ensure that attempting to create a database with the wrong number of
 	// parameters returns the expected error.
 	wantErr = fmt.Errorf("invalid arguments to %s.Create -- expected "+
-		"database path", dbType)
+		"database path and optional options", dbType)
 	if _, err := walletdb.Create(dbType, 1, 2, 3); err.Error() != wantErr.Error() {
 		t.Errorf("Create: did not receive expected error - got %v, "+
 			"want %v", err, wantErr)
@@ -184,6 +185,173 @@ func TestPersistence(t *testing.T) {
 	}
 }
 
+// TestOptions ensures that the options passed to Create and Open are used, and
+// that a database locked by another opener fails to open once the timeout
+// expires.
+func TestOptions(t *testing.T) {
+	// Ensure that passing options of the wrong type returns the expected
+	// error.
+	wantErr := fmt.Errorf("second argument to %s.Open is invalid -- "+
+		"expected *%s.Options", dbType, dbType)
+	if _, err := walletdb.Open(dbType, "noexist.db", 1); err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+
+	dbPath := "optionstest.db"
+	opts := &bdb.Options{
+		Timeout:  100 * time.Millisecond,
+		NoSync:   true,
+		FileMode: 0640,
+	}
+	db, err := walletdb.Create(dbType, dbPath, opts)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.Remove(dbPath)
+	defer db.Close()
+
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != opts.FileMode {
+		t.Errorf("Create: file mode is %v, want %v", fi.Mode().Perm(),
+			opts.FileMode)
+	}
+
+	// Ensure opening the database for writing, or read-only, while it is
+	// already open fails with the expected error once the lock times out
+	// rather than blocking forever.
+	wantErr = walletdb.ErrDbLocked
+	if _, err := walletdb.Open(dbType, dbPath, opts); err != wantErr {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+	if _, err := walletdb.OpenReadOnly(dbType, dbPath, opts); err != wantErr {
+		t.Errorf("OpenReadOnly: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+	if err := bdb.Compact(dbPath); err != wantErr {
+		t.Errorf("Compact: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+}
+
+// TestDefaultOptions ensures that zero fields of the options passed to Create
+// take their values from DefaultOptions.
+func TestDefaultOptions(t *testing.T) {
+	dbPath := "defaultoptionstest.db"
+	db, err := walletdb.Create(dbType, dbPath, &bdb.Options{NoSync: true})
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.Remove(dbPath)
+	defer db.Close()
+
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != bdb.DefaultOptions.FileMode {
+		t.Errorf("Create: file mode is %v, want %v", fi.Mode().Perm(),
+			bdb.DefaultOptions.FileMode)
+	}
+
+	// Ensure a zero timeout uses the default rather than waiting forever
+	// for the lock held by the open database.
+	wantErr := walletdb.ErrDbLocked
+	_, err = walletdb.Open(dbType, dbPath, &bdb.Options{})
+	if err != wantErr {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+}
+
+// TestReadOnly ensures that a database opened read-only can be read by more
+// than one opener at a time, and that every attempt to modify it fails.
+func TestReadOnly(t *testing.T) {
+	// Create a new database with a namespace holding a value.
+	dbPath := "readonlytest.db"
+	db, err := walletdb.Create(dbType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.Remove(dbPath)
+	ns1Key := []byte("ns1")
+	ns1, err := db.Namespace(ns1Key)
+	if err != nil {
+		db.Close()
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns1.Update(func(tx walletdb.Tx) error {
+		return tx.RootBucket().Put([]byte("key"), []byte("value"))
+	})
+	db.Close()
+	if err != nil {
+		t.Fatalf("ns1 Update: unexpected error: %v", err)
+	}
+
+	// Ensure opening a database that doesn't exist returns the expected
+	// error.
+	wantErr := walletdb.ErrDbDoesNotExist
+	if _, err := walletdb.OpenReadOnly(dbType, "noexist.db"); err != wantErr {
+		t.Errorf("OpenReadOnly: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+
+	// Open the database read-only twice at once.
+	db, err = walletdb.OpenReadOnly(dbType, dbPath)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: unexpected error: %v", err)
+	}
+	defer db.Close()
+	db2, err := walletdb.OpenReadOnly(dbType, dbPath)
+	if err != nil {
+		t.Fatalf("OpenReadOnly: unexpected error: %v", err)
+	}
+	defer db2.Close()
+
+	ns1, err = db2.Namespace(ns1Key)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns1.View(func(tx walletdb.Tx) error {
+		gotVal := tx.RootBucket().Get([]byte("key"))
+		if !reflect.DeepEqual(gotVal, []byte("value")) {
+			return fmt.Errorf("Get: unexpected value %s", gotVal)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ns1 View: unexpected error: %v", err)
+	}
+
+	// Ensure writes, including the creation of a missing namespace, fail
+	// with the expected errors.
+	wantErr = walletdb.ErrDbReadOnly
+	err = ns1.Update(func(tx walletdb.Tx) error {
+		return tx.RootBucket().Put([]byte("key"), []byte("value2"))
+	})
+	if err != wantErr {
+		t.Errorf("Update: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+	if _, err := db.BeginTx(true); err != wantErr {
+		t.Errorf("BeginTx: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+	if err := db.DeleteNamespace(ns1Key); err != wantErr {
+		t.Errorf("DeleteNamespace: did not receive expected error - "+
+			"got %v, want %v", err, wantErr)
+	}
+	wantErr = walletdb.ErrBucketNotFound
+	if _, err := db.Namespace([]byte("ns2")); err != wantErr {
+		t.Errorf("Namespace: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+	}
+}
+
 // TestInterface performs all interfaces tests for this database driver.
 func TestInterface(t *testing.T) {
 	// Create a new database to run tests against.
@@ -199,3 +367,134 @@ func TestInterface(t *testing.T) {
 	// Run all of the interface tests against the database.
 	testInterface(t, db)
 }
+
+// TestStatsCompact ensures the statistics of a database describe the stored
+// data, and that compacting the database shrinks the file without losing any
+// values.
+func TestStatsCompact(t *testing.T) {
+	// Create a new database to run tests against.
+	dbPath := "compacttest.db"
+	db, err := walletdb.Create(dbType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer os.Remove(dbPath)
+	defer db.Close()
+
+	// Store many values in a nested bucket, and then delete most of them
+	// so the pages which held them are freed.
+	ns1Key := []byte("ns1")
+	ns1, err := db.Namespace(ns1Key)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	value := make([]byte, 512)
+	err = ns1.Update(func(tx walletdb.Tx) error {
+		rootBucket := tx.RootBucket()
+		if err := rootBucket.Put([]byte("key"), []byte("value")); err != nil {
+			return err
+		}
+		nested, err := rootBucket.CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		for i := 0; i < 2000; i++ {
+			k := []byte(fmt.Sprintf("%08d", i))
+			if err := nested.Put(k, value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ns1 Update: unexpected error: %v", err)
+	}
+	err = ns1.Update(func(tx walletdb.Tx) error {
+		nested := tx.RootBucket().Bucket([]byte("nested"))
+		for i := 10; i < 2000; i++ {
+			k := []byte(fmt.Sprintf("%08d", i))
+			if err := nested.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ns1 Update: unexpected error: %v", err)
+	}
+
+	stats, err := bdb.Stats(db)
+	if err != nil {
+		t.Fatalf("Stats: unexpected error: %v", err)
+	}
+	if len(stats.Namespaces) != 1 {
+		t.Fatalf("Stats: got %d namespaces, want 1", len(stats.Namespaces))
+	}
+	ns := stats.Namespaces[0]
+	if string(ns.Name) != "ns1" || ns.Keys != 1 || ns.KeyBytes != 3 ||
+		ns.ValueBytes != 5 || len(ns.Buckets) != 1 {
+		t.Fatalf("Stats: unexpected namespace stats %+v", ns)
+	}
+	nested := ns.Buckets[0]
+	if string(nested.Name) != "nested" || nested.Keys != 10 ||
+		nested.KeyBytes != 80 || nested.ValueBytes != 10*512 {
+		t.Fatalf("Stats: unexpected nested bucket stats %+v", nested)
+	}
+	if keys, n := ns.Total(); keys != 11 || n != 3+5+80+10*512 {
+		t.Fatalf("Total: got %d keys and %d bytes", keys, n)
+	}
+
+	db.Close()
+	before, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bdb.Compact(dbPath); err != nil {
+		t.Fatalf("Compact: unexpected error: %v", err)
+	}
+	after, err := os.Stat(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() >= before.Size() {
+		t.Errorf("Compact: file size %d is not smaller than %d",
+			after.Size(), before.Size())
+	}
+
+	// Ensure the compacted database holds the same values.
+	db, err = walletdb.Open(dbType, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+	compacted, err := bdb.Stats(db)
+	if err != nil {
+		t.Fatalf("Stats: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(compacted.Namespaces, stats.Namespaces) {
+		t.Errorf("Stats: compacted namespaces differ")
+	}
+	ns1, err = db.Namespace(ns1Key)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns1.View(func(tx walletdb.Tx) error {
+		nested := tx.RootBucket().Bucket([]byte("nested"))
+		for i := 0; i < 10; i++ {
+			k := []byte(fmt.Sprintf("%08d", i))
+			if !reflect.DeepEqual(nested.Get(k), value) {
+				return fmt.Errorf("Get: key %s does not match", k)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ns1 View: unexpected error: %v", err)
+	}
+
+	// Ensure compacting a missing database returns the expected error.
+	if err := bdb.Compact("noexist.db"); err != walletdb.ErrDbDoesNotExist {
+		t.Errorf("Compact: did not receive expected error - got %v, "+
+			"want %v", err, walletdb.ErrDbDoesNotExist)
+	}
+}
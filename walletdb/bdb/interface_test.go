@@ -794,6 +794,215 @@ func testAdditionalErrors(tc *testContext) bool {
 	return true
 }
 
+// testDBTxInterface ensures the transactions started from the database
+// provide access to several namespaces and commit or roll back the changes
+// made to all of them together.
+func testDBTxInterface(tc *testContext) bool {
+	ns4Key, ns5Key := []byte("ns4"), []byte("ns5")
+	for _, key := range [][]byte{ns4Key, ns5Key} {
+		if _, err := tc.db.Namespace(key); err != nil {
+			tc.t.Errorf("Namespace: unexpected error: %v", err)
+			return false
+		}
+	}
+	defer func() {
+		// Remove the namespaces now that the tests are done for them.
+		for _, key := range [][]byte{ns4Key, ns5Key} {
+			if err := tc.db.DeleteNamespace(key); err != nil {
+				tc.t.Errorf("DeleteNamespace: unexpected error: %v",
+					err)
+			}
+		}
+	}()
+
+	ns4Values := map[string]string{"ns4key1": "foo1", "ns4key2": "foo2"}
+	ns5Values := map[string]string{"ns5key1": "foo1", "ns5key2": "foo2"}
+
+	// putValues stores the test values in both namespaces.
+	putValues := func(tx walletdb.DBTx) error {
+		if !testPutValues(tc, tx.RootBucket(ns4Key), ns4Values) {
+			return subTestFailError
+		}
+		if !testPutValues(tc, tx.RootBucket(ns5Key), ns5Values) {
+			return subTestFailError
+		}
+		return nil
+	}
+
+	// checkValues ensures both namespaces contain the passed values.
+	checkValues := func(ns4Want, ns5Want map[string]string) bool {
+		err := tc.db.View(func(tx walletdb.DBTx) error {
+			if !testGetValues(tc, tx.RootBucket(ns4Key), ns4Want) {
+				return subTestFailError
+			}
+			if !testGetValues(tc, tx.RootBucket(ns5Key), ns5Want) {
+				return subTestFailError
+			}
+			return nil
+		})
+		if err != nil {
+			if err != subTestFailError {
+				tc.t.Errorf("%v", err)
+			}
+			return false
+		}
+		return true
+	}
+
+	// Ensure the root bucket of a namespace which does not exist is nil.
+	err := tc.db.View(func(tx walletdb.DBTx) error {
+		if tx.RootBucket([]byte("nsnoexist")) != nil {
+			return fmt.Errorf("RootBucket: unexpected root bucket " +
+				"for namespace which does not exist")
+		}
+		if tx.RootBucket(ns4Key).Writable() {
+			return fmt.Errorf("Writable: bucket of read-only " +
+				"transaction is writable")
+		}
+
+		// Ensure both namespaces are iterated.
+		found := make(map[string]bool)
+		err := tx.ForEachNamespace(func(key []byte) error {
+			found[string(key)] = true
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("ForEachNamespace: unexpected "+
+				"error: %v", err)
+		}
+		if !found[string(ns4Key)] || !found[string(ns5Key)] {
+			return fmt.Errorf("ForEachNamespace: namespaces "+
+				"not iterated (got %v)", found)
+		}
+		return nil
+	})
+	if err != nil {
+		tc.t.Errorf("%v", err)
+		return false
+	}
+
+	// Ensure changes to both namespaces are discarded when the managed
+	// update returns an error.
+	forcedErr := fmt.Errorf("forced error")
+	err = tc.db.Update(func(tx walletdb.DBTx) error {
+		if err := putValues(tx); err != nil {
+			return err
+		}
+		return forcedErr
+	})
+	if err != forcedErr {
+		if err != subTestFailError {
+			tc.t.Errorf("Update: did not receive expected error - "+
+				"got %v, want %v", err, forcedErr)
+		}
+		return false
+	}
+	if !checkValues(rollbackValues(ns4Values), rollbackValues(ns5Values)) {
+		return false
+	}
+
+	// Ensure changes to both namespaces are discarded by a manual
+	// rollback.
+	tx, err := tc.db.BeginTx(true)
+	if err != nil {
+		tc.t.Errorf("BeginTx: unexpected error: %v", err)
+		return false
+	}
+	if err := putValues(tx); err != nil {
+		tx.Rollback()
+		return false
+	}
+	if err := tx.Rollback(); err != nil {
+		tc.t.Errorf("Rollback: unexpected error: %v", err)
+		return false
+	}
+	if !checkValues(rollbackValues(ns4Values), rollbackValues(ns5Values)) {
+		return false
+	}
+
+	// Ensure changes to both namespaces are stored by a manual commit and
+	// that they are visible to the namespace transactions.
+	tx, err = tc.db.BeginTx(true)
+	if err != nil {
+		tc.t.Errorf("BeginTx: unexpected error: %v", err)
+		return false
+	}
+	if err := putValues(tx); err != nil {
+		tx.Rollback()
+		return false
+	}
+	if err := tx.Commit(); err != nil {
+		tc.t.Errorf("Commit: unexpected error: %v", err)
+		return false
+	}
+	if !checkValues(ns4Values, ns5Values) {
+		return false
+	}
+	ns4, err := tc.db.Namespace(ns4Key)
+	if err != nil {
+		tc.t.Errorf("Namespace: unexpected error: %v", err)
+		return false
+	}
+	err = ns4.View(func(tx walletdb.Tx) error {
+		if !testGetValues(tc, tx.RootBucket(), ns4Values) {
+			return subTestFailError
+		}
+		return nil
+	})
+	if err != nil {
+		if err != subTestFailError {
+			tc.t.Errorf("%v", err)
+		}
+		return false
+	}
+
+	// Ensure changes to both namespaces are stored by a managed update.
+	err = tc.db.Update(func(tx walletdb.DBTx) error {
+		if !testDeleteValues(tc, tx.RootBucket(ns4Key), ns4Values) {
+			return subTestFailError
+		}
+		if !testDeleteValues(tc, tx.RootBucket(ns5Key), ns5Values) {
+			return subTestFailError
+		}
+		return nil
+	})
+	if err != nil {
+		if err != subTestFailError {
+			tc.t.Errorf("%v", err)
+		}
+		return false
+	}
+	if !checkValues(rollbackValues(ns4Values), rollbackValues(ns5Values)) {
+		return false
+	}
+
+	// Ensure committing a read-only transaction and closing a closed
+	// transaction return the expected errors.
+	tx, err = tc.db.BeginTx(false)
+	if err != nil {
+		tc.t.Errorf("BeginTx: unexpected error: %v", err)
+		return false
+	}
+	wantErr := walletdb.ErrTxNotWritable
+	if err := tx.Commit(); err != wantErr {
+		tc.t.Errorf("Commit: unexpected error - got %v, want %v", err,
+			wantErr)
+		return false
+	}
+	if err := tx.Rollback(); err != nil {
+		tc.t.Errorf("Rollback: unexpected error: %v", err)
+		return false
+	}
+	wantErr = walletdb.ErrTxClosed
+	if err := tx.Rollback(); err != wantErr {
+		tc.t.Errorf("Rollback: unexpected error - got %v, want %v", err,
+			wantErr)
+		return false
+	}
+
+	return true
+}
+
 // testInterface tests performs tests for the various interfaces of walletdb
 // which require state in the database for the given database type.
 func testInterface(t *testing.T, db walletdb.DB) {
@@ -814,4 +1023,9 @@ func testInterface(t *testing.T, db walletdb.DB) {
 	if !testAdditionalErrors(&context) {
 		return
 	}
+
+	// Test transactions which access several namespaces.
+	if !testDBTxInterface(&context) {
+		return
+	}
 }
@@ -0,0 +1,212 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package bdb
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/btcsuite/bolt"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// ErrNotBoltDB is returned by Stats when the database was not opened by this
+// driver.
+var ErrNotBoltDB = errors.New("database is not a bdb database")
+
+// BucketStats describes the keys and values stored in a namespace or bucket.
+// The key and byte counts only include the key/value pairs stored directly in
+// the bucket.  Nested buckets are described by Buckets.
+type BucketStats struct {
+	Name       []byte         // Key of the bucket
+	Keys       int            // Number of key/value pairs
+	KeyBytes   int64          // Total size of all keys
+	ValueBytes int64          // Total size of all values
+	Buckets    []*BucketStats // Nested buckets
+}
+
+// Total returns the number of key/value pairs and the total size of all keys
+// and values in the bucket, including those of every nested bucket.
+func (s *BucketStats) Total() (keys int, bytes int64) {
+	keys = s.Keys
+	bytes = s.KeyBytes + s.ValueBytes
+	for _, b := range s.Buckets {
+		k, n := b.Total()
+		keys += k
+		bytes += n
+	}
+	return keys, bytes
+}
+
+// DBStats describes the size of a database and the data stored in it.
+type DBStats struct {
+	Size       int64          // Size of the database data, in bytes
+	PageSize   int            // Size of each database page, in bytes
+	FreePages  int            // Number of pages free for reuse
+	Namespaces []*BucketStats // Every top level namespace
+}
+
+// Stats returns the key counts and sizes of every namespace and bucket in the
+// database, and the size of the database file.  Bolt never returns the space
+// of freed pages to the filesystem, so the free page count gives the amount of
+// space which may be reclaimed by Compact.  The database must have been opened
+// by this driver.
+func Stats(walletDB walletdb.DB) (*DBStats, error) {
+	boltDB, ok := walletDB.(*db)
+	if !ok {
+		return nil, ErrNotBoltDB
+	}
+
+	stats := &DBStats{
+		PageSize:  (*bolt.DB)(boltDB).Info().PageSize,
+		FreePages: (*bolt.DB)(boltDB).Stats().FreePageN,
+	}
+	err := (*bolt.DB)(boltDB).View(func(tx *bolt.Tx) error {
+		stats.Size = tx.Size()
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			stats.Namespaces = append(stats.Namespaces,
+				bucketStats(name, b))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return stats, nil
+}
+
+// bucketStats returns the statistics of a bolt bucket and all of its nested
+// buckets.
+func bucketStats(name []byte, b *bolt.Bucket) *BucketStats {
+	stats := &BucketStats{Name: append([]byte(nil), name...)}
+	b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			stats.Buckets = append(stats.Buckets,
+				bucketStats(k, b.Bucket(k)))
+			return nil
+		}
+		stats.Keys++
+		stats.KeyBytes += int64(len(k))
+		stats.ValueBytes += int64(len(v))
+		return nil
+	})
+	return stats
+}
+
+// Compact rewrites the database at dbPath into a new file, copying every
+// namespace, bucket, and key/value pair rather than the pages holding them, so
+// the space of freed pages is not carried over.  Once the new file is written
+// and synced, it atomically replaces the original, and the directory is synced
+// so the replacement survives a crash.  The original is left unmodified if any
+// error occurs before it is replaced.
+//
+// The database must not be opened while it is being compacted.
+// walletdb.ErrDbLocked is returned if it is open in another process.
+func Compact(dbPath string) error {
+	if !fileExists(dbPath) {
+		return walletdb.ErrDbDoesNotExist
+	}
+	fi, err := os.Stat(dbPath)
+	if err != nil {
+		return err
+	}
+
+	options := &bolt.Options{Timeout: DefaultOptions.Timeout}
+	src, err := bolt.Open(dbPath, 0600, options)
+	if err != nil {
+		return convertErr(err)
+	}
+	defer src.Close()
+
+	// The compacted database is written to a file in the same directory so
+	// it can be renamed over the original.
+	tmpPath := filepath.Join(filepath.Dir(dbPath),
+		"."+filepath.Base(dbPath)+".compact")
+	os.Remove(tmpPath)
+	dst, err := bolt.Open(tmpPath, fi.Mode().Perm(), options)
+	if err != nil {
+		return convertErr(err)
+	}
+	err = src.View(func(srcTx *bolt.Tx) error {
+		return dst.Update(func(dstTx *bolt.Tx) error {
+			return srcTx.ForEach(func(name []byte, b *bolt.Bucket) error {
+				dstBucket, err := dstTx.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return copyBucket(dstBucket, b)
+			})
+		})
+	})
+	if err == nil {
+		err = dst.Sync()
+	}
+	closeErr := dst.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return convertErr(err)
+	}
+
+	// Close the source before replacing it so its file lock is released.
+	if err := src.Close(); err != nil {
+		os.Remove(tmpPath)
+		return convertErr(err)
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return syncDir(filepath.Dir(dbPath))
+}
+
+// syncDir flushes the directory entries of dir to disk so that a file renamed
+// into the directory is not lost on a crash.  Directories can not be opened
+// for syncing on Windows, so this does nothing there.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// copyBucket copies every key/value pair and nested bucket of src into dst.
+// The destination pages are filled completely since the database is only
+// compacted while it is not in use.
+func copyBucket(dst, src *bolt.Bucket) error {
+	dst.FillPercent = 1.0
+	return src.ForEach(func(k, v []byte) error {
+		if v == nil {
+			nested, err := dst.CreateBucket(k)
+			if err != nil {
+				return err
+			}
+			return copyBucket(nested, src.Bucket(k))
+		}
+		return dst.Put(k, v)
+	})
+}
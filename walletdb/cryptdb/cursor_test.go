@@ -0,0 +1,229 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cryptdb
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
+)
+
+// TestCursorSkipsInvalid ensures that a value which can not be decrypted is
+// skipped by cursors without hiding the other entries of the bucket, and
+// that it causes an error to be returned by ForEach.
+func TestCursorSkipsInvalid(t *testing.T) {
+	underlying, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatalf("Failed to create test database (mem) %v", err)
+	}
+	db, err := openDB(underlying, []byte("passphrase"), true)
+	if err != nil {
+		t.Fatalf("openDB: unexpected error: %v", err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("ns"))
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+
+	err = ns.Update(func(tx walletdb.Tx) error {
+		b := tx.RootBucket().(*bucket)
+		for _, k := range []string{"key1", "key2", "key3"} {
+			if err := b.Put([]byte(k), []byte(k)); err != nil {
+				return err
+			}
+		}
+		// Replace the value of key2 with one which can not be
+		// decrypted.
+		return b.b.Put(b.keys.macKey([]byte("key2")), []byte("garbage"))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	err = ns.View(func(tx walletdb.Tx) error {
+		var gotKeys []string
+		c := tx.RootBucket().Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			gotKeys = append(gotKeys, string(k))
+		}
+		wantKeys := []string{"key1", "key3"}
+		if !reflect.DeepEqual(gotKeys, wantKeys) {
+			t.Errorf("Cursor: unexpected keys - got %v, want %v",
+				gotKeys, wantKeys)
+		}
+
+		err := tx.RootBucket().ForEach(func(k, v []byte) error {
+			return nil
+		})
+		if err == nil {
+			t.Errorf("ForEach: did not receive an error for the " +
+				"invalid value")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// TestCursorSeesWrites ensures that changes made through a transaction after a
+// bucket has been iterated are reflected by later cursors of the same
+// transaction, while cursors which were already positioned are unaffected.
+func TestCursorSeesWrites(t *testing.T) {
+	underlying, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatalf("Failed to create test database (mem) %v", err)
+	}
+	db, err := openDB(underlying, []byte("passphrase"), true)
+	if err != nil {
+		t.Fatalf("openDB: unexpected error: %v", err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("ns"))
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+
+	keys := func(c walletdb.Cursor) []string {
+		var keys []string
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			keys = append(keys, string(k))
+		}
+		return keys
+	}
+
+	err = ns.Update(func(tx walletdb.Tx) error {
+		b := tx.RootBucket()
+		for _, k := range []string{"key1", "key3", "key5"} {
+			if err := b.Put([]byte(k), []byte(k)); err != nil {
+				return err
+			}
+		}
+		old := b.Cursor()
+		old.First()
+
+		if err := b.Put([]byte("key2"), []byte("key2")); err != nil {
+			return err
+		}
+		if err := b.Delete([]byte("key3")); err != nil {
+			return err
+		}
+		if _, err := b.CreateBucket([]byte("key4")); err != nil {
+			return err
+		}
+		c := b.Cursor()
+		if k, _ := c.Seek([]byte("key5")); string(k) != "key5" {
+			t.Errorf("Seek: unexpected key %q", k)
+		}
+		if err := c.Delete(); err != nil {
+			return err
+		}
+
+		want := []string{"key1", "key2", "key4"}
+		if got := keys(b.Cursor()); !reflect.DeepEqual(got, want) {
+			t.Errorf("Cursor: unexpected keys - got %v, want %v",
+				got, want)
+		}
+		want = []string{"key1", "key3", "key5"}
+		if got := keys(old); !reflect.DeepEqual(got, want) {
+			t.Errorf("Cursor: unexpected keys of positioned "+
+				"cursor - got %v, want %v", got, want)
+		}
+
+		if err := b.DeleteBucket([]byte("key4")); err != nil {
+			return err
+		}
+		want = []string{"key1", "key2"}
+		if got := keys(b.Cursor()); !reflect.DeepEqual(got, want) {
+			t.Errorf("Cursor: unexpected keys after DeleteBucket "+
+				"- got %v, want %v", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	// The changes must also be seen by a new transaction which reads the
+	// bucket from the underlying database.
+	err = ns.View(func(tx walletdb.Tx) error {
+		want := []string{"key1", "key2"}
+		if got := keys(tx.RootBucket().Cursor()); !reflect.DeepEqual(got, want) {
+			t.Errorf("Cursor: unexpected keys in new transaction "+
+				"- got %v, want %v", got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+}
+
+// BenchmarkCursorSeek measures seeking to every key of a large bucket with a
+// new cursor for each key, as is done by lookups in a loop.
+func BenchmarkCursorSeek(b *testing.B) {
+	const numKeys = 4000
+
+	underlying, err := walletdb.Create("mem")
+	if err != nil {
+		b.Fatalf("Failed to create test database (mem) %v", err)
+	}
+	db, err := openDB(underlying, []byte("passphrase"), true)
+	if err != nil {
+		b.Fatalf("openDB: unexpected error: %v", err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("ns"))
+	if err != nil {
+		b.Fatalf("Namespace: unexpected error: %v", err)
+	}
+
+	keys := make([][]byte, numKeys)
+	err = ns.Update(func(tx walletdb.Tx) error {
+		for i := range keys {
+			keys[i] = []byte(fmt.Sprintf("key%08d", i))
+			err := tx.RootBucket().Put(keys[i], keys[i])
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := ns.View(func(tx walletdb.Tx) error {
+			bucket := tx.RootBucket()
+			for _, k := range keys {
+				got, _ := bucket.Cursor().Seek(k)
+				if !bytes.Equal(got, k) {
+					return fmt.Errorf("Seek: unexpected key %q", got)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatalf("View: %v", err)
+		}
+	}
+}
@@ -0,0 +1,1076 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cryptdb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcwallet/internal/zero"
+	"github.com/btcsuite/btcwallet/snacl"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/fastsha256"
+)
+
+var (
+	// ErrInvalidPassphrase is returned when opening a database with the
+	// wrong database passphrase.
+	ErrInvalidPassphrase = errors.New("invalid database passphrase")
+
+	// ErrNotEncrypted is returned when opening a database which was not
+	// created by this driver.
+	ErrNotEncrypted = errors.New("database is not encrypted")
+)
+
+// Layout of the underlying database.
+//
+// Each namespace, nested bucket, and key is stored in the underlying database
+// under the HMAC-SHA256 of its plaintext name, so exact lookups do not require
+// any decryption.  Values are stored encrypted together with their plaintext
+// key, which is recovered when iterating a bucket and checked on every lookup
+// so that values can not be swapped between keys.  The plaintext name of each
+// namespace and nested bucket is stored encrypted in the bucket itself under a
+// reserved key which is never a valid HMAC.
+//
+// The keys used for encryption and authentication are randomly generated and
+// stored in a plaintext metadata namespace, encrypted by a key derived from
+// the database passphrase.
+var (
+	// metaNamespaceKey is the key of the plaintext namespace which holds
+	// the metadata of the encrypted database.  It is never the length of
+	// an HMAC, so it can not collide with a user namespace.
+	metaNamespaceKey = []byte("cryptdb")
+
+	// secretKeyParamsName is the metadata key of the marshaled snacl
+	// parameters used to derive the passphrase key.
+	secretKeyParamsName = []byte("secretkeyparams")
+
+	// cryptoKeysName is the metadata key of the encryption and
+	// authentication keys, encrypted by the passphrase key.
+	cryptoKeysName = []byte("cryptokeys")
+
+	// bucketNameKey is the reserved key of each namespace root bucket and
+	// nested bucket which holds the encrypted bucket or namespace name.
+	bucketNameKey = []byte{0}
+)
+
+// macSize is the size of every key in the underlying database other than the
+// reserved bucket name key.
+const macSize = fastsha256.Size
+
+// keys holds the keys used to encrypt and authenticate the database contents.
+type keys struct {
+	crypt snacl.CryptoKey
+	mac   [32]byte
+}
+
+// macKey returns the key used in the underlying database for the plaintext key
+// k.  Empty keys are passed through unmodified so that the underlying
+// database reports the appropriate error.
+func (ks *keys) macKey(k []byte) []byte {
+	if len(k) == 0 {
+		return k
+	}
+	h := hmac.New(fastsha256.New, ks.mac[:])
+	h.Write(k)
+	return h.Sum(nil)
+}
+
+// sealValue encrypts a key/value pair for storage.
+func (ks *keys) sealValue(k, v []byte) ([]byte, error) {
+	buf := make([]byte, binary.MaxVarintLen64+len(k)+len(v))
+	n := binary.PutUvarint(buf, uint64(len(k)))
+	n += copy(buf[n:], k)
+	n += copy(buf[n:], v)
+	sealed, err := ks.crypt.Encrypt(buf[:n])
+	zero.Bytes(buf)
+	return sealed, err
+}
+
+// openValue decrypts a stored key/value pair.
+func (ks *keys) openValue(sealed []byte) (k, v []byte, err error) {
+	buf, err := ks.crypt.Decrypt(sealed)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyLen, n := binary.Uvarint(buf)
+	if n <= 0 || uint64(len(buf)-n) < keyLen {
+		return nil, nil, snacl.ErrMalformed
+	}
+	k = buf[n : n+int(keyLen)]
+	v = buf[n+int(keyLen):]
+	return k, v, nil
+}
+
+// bucket is an internal type used to represent a collection of key/value pairs
+// and implements the walletdb.Bucket interface.  It wraps a bucket of the
+// underlying database.
+type bucket struct {
+	b    walletdb.Bucket
+	keys *keys
+
+	// path identifies the bucket within its transaction's cache.  It is
+	// the concatenation of the underlying keys of every bucket from the
+	// root bucket of the namespace.
+	path  string
+	cache bucketCache
+}
+
+// Enforce bucket implements the walletdb.Bucket interface.
+var _ walletdb.Bucket = (*bucket)(nil)
+
+// Bucket retrieves a nested bucket with the given key.  Returns nil if
+// the bucket does not exist.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Bucket(key []byte) walletdb.Bucket {
+	// This nil check is intentional so the return value can be checked
+	// against nil directly.
+	k := b.keys.macKey(key)
+	nested := b.b.Bucket(k)
+	if nested == nil {
+		return nil
+	}
+	return b.nested(nested, k)
+}
+
+// nested returns the bucket wrapping the underlying nested bucket stored under
+// the key k.
+func (b *bucket) nested(nested walletdb.Bucket, k []byte) *bucket {
+	return &bucket{
+		b:     nested,
+		keys:  b.keys,
+		path:  b.path + string(k),
+		cache: b.cache,
+	}
+}
+
+// CreateBucket creates and returns a new nested bucket with the given key.
+// Returns ErrBucketExists if the bucket already exists, ErrBucketNameRequired
+// if the key is empty, or ErrIncompatibleValue if the key value is otherwise
+// invalid.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) CreateBucket(key []byte) (walletdb.Bucket, error) {
+	k := b.keys.macKey(key)
+	nested, err := b.b.CreateBucket(k)
+	if err != nil {
+		return nil, err
+	}
+	name, err := b.keys.crypt.Encrypt(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := nested.Put(bucketNameKey, name); err != nil {
+		return nil, err
+	}
+	b.cachePut(entry{key: copyBytes(key), macKey: k})
+	return b.nested(nested, k), nil
+}
+
+// CreateBucketIfNotExists creates and returns a new nested bucket with the
+// given key if it does not already exist.  Returns ErrBucketNameRequired if the
+// key is empty or ErrIncompatibleValue if the key value is otherwise invalid.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) CreateBucketIfNotExists(key []byte) (walletdb.Bucket, error) {
+	nested, err := b.CreateBucket(key)
+	if err == walletdb.ErrBucketExists {
+		return b.Bucket(key), nil
+	}
+	return nested, err
+}
+
+// DeleteBucket removes a nested bucket with the given key.  Returns
+// ErrTxNotWritable if attempted against a read-only transaction and
+// ErrBucketNotFound if the specified bucket does not exist.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) DeleteBucket(key []byte) error {
+	k := b.keys.macKey(key)
+	if err := b.b.DeleteBucket(k); err != nil {
+		return err
+	}
+	b.cacheDelete(key)
+
+	// Drop the cached entries of the deleted bucket and everything nested
+	// within it.
+	prefix := b.path + string(k)
+	for path := range b.cache {
+		if strings.HasPrefix(path, prefix) {
+			delete(b.cache, path)
+		}
+	}
+	return nil
+}
+
+// ForEach invokes the passed function with every key/value pair in the bucket.
+// This includes nested buckets, in which case the value is nil, but it does not
+// include the key/value pairs within those nested buckets.  Pairs are visited
+// in the order of their plaintext keys.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) ForEach(fn func(k, v []byte) error) error {
+	entries, err := b.entries(false)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		e := &entries[i]
+		if err := fn(e.key, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writable returns whether or not the bucket is writable.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Writable() bool {
+	return b.b.Writable()
+}
+
+// Put saves the specified key/value pair to the bucket.  Keys that do not
+// already exist are added and keys that already exist are overwritten.  Returns
+// ErrTxNotWritable if attempted against a read-only transaction.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Put(key, value []byte) error {
+	if len(key) == 0 || !b.b.Writable() {
+		// Let the underlying bucket report the error.
+		return b.b.Put(key, value)
+	}
+	sealed, err := b.keys.sealValue(key, value)
+	if err != nil {
+		return err
+	}
+	k := b.keys.macKey(key)
+	if err := b.b.Put(k, sealed); err != nil {
+		return err
+	}
+	// Values are never nil so they are not mistaken for nested buckets.
+	b.cachePut(entry{
+		key:    copyBytes(key),
+		value:  append([]byte{}, value...),
+		macKey: k,
+	})
+	return nil
+}
+
+// Get returns the value for the given key.  Returns nil if the key does
+// not exist in this bucket (or nested buckets).  Nil is also returned if the
+// stored value can not be decrypted and authenticated.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Get(key []byte) []byte {
+	sealed := b.b.Get(b.keys.macKey(key))
+	if sealed == nil {
+		return nil
+	}
+	k, v, err := b.keys.openValue(sealed)
+	if err != nil || !bytes.Equal(k, key) {
+		return nil
+	}
+	return v
+}
+
+// Delete removes the specified key from the bucket.  Deleting a key that does
+// not exist does not return an error.  Returns ErrTxNotWritable if attempted
+// against a read-only transaction.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Delete(key []byte) error {
+	if err := b.b.Delete(b.keys.macKey(key)); err != nil {
+		return err
+	}
+	b.cacheDelete(key)
+	return nil
+}
+
+// Cursor returns a new cursor, allowing for iteration over the bucket's
+// key/value pairs and nested buckets in forward or backward order.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Cursor() walletdb.Cursor {
+	return &cursor{bucket: b}
+}
+
+// entry describes a single key/value pair or nested bucket with its plaintext
+// key.
+type entry struct {
+	key    []byte
+	value  []byte // nil for nested buckets
+	macKey []byte
+}
+
+// bucketCache holds the decrypted entries of every bucket which has been
+// iterated during a transaction, keyed by bucket path.  Cached entries are
+// never modified.  Changes made through the transaction replace them with an
+// updated copy instead, so cursors which already hold the entries of a bucket
+// are unaffected.
+type bucketCache map[string]*bucketEntries
+
+// bucketEntries holds the decrypted entries of a bucket sorted by their
+// plaintext keys.  Entries which can not be decrypted and authenticated are
+// omitted, and err is set to the error of the first such entry.
+type bucketEntries struct {
+	entries []entry
+	err     error
+}
+
+// entries returns every key/value pair and nested bucket name of the bucket
+// sorted by their plaintext keys.  Since the keys of the underlying bucket are
+// not ordered by their plaintext, the entire bucket must be decrypted to
+// iterate over it in order.  This is done once per transaction, and the
+// entries are kept up to date with any changes made through the transaction.
+// Entries which can not be decrypted and authenticated cause an error to be
+// returned, unless skipInvalid is set, in which case only those entries are
+// omitted.
+//
+// The returned slice is shared and must not be modified.
+func (b *bucket) entries(skipInvalid bool) ([]entry, error) {
+	cached, ok := b.cache[b.path]
+	if !ok {
+		var err error
+		cached, err = b.readEntries()
+		if err != nil {
+			return nil, err
+		}
+		b.cache[b.path] = cached
+	}
+	if cached.err != nil && !skipInvalid {
+		return nil, cached.err
+	}
+	return cached.entries, nil
+}
+
+// readEntries decrypts every entry of the underlying bucket.
+func (b *bucket) readEntries() (*bucketEntries, error) {
+	var cached bucketEntries
+	err := b.b.ForEach(func(k, v []byte) error {
+		if len(k) != macSize {
+			// Skip the reserved bucket name key.
+			return nil
+		}
+		e, err := b.entry(k, v)
+		if err != nil {
+			if cached.err == nil {
+				cached.err = err
+			}
+			return nil
+		}
+		cached.entries = append(cached.entries, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(entriesByKey(cached.entries))
+	return &cached, nil
+}
+
+// cachePut records that the entry e was stored in the bucket, replacing any
+// existing entry with the same key.  Nothing is done if the entries of the
+// bucket have not been read during the transaction.
+func (b *bucket) cachePut(e entry) {
+	cached, ok := b.cache[b.path]
+	if !ok {
+		return
+	}
+	if cached.err != nil {
+		// The new entry may replace one which could not be
+		// decrypted, so read the bucket again when next iterated.
+		delete(b.cache, b.path)
+		return
+	}
+
+	old := cached.entries
+	i, found := searchEntries(old, e.key)
+	var entries []entry
+	if found {
+		entries = make([]entry, len(old))
+		copy(entries, old)
+	} else {
+		entries = make([]entry, len(old)+1)
+		copy(entries, old[:i])
+		copy(entries[i+1:], old[i:])
+	}
+	entries[i] = e
+	b.cache[b.path] = &bucketEntries{entries: entries}
+}
+
+// cacheDelete records that the entry with the plaintext key was removed from
+// the bucket.  Nothing is done if the entries of the bucket have not been read
+// during the transaction.
+func (b *bucket) cacheDelete(key []byte) {
+	cached, ok := b.cache[b.path]
+	if !ok {
+		return
+	}
+	if cached.err != nil {
+		delete(b.cache, b.path)
+		return
+	}
+
+	old := cached.entries
+	i, found := searchEntries(old, key)
+	if !found {
+		return
+	}
+	entries := make([]entry, 0, len(old)-1)
+	entries = append(entries, old[:i]...)
+	entries = append(entries, old[i+1:]...)
+	b.cache[b.path] = &bucketEntries{entries: entries}
+}
+
+// searchEntries returns the index of the entry with the plaintext key in the
+// sorted entries, or the index at which it would be inserted, and whether the
+// key was found.
+func searchEntries(entries []entry, key []byte) (int, bool) {
+	i := sort.Search(len(entries), func(i int) bool {
+		return bytes.Compare(entries[i].key, key) >= 0
+	})
+	return i, i < len(entries) && bytes.Equal(entries[i].key, key)
+}
+
+// copyBytes returns a copy of the passed byte slice.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// entry decrypts the key/value pair or nested bucket name stored in the
+// underlying bucket under the key k.
+func (b *bucket) entry(k, v []byte) (entry, error) {
+	e := entry{macKey: k}
+	if v == nil {
+		nested := b.b.Bucket(k)
+		if nested == nil {
+			return entry{}, snacl.ErrMalformed
+		}
+		name, err := b.keys.crypt.Decrypt(nested.Get(bucketNameKey))
+		if err != nil {
+			return entry{}, err
+		}
+		e.key = name
+	} else {
+		var err error
+		e.key, e.value, err = b.keys.openValue(v)
+		if err != nil {
+			return entry{}, err
+		}
+		// Values are never nil so they are not mistaken for nested
+		// buckets.
+		if e.value == nil {
+			e.value = []byte{}
+		}
+	}
+	if !hmac.Equal(b.keys.macKey(e.key), k) {
+		return entry{}, snacl.ErrMalformed
+	}
+	return e, nil
+}
+
+// entriesByKey implements sort.Interface to sort entries by their plaintext
+// keys.
+type entriesByKey []entry
+
+func (s entriesByKey) Len() int           { return len(s) }
+func (s entriesByKey) Less(i, j int) bool { return bytes.Compare(s[i].key, s[j].key) < 0 }
+func (s entriesByKey) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// cursor represents a cursor over key/value pairs and nested buckets of a
+// bucket.  Since the underlying bucket is not ordered by plaintext keys, the
+// cursor uses the decrypted entries of the bucket when it is first positioned
+// and iterates over them in order.  Keys which are added after the cursor is
+// positioned are not visited.  Entries which fail to decrypt or authenticate
+// are skipped, but do not prevent the remaining entries from being visited.
+type cursor struct {
+	bucket  *bucket
+	entries []entry
+	loaded  bool
+	pos     int
+
+	// owned is set once the cursor has copied the entries shared with the
+	// transaction's cache, so that they can be modified by Delete.
+	owned bool
+}
+
+// Enforce cursor implements the walletdb.Cursor interface.
+var _ walletdb.Cursor = (*cursor)(nil)
+
+// load reads the entries of the bucket if they have not yet been read.
+func (c *cursor) load() {
+	if c.loaded {
+		return
+	}
+	// The cursor interface has no way to report errors, so entries which
+	// can not be decrypted are skipped rather than hiding the entire
+	// bucket.  These are never returned by Get either.
+	entries, _ := c.bucket.entries(true)
+	c.entries = entries
+	c.loaded = true
+}
+
+// at positions the cursor at index i and returns the key/value pair there, or
+// nil if the index is out of range.
+func (c *cursor) at(i int) (key, value []byte) {
+	if i < 0 {
+		c.pos = -1
+		return nil, nil
+	}
+	if i >= len(c.entries) {
+		c.pos = len(c.entries)
+		return nil, nil
+	}
+	c.pos = i
+	e := &c.entries[i]
+	return e.key, e.value
+}
+
+// Bucket returns the bucket the cursor was created for.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Bucket() walletdb.Bucket {
+	return c.bucket
+}
+
+// Delete removes the current key/value pair the cursor is at without
+// invalidating the cursor.  Returns ErrTxNotWritable if attempted on a read-only
+// transaction, or ErrIncompatibleValue if attempted when the cursor points to a
+// nested bucket.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Delete() error {
+	if c.pos < 0 || c.pos >= len(c.entries) {
+		return nil
+	}
+	e := &c.entries[c.pos]
+	if e.value == nil {
+		return walletdb.ErrIncompatibleValue
+	}
+	if err := c.bucket.b.Delete(e.macKey); err != nil {
+		return err
+	}
+	c.bucket.cacheDelete(e.key)
+	if !c.owned {
+		c.entries = append([]entry(nil), c.entries...)
+		c.owned = true
+	}
+	c.entries = append(c.entries[:c.pos], c.entries[c.pos+1:]...)
+
+	// Leave the cursor between the neighbors of the removed entry so that
+	// both Next and Prev return them.
+	c.pos = -c.pos - 2
+	return nil
+}
+
+// resolvePos returns the positions the cursor moves to from its current
+// position with Next and Prev.
+func (c *cursor) resolvePos() (next, prev int) {
+	if c.pos <= -2 {
+		// The entry at the position was deleted.
+		i := -c.pos - 2
+		return i, i - 1
+	}
+	return c.pos + 1, c.pos - 1
+}
+
+// First positions the cursor at the first key/value pair and returns the pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) First() (key, value []byte) {
+	c.load()
+	return c.at(0)
+}
+
+// Last positions the cursor at the last key/value pair and returns the pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Last() (key, value []byte) {
+	c.load()
+	if len(c.entries) == 0 {
+		return c.at(-1)
+	}
+	return c.at(len(c.entries) - 1)
+}
+
+// Next moves the cursor one key/value pair forward and returns the new pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Next() (key, value []byte) {
+	c.load()
+	next, _ := c.resolvePos()
+	return c.at(next)
+}
+
+// Prev moves the cursor one key/value pair backward and returns the new pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Prev() (key, value []byte) {
+	c.load()
+	_, prev := c.resolvePos()
+	return c.at(prev)
+}
+
+// Seek positions the cursor at the passed seek key.  If the key does not exist,
+// the cursor is moved to the next key after seek.  Returns the new pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Seek(seek []byte) (key, value []byte) {
+	c.load()
+	i, _ := searchEntries(c.entries, seek)
+	return c.at(i)
+}
+
+// transaction represents a database transaction.  It can either be read-only
+// or read-write and implements the walletdb.Tx interface.  It wraps a
+// transaction of the underlying database.
+type transaction struct {
+	tx    walletdb.Tx
+	keys  *keys
+	cache bucketCache
+}
+
+// Enforce transaction implements the walletdb.Tx interface.
+var _ walletdb.Tx = (*transaction)(nil)
+
+// RootBucket returns the top-most bucket for the namespace the transaction was
+// created from.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx *transaction) RootBucket() walletdb.Bucket {
+	if tx.cache == nil {
+		tx.cache = make(bucketCache)
+	}
+	return &bucket{b: tx.tx.RootBucket(), keys: tx.keys, cache: tx.cache}
+}
+
+// Commit commits all changes that have been made through the root bucket and
+// all of its sub-buckets to persistent storage.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx *transaction) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback undoes all changes that have been made to the root bucket and all of
+// its sub-buckets.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx *transaction) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// dbTransaction represents a database transaction with access to every
+// namespace.  It can either be read-only or read-write and implements the
+// walletdb.DBTx interface.  It wraps a transaction of the underlying database.
+type dbTransaction struct {
+	tx    walletdb.DBTx
+	keys  *keys
+	cache bucketCache
+}
+
+// Enforce dbTransaction implements the walletdb.DBTx interface.
+var _ walletdb.DBTx = (*dbTransaction)(nil)
+
+// RootBucket returns the top-most bucket for the namespace with the passed
+// key.  Returns nil if the namespace does not exist.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) RootBucket(namespaceKey []byte) walletdb.Bucket {
+	// This nil check is intentional so the return value can be checked
+	// against nil directly.
+	k := tx.keys.macKey(namespaceKey)
+	b := tx.tx.RootBucket(k)
+	if b == nil {
+		return nil
+	}
+	if tx.cache == nil {
+		tx.cache = make(bucketCache)
+	}
+	return &bucket{b: b, keys: tx.keys, path: string(k), cache: tx.cache}
+}
+
+// ForEachNamespace invokes the passed function with the key of every namespace
+// in the database.  The plaintext keys are decrypted from the root bucket of
+// each namespace, and an error is returned for any namespace whose key can not
+// be decrypted and authenticated.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) ForEachNamespace(fn func(namespaceKey []byte) error) error {
+	return tx.tx.ForEachNamespace(func(k []byte) error {
+		if len(k) != macSize {
+			// Skip the plaintext metadata namespace.
+			return nil
+		}
+		root := tx.tx.RootBucket(k)
+		if root == nil {
+			return snacl.ErrMalformed
+		}
+		name, err := tx.keys.crypt.Decrypt(root.Get(bucketNameKey))
+		if err != nil {
+			return err
+		}
+		if !hmac.Equal(tx.keys.macKey(name), k) {
+			return snacl.ErrMalformed
+		}
+		return fn(name)
+	})
+}
+
+// Commit commits all changes that have been made through the root buckets and
+// all of their sub-buckets to persistent storage.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Rollback undoes all changes that have been made to the root buckets and all
+// of their sub-buckets.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) Rollback() error {
+	return tx.tx.Rollback()
+}
+
+// namespace represents a database namespace that is intended to support the
+// concept of a single entity that controls the opening, creating, and closing
+// of a database while providing other entities their own namespace to work in.
+// It implements the walletdb.Namespace interface.
+type namespace struct {
+	ns   walletdb.Namespace
+	keys *keys
+}
+
+// Enforce namespace implements the walletdb.Namespace interface.
+var _ walletdb.Namespace = (*namespace)(nil)
+
+// Begin starts a transaction which is either read-only or read-write depending
+// on the specified flag.  Multiple read-only transactions can be started
+// simultaneously while only a single read-write transaction can be started at a
+// time.  The call will block when starting a read-write transaction when one is
+// already open.
+//
+// NOTE: The transaction must be closed by calling Rollback or Commit on it when
+// it is no longer needed.  Failure to do so can result in unrecoverable
+// deadlocks in the underlying database.
+//
+// This function is part of the walletdb.Namespace interface implementation.
+func (ns *namespace) Begin(writable bool) (walletdb.Tx, error) {
+	tx, err := ns.ns.Begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction{tx: tx, keys: ns.keys}, nil
+}
+
+// View invokes the passed function in the context of a managed read-only
+// transaction.  Any errors returned from the user-supplied function are
+// returned from this function.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Namespace interface implementation.
+func (ns *namespace) View(fn func(walletdb.Tx) error) error {
+	return ns.ns.View(func(tx walletdb.Tx) error {
+		return fn(&transaction{tx: tx, keys: ns.keys})
+	})
+}
+
+// Update invokes the passed function in the context of a managed read-write
+// transaction.  Any errors returned from the user-supplied function will cause
+// the transaction to be rolled back and are returned from this function.
+// Otherwise, the transaction is commited when the user-supplied function
+// returns a nil error.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Namespace interface implementation.
+func (ns *namespace) Update(fn func(walletdb.Tx) error) error {
+	return ns.ns.Update(func(tx walletdb.Tx) error {
+		return fn(&transaction{tx: tx, keys: ns.keys})
+	})
+}
+
+// db represents a collection of namespaces which are encrypted before being
+// stored in an underlying database.  It implements the walletdb.Db interface.
+type db struct {
+	db   walletdb.DB
+	keys *keys
+}
+
+// Enforce db implements the walletdb.Db interface.
+var _ walletdb.DB = (*db)(nil)
+
+// Namespace returns a Namespace interface for the provided key.  See the
+// Namespace interface documentation for more details.  Attempting to access a
+// Namespace on a database that is not open yet or has been closed will result
+// in ErrDbNotOpen.  Namespaces are created in the database on first access.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Namespace(key []byte) (walletdb.Namespace, error) {
+	ns, err := db.db.Namespace(db.keys.macKey(key))
+	if err != nil {
+		return nil, err
+	}
+
+	// Record the encrypted name of a new namespace so that it can be
+	// listed by ForEachNamespace.
+	var named bool
+	err = ns.View(func(tx walletdb.Tx) error {
+		named = tx.RootBucket().Get(bucketNameKey) != nil
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !named {
+		err := ns.Update(func(tx walletdb.Tx) error {
+			name, err := db.keys.crypt.Encrypt(key)
+			if err != nil {
+				return err
+			}
+			return tx.RootBucket().Put(bucketNameKey, name)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &namespace{ns: ns, keys: db.keys}, nil
+}
+
+// DeleteNamespace deletes the namespace for the passed key.  ErrBucketNotFound
+// will be returned if the namespace does not exist.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) DeleteNamespace(key []byte) error {
+	return db.db.DeleteNamespace(db.keys.macKey(key))
+}
+
+// BeginTx starts a transaction with access to every namespace which is either
+// read-only or read-write depending on the specified flag.  Multiple read-only
+// transactions can be started simultaneously while only a single read-write
+// transaction can be started at a time.  The call will block when starting a
+// read-write transaction when one is already open.
+//
+// NOTE: The transaction must be closed by calling Rollback or Commit on it when
+// it is no longer needed.  Failure to do so can result in unrecoverable
+// deadlocks in the underlying database.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) BeginTx(writable bool) (walletdb.DBTx, error) {
+	tx, err := db.db.BeginTx(writable)
+	if err != nil {
+		return nil, err
+	}
+	return &dbTransaction{tx: tx, keys: db.keys}, nil
+}
+
+// View invokes the passed function in the context of a managed read-only
+// transaction with access to every namespace.  Any errors returned from the
+// user-supplied function are returned from this function.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) View(fn func(walletdb.DBTx) error) error {
+	return db.db.View(func(tx walletdb.DBTx) error {
+		return fn(&dbTransaction{tx: tx, keys: db.keys})
+	})
+}
+
+// Update invokes the passed function in the context of a managed read-write
+// transaction with access to every namespace.  Any errors returned from the
+// user-supplied function will cause the transaction to be rolled back and are
+// returned from this function.  Otherwise, the transaction is commited when
+// the user-supplied function returns a nil error.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Update(fn func(walletdb.DBTx) error) error {
+	return db.db.Update(func(tx walletdb.DBTx) error {
+		return fn(&dbTransaction{tx: tx, keys: db.keys})
+	})
+}
+
+// Copy writes a copy of the database to the provided writer.  The copy remains
+// encrypted and is written in the format of the underlying database, so it may
+// be opened with this driver using the same database type and passphrase.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Copy(w io.Writer) error {
+	return db.db.Copy(w)
+}
+
+// Close cleanly shuts down the underlying database and zeros the keys held in
+// memory.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Close() error {
+	db.keys.crypt.Zero()
+	zero.Bytea32(&db.keys.mac)
+	return db.db.Close()
+}
+
+// IsEncrypted returns whether the database, opened with the driver of its
+// underlying type, was created by this driver.  The database is only read, so
+// it may have been opened read-only.
+func IsEncrypted(underlying walletdb.DB) (bool, error) {
+	var encrypted bool
+	err := underlying.View(func(tx walletdb.DBTx) error {
+		encrypted = tx.RootBucket(metaNamespaceKey) != nil
+		return nil
+	})
+	return encrypted, err
+}
+
+// openDB wraps an open database of the underlying type with encryption.  When
+// create is set, new keys are generated and stored in the database, encrypted
+// with the passphrase, and walletdb.ErrDbExists is returned if the database
+// already contains keys.  Otherwise, the existing keys are decrypted with the
+// passphrase.  The underlying database is not closed on errors.
+//
+// The keys of an existing database are only read, so the underlying database
+// may have been opened read-only, and nothing is written to a database which
+// was not created by this driver.
+func openDB(underlying walletdb.DB, passphrase []byte, create bool) (walletdb.DB, error) {
+	var ks *keys
+	var err error
+	if create {
+		ks, err = createKeys(underlying, passphrase)
+	} else {
+		ks, err = readKeys(underlying, passphrase)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &db{db: underlying, keys: ks}, nil
+}
+
+// readKeys decrypts the keys of an existing encrypted database with the
+// passphrase.  ErrNotEncrypted is returned if the database does not contain
+// any keys.
+func readKeys(underlying walletdb.DB, passphrase []byte) (*keys, error) {
+	var ks *keys
+	err := underlying.View(func(tx walletdb.DBTx) error {
+		meta := tx.RootBucket(metaNamespaceKey)
+		if meta == nil {
+			return ErrNotEncrypted
+		}
+		params := meta.Get(secretKeyParamsName)
+		encKeys := meta.Get(cryptoKeysName)
+		if params == nil || encKeys == nil {
+			return ErrNotEncrypted
+		}
+
+		var sk snacl.SecretKey
+		if err := sk.Unmarshal(params); err != nil {
+			return err
+		}
+		defer sk.Zero()
+		err := sk.DeriveKey(&passphrase)
+		if err == snacl.ErrInvalidPassword {
+			return ErrInvalidPassphrase
+		}
+		if err != nil {
+			return err
+		}
+		plainKeys, err := sk.Decrypt(encKeys)
+		if err != nil {
+			return err
+		}
+		defer zero.Bytes(plainKeys)
+		if len(plainKeys) != snacl.KeySize*2 {
+			return snacl.ErrMalformed
+		}
+		ks = new(keys)
+		copy(ks.crypt[:], plainKeys[:snacl.KeySize])
+		copy(ks.mac[:], plainKeys[snacl.KeySize:])
+		return nil
+	})
+	return ks, err
+}
+
+// createKeys generates new keys for the database and stores them, encrypted
+// with the passphrase, in the metadata namespace.  walletdb.ErrDbExists is
+// returned if the database already contains keys.
+func createKeys(underlying walletdb.DB, passphrase []byte) (*keys, error) {
+	metaNS, err := underlying.Namespace(metaNamespaceKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var ks *keys
+	err = metaNS.Update(func(tx walletdb.Tx) error {
+		meta := tx.RootBucket()
+		if meta.Get(secretKeyParamsName) != nil ||
+			meta.Get(cryptoKeysName) != nil {
+			return walletdb.ErrDbExists
+		}
+
+		sk, err := snacl.NewSecretKey(&passphrase, snacl.DefaultN,
+			snacl.DefaultR, snacl.DefaultP)
+		if err != nil {
+			return err
+		}
+		defer sk.Zero()
+		cryptKey, err := snacl.GenerateCryptoKey()
+		if err != nil {
+			return err
+		}
+		defer cryptKey.Zero()
+		macKey, err := snacl.GenerateCryptoKey()
+		if err != nil {
+			return err
+		}
+		defer macKey.Zero()
+
+		plainKeys := make([]byte, 0, snacl.KeySize*2)
+		plainKeys = append(plainKeys, cryptKey[:]...)
+		plainKeys = append(plainKeys, macKey[:]...)
+		encKeys, err := sk.Encrypt(plainKeys)
+		zero.Bytes(plainKeys)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(secretKeyParamsName, sk.Marshal()); err != nil {
+			return err
+		}
+		if err := meta.Put(cryptoKeysName, encKeys); err != nil {
+			return err
+		}
+		ks = &keys{crypt: *cryptKey, mac: *macKey}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+/*
+Package cryptdb implements an instance of walletdb that encrypts all keys and
+values before storing them in another walletdb database.
+
+Encryption
+
+Every value is encrypted with NaCl secretbox using a random key, and every key,
+bucket name, and namespace name is replaced by its HMAC-SHA256 under a second
+random key.  Both random keys are stored in the underlying database encrypted
+by a key derived from the database passphrase with scrypt, using the snacl
+package.  Someone with access to the underlying database without the passphrase
+only learns the number of keys and buckets, the sizes of values, and which
+writes modified the same keys.
+
+Since the keys of the underlying database are not ordered by the plaintext
+keys, cursors and ForEach must decrypt every key of a bucket before iterating
+over it in order.  This is done at most once per bucket in each transaction,
+and the decrypted entries are kept in memory until the transaction ends.
+Lookups of a single key do not require this.
+
+Usage
+
+This package is only a driver to the walletdb package and provides the database
+type of "cryptdb".  The first parameter to the Open and Create functions is the
+database type of the underlying database and the second is the database
+passphrase as a byte slice.  All remaining parameters are passed to the driver
+of the underlying database:
+
+	db, err := walletdb.Open("cryptdb", "bdb", passphrase, "path/to/database.db")
+	if err != nil {
+		// Handle error
+	}
+
+	db, err := walletdb.Create("cryptdb", "bdb", passphrase, "path/to/database.db")
+	if err != nil {
+		// Handle error
+	}
+
+OpenReadOnly takes the same parameters and opens the underlying database
+read-only, which is only supported when the underlying driver supports it:
+
+	db, err := walletdb.OpenReadOnly("cryptdb", "bdb", passphrase, "path/to/database.db")
+	if err != nil {
+		// Handle error
+	}
+
+The driver of the underlying database must also be registered, usually by
+importing its package.  Opening a database with the wrong passphrase returns
+ErrInvalidPassphrase, and opening one which was not created by this driver
+returns ErrNotEncrypted.  IsEncrypted reports whether a database opened with
+its underlying driver was created by this driver.
+*/
+package cryptdb
@@ -0,0 +1,136 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cryptdb
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+const (
+	dbType = "cryptdb"
+)
+
+// parseArgs parses the arguments from the walletdb Open/Create methods.  The
+// first two arguments are the type of the underlying database and the database
+// passphrase.  All remaining arguments are passed to the underlying driver.
+func parseArgs(funcName string, args ...interface{}) (string, []byte, []interface{}, error) {
+	if len(args) < 2 {
+		return "", nil, nil, fmt.Errorf("invalid arguments to %s.%s -- "+
+			"expected underlying database type and passphrase",
+			dbType, funcName)
+	}
+
+	underlyingType, ok := args[0].(string)
+	if !ok {
+		return "", nil, nil, fmt.Errorf("first argument to %s.%s is "+
+			"invalid -- expected underlying database type string",
+			dbType, funcName)
+	}
+	if underlyingType == dbType {
+		return "", nil, nil, fmt.Errorf("first argument to %s.%s is "+
+			"invalid -- underlying database type may not be %s",
+			dbType, funcName, dbType)
+	}
+
+	passphrase, ok := args[1].([]byte)
+	if !ok {
+		return "", nil, nil, fmt.Errorf("second argument to %s.%s is "+
+			"invalid -- expected passphrase byte slice",
+			dbType, funcName)
+	}
+
+	return underlyingType, passphrase, args[2:], nil
+}
+
+// openDBDriver is the callback provided during driver registration that opens
+// an existing database for use.
+func openDBDriver(args ...interface{}) (walletdb.DB, error) {
+	underlyingType, passphrase, underlyingArgs, err := parseArgs("Open",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	underlying, err := walletdb.Open(underlyingType, underlyingArgs...)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDB(underlying, passphrase, false)
+	if err != nil {
+		underlying.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openReadOnlyDBDriver is the callback provided during driver registration
+// that opens an existing database without write access.  The underlying driver
+// must also support read-only access.
+func openReadOnlyDBDriver(args ...interface{}) (walletdb.DB, error) {
+	underlyingType, passphrase, underlyingArgs, err := parseArgs(
+		"OpenReadOnly", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	underlying, err := walletdb.OpenReadOnly(underlyingType,
+		underlyingArgs...)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDB(underlying, passphrase, false)
+	if err != nil {
+		underlying.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// createDBDriver is the callback provided during driver registration that
+// creates, initializes, and opens a database for use.
+func createDBDriver(args ...interface{}) (walletdb.DB, error) {
+	underlyingType, passphrase, underlyingArgs, err := parseArgs("Create",
+		args...)
+	if err != nil {
+		return nil, err
+	}
+
+	underlying, err := walletdb.Create(underlyingType, underlyingArgs...)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDB(underlying, passphrase, true)
+	if err != nil {
+		underlying.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func init() {
+	// Register the driver.
+	driver := walletdb.Driver{
+		DbType:       dbType,
+		Create:       createDBDriver,
+		Open:         openDBDriver,
+		OpenReadOnly: openReadOnlyDBDriver,
+	}
+	if err := walletdb.RegisterDriver(driver); err != nil {
+		panic(fmt.Sprintf("Failed to regiser database driver '%s': %v",
+			dbType, err))
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package cryptdb_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/btcsuite/btcwallet/walletdb/cryptdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
+)
+
+// dbType is the database type name for this driver.
+const dbType = "cryptdb"
+
+// passphrase is the database passphrase used by the tests.
+var passphrase = []byte("passphrase")
+
+// TestCreateOpenFail ensures that errors related to creating and opening a
+// database are handled properly.
+func TestCreateOpenFail(t *testing.T) {
+	// Ensure that attempting to open a database with the wrong number of
+	// parameters returns the expected error.
+	wantErr := fmt.Errorf("invalid arguments to %s.Open -- expected "+
+		"underlying database type and passphrase", dbType)
+	if _, err := walletdb.Open(dbType, "bdb"); err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to create a database with an invalid type for
+	// the passphrase returns the expected error.
+	wantErr = fmt.Errorf("second argument to %s.Create is invalid -- "+
+		"expected passphrase byte slice", dbType)
+	if _, err := walletdb.Create(dbType, "mem", "pass"); err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that errors from the underlying driver are returned.
+	if _, err := walletdb.Open(dbType, "bdb", passphrase, "noexist.db"); err != walletdb.ErrDbDoesNotExist {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, walletdb.ErrDbDoesNotExist)
+		return
+	}
+}
+
+// TestOpenNotEncrypted ensures that opening a database which was not created
+// by this driver fails without modifying the database.
+func TestOpenNotEncrypted(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cryptdb_test")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dbPath := tmpDir + string(os.PathSeparator) + "plaintest.db"
+
+	db, err := walletdb.Create("bdb", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database (bdb) %v", err)
+	}
+	db.Close()
+
+	db, err = walletdb.Open("bdb", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database (bdb) %v", err)
+	}
+	encrypted, err := cryptdb.IsEncrypted(db)
+	db.Close()
+	if err != nil || encrypted {
+		t.Fatalf("IsEncrypted: got %v, %v, want false", encrypted, err)
+	}
+
+	_, err = walletdb.Open(dbType, "bdb", passphrase, dbPath)
+	if err != cryptdb.ErrNotEncrypted {
+		t.Fatalf("Open: did not receive expected error - got %v, "+
+			"want %v", err, cryptdb.ErrNotEncrypted)
+	}
+
+	// Ensure the metadata namespace was not created.
+	db, err = walletdb.Open("bdb", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database (bdb) %v", err)
+	}
+	defer db.Close()
+	err = db.View(func(tx walletdb.DBTx) error {
+		if tx.RootBucket([]byte("cryptdb")) != nil {
+			return fmt.Errorf("metadata namespace was created")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestPersistence ensures that values stored are still valid after closing and
+// reopening the database, that the database can only be reopened with the
+// correct passphrase, and that no keys or values are stored in plaintext.
+func TestPersistence(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "cryptdb_test")
+	if err != nil {
+		t.Fatalf("TempDir: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+	dbPath := tmpDir + string(os.PathSeparator) + "persistencetest.db"
+
+	db, err := walletdb.Create(dbType, "bdb", passphrase, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+
+	// Create a namespace and put some values into it, including a nested
+	// bucket, so they can be tested for existence on re-open.
+	storeValues := map[string]string{
+		"ns1key1": "secretvalue1",
+		"ns1key2": "secretvalue2",
+		"ns1key3": "secretvalue3",
+	}
+	ns1Key := []byte("secretnamespace")
+	bucketKey := []byte("secretbucket")
+	ns1, err := db.Namespace(ns1Key)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns1.Update(func(tx walletdb.Tx) error {
+		rootBucket := tx.RootBucket()
+		if _, err := rootBucket.CreateBucket(bucketKey); err != nil {
+			return err
+		}
+		for k, v := range storeValues {
+			if err := rootBucket.Put([]byte(k), []byte(v)); err != nil {
+				return fmt.Errorf("Put: unexpected error: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ns1 Update: unexpected error: %v", err)
+	}
+	db.Close()
+
+	// Ensure the database is reported as encrypted when opened with the
+	// underlying driver.
+	plainDB, err := walletdb.Open("bdb", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database (bdb) %v", err)
+	}
+	encrypted, err := cryptdb.IsEncrypted(plainDB)
+	plainDB.Close()
+	if err != nil || !encrypted {
+		t.Fatalf("IsEncrypted: got %v, %v, want true", encrypted, err)
+	}
+
+	// Ensure none of the keys, values, or names appear in the file.
+	contents, err := ioutil.ReadFile(dbPath)
+	if err != nil {
+		t.Fatalf("ReadFile: unexpected error: %v", err)
+	}
+	plaintexts := [][]byte{ns1Key, bucketKey, []byte("ns1key"),
+		[]byte("secretvalue")}
+	for _, p := range plaintexts {
+		if bytes.Contains(contents, p) {
+			t.Errorf("Database file contains plaintext %q", p)
+		}
+	}
+
+	// Ensure the database can not be opened with the wrong passphrase.
+	_, err = walletdb.Open(dbType, "bdb", []byte("wrong"), dbPath)
+	if err != cryptdb.ErrInvalidPassphrase {
+		t.Fatalf("Open: did not receive expected error - got %v, "+
+			"want %v", err, cryptdb.ErrInvalidPassphrase)
+	}
+
+	// Reopen the database to ensure the values persist and are returned
+	// in order by ForEach.
+	db, err = walletdb.Open(dbType, "bdb", passphrase, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+	ns1, err = db.Namespace(ns1Key)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns1.View(func(tx walletdb.Tx) error {
+		rootBucket := tx.RootBucket()
+		for k, v := range storeValues {
+			gotVal := rootBucket.Get([]byte(k))
+			if !reflect.DeepEqual(gotVal, []byte(v)) {
+				return fmt.Errorf("Get: key '%s' does not "+
+					"match expected value - got %s, want %s",
+					k, gotVal, v)
+			}
+		}
+
+		var gotKeys []string
+		err := rootBucket.ForEach(func(k, v []byte) error {
+			gotKeys = append(gotKeys, string(k))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		wantKeys := []string{"ns1key1", "ns1key2", "ns1key3",
+			string(bucketKey)}
+		if !reflect.DeepEqual(gotKeys, wantKeys) {
+			return fmt.Errorf("ForEach: unexpected keys - got %v, "+
+				"want %v", gotKeys, wantKeys)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ns1 View: unexpected error: %v", err)
+	}
+
+	// Reopen the database read-only to ensure the keys can be read without
+	// write access, and that writes are refused.
+	db.Close()
+	db, err = walletdb.OpenReadOnly(dbType, "bdb", passphrase, dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database read-only (%s) %v",
+			dbType, err)
+	}
+	defer db.Close()
+	ns1, err = db.Namespace(ns1Key)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns1.View(func(tx walletdb.Tx) error {
+		gotVal := tx.RootBucket().Get([]byte("ns1key1"))
+		if !reflect.DeepEqual(gotVal, []byte(storeValues["ns1key1"])) {
+			return fmt.Errorf("Get: unexpected value %s", gotVal)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Errorf("ns1 View: unexpected error: %v", err)
+	}
+	err = ns1.Update(func(tx walletdb.Tx) error {
+		return tx.RootBucket().Put([]byte("ns1key4"), []byte("foo4"))
+	})
+	if err != walletdb.ErrDbReadOnly {
+		t.Errorf("Update: did not receive expected error - got %v, "+
+			"want %v", err, walletdb.ErrDbReadOnly)
+	}
+}
+
+// TestInterface performs all interfaces tests for this database driver.
+func TestInterface(t *testing.T) {
+	// Create a new database to run tests against.
+	db, err := walletdb.Create(dbType, "mem", passphrase)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer db.Close()
+
+	// Run all of the interface tests against the database.
+	testInterface(t, db)
+}
@@ -139,4 +139,35 @@ func TestCreateOpenUnsupported(t *testing.T) {
 			walletdb.ErrDbUnknownType)
 		return
 	}
+
+	// Ensure opening a read-only database with an unsupported type fails
+	// with the expected error.
+	_, err = walletdb.OpenReadOnly(dbType)
+	if err != walletdb.ErrDbUnknownType {
+		t.Errorf("expected error not received - got: %v, want %v", err,
+			walletdb.ErrDbUnknownType)
+		return
+	}
+}
+
+// TestOpenReadOnlyUnsupported ensures that attempting to open a database
+// read-only with a driver that does not support it is handled properly.
+func TestOpenReadOnlyUnsupported(t *testing.T) {
+	dbType := "readonlyunsupported"
+	bogusOpenDB := func(args ...interface{}) (walletdb.DB, error) {
+		return nil, nil
+	}
+	driver := walletdb.Driver{
+		DbType: dbType,
+		Create: bogusOpenDB,
+		Open:   bogusOpenDB,
+	}
+	walletdb.RegisterDriver(driver)
+
+	_, err := walletdb.OpenReadOnly(dbType)
+	if err != walletdb.ErrReadOnlyUnsupported {
+		t.Errorf("expected error not received - got: %v, want %v", err,
+			walletdb.ErrReadOnlyUnsupported)
+		return
+	}
 }
@@ -53,7 +53,8 @@ The main entry point is the DB interface.  It exposes functionality for
 creating, retrieving, and removing namespaces.  It is obtained via the Create
 and Open functions which take a database type string that identifies the
 specific database driver (backend) to use as well as arguments specific to the
-specified driver.
+specified driver.  Drivers which support it may also open a database without
+write access with the OpenReadOnly function.
 
 Namespaces
 
@@ -74,6 +75,14 @@ took place while the transaction was active.  It also provides the root bucket
 under which all keys, values, and nested buckets are stored.  A transaction
 can either be read-only or read-write and managed or unmanaged.
 
+Transactions Across Namespaces
+
+Transactions obtained through a Namespace can only access that namespace.  When
+a change must be made to several namespaces atomically, the BeginTx, View, and
+Update functions of the DB interface provide a transaction (the DBTx interface)
+which can access the root bucket of every namespace.  The namespaces must
+already exist, since they are created with the Namespace function of the DB.
+
 Managed versus Unmanaged Transactions
 
 A managed transaction is one where the caller provides a function to execute
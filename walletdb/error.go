@@ -51,6 +51,19 @@ var (
 
 	// ErrInvalid is returned if the specified database is not valid.
 	ErrInvalid = errors.New("invalid database")
+
+	// ErrDbLocked is returned when a database can not be opened because
+	// it is locked by another process.
+	ErrDbLocked = errors.New("database is locked by another process")
+
+	// ErrDbReadOnly is returned when attempting to modify a database that
+	// was opened with OpenReadOnly.
+	ErrDbReadOnly = errors.New("database is read-only")
+
+	// ErrReadOnlyUnsupported is returned by OpenReadOnly when the driver
+	// for the database type does not support read-only access.
+	ErrReadOnlyUnsupported = errors.New("database type does not " +
+		"support read-only access")
 )
 
 // Errors that can occur when beginning or committing a transaction.
@@ -183,21 +183,87 @@ type Namespace interface {
 	Update(fn func(Tx) error) error
 }
 
+// DBTx represents a database transaction which spans every namespace of the
+// database.  It can either be read-only or read-write.  The transaction
+// provides the root bucket of each namespace, so changes to several namespaces
+// can be committed or rolled back together.
+//
+// As would be expected with a transaction, no changes will be saved to the
+// database until it has been committed.  The transaction will only provide a
+// view of the database at the time it was created.  Transactions should not be
+// long running operations.
+type DBTx interface {
+	// RootBucket returns the top-most bucket for the namespace with the
+	// passed key.  Returns nil if the namespace does not exist.
+	// Namespaces are not created by the transaction and must be created
+	// with the Namespace function of the DB before the transaction is
+	// started.
+	RootBucket(namespaceKey []byte) Bucket
+
+	// ForEachNamespace invokes the passed function with the key of every
+	// namespace in the database.  Any error returned from the function
+	// stops the iteration and is returned.
+	ForEachNamespace(fn func(namespaceKey []byte) error) error
+
+	// Commit commits all changes that have been made through the root
+	// buckets and all of their sub-buckets to persistent storage.
+	Commit() error
+
+	// Rollback undoes all changes that have been made to the root buckets
+	// and all of their sub-buckets.
+	Rollback() error
+}
+
 // DB represents a collection of namespaces which are persisted.  All database
 // access is performed through transactions which are obtained through the
-// specific Namespace.
+// specific Namespace, or through the DB when a transaction must access
+// several namespaces.
 type DB interface {
 	// Namespace returns a Namespace interface for the provided key.  See
 	// the Namespace interface documentation for more details.  Attempting
 	// to access a Namespace on a database that is not open yet or has been
 	// closed will result in ErrDbNotOpen.  Namespaces are created in the
-	// database on first access.
+	// database on first access, except when the database was opened
+	// read-only, in which case ErrBucketNotFound is returned for a
+	// namespace which does not exist.
 	Namespace(key []byte) (Namespace, error)
 
 	// DeleteNamespace deletes the namespace for the passed key.
 	// ErrBucketNotFound will be returned if the namespace does not exist.
 	DeleteNamespace(key []byte) error
 
+	// BeginTx starts a transaction with access to every namespace which
+	// is either read-only or read-write depending on the specified flag.
+	// Namespace and database transactions share the same locking, so
+	// only a single read-write transaction of either kind can be started
+	// at a time.  The call will block when starting a read-write
+	// transaction when one is already open.
+	//
+	// NOTE: The transaction must be closed by calling Rollback or Commit on
+	// it when it is no longer needed.  Failure to do so can result in
+	// unclaimed memory depending on the specific database implementation.
+	BeginTx(writable bool) (DBTx, error)
+
+	// View invokes the passed function in the context of a managed
+	// read-only transaction with access to every namespace.  Any errors
+	// returned from the user-supplied function are returned from this
+	// function.
+	//
+	// Calling Rollback on the transaction passed to the user-supplied
+	// function will result in a panic.
+	View(fn func(DBTx) error) error
+
+	// Update invokes the passed function in the context of a managed
+	// read-write transaction with access to every namespace.  Any errors
+	// returned from the user-supplied function will cause the transaction
+	// to be rolled back and are returned from this function.  Otherwise,
+	// the transaction is commited when the user-supplied function returns
+	// a nil error.
+	//
+	// Calling Rollback on the transaction passed to the user-supplied
+	// function will result in a panic.
+	Update(fn func(DBTx) error) error
+
 	// Copy writes a copy of the database to the provided writer.  This
 	// call will start a read-only transaction to perform all operations.
 	Copy(w io.Writer) error
@@ -222,6 +288,15 @@ type Driver struct {
 	// arguments to open the database.  This function must return
 	// ErrDbDoesNotExist if the database has not already been created.
 	Open func(args ...interface{}) (DB, error)
+
+	// OpenReadOnly is the function that will be invoked with all
+	// user-specified arguments to open the database without write access.
+	// It takes the same arguments as Open.  Every attempt to modify the
+	// opened database must fail with ErrDbReadOnly, except that accessing
+	// a namespace which does not exist must fail with ErrBucketNotFound
+	// rather than creating it.  Drivers which do not support read-only
+	// access leave this nil.
+	OpenReadOnly func(args ...interface{}) (DB, error)
 }
 
 // driverList holds all of the registered database backends.
@@ -276,3 +351,24 @@ func Open(dbType string, args ...interface{}) (DB, error) {
 
 	return drv.Open(args...)
 }
+
+// OpenReadOnly opens an existing database for the specified type without write
+// access.  Unlike Open, this does not prevent other processes from opening the
+// same database read-only, which allows tools to inspect a database while it
+// is in use elsewhere.  The arguments are the same as those taken by Open for
+// the database type.
+//
+// ErrDbUnknownType will be returned if the the database type is not registered
+// and ErrReadOnlyUnsupported will be returned if the database type driver does
+// not support read-only access.
+func OpenReadOnly(dbType string, args ...interface{}) (DB, error) {
+	drv, exists := drivers[dbType]
+	if !exists {
+		return nil, ErrDbUnknownType
+	}
+	if drv.OpenReadOnly == nil {
+		return nil, ErrReadOnlyUnsupported
+	}
+
+	return drv.OpenReadOnly(args...)
+}
@@ -0,0 +1,914 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mem
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/bolt"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// entry is a single key/value pair or nested bucket stored in a node.
+// Exactly one of value and bucket is non-nil.
+type entry struct {
+	key    []byte
+	value  []byte
+	bucket *node
+}
+
+// node holds the entries of a single bucket sorted by key.  Nodes which have
+// been committed are never modified.  Instead, a writable transaction copies
+// each node (and all of its parents) the first time it is modified, so open
+// read-only transactions continue to see the state they began with.
+type node struct {
+	entries []entry
+
+	// gen is the generation of the writable transaction which created
+	// this copy of the node.  Only that transaction may modify it.
+	gen uint64
+}
+
+// search returns the index of the entry with the passed key, or the index at
+// which it would be inserted, and whether the key was found.
+func (n *node) search(key []byte) (int, bool) {
+	i := sort.Search(len(n.entries), func(i int) bool {
+		return bytes.Compare(n.entries[i].key, key) >= 0
+	})
+	return i, i < len(n.entries) && bytes.Equal(n.entries[i].key, key)
+}
+
+// child returns the nested bucket node with the passed key, or nil if it does
+// not exist.
+func (n *node) child(key []byte) *node {
+	i, ok := n.search(key)
+	if !ok {
+		return nil
+	}
+	return n.entries[i].bucket
+}
+
+// insert adds the entry at index i, which must have been returned by a
+// search for the entry key which did not find an existing entry.
+func (n *node) insert(i int, e entry) {
+	n.entries = append(n.entries, entry{})
+	copy(n.entries[i+1:], n.entries[i:])
+	n.entries[i] = e
+}
+
+// remove removes the entry at index i.
+func (n *node) remove(i int) {
+	copy(n.entries[i:], n.entries[i+1:])
+	n.entries[len(n.entries)-1] = entry{}
+	n.entries = n.entries[:len(n.entries)-1]
+}
+
+// clone returns a shallow copy of the node owned by generation gen.  Nested
+// buckets are shared with the original until they are modified.
+func (n *node) clone(gen uint64) *node {
+	entries := make([]entry, len(n.entries))
+	copy(entries, n.entries)
+	return &node{entries: entries, gen: gen}
+}
+
+// copyBytes returns a copy of b which is never nil.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
+
+// bucket is an internal type used to represent a collection of key/value pairs
+// and implements the walletdb.Bucket interface.  Rather than referencing a
+// node directly, a bucket records its path from the namespace so it always
+// refers to the transaction's current copy of the node.
+type bucket struct {
+	tx     *transaction
+	parent *bucket // nil for the namespace root bucket
+	key    []byte
+}
+
+// Enforce bucket implements the walletdb.Bucket interface.
+var _ walletdb.Bucket = (*bucket)(nil)
+
+// node returns the transaction's current node for the bucket, or nil if the
+// bucket has been deleted.
+func (b *bucket) node() *node {
+	parent := b.tx.root
+	if b.parent != nil {
+		parent = b.parent.node()
+	}
+	if parent == nil {
+		return nil
+	}
+	return parent.child(b.key)
+}
+
+// writableNode returns the node for the bucket after copying it, and each of
+// its parents, so it is owned by the transaction.  Returns nil if the bucket
+// has been deleted.
+func (b *bucket) writableNode() *node {
+	var parent *node
+	if b.parent == nil {
+		parent = b.tx.writableRoot()
+	} else {
+		parent = b.parent.writableNode()
+	}
+	if parent == nil {
+		return nil
+	}
+	i, ok := parent.search(b.key)
+	if !ok || parent.entries[i].bucket == nil {
+		return nil
+	}
+	n := parent.entries[i].bucket
+	if n.gen != b.tx.gen {
+		n = n.clone(b.tx.gen)
+		parent.entries[i].bucket = n
+	}
+	return n
+}
+
+// checkWritable returns the error for a modification of the bucket, if any,
+// and otherwise the node to modify.
+func (b *bucket) checkWritable() (*node, error) {
+	if b.tx.closed {
+		return nil, walletdb.ErrTxClosed
+	}
+	if !b.tx.writable {
+		return nil, walletdb.ErrTxNotWritable
+	}
+	n := b.writableNode()
+	if n == nil {
+		return nil, walletdb.ErrBucketNotFound
+	}
+	return n, nil
+}
+
+// Bucket retrieves a nested bucket with the given key.  Returns nil if
+// the bucket does not exist.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Bucket(key []byte) walletdb.Bucket {
+	// This nil check is intentional so the return value can be checked
+	// against nil directly.
+	n := b.node()
+	if n == nil || n.child(key) == nil {
+		return nil
+	}
+	return &bucket{tx: b.tx, parent: b, key: copyBytes(key)}
+}
+
+// CreateBucket creates and returns a new nested bucket with the given key.
+// Returns ErrBucketExists if the bucket already exists, ErrBucketNameRequired
+// if the key is empty, or ErrIncompatibleValue if the key value is otherwise
+// invalid.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) CreateBucket(key []byte) (walletdb.Bucket, error) {
+	n, err := b.checkWritable()
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, walletdb.ErrBucketNameRequired
+	}
+	i, ok := n.search(key)
+	if ok {
+		if n.entries[i].bucket != nil {
+			return nil, walletdb.ErrBucketExists
+		}
+		return nil, walletdb.ErrIncompatibleValue
+	}
+	key = copyBytes(key)
+	n.insert(i, entry{key: key, bucket: &node{gen: b.tx.gen}})
+	return &bucket{tx: b.tx, parent: b, key: key}, nil
+}
+
+// CreateBucketIfNotExists creates and returns a new nested bucket with the
+// given key if it does not already exist.  Returns ErrBucketNameRequired if the
+// key is empty or ErrIncompatibleValue if the key value is otherwise invalid.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) CreateBucketIfNotExists(key []byte) (walletdb.Bucket, error) {
+	bkt, err := b.CreateBucket(key)
+	if err == walletdb.ErrBucketExists {
+		return b.Bucket(key), nil
+	}
+	return bkt, err
+}
+
+// DeleteBucket removes a nested bucket with the given key.  Returns
+// ErrTxNotWritable if attempted against a read-only transaction and
+// ErrBucketNotFound if the specified bucket does not exist.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) DeleteBucket(key []byte) error {
+	n, err := b.checkWritable()
+	if err != nil {
+		return err
+	}
+	// Like bolt, an empty key refers to no valid bucket and is reported
+	// as an incompatible value rather than a missing bucket.
+	if len(key) == 0 {
+		return walletdb.ErrIncompatibleValue
+	}
+	i, ok := n.search(key)
+	if !ok {
+		return walletdb.ErrBucketNotFound
+	}
+	if n.entries[i].bucket == nil {
+		return walletdb.ErrIncompatibleValue
+	}
+	n.remove(i)
+	return nil
+}
+
+// ForEach invokes the passed function with every key/value pair in the bucket.
+// This includes nested buckets, in which case the value is nil, but it does not
+// include the key/value pairs within those nested buckets.
+//
+// NOTE: The values returned by this function are only valid during a
+// transaction.  Modifying them will result in undefined behavior.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) ForEach(fn func(k, v []byte) error) error {
+	c := b.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writable returns whether or not the bucket is writable.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Writable() bool {
+	return b.tx.writable
+}
+
+// Put saves the specified key/value pair to the bucket.  Keys that do not
+// already exist are added and keys that already exist are overwritten.  Returns
+// ErrTxNotWritable if attempted against a read-only transaction.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Put(key, value []byte) error {
+	n, err := b.checkWritable()
+	if err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return walletdb.ErrKeyRequired
+	}
+	i, ok := n.search(key)
+	if ok {
+		if n.entries[i].bucket != nil {
+			return walletdb.ErrIncompatibleValue
+		}
+		n.entries[i].value = copyBytes(value)
+		return nil
+	}
+	n.insert(i, entry{key: copyBytes(key), value: copyBytes(value)})
+	return nil
+}
+
+// Get returns the value for the given key.  Returns nil if the key does
+// not exist in this bucket (or nested buckets).
+//
+// NOTE: The value returned by this function is only valid during a
+// transaction.  Modifying it will result in undefined behavior.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Get(key []byte) []byte {
+	n := b.node()
+	if n == nil {
+		return nil
+	}
+	i, ok := n.search(key)
+	if !ok {
+		return nil
+	}
+	return n.entries[i].value
+}
+
+// Delete removes the specified key from the bucket.  Deleting a key that does
+// not exist does not return an error.  Returns ErrTxNotWritable if attempted
+// against a read-only transaction.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Delete(key []byte) error {
+	n, err := b.checkWritable()
+	if err != nil {
+		return err
+	}
+	i, ok := n.search(key)
+	if !ok {
+		return nil
+	}
+	if n.entries[i].bucket != nil {
+		return walletdb.ErrIncompatibleValue
+	}
+	n.remove(i)
+	return nil
+}
+
+// Cursor returns a new cursor, allowing for iteration over the bucket's
+// key/value pairs and nested buckets in forward or backward order.
+//
+// This function is part of the walletdb.Bucket interface implementation.
+func (b *bucket) Cursor() walletdb.Cursor {
+	return &cursor{bucket: b}
+}
+
+// cursor represents a cursor over key/value pairs and nested buckets of a
+// bucket.  The cursor only remembers the key it is positioned at, so it
+// remains valid while the bucket is modified during iteration.
+type cursor struct {
+	bucket *bucket
+	key    []byte
+
+	// end records that the cursor was sought past the last key.  Like
+	// bolt, moving backward from there positions the cursor at the last
+	// key.
+	end bool
+}
+
+// Enforce cursor implements the walletdb.Cursor interface.
+var _ walletdb.Cursor = (*cursor)(nil)
+
+// at positions the cursor at index i of n and returns the key/value pair
+// there, or nil if the index is out of range.
+func (c *cursor) at(n *node, i int) (key, value []byte) {
+	if n == nil || i < 0 || i >= len(n.entries) {
+		c.key = nil
+		c.end = false
+		return nil, nil
+	}
+	e := &n.entries[i]
+	c.key = e.key
+	c.end = false
+	return e.key, e.value
+}
+
+// Bucket returns the bucket the cursor was created for.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Bucket() walletdb.Bucket {
+	return c.bucket
+}
+
+// Delete removes the current key/value pair the cursor is at without
+// invalidating the cursor.  Returns ErrTxNotWritable if attempted on a read-only
+// transaction, or ErrIncompatibleValue if attempted when the cursor points to a
+// nested bucket.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Delete() error {
+	n, err := c.bucket.checkWritable()
+	if err != nil {
+		return err
+	}
+	if c.key == nil {
+		return nil
+	}
+	i, ok := n.search(c.key)
+	if !ok {
+		return nil
+	}
+	if n.entries[i].bucket != nil {
+		return walletdb.ErrIncompatibleValue
+	}
+	n.remove(i)
+	return nil
+}
+
+// First positions the cursor at the first key/value pair and returns the pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) First() (key, value []byte) {
+	return c.at(c.bucket.node(), 0)
+}
+
+// Last positions the cursor at the last key/value pair and returns the pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Last() (key, value []byte) {
+	n := c.bucket.node()
+	if n == nil {
+		return c.at(nil, 0)
+	}
+	return c.at(n, len(n.entries)-1)
+}
+
+// Next moves the cursor one key/value pair forward and returns the new pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Next() (key, value []byte) {
+	n := c.bucket.node()
+	if n == nil || c.key == nil {
+		return c.at(nil, 0)
+	}
+	i, ok := n.search(c.key)
+	if ok {
+		i++
+	}
+	return c.at(n, i)
+}
+
+// Prev moves the cursor one key/value pair backward and returns the new pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Prev() (key, value []byte) {
+	n := c.bucket.node()
+	if n != nil && c.key == nil && c.end {
+		return c.at(n, len(n.entries)-1)
+	}
+	if n == nil || c.key == nil {
+		return c.at(nil, 0)
+	}
+	i, _ := n.search(c.key)
+	return c.at(n, i-1)
+}
+
+// Seek positions the cursor at the passed seek key.  If the key does not exist,
+// the cursor is moved to the next key after seek.  Returns the new pair.
+//
+// This function is part of the walletdb.Cursor interface implementation.
+func (c *cursor) Seek(seek []byte) (key, value []byte) {
+	n := c.bucket.node()
+	if n == nil {
+		return c.at(nil, 0)
+	}
+	i, _ := n.search(seek)
+	k, v := c.at(n, i)
+	c.end = k == nil
+	return k, v
+}
+
+// transaction represents a database transaction.  It can either be read-only
+// or read-write and implements the walletdb.Tx interface.  The transaction
+// provides a root bucket against which all read and writes occur.
+type transaction struct {
+	db       *db
+	nsKey    []byte
+	root     *node
+	gen      uint64
+	writable bool
+	closed   bool
+}
+
+// Enforce transaction implements the walletdb.Tx interface.
+var _ walletdb.Tx = (*transaction)(nil)
+
+// writableRoot returns the transaction's root node after copying it so it is
+// owned by the transaction.
+func (tx *transaction) writableRoot() *node {
+	if tx.root.gen != tx.gen {
+		tx.root = tx.root.clone(tx.gen)
+	}
+	return tx.root
+}
+
+// RootBucket returns the top-most bucket for the namespace the transaction was
+// created from.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx *transaction) RootBucket() walletdb.Bucket {
+	return &bucket{tx: tx, key: tx.nsKey}
+}
+
+// Commit commits all changes that have been made through the root bucket and
+// all of its sub-buckets to the database.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx *transaction) Commit() error {
+	if tx.closed {
+		return walletdb.ErrTxClosed
+	}
+	if !tx.writable {
+		return walletdb.ErrTxNotWritable
+	}
+	tx.closed = true
+
+	tx.db.mtx.Lock()
+	tx.db.root = tx.root
+	tx.db.mtx.Unlock()
+	tx.db.writeMtx.Unlock()
+	return nil
+}
+
+// Rollback undoes all changes that have been made to the root bucket and all of
+// its sub-buckets.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx *transaction) Rollback() error {
+	if tx.closed {
+		return walletdb.ErrTxClosed
+	}
+	tx.closed = true
+	if tx.writable {
+		tx.db.writeMtx.Unlock()
+	}
+	return nil
+}
+
+// dbTransaction represents a database transaction with access to every
+// namespace.  It can either be read-only or read-write and implements the
+// walletdb.DBTx interface.
+type dbTransaction transaction
+
+// Enforce dbTransaction implements the walletdb.DBTx interface.
+var _ walletdb.DBTx = (*dbTransaction)(nil)
+
+// RootBucket returns the top-most bucket for the namespace with the passed
+// key.  Returns nil if the namespace does not exist.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) RootBucket(namespaceKey []byte) walletdb.Bucket {
+	// This nil check is intentional so the return value can be checked
+	// against nil directly.
+	if tx.root.child(namespaceKey) == nil {
+		return nil
+	}
+	return &bucket{tx: (*transaction)(tx), key: copyBytes(namespaceKey)}
+}
+
+// ForEachNamespace invokes the passed function with the key of every namespace
+// in the database.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) ForEachNamespace(fn func(namespaceKey []byte) error) error {
+	if tx.closed {
+		return walletdb.ErrTxClosed
+	}
+	entries := tx.root.entries
+	for i := range entries {
+		if err := fn(copyBytes(entries[i].key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commit commits all changes that have been made through the root buckets and
+// all of their sub-buckets to the database.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) Commit() error {
+	return (*transaction)(tx).Commit()
+}
+
+// Rollback undoes all changes that have been made to the root buckets and all
+// of their sub-buckets.
+//
+// This function is part of the walletdb.DBTx interface implementation.
+func (tx *dbTransaction) Rollback() error {
+	return (*transaction)(tx).Rollback()
+}
+
+// namespace represents a database namespace that is intended to support the
+// concept of a single entity that controls the opening, creating, and closing
+// of a database while providing other entities their own namespace to work in.
+// It implements the walletdb.Namespace interface.
+type namespace struct {
+	db  *db
+	key []byte
+}
+
+// Enforce namespace implements the walletdb.Namespace interface.
+var _ walletdb.Namespace = (*namespace)(nil)
+
+// Begin starts a transaction which is either read-only or read-write depending
+// on the specified flag.  Multiple read-only transactions can be started
+// simultaneously while only a single read-write transaction can be started at a
+// time.  The call will block when starting a read-write transaction when one is
+// already open.
+//
+// NOTE: The transaction must be closed by calling Rollback or Commit on it when
+// it is no longer needed.  Failure to do so will result in unclaimed memory.
+//
+// This function is part of the walletdb.Namespace interface implementation.
+func (ns *namespace) Begin(writable bool) (walletdb.Tx, error) {
+	tx, err := ns.db.begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	if tx.root.child(ns.key) == nil {
+		tx.Rollback()
+		return nil, walletdb.ErrBucketNotFound
+	}
+	tx.nsKey = ns.key
+	return tx, nil
+}
+
+// View invokes the passed function in the context of a managed read-only
+// transaction.  Any errors returned from the user-supplied function are
+// returned from this function.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Namespace interface implementation.
+func (ns *namespace) View(fn func(walletdb.Tx) error) error {
+	tx, err := ns.Begin(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+// Update invokes the passed function in the context of a managed read-write
+// transaction.  Any errors returned from the user-supplied function will cause
+// the transaction to be rolled back and are returned from this function.
+// Otherwise, the transaction is commited when the user-supplied function
+// returns a nil error.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Namespace interface implementation.
+func (ns *namespace) Update(fn func(walletdb.Tx) error) error {
+	tx, err := ns.Begin(true)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// db represents a collection of namespaces which are held entirely in memory.
+// It implements the walletdb.Db interface.
+type db struct {
+	// writeMtx is held for the lifetime of the single writable
+	// transaction.
+	writeMtx sync.Mutex
+
+	// mtx protects the fields below.  The committed root node is never
+	// modified, so read-only transactions only need the lock to read the
+	// root pointer when they begin.
+	mtx    sync.RWMutex
+	root   *node
+	gen    uint64
+	closed bool
+}
+
+// Enforce db implements the walletdb.Db interface.
+var _ walletdb.DB = (*db)(nil)
+
+// begin starts a transaction which is either read-only or read-write
+// depending on the specified flag.  The call will block when starting a
+// read-write transaction when one is already open.
+func (db *db) begin(writable bool) (*transaction, error) {
+	if writable {
+		db.writeMtx.Lock()
+	}
+
+	db.mtx.RLock()
+	root, closed := db.root, db.closed
+	db.mtx.RUnlock()
+
+	if closed {
+		if writable {
+			db.writeMtx.Unlock()
+		}
+		return nil, walletdb.ErrDbNotOpen
+	}
+
+	tx := &transaction{
+		db:       db,
+		root:     root,
+		writable: writable,
+	}
+	if writable {
+		// Only a single writable transaction may be open at a time, so
+		// the generation does not need any further synchronization.
+		db.gen++
+		tx.gen = db.gen
+	}
+	return tx, nil
+}
+
+// update runs fn with the writable root node of a new generation and commits
+// the result.  It is used by the database-wide operations which are not
+// performed through a namespace.
+func (db *db) update(fn func(root *node) error) error {
+	db.writeMtx.Lock()
+	defer db.writeMtx.Unlock()
+
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+	if db.closed {
+		return walletdb.ErrDbNotOpen
+	}
+	db.gen++
+	root := db.root.clone(db.gen)
+	if err := fn(root); err != nil {
+		return err
+	}
+	db.root = root
+	return nil
+}
+
+// Namespace returns a Namespace interface for the provided key.  See the
+// Namespace interface documentation for more details.  Attempting to access a
+// Namespace on a database that is not open yet or has been closed will result
+// in ErrDbNotOpen.  Namespaces are created in the database on first access.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Namespace(key []byte) (walletdb.Namespace, error) {
+	if len(key) == 0 {
+		return nil, walletdb.ErrBucketNameRequired
+	}
+	key = copyBytes(key)
+	err := db.update(func(root *node) error {
+		i, ok := root.search(key)
+		if !ok {
+			root.insert(i, entry{key: key, bucket: &node{gen: db.gen}})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &namespace{db: db, key: key}, nil
+}
+
+// DeleteNamespace deletes the namespace for the passed key.  ErrBucketNotFound
+// will be returned if the namespace does not exist.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) DeleteNamespace(key []byte) error {
+	return db.update(func(root *node) error {
+		i, ok := root.search(key)
+		if !ok {
+			return walletdb.ErrBucketNotFound
+		}
+		root.remove(i)
+		return nil
+	})
+}
+
+// BeginTx starts a transaction with access to every namespace which is either
+// read-only or read-write depending on the specified flag.  Multiple read-only
+// transactions can be started simultaneously while only a single read-write
+// transaction can be started at a time.  The call will block when starting a
+// read-write transaction when one is already open.
+//
+// NOTE: The transaction must be closed by calling Rollback or Commit on it when
+// it is no longer needed.  Failure to do so will result in unclaimed memory.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) BeginTx(writable bool) (walletdb.DBTx, error) {
+	tx, err := db.begin(writable)
+	if err != nil {
+		return nil, err
+	}
+	return (*dbTransaction)(tx), nil
+}
+
+// View invokes the passed function in the context of a managed read-only
+// transaction with access to every namespace.  Any errors returned from the
+// user-supplied function are returned from this function.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) View(fn func(walletdb.DBTx) error) error {
+	tx, err := db.BeginTx(false)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
+
+// Update invokes the passed function in the context of a managed read-write
+// transaction with access to every namespace.  Any errors returned from the
+// user-supplied function will cause the transaction to be rolled back and are
+// returned from this function.  Otherwise, the transaction is commited when
+// the user-supplied function returns a nil error.
+//
+// Calling Rollback on the transaction passed to the user-supplied function will
+// result in a panic.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Update(fn func(walletdb.DBTx) error) error {
+	tx, err := db.BeginTx(true)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// copyNode writes every entry of n into the bolt bucket b, recursing into
+// nested buckets.
+func copyNode(b *bolt.Bucket, n *node) error {
+	for i := range n.entries {
+		e := &n.entries[i]
+		if e.bucket == nil {
+			if err := b.Put(e.key, e.value); err != nil {
+				return err
+			}
+			continue
+		}
+		nested, err := b.CreateBucket(e.key)
+		if err != nil {
+			return err
+		}
+		if err := copyNode(nested, e.bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Copy writes a copy of the database to the provided writer.  The copy is
+// written in the same format as the bdb driver, so it may be opened with the
+// "bdb" database type.  A temporary file is used to create the copy.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Copy(w io.Writer) error {
+	db.mtx.RLock()
+	root, closed := db.root, db.closed
+	db.mtx.RUnlock()
+	if closed {
+		return walletdb.ErrDbNotOpen
+	}
+
+	tmpDir, err := ioutil.TempDir("", "walletdb")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	boltDB, err := bolt.Open(tmpDir+string(os.PathSeparator)+"copy.db",
+		0600, nil)
+	if err != nil {
+		return err
+	}
+	defer boltDB.Close()
+
+	err = boltDB.Update(func(tx *bolt.Tx) error {
+		for i := range root.entries {
+			e := &root.entries[i]
+			b, err := tx.CreateBucket(e.key)
+			if err != nil {
+				return err
+			}
+			if err := copyNode(b, e.bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return boltDB.View(func(tx *bolt.Tx) error {
+		return tx.Copy(w)
+	})
+}
+
+// Close shuts down the database.  All data held by the database is discarded
+// once the database and any open transactions are no longer referenced.
+//
+// This function is part of the walletdb.Db interface implementation.
+func (db *db) Close() error {
+	db.mtx.Lock()
+	db.closed = true
+	db.mtx.Unlock()
+	return nil
+}
+
+// newDB returns a new, empty in-memory database.
+func newDB() *db {
+	return &db{root: &node{}}
+}
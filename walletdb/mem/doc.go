@@ -0,0 +1,40 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+/*
+Package mem implements an instance of walletdb that is held entirely in
+memory.
+
+The database is intended for tests and ephemeral wallets, such as simulation
+wallets, which do not need to outlive the process.  It provides the same
+transaction semantics as the bdb driver: any number of read-only transactions
+may be open while at most one read-write transaction is open, read-only
+transactions see the database as it was when they began, and changes made by a
+read-write transaction are discarded if it is rolled back.
+
+Usage
+
+This package is only a driver to the walletdb package and provides the database
+type of "mem".  The Create function takes no parameters:
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		// Handle error
+	}
+
+Since the database is not persisted, calling Open always returns
+walletdb.ErrDbDoesNotExist.  A copy of the database written with the Copy method
+uses the same format as the bdb driver and may be opened with it.
+*/
+package mem
@@ -0,0 +1,260 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package mem_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
+)
+
+// dbType is the database type name for this driver.
+const dbType = "mem"
+
+// TestCreateOpenFail ensures that errors related to creating and opening a
+// database are handled properly.
+func TestCreateOpenFail(t *testing.T) {
+	// Ensure that attempting to open a database returns the expected error
+	// since in-memory databases can never be reopened.
+	wantErr := walletdb.ErrDbDoesNotExist
+	if _, err := walletdb.Open(dbType); err != wantErr {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure that attempting to open or create a database with any
+	// parameters returns the expected error.
+	wantErr = fmt.Errorf("invalid arguments to %s.Open -- expected "+
+		"no arguments", dbType)
+	if _, err := walletdb.Open(dbType, "path"); err.Error() != wantErr.Error() {
+		t.Errorf("Open: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+	wantErr = fmt.Errorf("invalid arguments to %s.Create -- expected "+
+		"no arguments", dbType)
+	if _, err := walletdb.Create(dbType, "path"); err.Error() != wantErr.Error() {
+		t.Errorf("Create: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+
+	// Ensure operations against a closed database return the expected
+	// error.
+	db, err := walletdb.Create(dbType)
+	if err != nil {
+		t.Errorf("Create: unexpected error: %v", err)
+		return
+	}
+	ns, err := db.Namespace([]byte("ns1"))
+	if err != nil {
+		t.Errorf("Namespace: unexpected error: %v", err)
+		return
+	}
+	db.Close()
+
+	wantErr = walletdb.ErrDbNotOpen
+	if _, err := db.Namespace([]byte("ns1")); err != wantErr {
+		t.Errorf("Namespace: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+	if _, err := ns.Begin(false); err != wantErr {
+		t.Errorf("Begin: did not receive expected error - got %v, "+
+			"want %v", err, wantErr)
+		return
+	}
+}
+
+// TestSnapshotIsolation ensures that read-only transactions do not observe
+// changes made by a read-write transaction, whether it is still open or has
+// committed since the read-only transaction began.
+func TestSnapshotIsolation(t *testing.T) {
+	db, err := walletdb.Create(dbType)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+
+	ns, err := db.Namespace([]byte("ns1"))
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	key, oldValue, newValue := []byte("key"), []byte("old"), []byte("new")
+	err = ns.Update(func(tx walletdb.Tx) error {
+		b, err := tx.RootBucket().CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		return b.Put(key, oldValue)
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	readTx, err := ns.Begin(false)
+	if err != nil {
+		t.Fatalf("Begin: unexpected error: %v", err)
+	}
+	defer readTx.Rollback()
+	readBucket := readTx.RootBucket().Bucket([]byte("nested"))
+
+	writeTx, err := ns.Begin(true)
+	if err != nil {
+		t.Fatalf("Begin: unexpected error: %v", err)
+	}
+	writeBucket := writeTx.RootBucket().Bucket([]byte("nested"))
+	if err := writeBucket.Put(key, newValue); err != nil {
+		t.Fatalf("Put: unexpected error: %v", err)
+	}
+	if got := readBucket.Get(key); !bytes.Equal(got, oldValue) {
+		t.Errorf("Get: uncommitted value is visible - got %s, want %s",
+			got, oldValue)
+	}
+	if err := writeTx.Commit(); err != nil {
+		t.Fatalf("Commit: unexpected error: %v", err)
+	}
+	if got := readBucket.Get(key); !bytes.Equal(got, oldValue) {
+		t.Errorf("Get: value committed after the transaction began is "+
+			"visible - got %s, want %s", got, oldValue)
+	}
+
+	err = ns.View(func(tx walletdb.Tx) error {
+		b := tx.RootBucket().Bucket([]byte("nested"))
+		if got := b.Get(key); !bytes.Equal(got, newValue) {
+			return fmt.Errorf("Get: committed value is not visible - "+
+				"got %s, want %s", got, newValue)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestCursorPrevAfterEnd ensures that, like bolt, moving a cursor backward
+// after seeking past the last key positions it at the last key.
+func TestCursorPrevAfterEnd(t *testing.T) {
+	db, err := walletdb.Create(dbType)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+
+	ns, err := db.Namespace([]byte("ns1"))
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns.Update(func(tx walletdb.Tx) error {
+		root := tx.RootBucket()
+		for _, k := range []string{"a", "b"} {
+			if err := root.Put([]byte(k), []byte(k)); err != nil {
+				return err
+			}
+		}
+
+		c := root.Cursor()
+		if k, _ := c.Seek([]byte("c")); k != nil {
+			return fmt.Errorf("Seek: got key %s past the last key", k)
+		}
+		if k, _ := c.Prev(); !bytes.Equal(k, []byte("b")) {
+			return fmt.Errorf("Prev after Seek: got key %s, want b", k)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestCopy ensures a copy of the database may be opened with the bdb driver.
+func TestCopy(t *testing.T) {
+	db, err := walletdb.Create(dbType)
+	if err != nil {
+		t.Fatalf("Failed to create test database (%s) %v", dbType, err)
+	}
+	defer db.Close()
+
+	ns, err := db.Namespace([]byte("ns1"))
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns.Update(func(tx walletdb.Tx) error {
+		b, err := tx.RootBucket().CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte("key"), []byte("value"))
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+
+	dbPath := "copytest.db"
+	fi, err := os.Create(dbPath)
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer os.Remove(dbPath)
+	err = db.Copy(fi)
+	fi.Close()
+	if err != nil {
+		t.Fatalf("Copy: unexpected error: %v", err)
+	}
+
+	copyDB, err := walletdb.Open("bdb", dbPath)
+	if err != nil {
+		t.Fatalf("Open: unexpected error: %v", err)
+	}
+	defer copyDB.Close()
+	ns, err = copyDB.Namespace([]byte("ns1"))
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns.View(func(tx walletdb.Tx) error {
+		b := tx.RootBucket().Bucket([]byte("nested"))
+		if b == nil {
+			return fmt.Errorf("Bucket: nested bucket was not copied")
+		}
+		if got := b.Get([]byte("key")); !bytes.Equal(got, []byte("value")) {
+			return fmt.Errorf("Get: unexpected value - got %s, "+
+				"want %s", got, "value")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// TestInterface performs all interfaces tests for this database driver.
+func TestInterface(t *testing.T) {
+	// Create a new database to run tests against.
+	db, err := walletdb.Create(dbType)
+	if err != nil {
+		t.Errorf("Failed to create test database (%s) %v", dbType, err)
+		return
+	}
+	defer db.Close()
+
+	// Run all of the interface tests against the database.
+	testInterface(t, db)
+}
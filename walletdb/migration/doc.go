@@ -0,0 +1,41 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+/*
+Package migration provides versioning and upgrades for the data that packages
+store in walletdb namespaces.
+
+Each package storing data in a namespace describes it with a Schema: how the
+version of the data is recorded, the latest version the package writes, and an
+ordered list of named migrations, each upgrading the data by one version.
+Migrations are run in order from the recorded version until the latest version
+is reached, and the new version is recorded after every migration by the
+framework, so migrations only need to change the data itself.
+
+Upgrading
+
+Upgrade runs the pending migrations of any number of schemas, spanning
+multiple namespaces, inside a single database transaction.  Either every
+namespace is upgraded to its latest version or, if any migration fails, the
+database is left unchanged.  Before running any migrations, a backup of the
+entire database may be written with the Copy function of the database.  A dry
+run runs all migrations and then rolls back the transaction, which checks that
+the upgrade succeeds without modifying the database.
+
+Packages which are opened with a single namespace rather than a database use
+UpgradeNamespace to upgrade their data when opened.  Since this does nothing
+once the data is at the latest version, applications can run Upgrade with a
+backup before opening the packages.
+*/
+package migration
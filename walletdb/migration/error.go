@@ -0,0 +1,85 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package migration
+
+import "fmt"
+
+// ErrorCode identifies a category of error.
+type ErrorCode uint8
+
+// These constants are used to identify a specific Error.
+const (
+	// ErrDatabase indicates an error with the underlying database.  When
+	// this error code is set, the Err field of the Error will be set to
+	// the underlying error returned from the database.
+	ErrDatabase ErrorCode = iota
+
+	// ErrUnknownVersion describes an error where the version recorded in
+	// a namespace is newer than the latest version of its schema.  This
+	// likely indicates an outdated binary.
+	ErrUnknownVersion
+
+	// ErrMissingMigration describes an error where a namespace is older
+	// than the latest version of its schema, but the schema has no
+	// migration to the next version.  This indicates a programming error
+	// in the package defining the schema.
+	ErrMissingMigration
+
+	// ErrBackup describes an error where the backup of the database could
+	// not be written before running migrations.  No migrations are run
+	// when this error is returned.
+	ErrBackup
+
+	// ErrManagedTx describes an error where a migration attempted to
+	// commit or roll back the transaction it was passed.  Transactions
+	// are committed or rolled back after all migrations have run.
+	ErrManagedTx
+)
+
+var errStrs = [...]string{
+	ErrDatabase:         "ErrDatabase",
+	ErrUnknownVersion:   "ErrUnknownVersion",
+	ErrMissingMigration: "ErrMissingMigration",
+	ErrBackup:           "ErrBackup",
+	ErrManagedTx:        "ErrManagedTx",
+}
+
+// String returns the ErrorCode as a human-readable name.
+func (e ErrorCode) String() string {
+	if e < ErrorCode(len(errStrs)) {
+		return errStrs[e]
+	}
+	return fmt.Sprintf("ErrorCode(%d)", e)
+}
+
+// Error provides a single type for errors that are detected by the migration
+// framework itself.  Errors returned by a migration are returned unchanged.
+type Error struct {
+	Code ErrorCode // Describes the kind of error
+	Desc string    // Human readable description of the issue
+	Err  error     // Underlying error, optional
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e Error) Error() string {
+	if e.Err != nil {
+		return e.Desc + ": " + e.Err.Error()
+	}
+	return e.Desc
+}
+
+func migrationError(c ErrorCode, desc string, err error) Error {
+	return Error{Code: c, Desc: desc, Err: err}
+}
@@ -0,0 +1,328 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package migration
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// Migration describes a single change to the data stored in a namespace,
+// upgrading it from the previous version to Version.
+type Migration struct {
+	// Version is the version of the namespace after the migration has
+	// been applied.  The migration is run when the recorded version is
+	// exactly one less.
+	Version uint32
+
+	// Name is a short human-readable description of the change.
+	Name string
+
+	// Migrate performs the migration using the passed transaction, which
+	// is scoped to the namespace of the schema.  Migrate must not record
+	// the new version, which is done by the framework afterwards, and must
+	// not commit or roll back the transaction.
+	Migrate func(tx walletdb.Tx) error
+}
+
+// Schema describes the versioned data stored in a single namespace and the
+// migrations which upgrade it to the latest version.
+type Schema struct {
+	// Name is the name of the package defining the schema, used in
+	// errors and to report applied migrations.
+	Name string
+
+	// Namespace is the key of the namespace holding the data.
+	Namespace []byte
+
+	// LatestVersion is the version written by the package when creating
+	// new data in the namespace.
+	LatestVersion uint32
+
+	// Version returns the version recorded in the namespace.  A version of
+	// zero indicates no data has been created in the namespace yet, and no
+	// migrations are run.
+	Version func(tx walletdb.Tx) (uint32, error)
+
+	// SetVersion records a new version in the namespace.
+	SetVersion func(tx walletdb.Tx, version uint32) error
+
+	// Migrations are the migrations that may be run to upgrade older data.
+	Migrations []Migration
+}
+
+// migration returns the migration of the schema which upgrades to version.
+func (s *Schema) migration(version uint32) (*Migration, bool) {
+	for i := range s.Migrations {
+		if s.Migrations[i].Version == version {
+			return &s.Migrations[i], true
+		}
+	}
+	return nil, false
+}
+
+// pending returns the migrations which must be run, in order, to upgrade the
+// namespace accessed by tx to the latest version of the schema.
+func (s *Schema) pending(tx walletdb.Tx) ([]*Migration, error) {
+	if tx.RootBucket() == nil {
+		return nil, nil
+	}
+	version, err := s.Version(tx)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 {
+		return nil, nil
+	}
+	if version > s.LatestVersion {
+		str := fmt.Sprintf("%s: recorded version %d is newer than latest "+
+			"understood version %d", s.Name, version, s.LatestVersion)
+		return nil, migrationError(ErrUnknownVersion, str, nil)
+	}
+
+	var migrations []*Migration
+	for ; version < s.LatestVersion; version++ {
+		m, ok := s.migration(version + 1)
+		if !ok {
+			str := fmt.Sprintf("%s: the latest version is %d, but no "+
+				"migration upgrades version %d", s.Name,
+				s.LatestVersion, version)
+			return nil, migrationError(ErrMissingMigration, str, nil)
+		}
+		migrations = append(migrations, m)
+	}
+	return migrations, nil
+}
+
+// Applied describes a migration which was applied to a namespace.
+type Applied struct {
+	Schema  string // Name of the schema
+	Version uint32 // Version of the namespace after the migration
+	Name    string // Name of the migration
+}
+
+// String returns a human-readable description of the applied migration.
+func (a Applied) String() string {
+	return fmt.Sprintf("%s version %d: %s", a.Schema, a.Version, a.Name)
+}
+
+// apply runs all pending migrations of the schema using tx, recording the
+// version after each migration.
+func (s *Schema) apply(tx walletdb.Tx) ([]Applied, error) {
+	migrations, err := s.pending(tx)
+	if err != nil {
+		return nil, err
+	}
+	applied := make([]Applied, 0, len(migrations))
+	for _, m := range migrations {
+		if err := m.Migrate(tx); err != nil {
+			return nil, err
+		}
+		if err := s.SetVersion(tx, m.Version); err != nil {
+			return nil, err
+		}
+		applied = append(applied, Applied{s.Name, m.Version, m.Name})
+	}
+	return applied, nil
+}
+
+// managedTx is the walletdb.Tx passed to schemas and migrations.  It provides
+// the root bucket of a single namespace and prevents migrations from ending
+// the transaction, which is committed or rolled back by the framework.
+type managedTx struct {
+	root walletdb.Bucket
+}
+
+// Enforce managedTx implements the walletdb.Tx interface.
+var _ walletdb.Tx = managedTx{}
+
+// RootBucket returns the root bucket of the namespace.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx managedTx) RootBucket() walletdb.Bucket {
+	return tx.root
+}
+
+// Commit always returns an error since migrations may not commit the
+// transaction.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx managedTx) Commit() error {
+	const str = "migrations may not commit the transaction"
+	return migrationError(ErrManagedTx, str, nil)
+}
+
+// Rollback always returns an error since migrations may not roll back the
+// transaction.  Returning an error from the migration rolls back all
+// migrations instead.
+//
+// This function is part of the walletdb.Tx interface implementation.
+func (tx managedTx) Rollback() error {
+	const str = "migrations may not roll back the transaction"
+	return migrationError(ErrManagedTx, str, nil)
+}
+
+// Options modifies the behavior of Upgrade.
+type Options struct {
+	// DryRun runs all pending migrations and then rolls back the
+	// transaction instead of committing it.  This reports which migrations
+	// would be applied and whether they succeed without modifying the
+	// database.
+	DryRun bool
+
+	// Backup, if not nil, is called to create the destination of a copy
+	// of the database made before any migrations are run.  It is only
+	// called when there are migrations to run and DryRun is false.  The
+	// writer is closed after the copy is written.
+	Backup func() (io.WriteCloser, error)
+}
+
+// Pending returns the migrations which would be applied by Upgrade, without
+// running them.
+func Pending(db walletdb.DB, schemas []*Schema) ([]Applied, error) {
+	var pending []Applied
+	err := db.View(func(tx walletdb.DBTx) error {
+		for _, s := range schemas {
+			migrations, err := s.pending(managedTx{tx.RootBucket(s.Namespace)})
+			if err != nil {
+				return err
+			}
+			for _, m := range migrations {
+				pending = append(pending,
+					Applied{s.Name, m.Version, m.Name})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pending, nil
+}
+
+// Upgrade upgrades the namespaces of each schema to their latest versions.
+// All migrations of every schema are run, in order, in a single transaction,
+// so either every namespace is upgraded or the database is left unchanged.
+// Namespaces which do not exist or which have not had any data created in
+// them are skipped.
+//
+// The applied migrations are returned.  When opts.DryRun is set, these are the
+// migrations which would have been applied, and the database is not modified.
+func Upgrade(db walletdb.DB, schemas []*Schema, opts *Options) ([]Applied, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	// Check for pending migrations first so a backup is only made, and
+	// the write lock is only taken, when there is something to do.
+	pending, err := Pending(db, schemas)
+	if err != nil {
+		return nil, err
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if opts.Backup != nil && !opts.DryRun {
+		if err := backup(db, opts.Backup); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.BeginTx(true)
+	if err != nil {
+		str := "failed to begin migration transaction"
+		return nil, migrationError(ErrDatabase, str, err)
+	}
+	var applied []Applied
+	for _, s := range schemas {
+		a, err := s.apply(managedTx{tx.RootBucket(s.Namespace)})
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		applied = append(applied, a...)
+	}
+	if opts.DryRun {
+		err = tx.Rollback()
+	} else {
+		err = tx.Commit()
+	}
+	if err != nil {
+		str := "failed to end migration transaction"
+		return nil, migrationError(ErrDatabase, str, err)
+	}
+	return applied, nil
+}
+
+// backup writes a copy of db to the writer returned by create.
+func backup(db walletdb.DB, create func() (io.WriteCloser, error)) error {
+	const str = "failed to back up database"
+	w, err := create()
+	if err != nil {
+		return migrationError(ErrBackup, str, err)
+	}
+	err = db.Copy(w)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return migrationError(ErrBackup, str, err)
+	}
+	return nil
+}
+
+// UpgradeNamespace upgrades the data in namespace to the latest version of
+// the schema in a single transaction.  The Namespace field of the schema is
+// not used.  It is intended for packages to upgrade their own namespace when
+// opened, and does not support backups since a namespace can not be copied on
+// its own.  Callers wishing to take a backup should use Upgrade first.
+func UpgradeNamespace(namespace walletdb.Namespace, s *Schema, dryRun bool) ([]Applied, error) {
+	var migrations []*Migration
+	err := namespace.View(func(tx walletdb.Tx) error {
+		var err error
+		migrations, err = s.pending(managedTx{tx.RootBucket()})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(migrations) == 0 {
+		return nil, nil
+	}
+
+	tx, err := namespace.Begin(true)
+	if err != nil {
+		str := "failed to begin migration transaction"
+		return nil, migrationError(ErrDatabase, str, err)
+	}
+	applied, err := s.apply(managedTx{tx.RootBucket()})
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if dryRun {
+		err = tx.Rollback()
+	} else {
+		err = tx.Commit()
+	}
+	if err != nil {
+		str := "failed to end migration transaction"
+		return nil, migrationError(ErrDatabase, str, err)
+	}
+	return applied, nil
+}
@@ -0,0 +1,313 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package migration_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
+)
+
+var versionKey = []byte("version")
+
+// testSchema returns a schema for the namespace with the passed key which
+// records its version under versionKey.  Each migration writes its version
+// under a key named by the migration.
+func testSchema(name string, latest uint32, versions ...uint32) *migration.Schema {
+	s := &migration.Schema{
+		Name:          name,
+		Namespace:     []byte(name),
+		LatestVersion: latest,
+		Version: func(tx walletdb.Tx) (uint32, error) {
+			v := tx.RootBucket().Get(versionKey)
+			if v == nil {
+				return 0, nil
+			}
+			return binary.LittleEndian.Uint32(v), nil
+		},
+		SetVersion: func(tx walletdb.Tx, version uint32) error {
+			v := make([]byte, 4)
+			binary.LittleEndian.PutUint32(v, version)
+			return tx.RootBucket().Put(versionKey, v)
+		},
+	}
+	for _, version := range versions {
+		version := version
+		s.Migrations = append(s.Migrations, migration.Migration{
+			Version: version,
+			Name:    "migration " + string('0'+byte(version)),
+			Migrate: func(tx walletdb.Tx) error {
+				key := []byte{'m', '0' + byte(version)}
+				return tx.RootBucket().Put(key, []byte{1})
+			},
+		})
+	}
+	return s
+}
+
+// createNamespace creates the namespace of s in db at the passed version.
+func createNamespace(t *testing.T, db walletdb.DB, s *migration.Schema, version uint32) {
+	ns, err := db.Namespace(s.Namespace)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+	err = ns.Update(func(tx walletdb.Tx) error {
+		return s.SetVersion(tx, version)
+	})
+	if err != nil {
+		t.Fatalf("Update: unexpected error: %v", err)
+	}
+}
+
+// namespaceState returns the recorded version of the namespace of s and which
+// migrations have written their keys.
+func namespaceState(t *testing.T, db walletdb.DB, s *migration.Schema) (uint32, string) {
+	var version uint32
+	var migrated []byte
+	err := db.View(func(tx walletdb.DBTx) error {
+		root := tx.RootBucket(s.Namespace)
+		v := root.Get(versionKey)
+		if v != nil {
+			version = binary.LittleEndian.Uint32(v)
+		}
+		return root.ForEach(func(k, v []byte) error {
+			if len(k) == 2 && k[0] == 'm' {
+				migrated = append(migrated, k[1])
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("View: unexpected error: %v", err)
+	}
+	return version, string(migrated)
+}
+
+type closeBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closeBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+// TestUpgrade ensures migrations of multiple namespaces are run in order, that
+// a backup is only made when there are migrations to run, and that dry runs do
+// not modify the database.
+func TestUpgrade(t *testing.T) {
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	ns1 := testSchema("ns1", 3, 2, 3)
+	ns2 := testSchema("ns2", 4, 4, 2, 3)
+	ns3 := testSchema("ns3", 1)
+	createNamespace(t, db, ns1, 1)
+	createNamespace(t, db, ns2, 2)
+	schemas := []*migration.Schema{ns1, ns2, ns3}
+
+	wantApplied := []migration.Applied{
+		{Schema: "ns1", Version: 2, Name: "migration 2"},
+		{Schema: "ns1", Version: 3, Name: "migration 3"},
+		{Schema: "ns2", Version: 3, Name: "migration 3"},
+		{Schema: "ns2", Version: 4, Name: "migration 4"},
+	}
+	pending, err := migration.Pending(db, schemas)
+	if err != nil {
+		t.Fatalf("Pending: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(pending, wantApplied) {
+		t.Fatalf("Pending: got %v, want %v", pending, wantApplied)
+	}
+
+	// A dry run must report the migrations but leave the namespaces
+	// unchanged, and must not make a backup.
+	backupCalled := false
+	opts := &migration.Options{
+		DryRun: true,
+		Backup: func() (io.WriteCloser, error) {
+			backupCalled = true
+			return &closeBuffer{}, nil
+		},
+	}
+	applied, err := migration.Upgrade(db, schemas, opts)
+	if err != nil {
+		t.Fatalf("Upgrade (dry run): unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(applied, wantApplied) {
+		t.Errorf("Upgrade (dry run): got %v, want %v", applied, wantApplied)
+	}
+	if backupCalled {
+		t.Errorf("Upgrade (dry run): backup was made")
+	}
+	if v, m := namespaceState(t, db, ns1); v != 1 || m != "" {
+		t.Errorf("Upgrade (dry run): ns1 was modified: version %d, "+
+			"migrations %q", v, m)
+	}
+
+	backup := &closeBuffer{}
+	opts = &migration.Options{
+		Backup: func() (io.WriteCloser, error) { return backup, nil },
+	}
+	applied, err = migration.Upgrade(db, schemas, opts)
+	if err != nil {
+		t.Fatalf("Upgrade: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(applied, wantApplied) {
+		t.Errorf("Upgrade: got %v, want %v", applied, wantApplied)
+	}
+	if backup.Len() == 0 || !backup.closed {
+		t.Errorf("Upgrade: backup was not written and closed")
+	}
+	if v, m := namespaceState(t, db, ns1); v != 3 || m != "23" {
+		t.Errorf("Upgrade: unexpected ns1 state: version %d, "+
+			"migrations %q", v, m)
+	}
+	if v, m := namespaceState(t, db, ns2); v != 4 || m != "34" {
+		t.Errorf("Upgrade: unexpected ns2 state: version %d, "+
+			"migrations %q", v, m)
+	}
+
+	// Upgrading again must do nothing, and must not make a backup.
+	opts.Backup = func() (io.WriteCloser, error) {
+		t.Errorf("Upgrade: backup made with no pending migrations")
+		return &closeBuffer{}, nil
+	}
+	applied, err = migration.Upgrade(db, schemas, opts)
+	if err != nil {
+		t.Fatalf("Upgrade: unexpected error: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("Upgrade: unexpected migrations applied: %v", applied)
+	}
+}
+
+// TestUpgradeErrors ensures that failed upgrades return the expected errors and
+// leave every namespace unchanged.
+func TestUpgradeErrors(t *testing.T) {
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	ns1 := testSchema("ns1", 2, 2)
+	createNamespace(t, db, ns1, 1)
+
+	checkCode := func(desc string, err error, code migration.ErrorCode) {
+		merr, ok := err.(migration.Error)
+		if !ok || merr.Code != code {
+			t.Errorf("%s: unexpected error - got %v, want %v", desc,
+				err, code)
+		}
+	}
+
+	newer := testSchema("newer", 1)
+	createNamespace(t, db, newer, 2)
+	_, err = migration.Upgrade(db, []*migration.Schema{ns1, newer}, nil)
+	checkCode("newer version", err, migration.ErrUnknownVersion)
+
+	missing := testSchema("missing", 3, 3)
+	createNamespace(t, db, missing, 1)
+	_, err = migration.Upgrade(db, []*migration.Schema{ns1, missing}, nil)
+	checkCode("missing migration", err, migration.ErrMissingMigration)
+
+	backupErr := errors.New("backup failure")
+	opts := &migration.Options{
+		Backup: func() (io.WriteCloser, error) { return nil, backupErr },
+	}
+	_, err = migration.Upgrade(db, []*migration.Schema{ns1}, opts)
+	checkCode("backup failure", err, migration.ErrBackup)
+
+	// A failing migration after a successful one must roll back both.
+	failErr := errors.New("migration failure")
+	failing := testSchema("failing", 2)
+	failing.Migrations = []migration.Migration{{
+		Version: 2,
+		Name:    "failing",
+		Migrate: func(tx walletdb.Tx) error { return failErr },
+	}}
+	createNamespace(t, db, failing, 1)
+	_, err = migration.Upgrade(db, []*migration.Schema{ns1, failing}, nil)
+	if err != failErr {
+		t.Errorf("failing migration: unexpected error - got %v, want %v",
+			err, failErr)
+	}
+
+	// Migrations must not be able to end the transaction.
+	committing := testSchema("committing", 2)
+	committing.Migrations = []migration.Migration{{
+		Version: 2,
+		Name:    "committing",
+		Migrate: func(tx walletdb.Tx) error { return tx.Commit() },
+	}}
+	createNamespace(t, db, committing, 1)
+	_, err = migration.Upgrade(db, []*migration.Schema{ns1, committing}, nil)
+	checkCode("committing migration", err, migration.ErrManagedTx)
+
+	if v, m := namespaceState(t, db, ns1); v != 1 || m != "" {
+		t.Errorf("ns1 was modified by failed upgrades: version %d, "+
+			"migrations %q", v, m)
+	}
+}
+
+// TestUpgradeNamespace ensures a single namespace can be upgraded.
+func TestUpgradeNamespace(t *testing.T) {
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+	defer db.Close()
+
+	ns1 := testSchema("ns1", 3, 2, 3)
+	createNamespace(t, db, ns1, 1)
+	ns, err := db.Namespace(ns1.Namespace)
+	if err != nil {
+		t.Fatalf("Namespace: unexpected error: %v", err)
+	}
+
+	applied, err := migration.UpgradeNamespace(ns, ns1, true)
+	if err != nil {
+		t.Fatalf("UpgradeNamespace (dry run): unexpected error: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("UpgradeNamespace (dry run): got %v, want 2 migrations",
+			applied)
+	}
+	if v, m := namespaceState(t, db, ns1); v != 1 || m != "" {
+		t.Errorf("UpgradeNamespace (dry run): ns1 was modified: "+
+			"version %d, migrations %q", v, m)
+	}
+
+	_, err = migration.UpgradeNamespace(ns, ns1, false)
+	if err != nil {
+		t.Fatalf("UpgradeNamespace: unexpected error: %v", err)
+	}
+	if v, m := namespaceState(t, db, ns1); v != 3 || m != "23" {
+		t.Errorf("UpgradeNamespace: unexpected ns1 state: version %d, "+
+			"migrations %q", v, m)
+	}
+}
@@ -0,0 +1,193 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: This file is intended to house the RPC commands that are supported by
+// each wallet of a btcwallet server as extensions to the reference
+// implementation's commands.
+
+package walletjson
+
+import "github.com/btcsuite/btcd/btcjson"
+
+// AbandonTransactionCmd defines the abandontransaction JSON-RPC command.
+type AbandonTransactionCmd struct {
+	Txid string
+}
+
+// NewAbandonTransactionCmd returns a new instance which can be used to issue
+// an abandontransaction JSON-RPC command.
+func NewAbandonTransactionCmd(txid string) *AbandonTransactionCmd {
+	return &AbandonTransactionCmd{
+		Txid: txid,
+	}
+}
+
+// ExportTransactionsCmd defines the exporttransactions JSON-RPC command.
+type ExportTransactionsCmd struct {
+	Format      *string `jsonrpcdefault:"\"csv\""`
+	Account     *string `jsonrpcdefault:"\"*\""`
+	StartHeight *int32  `jsonrpcdefault:"0"`
+	EndHeight   *int32
+	StartTime   *int64
+	EndTime     *int64
+}
+
+// NewExportTransactionsCmd returns a new instance which can be used to issue
+// an exporttransactions JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewExportTransactionsCmd(format, account *string, startHeight,
+	endHeight *int32, startTime, endTime *int64) *ExportTransactionsCmd {
+
+	return &ExportTransactionsCmd{
+		Format:      format,
+		Account:     account,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		StartTime:   startTime,
+		EndTime:     endTime,
+	}
+}
+
+// GetBalanceHistoryCmd defines the getbalancehistory JSON-RPC command.
+type GetBalanceHistoryCmd struct {
+	Account     *string `jsonrpcdefault:"\"*\""`
+	StartHeight *int32  `jsonrpcdefault:"0"`
+	EndHeight   *int32
+	Interval    *string `jsonrpcdefault:"\"block\""`
+}
+
+// NewGetBalanceHistoryCmd returns a new instance which can be used to issue a
+// getbalancehistory JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewGetBalanceHistoryCmd(account *string, startHeight, endHeight *int32,
+	interval *string) *GetBalanceHistoryCmd {
+
+	return &GetBalanceHistoryCmd{
+		Account:     account,
+		StartHeight: startHeight,
+		EndHeight:   endHeight,
+		Interval:    interval,
+	}
+}
+
+// ListSpendPoliciesCmd defines the listspendpolicies JSON-RPC command.
+type ListSpendPoliciesCmd struct{}
+
+// NewListSpendPoliciesCmd returns a new instance which can be used to issue a
+// listspendpolicies JSON-RPC command.
+func NewListSpendPoliciesCmd() *ListSpendPoliciesCmd {
+	return &ListSpendPoliciesCmd{}
+}
+
+// LockOutpointsCmd defines the lockoutpoints JSON-RPC command.
+type LockOutpointsCmd struct {
+	Transactions []btcjson.TransactionInput
+	Reason       *string
+	Timeout      *int64 `jsonrpcdefault:"0"`
+}
+
+// NewLockOutpointsCmd returns a new instance which can be used to issue a
+// lockoutpoints JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewLockOutpointsCmd(transactions []btcjson.TransactionInput,
+	reason *string, timeout *int64) *LockOutpointsCmd {
+
+	return &LockOutpointsCmd{
+		Transactions: transactions,
+		Reason:       reason,
+		Timeout:      timeout,
+	}
+}
+
+// RemoveSpendPolicyCmd defines the removespendpolicy JSON-RPC command.
+type RemoveSpendPolicyCmd struct {
+	Account string
+}
+
+// NewRemoveSpendPolicyCmd returns a new instance which can be used to issue a
+// removespendpolicy JSON-RPC command.
+func NewRemoveSpendPolicyCmd(account string) *RemoveSpendPolicyCmd {
+	return &RemoveSpendPolicyCmd{
+		Account: account,
+	}
+}
+
+// SendAllCmd defines the sendall JSON-RPC command.
+type SendAllCmd struct {
+	FromAccount string
+	ToAddress   string
+	MinConf     *int32 `jsonrpcdefault:"1"`
+}
+
+// NewSendAllCmd returns a new instance which can be used to issue a sendall
+// JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSendAllCmd(fromAccount, toAddress string, minConf *int32) *SendAllCmd {
+	return &SendAllCmd{
+		FromAccount: fromAccount,
+		ToAddress:   toAddress,
+		MinConf:     minConf,
+	}
+}
+
+// SetSpendPolicyCmd defines the setspendpolicy JSON-RPC command.
+type SetSpendPolicyCmd struct {
+	Account          string
+	SpendLimit       *float64 `jsonrpcdefault:"0"`
+	LimitPeriod      *int64   `jsonrpcdefault:"86400"`
+	MaxPayment       *float64 `jsonrpcdefault:"0"`
+	AllowedAddresses *[]string
+	MinConf          *int32 `jsonrpcdefault:"0"`
+}
+
+// NewSetSpendPolicyCmd returns a new instance which can be used to issue a
+// setspendpolicy JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewSetSpendPolicyCmd(account string, spendLimit *float64,
+	limitPeriod *int64, maxPayment *float64, allowedAddresses *[]string,
+	minConf *int32) *SetSpendPolicyCmd {
+
+	return &SetSpendPolicyCmd{
+		Account:          account,
+		SpendLimit:       spendLimit,
+		LimitPeriod:      limitPeriod,
+		MaxPayment:       maxPayment,
+		AllowedAddresses: allowedAddresses,
+		MinConf:          minConf,
+	}
+}
+
+func init() {
+	// The commands in this file are only usable with a wallet server.
+	flags := btcjson.UFWalletOnly
+
+	btcjson.MustRegisterCmd("abandontransaction", (*AbandonTransactionCmd)(nil), flags)
+	btcjson.MustRegisterCmd("exporttransactions", (*ExportTransactionsCmd)(nil), flags)
+	btcjson.MustRegisterCmd("getbalancehistory", (*GetBalanceHistoryCmd)(nil), flags)
+	btcjson.MustRegisterCmd("listspendpolicies", (*ListSpendPoliciesCmd)(nil), flags)
+	btcjson.MustRegisterCmd("lockoutpoints", (*LockOutpointsCmd)(nil), flags)
+	btcjson.MustRegisterCmd("removespendpolicy", (*RemoveSpendPolicyCmd)(nil), flags)
+	btcjson.MustRegisterCmd("sendall", (*SendAllCmd)(nil), flags)
+	btcjson.MustRegisterCmd("setspendpolicy", (*SetSpendPolicyCmd)(nil), flags)
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: This file is intended to house the reference implementation commands
+// which a btcwallet server accepts with more parameters than are registered by
+// the btcjson package.  These commands must be unmarshaled with UnmarshalCmd.
+
+package walletjson
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcjson"
+)
+
+// GetBalanceCmd extends the getbalance JSON-RPC command with an additional
+// optional parameter to also return the balance of immature coinbase outputs.
+type GetBalanceCmd struct {
+	btcjson.GetBalanceCmd
+	Verbose *bool
+}
+
+// SendManyCmd extends the sendmany JSON-RPC command with an additional
+// optional parameter listing the addresses of the amounts which the fee is
+// subtracted from.
+type SendManyCmd struct {
+	btcjson.SendManyCmd
+	SubtractFeeFrom *[]string
+}
+
+// SendToAddressCmd extends the sendtoaddress JSON-RPC command with an
+// additional optional parameter to subtract the fee from the amount sent.
+type SendToAddressCmd struct {
+	btcjson.SendToAddressCmd
+	SubtractFeeFromAmount *bool
+}
+
+// registeredParams is the number of parameters registered by the btcjson
+// package for each command in this file.
+var registeredParams = map[string]int{
+	"getbalance":    2,
+	"sendmany":      4,
+	"sendtoaddress": 4,
+}
+
+// UnmarshalCmd unmarshals a JSON-RPC request into a suitable concrete command
+// in the same manner as btcjson.UnmarshalCmd, except requests for the commands
+// in this file are unmarshaled into the extended commands, accepting the
+// additional parameters.
+func UnmarshalCmd(r *btcjson.Request) (interface{}, error) {
+	numParams, ok := registeredParams[r.Method]
+	if !ok || len(r.Params) > numParams+1 {
+		return btcjson.UnmarshalCmd(r)
+	}
+
+	// Unmarshal the registered parameters with btcjson, which also sets
+	// any default values.
+	var extra json.RawMessage
+	req := *r
+	if len(req.Params) > numParams {
+		extra = req.Params[numParams]
+		req.Params = req.Params[:numParams]
+	}
+	cmd, err := btcjson.UnmarshalCmd(&req)
+	if err != nil {
+		return nil, err
+	}
+	if extra != nil && string(extra) == "null" {
+		extra = nil
+	}
+
+	switch cmd := cmd.(type) {
+	case *btcjson.GetBalanceCmd:
+		extCmd := &GetBalanceCmd{GetBalanceCmd: *cmd}
+		if extra != nil {
+			var verbose bool
+			if err := json.Unmarshal(extra, &verbose); err != nil {
+				return nil, paramTypeError(3, "verbose", err)
+			}
+			extCmd.Verbose = &verbose
+		}
+		return extCmd, nil
+
+	case *btcjson.SendManyCmd:
+		extCmd := &SendManyCmd{SendManyCmd: *cmd}
+		if extra != nil {
+			var addrs []string
+			if err := json.Unmarshal(extra, &addrs); err != nil {
+				return nil, paramTypeError(5, "subtractfeefrom", err)
+			}
+			extCmd.SubtractFeeFrom = &addrs
+		}
+		return extCmd, nil
+
+	case *btcjson.SendToAddressCmd:
+		extCmd := &SendToAddressCmd{SendToAddressCmd: *cmd}
+		if extra != nil {
+			var subtract bool
+			if err := json.Unmarshal(extra, &subtract); err != nil {
+				return nil, paramTypeError(5, "subtractfeefromamount", err)
+			}
+			extCmd.SubtractFeeFromAmount = &subtract
+		}
+		return extCmd, nil
+	}
+
+	// Not reached unless the btcjson package registers a different type.
+	return cmd, nil
+}
+
+// paramTypeError returns an error describing a parameter which could not be
+// unmarshaled.
+func paramTypeError(num int, name string, err error) error {
+	str := fmt.Sprintf("parameter #%d '%s' must be a valid JSON value "+
+		"of the expected type: %v", num, name, err)
+	return btcjson.Error{ErrorCode: btcjson.ErrInvalidType, Description: str}
+}
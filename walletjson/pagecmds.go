@@ -0,0 +1,77 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: This file is intended to house the RPC commands that are supported by
+// each wallet of a btcwallet server for returning large result sets over
+// several requests.
+
+package walletjson
+
+import "github.com/btcsuite/btcd/btcjson"
+
+// ListTransactionsPageCmd defines the listtransactionspage JSON-RPC command.
+type ListTransactionsPageCmd struct {
+	Account *string `jsonrpcdefault:"\"*\""`
+	Count   *int    `jsonrpcdefault:"10"`
+	Token   *string
+}
+
+// NewListTransactionsPageCmd returns a new instance which can be used to issue
+// a listtransactionspage JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewListTransactionsPageCmd(account *string, count *int,
+	token *string) *ListTransactionsPageCmd {
+
+	return &ListTransactionsPageCmd{
+		Account: account,
+		Count:   count,
+		Token:   token,
+	}
+}
+
+// ListUnspentPageCmd defines the listunspentpage JSON-RPC command.
+type ListUnspentPageCmd struct {
+	MinConf   *int `jsonrpcdefault:"1"`
+	MaxConf   *int `jsonrpcdefault:"9999999"`
+	Addresses *[]string
+	Count     *int `jsonrpcdefault:"100"`
+	Token     *string
+}
+
+// NewListUnspentPageCmd returns a new instance which can be used to issue a
+// listunspentpage JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewListUnspentPageCmd(minConf, maxConf *int, addresses *[]string,
+	count *int, token *string) *ListUnspentPageCmd {
+
+	return &ListUnspentPageCmd{
+		MinConf:   minConf,
+		MaxConf:   maxConf,
+		Addresses: addresses,
+		Count:     count,
+		Token:     token,
+	}
+}
+
+func init() {
+	// The commands in this file are only usable with a wallet server.
+	flags := btcjson.UFWalletOnly
+
+	btcjson.MustRegisterCmd("listtransactionspage", (*ListTransactionsPageCmd)(nil), flags)
+	btcjson.MustRegisterCmd("listunspentpage", (*ListUnspentPageCmd)(nil), flags)
+}
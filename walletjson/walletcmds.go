@@ -0,0 +1,121 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: This file is intended to house the RPC commands that are supported by
+// a btcwallet server for managing the loaded wallets.
+
+package walletjson
+
+import "github.com/btcsuite/btcd/btcjson"
+
+// CreateWalletCmd defines the createwallet JSON-RPC command.
+type CreateWalletCmd struct {
+	Name               string
+	PrivPassphrase     string
+	PubPassphrase      *string
+	MnemonicPassphrase *string
+}
+
+// NewCreateWalletCmd returns a new instance which can be used to issue a
+// createwallet JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewCreateWalletCmd(name, privPassphrase string, pubPassphrase,
+	mnemonicPassphrase *string) *CreateWalletCmd {
+
+	return &CreateWalletCmd{
+		Name:               name,
+		PrivPassphrase:     privPassphrase,
+		PubPassphrase:      pubPassphrase,
+		MnemonicPassphrase: mnemonicPassphrase,
+	}
+}
+
+// ListWalletsCmd defines the listwallets JSON-RPC command.
+type ListWalletsCmd struct{}
+
+// NewListWalletsCmd returns a new instance which can be used to issue a
+// listwallets JSON-RPC command.
+func NewListWalletsCmd() *ListWalletsCmd {
+	return &ListWalletsCmd{}
+}
+
+// LoadWalletCmd defines the loadwallet JSON-RPC command.
+type LoadWalletCmd struct {
+	Name          string
+	PubPassphrase *string
+}
+
+// NewLoadWalletCmd returns a new instance which can be used to issue a
+// loadwallet JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewLoadWalletCmd(name string, pubPassphrase *string) *LoadWalletCmd {
+	return &LoadWalletCmd{
+		Name:          name,
+		PubPassphrase: pubPassphrase,
+	}
+}
+
+// RestoreWalletCmd defines the restorewallet JSON-RPC command.
+type RestoreWalletCmd struct {
+	Name               string
+	Seed               string
+	PrivPassphrase     string
+	PubPassphrase      *string
+	MnemonicPassphrase *string
+}
+
+// NewRestoreWalletCmd returns a new instance which can be used to issue a
+// restorewallet JSON-RPC command.
+//
+// The parameters which are pointers indicate they are optional.  Passing nil
+// for optional parameters will use the default value.
+func NewRestoreWalletCmd(name, seed, privPassphrase string, pubPassphrase,
+	mnemonicPassphrase *string) *RestoreWalletCmd {
+
+	return &RestoreWalletCmd{
+		Name:               name,
+		Seed:               seed,
+		PrivPassphrase:     privPassphrase,
+		PubPassphrase:      pubPassphrase,
+		MnemonicPassphrase: mnemonicPassphrase,
+	}
+}
+
+// UnloadWalletCmd defines the unloadwallet JSON-RPC command.
+type UnloadWalletCmd struct {
+	Name string
+}
+
+// NewUnloadWalletCmd returns a new instance which can be used to issue an
+// unloadwallet JSON-RPC command.
+func NewUnloadWalletCmd(name string) *UnloadWalletCmd {
+	return &UnloadWalletCmd{
+		Name: name,
+	}
+}
+
+func init() {
+	// The commands in this file are only usable with a wallet server.
+	flags := btcjson.UFWalletOnly
+
+	btcjson.MustRegisterCmd("createwallet", (*CreateWalletCmd)(nil), flags)
+	btcjson.MustRegisterCmd("listwallets", (*ListWalletsCmd)(nil), flags)
+	btcjson.MustRegisterCmd("loadwallet", (*LoadWalletCmd)(nil), flags)
+	btcjson.MustRegisterCmd("restorewallet", (*RestoreWalletCmd)(nil), flags)
+	btcjson.MustRegisterCmd("unloadwallet", (*UnloadWalletCmd)(nil), flags)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package walletjson
+
+import "github.com/btcsuite/btcd/btcjson"
+
+// CreateWalletResult models the data from the createwallet command.
+type CreateWalletResult struct {
+	Mnemonic string `json:"mnemonic"`
+	Seed     string `json:"seed"`
+}
+
+// GetBalanceResult models the data from the getbalance command when the
+// verbose parameter is true.
+type GetBalanceResult struct {
+	Balance         float64 `json:"balance"`
+	ImmatureBalance float64 `json:"immature_balance"`
+}
+
+// GetBalanceHistoryResult models each balance snapshot returned by the
+// getbalancehistory command.
+type GetBalanceHistoryResult struct {
+	Height    int32   `json:"height"`
+	BlockHash string  `json:"blockhash"`
+	BlockTime int64   `json:"blocktime"`
+	Date      string  `json:"date,omitempty"`
+	Balance   float64 `json:"balance"`
+}
+
+// GetWalletInfoResult models the data from the getwalletinfo command.
+type GetWalletInfoResult struct {
+	WalletVersion      int32   `json:"walletversion"`
+	Balance            float64 `json:"balance"`
+	UnconfirmedBalance float64 `json:"unconfirmed_balance"`
+	ImmatureBalance    float64 `json:"immature_balance"`
+	PayTxFee           float64 `json:"paytxfee"`
+}
+
+// ListTransactionsPageResult models the data from the listtransactionspage
+// command.
+type ListTransactionsPageResult struct {
+	Transactions []btcjson.ListTransactionsResult `json:"transactions"`
+	NextToken    string                           `json:"nexttoken"`
+}
+
+// ListUnspentPageResult models the data from the listunspentpage command.
+type ListUnspentPageResult struct {
+	Unspent   []btcjson.ListUnspentResult `json:"unspent"`
+	NextToken string                      `json:"nexttoken"`
+}
+
+// ListLockUnspentResult models the data from the listlockunspent command.  It
+// extends the btcjson.TransactionInput results of the reference implementation
+// with the expiry and reason of each lock.
+type ListLockUnspentResult struct {
+	Txid   string `json:"txid"`
+	Vout   uint32 `json:"vout"`
+	Expiry int64  `json:"expiry,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// SpendPolicyResult models the spending policy of each account returned by the
+// listspendpolicies command.
+type SpendPolicyResult struct {
+	Account          string   `json:"account"`
+	SpendLimit       float64  `json:"spendlimit"`
+	LimitPeriod      int64    `json:"limitperiod"`
+	Spent            float64  `json:"spent"`
+	MaxPayment       float64  `json:"maxpayment"`
+	AllowedAddresses []string `json:"allowedaddresses"`
+	MinConf          int32    `json:"minconf"`
+}
@@ -20,21 +20,31 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
+	"time"
 
 	"github.com/btcsuite/btcd/btcec"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcutil/hdkeychain"
+	"github.com/btcsuite/btcwallet/internal/bip39"
 	"github.com/btcsuite/btcwallet/internal/legacy/keystore"
+	"github.com/btcsuite/btcwallet/internal/zero"
 	"github.com/btcsuite/btcwallet/waddrmgr"
 	"github.com/btcsuite/btcwallet/wallet"
 	"github.com/btcsuite/btcwallet/walletdb"
-	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/btcsuite/btcwallet/walletdb/bdb"
+	"github.com/btcsuite/btcwallet/walletdb/cryptdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
+	"github.com/btcsuite/btcwallet/wtxmgr"
 	"github.com/btcsuite/golangcrypto/ssh/terminal"
 )
 
@@ -44,6 +54,12 @@ var (
 	wtxmgrNamespaceKey   = []byte("wtxmgr")
 )
 
+// errDbPassRequired describes the error where an encrypted wallet database is
+// opened without the database passphrase and there is no console to prompt
+// for it.
+var errDbPassRequired = errors.New("wallet database is encrypted and the " +
+	"database passphrase must be set with --dbpass")
+
 // networkDir returns the directory name of a network directory to hold wallet
 // files.
 func networkDir(dataDir string, chainParams *chaincfg.Params) string {
@@ -108,6 +124,26 @@ func promptPrivPassPhrase() ([]byte, error) {
 	}
 }
 
+// promptDbPassPhrase is used to prompt for the database passphrase of an
+// encrypted wallet database when it has not been configured.
+func promptDbPassPhrase() ([]byte, error) {
+	prompt := "Enter the database passphrase of your wallet: "
+	for {
+		fmt.Print(prompt)
+		pass, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		if err != nil {
+			return nil, err
+		}
+		fmt.Print("\n")
+		pass = bytes.TrimSpace(pass)
+		if len(pass) == 0 {
+			continue
+		}
+
+		return pass, nil
+	}
+}
+
 // promptConsoleList prompts the user with the given prefix, list of valid
 // responses, and default list entry to use.  The function will repeat the
 // prompt to the user until they enter a valid response.
@@ -299,11 +335,45 @@ func promptConsolePublicPass(reader *bufio.Reader, privPass []byte, cfg *config)
 	return pubPass, nil
 }
 
+// promptConsoleDbPass prompts the user whether they want to encrypt the entire
+// wallet database, unless a database passphrase is already configured, in
+// which case it is used.  The database passphrase is returned, or nil if the
+// database is not to be encrypted.
+func promptConsoleDbPass(reader *bufio.Reader, cfg *config) ([]byte, error) {
+	if cfg.DbPass != "" {
+		fmt.Println("The wallet database will be encrypted with the " +
+			"configured database passphrase.")
+		return []byte(cfg.DbPass), nil
+	}
+
+	useDbPass, err := promptConsoleListBool(reader, "Do you want to "+
+		"encrypt the entire wallet database with a database "+
+		"passphrase?", "no")
+	if err != nil {
+		return nil, err
+	}
+	if !useDbPass {
+		return nil, nil
+	}
+
+	dbPass, err := promptConsolePass(reader, "Enter the database "+
+		"passphrase for your new wallet", true)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Println("NOTE: Use the --dbpass option to configure your " +
+		"database passphrase, or enter it when prompted.")
+	return dbPass, nil
+}
+
 // promptConsoleSeed prompts the user whether they want to use an existing
-// wallet generation seed.  When the user answers no, a seed will be generated
-// and displayed to the user along with prompting them for confirmation.  When
-// the user answers yes, a the user is prompted for it.  All prompts are
-// repeated until the user enters a valid response.
+// wallet generation seed.  When the user answers no, a BIP0039 mnemonic will
+// be generated and displayed to the user along with prompting them for
+// confirmation.  When the user answers yes, the user is prompted for it, and
+// may enter either a mnemonic or a hex encoded seed.  Mnemonics may be
+// protected by an additional passphrase which the user is prompted for.  All
+// prompts are repeated until the user enters a valid response.
 func promptConsoleSeed(reader *bufio.Reader) ([]byte, error) {
 	// Ascertain the wallet generation seed.
 	useUserSeed, err := promptConsoleListBool(reader, "Do you have an "+
@@ -312,13 +382,17 @@ func promptConsoleSeed(reader *bufio.Reader) ([]byte, error) {
 		return nil, err
 	}
 	if !useUserSeed {
-		seed, err := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
+		entropy, err := bip39.NewEntropy(bip39.RecommendedEntropyBits)
+		if err != nil {
+			return nil, err
+		}
+		mnemonic, err := bip39.NewMnemonic(entropy)
 		if err != nil {
 			return nil, err
 		}
 
 		fmt.Println("Your wallet generation seed is:")
-		fmt.Printf("%x\n", seed)
+		fmt.Println(mnemonic)
 		fmt.Println("IMPORTANT: Keep the seed in a safe place as you\n" +
 			"will NOT be able to restore your wallet without it.")
 		fmt.Println("Please keep in mind that anyone who has access\n" +
@@ -340,24 +414,33 @@ func promptConsoleSeed(reader *bufio.Reader) ([]byte, error) {
 			}
 		}
 
-		return seed, nil
+		return promptConsoleMnemonicSeed(reader, mnemonic)
 	}
 
 	for {
-		fmt.Print("Enter existing wallet seed: ")
+		fmt.Print("Enter existing wallet seed (mnemonic or hex): ")
 		seedStr, err := reader.ReadString('\n')
 		if err != nil {
 			return nil, err
 		}
-		seedStr = strings.TrimSpace(strings.ToLower(seedStr))
 
+		if bip39.IsMnemonic(seedStr) {
+			if _, err := bip39.EntropyFromMnemonic(seedStr); err != nil {
+				fmt.Printf("Invalid mnemonic specified: %v\n", err)
+				continue
+			}
+			return promptConsoleMnemonicSeed(reader, seedStr)
+		}
+
+		seedStr = strings.TrimSpace(strings.ToLower(seedStr))
 		seed, err := hex.DecodeString(seedStr)
 		if err != nil || len(seed) < hdkeychain.MinSeedBytes ||
 			len(seed) > hdkeychain.MaxSeedBytes {
 
 			fmt.Printf("Invalid seed specified.  Must be a "+
-				"hexadecimal value that is at least %d bits and "+
-				"at most %d bits\n", hdkeychain.MinSeedBytes*8,
+				"mnemonic, or a hexadecimal value that is at "+
+				"least %d bits and at most %d bits\n",
+				hdkeychain.MinSeedBytes*8,
 				hdkeychain.MaxSeedBytes*8)
 			continue
 		}
@@ -366,6 +449,34 @@ func promptConsoleSeed(reader *bufio.Reader) ([]byte, error) {
 	}
 }
 
+// promptConsoleMnemonicSeed prompts the user for the optional passphrase of a
+// valid BIP0039 mnemonic and returns the seed derived from both.
+func promptConsoleMnemonicSeed(reader *bufio.Reader, mnemonic string) ([]byte, error) {
+	usePass, err := promptConsoleListBool(reader, "Do you want to protect "+
+		"the seed with an additional mnemonic passphrase?", "no")
+	if err != nil {
+		return nil, err
+	}
+	if !usePass {
+		return bip39.Seed(mnemonic, "")
+	}
+
+	for {
+		pass, err := promptConsolePass(reader, "Enter the mnemonic "+
+			"passphrase", true)
+		if err != nil {
+			return nil, err
+		}
+		seed, err := bip39.Seed(mnemonic, string(pass))
+		if err == bip39.ErrNonASCIIPassphrase {
+			fmt.Println("The mnemonic passphrase must only contain " +
+				"ASCII characters")
+			continue
+		}
+		return seed, err
+	}
+}
+
 // convertLegacyKeystore converts all of the addresses in the passed legacy
 // key store to the new waddrmgr.Manager format.  Both the legacy keystore and
 // the new manager must be unlocked.
@@ -457,6 +568,13 @@ func createWallet(cfg *config) error {
 		return err
 	}
 
+	// Ascertain whether the entire database is encrypted, which also
+	// protects the transaction history and addresses of the wallet.
+	dbPass, err := promptConsoleDbPass(reader, cfg)
+	if err != nil {
+		return err
+	}
+
 	// Ascertain the wallet generation seed.  This will either be an
 	// automatically generated value the user has already confirmed or a
 	// value the user has entered which has already been validated.
@@ -470,7 +588,7 @@ func createWallet(cfg *config) error {
 	fmt.Println("Creating the wallet...")
 
 	// Create the wallet database backed by bolt db.
-	db, err := walletdb.Create("bdb", dbPath)
+	db, err := createDb(dbPath, dbPass)
 	if err != nil {
 		return err
 	}
@@ -513,8 +631,9 @@ func createWallet(cfg *config) error {
 }
 
 // createSimulationWallet is intended to be called from the rpcclient
-// and used to create a wallet for actors involved in simulations.
-func createSimulationWallet(cfg *config) error {
+// and used to create a wallet for actors involved in simulations.  The wallet
+// is held in an in-memory database and is discarded when the process exits.
+func createSimulationWallet() (*wallet.Wallet, walletdb.DB, error) {
 	// Simulation wallet password is 'password'.
 	privPass := []byte("password")
 
@@ -524,38 +643,74 @@ func createSimulationWallet(cfg *config) error {
 	// Generate a random seed.
 	seed, err := hdkeychain.GenerateSeed(hdkeychain.RecommendedSeedLen)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	netDir := networkDir(cfg.DataDir, activeNet.Params)
-
 	// Create the wallet.
-	dbPath := filepath.Join(netDir, walletDbName)
-	fmt.Println("Creating the wallet...")
-
-	// Create the wallet database backed by bolt db.
-	db, err := walletdb.Create("bdb", dbPath)
+	log.Info("Creating the temporary simulation wallet")
+	db, err := walletdb.Create("mem")
 	if err != nil {
+		return nil, nil, err
+	}
+	if err := initWalletDb(db, seed, pubPass, privPass); err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return loadWalletDb(db, pubPass, nil)
+}
+
+// createWalletDir creates a new wallet database in directory, which is
+// created if it does not already exist.  Both the address manager, created
+// from the seed and passphrases, and the transaction store are initialized
+// so the wallet may be opened immediately with openWalletDir.  If the wallet
+// can not be created, the partially created database is removed.
+func createWalletDir(directory string, seed, pubPass, privPass []byte) error {
+	if err := checkCreateDir(directory); err != nil {
 		return err
 	}
-	defer db.Close()
 
-	// Create the address manager.
-	waddrmgrNamespace, err := db.Namespace(waddrmgrNamespaceKey)
+	// Create the wallet database backed by bolt db, encrypted with the
+	// configured database passphrase, if any.
+	dbPath := filepath.Join(directory, walletDbName)
+	db, err := createDb(dbPath, []byte(cfg.DbPass))
 	if err != nil {
 		return err
 	}
 
-	manager, err := waddrmgr.Create(waddrmgrNamespace, seed, []byte(pubPass),
-		[]byte(privPass), activeNet.Params, nil)
+	err = initWalletDb(db, seed, pubPass, privPass)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		if rmErr := os.Remove(dbPath); rmErr != nil {
+			log.Warnf("Cannot remove partially created wallet %s: %v",
+				dbPath, rmErr)
+		}
 		return err
 	}
+	return nil
+}
 
+// initWalletDb initializes the address manager and transaction store
+// namespaces of a newly created wallet database.
+func initWalletDb(db walletdb.DB, seed, pubPass, privPass []byte) error {
+	addrMgrNS, err := db.Namespace(waddrmgrNamespaceKey)
+	if err != nil {
+		return err
+	}
+	manager, err := waddrmgr.Create(addrMgrNS, seed, pubPass,
+		privPass, activeNet.Params, nil)
+	if err != nil {
+		return err
+	}
 	manager.Close()
 
-	fmt.Println("The wallet has been created successfully.")
-	return nil
+	txMgrNS, err := db.Namespace(wtxmgrNamespaceKey)
+	if err != nil {
+		return err
+	}
+	_, err = wtxmgr.Create(txMgrNS)
+	return err
 }
 
 // checkCreateDir checks that the path exists and is a directory.
@@ -579,9 +734,24 @@ func checkCreateDir(path string) error {
 	return nil
 }
 
+// createDb creates a new wallet database backed by bolt db at dbPath.  When
+// dbPass is not empty, all contents of the database are encrypted with it using
+// the cryptdb driver.
+func createDb(dbPath string, dbPass []byte) (walletdb.DB, error) {
+	if len(dbPass) == 0 {
+		return walletdb.Create("bdb", dbPath, walletDbOptions(cfg))
+	}
+	return walletdb.Create("cryptdb", "bdb", dbPass, dbPath,
+		walletDbOptions(cfg))
+}
+
 // openDb opens and returns a walletdb.DB (boltdb here) given the directory and
-// dbname
-func openDb(directory string, dbname string) (walletdb.DB, error) {
+// dbname.  When a database passphrase is configured, the database must have
+// been encrypted with it.  Otherwise, obtainDbPass is called to obtain the
+// passphrase if the database is encrypted.
+func openDb(directory string, dbname string,
+	obtainDbPass func() ([]byte, error)) (walletdb.DB, error) {
+
 	dbPath := filepath.Join(directory, dbname)
 
 	// Ensure that the network directory exists.
@@ -589,8 +759,231 @@ func openDb(directory string, dbname string) (walletdb.DB, error) {
 		return nil, err
 	}
 
-	// Open the database using the boltdb backend.
-	return walletdb.Open("bdb", dbPath)
+	// Open the database using the boltdb backend, decrypting it with the
+	// configured database passphrase when set.
+	if cfg.DbPass != "" {
+		db, err := walletdb.Open("cryptdb", "bdb", []byte(cfg.DbPass),
+			dbPath, walletDbOptions(cfg))
+		if err == cryptdb.ErrNotEncrypted {
+			err = errors.New("wallet database is not encrypted -- " +
+				"run with --encryptdb to encrypt it")
+		}
+		return db, err
+	}
+	db, err := walletdb.Open("bdb", dbPath, walletDbOptions(cfg))
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := cryptdb.IsEncrypted(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if !encrypted {
+		return db, nil
+	}
+
+	// The database is encrypted, so reopen it with the passphrase.
+	if err := db.Close(); err != nil {
+		return nil, err
+	}
+	dbPass, err := obtainDbPass()
+	if err != nil {
+		return nil, err
+	}
+	defer zero.Bytes(dbPass)
+	return walletdb.Open("cryptdb", "bdb", dbPass, dbPath,
+		walletDbOptions(cfg))
+}
+
+// encryptWalletDb replaces the unencrypted wallet database in netDir with a
+// copy encrypted with the configured database passphrase, or one the user is
+// prompted for.  The copy is written to a new file which is renamed over the
+// original database once it has been completely written.
+func encryptWalletDb(cfg *config, netDir string) error {
+	dbPath := filepath.Join(netDir, walletDbName)
+	src, err := walletdb.OpenReadOnly("bdb", dbPath, walletDbOptions(cfg))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	encrypted, err := cryptdb.IsEncrypted(src)
+	if err != nil {
+		return err
+	}
+	if encrypted {
+		return errors.New("wallet database is already encrypted")
+	}
+
+	dbPass := []byte(cfg.DbPass)
+	if len(dbPass) == 0 {
+		reader := bufio.NewReader(os.Stdin)
+		dbPass, err = promptConsolePass(reader, "Enter the database "+
+			"passphrase for your wallet", true)
+		if err != nil {
+			return err
+		}
+		defer zero.Bytes(dbPass)
+	}
+
+	fmt.Println("Encrypting the wallet database...")
+	tmpPath := dbPath + ".encrypt"
+	dst, err := walletdb.Create("cryptdb", "bdb", dbPass, tmpPath,
+		walletDbOptions(cfg))
+	if err != nil {
+		return err
+	}
+	err = copyWalletDb(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		if rmErr := os.Remove(tmpPath); rmErr != nil {
+			log.Warnf("Cannot remove partially encrypted wallet %s: %v",
+				tmpPath, rmErr)
+		}
+		return err
+	}
+
+	// Replace the unencrypted database.  The source must be closed first
+	// on platforms which do not allow open files to be replaced.
+	if err := src.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		return err
+	}
+	if err := syncDir(netDir); err != nil {
+		return err
+	}
+
+	fmt.Println("The wallet database has been encrypted successfully.")
+	fmt.Println("NOTE: Backups of the wallet database written before " +
+		"upgrades are not encrypted and should be removed.")
+	if cfg.DbPass == "" {
+		fmt.Println("NOTE: Use the --dbpass option to configure your " +
+			"database passphrase, or enter it when prompted.")
+	}
+	return nil
+}
+
+// copyWalletDb copies every namespace of the wallet database src to the new
+// database dst in a single transaction.  This includes namespaces which are
+// not opened by the wallet itself, such as those of voting pools, so nothing
+// is lost when the database is replaced by the copy.
+func copyWalletDb(dst, src walletdb.DB) error {
+	// Namespaces must be created before the transaction is started.
+	var keys [][]byte
+	err := src.View(func(tx walletdb.DBTx) error {
+		return tx.ForEachNamespace(func(key []byte) error {
+			keys = append(keys, append([]byte(nil), key...))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if _, err := dst.Namespace(key); err != nil {
+			return fmt.Errorf("cannot create namespace %q: %v",
+				key, err)
+		}
+	}
+
+	return src.View(func(srcTx walletdb.DBTx) error {
+		return dst.Update(func(dstTx walletdb.DBTx) error {
+			for _, key := range keys {
+				err := copyBucket(dstTx.RootBucket(key),
+					srcTx.RootBucket(key))
+				if err != nil {
+					return fmt.Errorf("cannot copy "+
+						"namespace %q: %v", key, err)
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// copyBucket copies every key/value pair and nested bucket of src to dst.
+func copyBucket(dst, src walletdb.Bucket) error {
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(k, v)
+		}
+		nested, err := dst.CreateBucket(k)
+		if err != nil {
+			return err
+		}
+		return copyBucket(nested, src.Bucket(k))
+	})
+}
+
+// syncDir flushes the directory entries of path to disk so that files renamed
+// into the directory are not lost on a crash.  Directories can not be opened
+// for syncing on Windows, so this does nothing there.
+func syncDir(path string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// walletDbOptions returns the options used to create and open the wallet
+// database with the boltdb backend.  The driver defaults are used when the
+// configuration has not been loaded.
+func walletDbOptions(cfg *config) *bdb.Options {
+	opts := bdb.DefaultOptions
+	if cfg != nil {
+		// A zero timeout in the driver options uses the default, while
+		// the configuration uses zero to wait forever.
+		opts.Timeout = cfg.DbTimeout
+		if opts.Timeout == 0 {
+			opts.Timeout = -1
+		}
+		opts.NoSync = cfg.DbNoSync
+	}
+	return &opts
+}
+
+// walletsDirName is the name of the directory within the network directory
+// which holds a directory for each wallet loaded by name.
+const walletsDirName = "wallets"
+
+// namedWalletDir returns the directory holding the database of the wallet
+// with the given name.  The name must have already been checked with
+// validWalletName.
+func namedWalletDir(name string) string {
+	netdir := networkDir(cfg.DataDir, activeNet.Params)
+	return filepath.Join(netdir, walletsDirName, name)
+}
+
+// validWalletName returns whether name may be used as the name of a wallet.
+// Names are limited to ASCII letters, digits, underscores, and dashes so
+// they may always be safely used as a directory name.
+func validWalletName(name string) bool {
+	if len(name) == 0 || len(name) > 64 {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z':
+		case 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9':
+		case c == '_' || c == '-':
+		default:
+			return false
+		}
+	}
+	return true
 }
 
 // openWallet returns a wallet. The function handles opening an existing wallet
@@ -598,26 +991,87 @@ func openDb(directory string, dbname string) (walletdb.DB, error) {
 // to open a wallet.Wallet
 func openWallet() (*wallet.Wallet, walletdb.DB, error) {
 	netdir := networkDir(cfg.DataDir, activeNet.Params)
+	cbs := &waddrmgr.OpenCallbacks{
+		ObtainSeed:        promptSeed,
+		ObtainPrivatePass: promptPrivPassPhrase,
+	}
+	return openWalletDir(netdir, []byte(cfg.WalletPass), cbs,
+		promptDbPassPhrase)
+}
 
-	db, err := openDb(netdir, walletDbName)
+// openWalletDir opens the wallet database in directory and uses it to open a
+// wallet.Wallet.  The callbacks are used if the address manager requires an
+// upgrade which needs the seed or private passphrase.  obtainDbPass is used to
+// obtain the database passphrase of an encrypted database when one is not
+// configured.
+func openWalletDir(directory string, pubPass []byte,
+	cbs *waddrmgr.OpenCallbacks,
+	obtainDbPass func() ([]byte, error)) (*wallet.Wallet, walletdb.DB, error) {
+
+	db, err := openDb(directory, walletDbName, obtainDbPass)
 	if err != nil {
 		log.Errorf("Failed to open database: %v", err)
 		return nil, nil, err
 	}
+	if err := upgradeWalletDb(db, directory, pubPass, cbs); err != nil {
+		log.Errorf("Failed to upgrade database: %v", err)
+		db.Close()
+		return nil, nil, err
+	}
+	return loadWalletDb(db, pubPass, cbs)
+}
+
+// upgradeWalletDb upgrades the address manager and transaction store in the
+// wallet database to their latest versions in a single transaction.  If any
+// upgrades are needed, a backup of the database is first written to a new file
+// in directory.
+func upgradeWalletDb(db walletdb.DB, directory string, pubPass []byte,
+	cbs *waddrmgr.OpenCallbacks) error {
+
+	schemas := []*migration.Schema{
+		waddrmgr.MigrationSchema(waddrmgrNamespaceKey, pubPass,
+			activeNet.Params, cbs),
+		wtxmgr.MigrationSchema(wtxmgrNamespaceKey),
+	}
+	backup := func() (io.WriteCloser, error) {
+		name := fmt.Sprintf("%s.%d.bak", walletDbName, time.Now().Unix())
+		backupPath := filepath.Join(directory, name)
+		log.Infof("Backing up wallet database to %s before upgrading",
+			backupPath)
+		return os.OpenFile(backupPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+			0600)
+	}
+	applied, err := migration.Upgrade(db, schemas,
+		&migration.Options{Backup: backup})
+	if err != nil {
+		return err
+	}
+	for _, a := range applied {
+		log.Infof("Upgraded %v", a)
+	}
+	return nil
+}
+
+// loadWalletDb uses an open wallet database to open a wallet.Wallet.  The
+// database is closed if the wallet can not be opened.
+func loadWalletDb(db walletdb.DB, pubPass []byte,
+	cbs *waddrmgr.OpenCallbacks) (*wallet.Wallet, walletdb.DB, error) {
 
 	addrMgrNS, err := db.Namespace(waddrmgrNamespaceKey)
 	if err != nil {
+		db.Close()
 		return nil, nil, err
 	}
 	txMgrNS, err := db.Namespace(wtxmgrNamespaceKey)
 	if err != nil {
+		db.Close()
 		return nil, nil, err
 	}
-	cbs := &waddrmgr.OpenCallbacks{
-		ObtainSeed:        promptSeed,
-		ObtainPrivatePass: promptPrivPassPhrase,
+	w, err := wallet.Open(pubPass, activeNet.Params, db, addrMgrNS,
+		txMgrNS, cbs)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
 	}
-	w, err := wallet.Open([]byte(cfg.WalletPass), activeNet.Params, db,
-		addrMgrNS, txMgrNS, cbs)
-	return w, db, err
+	return w, db, nil
 }
@@ -0,0 +1,503 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcwallet/wallet"
+	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+const (
+	// webhookDbName is the name of the database, saved in the network
+	// directory, which holds the outbox of undelivered webhook events.
+	webhookDbName = "webhooks.db"
+
+	defaultWebhookMaxAttempts = 20
+
+	// webhookMinBackoff and webhookMaxBackoff bound the exponential delay
+	// between delivery attempts of a single event.
+	webhookMinBackoff = 5 * time.Second
+	webhookMaxBackoff = time.Hour
+
+	// webhookNotificationBuffer is the number of wallet notifications
+	// which may be waiting to be added to the outbox before further
+	// notifications are dropped.
+	webhookNotificationBuffer = 1000
+
+	// webhookTimeout is the time allowed for a receiver to accept a
+	// single POST before the delivery is considered failed.
+	webhookTimeout = 30 * time.Second
+)
+
+// Webhook event names.  These are sent both as the event field of the JSON
+// payload and as the X-Btcwallet-Event header.
+const (
+	webhookNewTx       = "newtx"
+	webhookTxConfirmed = "txconfirmed"
+	webhookLockState   = "walletlockstate"
+)
+
+// HTTP headers set on each webhook request.
+const (
+	webhookEventHeader     = "X-Btcwallet-Event"
+	webhookDeliveryHeader  = "X-Btcwallet-Delivery"
+	webhookSignatureHeader = "X-Btcwallet-Signature"
+)
+
+// Webhook outbox database layout.  All events waiting to be delivered are
+// saved in the outbox bucket keyed by the event ID (8 bytes) followed by the
+// index of the receiving URL (4 bytes), so a cursor iterates in the order the
+// events were created.  The ID of the most recently created event is saved in
+// the namespace root bucket.
+var (
+	webhookNamespaceKey = []byte("webhooks")
+	webhookOutboxBucket = []byte("outbox")
+	webhookLastIDKey    = []byte("lastid")
+)
+
+// webhookPayload is the JSON object POSTed to webhook receivers.
+type webhookPayload struct {
+	ID    uint64      `json:"id"`
+	Event string      `json:"event"`
+	Time  int64       `json:"time"`
+	Data  interface{} `json:"data"`
+}
+
+// webhookLockStateData is the data of a walletlockstate event.
+type webhookLockStateData struct {
+	Locked bool `json:"locked"`
+}
+
+// outboxEntry records a single event waiting to be delivered to a single
+// receiver.  The body is saved exactly as it was serialized so the signature
+// is unchanged between delivery attempts.
+type outboxEntry struct {
+	URL      string `json:"url"`
+	ID       uint64 `json:"id"`
+	Event    string `json:"event"`
+	Body     []byte `json:"body"`
+	Attempts uint32 `json:"attempts"`
+	NextTry  int64  `json:"nexttry"` // Unix nanoseconds
+}
+
+// webhookDispatcher POSTs wallet events to configured URLs.  Events are first
+// saved to a persistent outbox and are removed after a receiver responds
+// with a 2xx status, or after too many failed attempts.  Failed deliveries
+// are retried with an exponential backoff.
+type webhookDispatcher struct {
+	namespace   walletdb.Namespace
+	urls        []string
+	secret      []byte
+	maxAttempts uint32
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	client      *http.Client
+
+	// wake is signaled (without blocking) whenever new events are added
+	// to the outbox.
+	wake chan struct{}
+
+	wg      sync.WaitGroup
+	quit    chan struct{}
+	quitMtx sync.Mutex
+}
+
+// openWebhookDb opens the webhook outbox database at dbPath, creating it if
+// it does not yet exist.
+func openWebhookDb(dbPath string) (walletdb.DB, error) {
+	if !fileExists(dbPath) {
+		return walletdb.Create("bdb", dbPath, walletDbOptions(cfg))
+	}
+	return walletdb.Open("bdb", dbPath, walletDbOptions(cfg))
+}
+
+// newWebhookDispatcher creates a dispatcher for delivering events to each of
+// the passed URLs, saving the outbox in db.  Each payload is signed using an
+// HMAC-SHA256 keyed by secret.
+func newWebhookDispatcher(db walletdb.DB, urls []string, secret []byte,
+	maxAttempts uint32) (*webhookDispatcher, error) {
+
+	namespace, err := db.Namespace(webhookNamespaceKey)
+	if err != nil {
+		return nil, err
+	}
+	err = namespace.Update(func(tx walletdb.Tx) error {
+		_, err := tx.RootBucket().CreateBucketIfNotExists(webhookOutboxBucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d := &webhookDispatcher{
+		namespace:   namespace,
+		urls:        urls,
+		secret:      secret,
+		maxAttempts: maxAttempts,
+		minBackoff:  webhookMinBackoff,
+		maxBackoff:  webhookMaxBackoff,
+		client:      &http.Client{Timeout: webhookTimeout},
+		wake:        make(chan struct{}, 1),
+		quit:        make(chan struct{}),
+	}
+	return d, nil
+}
+
+// Start subscribes to transaction and lock state notifications from w and
+// begins delivering all events in the outbox, including any which were left
+// undelivered by a previous run.
+func (d *webhookDispatcher) Start(w *wallet.Wallet) {
+	sub := w.Subscribe(wallet.NtfnRelevantTx|wallet.NtfnLockState,
+		webhookNotificationBuffer, wallet.DropNewest)
+
+	d.wg.Add(2)
+	go d.notificationHandler(w, sub)
+	go d.deliveryHandler()
+}
+
+// Stop signals the delivery goroutine to quit.  Undelivered events remain in
+// the outbox and are sent the next time the dispatcher is started.
+func (d *webhookDispatcher) Stop() {
+	d.quitMtx.Lock()
+	defer d.quitMtx.Unlock()
+
+	select {
+	case <-d.quit:
+	default:
+		close(d.quit)
+	}
+}
+
+// WaitForShutdown blocks until the delivery goroutine finishes.
+func (d *webhookDispatcher) WaitForShutdown() {
+	d.wg.Wait()
+}
+
+// notificationHandler adds an outbox event for each wallet notification
+// received from sub until the dispatcher is stopped or the subscription is
+// closed by the wallet.
+func (d *webhookDispatcher) notificationHandler(w *wallet.Wallet, sub *wallet.Subscription) {
+out:
+	for {
+		select {
+		case n, ok := <-sub.C:
+			if !ok {
+				break out
+			}
+			d.notify(w, n)
+		case <-d.quit:
+			break out
+		}
+	}
+	sub.Close()
+	if dropped := sub.Dropped(); dropped != 0 {
+		log.Warnf("%d wallet notifications were dropped before "+
+			"webhook events could be created", dropped)
+	}
+	d.wg.Done()
+}
+
+// notify creates webhook events for the subset of wallet notifications which
+// are interesting to webhook receivers.  Transaction notifications result in
+// a newtx event for unmined transactions and a txconfirmed event when the
+// transaction is mined, and lock state changes result in a walletlockstate
+// event.  All other notifications are ignored.
+func (d *webhookDispatcher) notify(w *wallet.Wallet, n interface{}) {
+	var event string
+	var data interface{}
+	switch n := n.(type) {
+	case wallet.RelevantTxNotification:
+		var block *wtxmgr.Block
+		event = webhookNewTx
+		if n.Block != nil {
+			block = &n.Block.Block
+			event = webhookTxConfirmed
+		}
+		details, err := w.TxStore.UniqueTxDetails(&n.TxRecord.Hash, block)
+		if err != nil {
+			log.Errorf("Cannot fetch transaction details for "+
+				"webhook event: %v", err)
+			return
+		}
+		if details == nil {
+			log.Errorf("No details found for webhook transaction event")
+			return
+		}
+		syncBlock := w.Manager.SyncedTo()
+		data = wallet.ListTransactions(details, syncBlock.Height,
+			activeNet.Params)
+
+	case wallet.LockStateNotification:
+		event = webhookLockState
+		data = webhookLockStateData{Locked: bool(n)}
+
+	default:
+		return
+	}
+
+	err := d.enqueue(event, data)
+	if err != nil {
+		log.Errorf("Cannot add %s event to webhook outbox: %v", event, err)
+	}
+}
+
+// enqueue saves an event for each receiver to the outbox and wakes the
+// delivery goroutine.
+func (d *webhookDispatcher) enqueue(event string, data interface{}) error {
+	now := time.Now()
+	err := d.namespace.Update(func(tx walletdb.Tx) error {
+		ns := tx.RootBucket()
+
+		var id uint64
+		if v := ns.Get(webhookLastIDKey); len(v) == 8 {
+			id = binary.BigEndian.Uint64(v)
+		}
+		id++
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, id)
+		err := ns.Put(webhookLastIDKey, v)
+		if err != nil {
+			return err
+		}
+
+		body, err := json.Marshal(&webhookPayload{
+			ID:    id,
+			Event: event,
+			Time:  now.Unix(),
+			Data:  data,
+		})
+		if err != nil {
+			return err
+		}
+
+		outbox := ns.Bucket(webhookOutboxBucket)
+		for i, url := range d.urls {
+			entry := outboxEntry{
+				URL:     url,
+				ID:      id,
+				Event:   event,
+				Body:    body,
+				NextTry: now.UnixNano(),
+			}
+			err := putOutboxEntry(outbox, outboxKey(id, uint32(i)), &entry)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func outboxKey(id uint64, urlIndex uint32) []byte {
+	k := make([]byte, 12)
+	binary.BigEndian.PutUint64(k, id)
+	binary.BigEndian.PutUint32(k[8:12], urlIndex)
+	return k
+}
+
+func putOutboxEntry(outbox walletdb.Bucket, k []byte, entry *outboxEntry) error {
+	v, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return outbox.Put(k, v)
+}
+
+// deliveryHandler attempts delivery of each outbox event once it is due,
+// sleeping until either the next retry is due or a new event is added.
+func (d *webhookDispatcher) deliveryHandler() {
+	var retry <-chan time.Time
+	wake := make(chan struct{}, 1)
+	wake <- struct{}{} // Deliver anything left from a previous run.
+out:
+	for {
+		select {
+		case <-wake:
+		case <-d.wake:
+		case <-retry:
+		case <-d.quit:
+			break out
+		}
+
+		nextTry, err := d.deliverDue()
+		if err != nil {
+			log.Errorf("Webhook delivery failed: %v", err)
+		}
+		retry = nil
+		if !nextTry.IsZero() {
+			retry = time.After(nextTry.Sub(time.Now()))
+		}
+	}
+	d.wg.Done()
+}
+
+// deliverDue attempts delivery of every outbox event with a passed retry
+// time.  Successfully delivered events and events which have exceeded the
+// maximum number of attempts are removed, while the retry time of all other
+// failed events is pushed back.  The earliest retry time of all events
+// remaining in the outbox is returned, or the zero time if it is empty.
+func (d *webhookDispatcher) deliverDue() (time.Time, error) {
+	type dueEntry struct {
+		key   []byte
+		entry outboxEntry
+	}
+	var due []dueEntry
+	now := time.Now().UnixNano()
+	err := d.namespace.View(func(tx walletdb.Tx) error {
+		outbox := tx.RootBucket().Bucket(webhookOutboxBucket)
+		return outbox.ForEach(func(k, v []byte) error {
+			var e dueEntry
+			err := json.Unmarshal(v, &e.entry)
+			if err != nil {
+				return err
+			}
+			if e.entry.NextTry <= now {
+				e.key = append([]byte(nil), k...)
+				due = append(due, e)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for i := range due {
+		select {
+		case <-d.quit:
+			return time.Time{}, nil
+		default:
+		}
+
+		e := &due[i]
+		postErr := d.post(&e.entry)
+		err := d.namespace.Update(func(tx walletdb.Tx) error {
+			outbox := tx.RootBucket().Bucket(webhookOutboxBucket)
+			if postErr == nil {
+				log.Debugf("Delivered webhook event %d to %s",
+					e.entry.ID, e.entry.URL)
+				return outbox.Delete(e.key)
+			}
+			e.entry.Attempts++
+			if e.entry.Attempts >= d.maxAttempts {
+				log.Errorf("Dropping webhook event %d for %s after "+
+					"%d failed attempts: %v", e.entry.ID,
+					e.entry.URL, e.entry.Attempts, postErr)
+				return outbox.Delete(e.key)
+			}
+			backoff := d.backoff(e.entry.Attempts)
+			log.Warnf("Webhook event %d for %s failed (retrying in "+
+				"%v): %v", e.entry.ID, e.entry.URL, backoff, postErr)
+			e.entry.NextTry = time.Now().Add(backoff).UnixNano()
+			return putOutboxEntry(outbox, e.key, &e.entry)
+		})
+		if err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	var nextTry int64
+	err = d.namespace.View(func(tx walletdb.Tx) error {
+		outbox := tx.RootBucket().Bucket(webhookOutboxBucket)
+		return outbox.ForEach(func(k, v []byte) error {
+			var entry outboxEntry
+			err := json.Unmarshal(v, &entry)
+			if err != nil {
+				return err
+			}
+			if nextTry == 0 || entry.NextTry < nextTry {
+				nextTry = entry.NextTry
+			}
+			return nil
+		})
+	})
+	if err != nil || nextTry == 0 {
+		return time.Time{}, err
+	}
+	return time.Unix(0, nextTry), nil
+}
+
+// backoff returns the delay before the next delivery attempt after some
+// number of failed attempts.
+func (d *webhookDispatcher) backoff(attempts uint32) time.Duration {
+	backoff := d.minBackoff
+	for i := uint32(1); i < attempts && backoff < d.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > d.maxBackoff {
+		backoff = d.maxBackoff
+	}
+	return backoff
+}
+
+// signWebhookBody returns the hex encoded HMAC-SHA256 of body keyed by
+// secret.  Receivers should compute the same and compare it against the
+// X-Btcwallet-Signature header (after the "sha256=" prefix) to authenticate
+// a request.
+func signWebhookBody(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// post sends a single event to its receiver.  Any non-2xx response status is
+// returned as an error.
+func (d *webhookDispatcher) post(entry *outboxEntry) error {
+	req, err := http.NewRequest("POST", entry.URL, bytes.NewReader(entry.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, entry.Event)
+	req.Header.Set(webhookDeliveryHeader, strconv.FormatUint(entry.ID, 10))
+	req.Header.Set(webhookSignatureHeader,
+		"sha256="+signWebhookBody(d.secret, entry.Body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	// Drain the body so the connection may be reused.
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("receiver responded with status %s", resp.Status)
+	}
+	return nil
+}
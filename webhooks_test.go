@@ -0,0 +1,235 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// webhookRequest is a request as seen by a test receiver.
+type webhookRequest struct {
+	event     string
+	signature string
+	body      []byte
+}
+
+// newTestReceiver returns an HTTP test server which fails the first
+// failures requests with a 500 status and records the rest.
+func newTestReceiver(failures int) (*httptest.Server, <-chan webhookRequest) {
+	c := make(chan webhookRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if failures > 0 {
+				failures--
+				http.Error(w, "try again", http.StatusInternalServerError)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			c <- webhookRequest{
+				event:     r.Header.Get(webhookEventHeader),
+				signature: r.Header.Get(webhookSignatureHeader),
+				body:      body,
+			}
+		}))
+	return srv, c
+}
+
+func openTestWebhookDb(t *testing.T) (walletdb.DB, func()) {
+	dir, err := ioutil.TempDir("", "webhooks_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openWebhookDb(filepath.Join(dir, webhookDbName))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return db, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	db, teardown := openTestWebhookDb(t)
+	defer teardown()
+
+	srv, reqs := newTestReceiver(2)
+	defer srv.Close()
+
+	secret := []byte("secret")
+	d, err := newWebhookDispatcher(db, []string{srv.URL}, secret, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.minBackoff = 10 * time.Millisecond
+	d.wg.Add(1)
+	go d.deliveryHandler()
+	defer func() {
+		d.Stop()
+		d.WaitForShutdown()
+	}()
+
+	err = d.enqueue(webhookLockState, webhookLockStateData{Locked: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req webhookRequest
+	select {
+	case req = <-reqs:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for webhook delivery")
+	}
+
+	if req.event != webhookLockState {
+		t.Errorf("event header: got %q, want %q", req.event,
+			webhookLockState)
+	}
+	wantSig := "sha256=" + signWebhookBody(secret, req.body)
+	if req.signature != wantSig {
+		t.Errorf("signature header: got %q, want %q", req.signature,
+			wantSig)
+	}
+	var payload struct {
+		ID    uint64               `json:"id"`
+		Event string               `json:"event"`
+		Data  webhookLockStateData `json:"data"`
+	}
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.ID != 1 || payload.Event != webhookLockState ||
+		!payload.Data.Locked {
+		t.Errorf("unexpected payload: %s", req.body)
+	}
+}
+
+func TestWebhookOutboxPersists(t *testing.T) {
+	db, teardown := openTestWebhookDb(t)
+	defer teardown()
+
+	srv, reqs := newTestReceiver(0)
+	defer srv.Close()
+
+	// Events added while the dispatcher is not running must be delivered
+	// once it is started.
+	d, err := newWebhookDispatcher(db, []string{srv.URL}, nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, locked := range []bool{true, false} {
+		err := d.enqueue(webhookLockState,
+			webhookLockStateData{Locked: locked})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d, err = newWebhookDispatcher(db, []string{srv.URL}, nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.wg.Add(1)
+	go d.deliveryHandler()
+	defer func() {
+		d.Stop()
+		d.WaitForShutdown()
+	}()
+
+	for i := uint64(1); i <= 2; i++ {
+		select {
+		case req := <-reqs:
+			var payload webhookPayload
+			if err := json.Unmarshal(req.body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			if payload.ID != i {
+				t.Errorf("got event %d, want %d", payload.ID, i)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for webhook delivery")
+		}
+	}
+}
+
+func TestWebhookDropAfterMaxAttempts(t *testing.T) {
+	db, teardown := openTestWebhookDb(t)
+	defer teardown()
+
+	srv, _ := newTestReceiver(1 << 30)
+	defer srv.Close()
+
+	d, err := newWebhookDispatcher(db, []string{srv.URL}, nil, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.minBackoff = time.Millisecond
+	err = d.enqueue(webhookLockState, webhookLockStateData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(d.backoff(uint32(i)))
+		if _, err := d.deliverDue(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = d.namespace.View(func(tx walletdb.Tx) error {
+		outbox := tx.RootBucket().Bucket(webhookOutboxBucket)
+		if k, _ := outbox.Cursor().First(); k != nil {
+			t.Errorf("event still in outbox after max attempts")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	d := &webhookDispatcher{
+		minBackoff: time.Second,
+		maxBackoff: 10 * time.Second,
+	}
+	tests := []struct {
+		attempts uint32
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{100, 10 * time.Second},
+	}
+	for _, test := range tests {
+		if got := d.backoff(test.attempts); got != test.want {
+			t.Errorf("backoff(%d): got %v, want %v", test.attempts,
+				got, test.want)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// AddressCredit describes a mined or unmined credit paying to an output script,
+// as recorded by the address index.
+type AddressCredit struct {
+	OutPoint wire.OutPoint
+	Block    Block // Height is -1 for unmined credits
+	Amount   btcutil.Amount
+}
+
+// addressCreditsByHeight sorts address credits by increasing block height, with
+// unmined credits last.
+type addressCreditsByHeight []AddressCredit
+
+func (c addressCreditsByHeight) Len() int      { return len(c) }
+func (c addressCreditsByHeight) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+func (c addressCreditsByHeight) Less(i, j int) bool {
+	hi, hj := c[i].Block.Height, c[j].Block.Height
+	if hi == -1 || hj == -1 {
+		return hj == -1 && hi != -1
+	}
+	return hi < hj
+}
+
+// AddressCredits returns every credit paying to any address of the output
+// scripts pkScripts, in increasing order of block height with unmined credits
+// last.  Callers look up the credits of an address by passing the output
+// script which pays to it.  Credits are matched by the public keys and scripts
+// they pay to rather than the exact output script, so the pay-to-pubkey-hash
+// script of a key also returns pay-to-pubkey and bare multisig credits paying
+// to the key.  Each credit is returned once, even if it matches several
+// scripts.
+func (s *Store) AddressCredits(pkScripts ...[]byte) ([]AddressCredit, error) {
+	var credits []AddressCredit
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		seenIDs := make(map[string]struct{}, len(pkScripts))
+		seenCredits := make(map[wire.OutPoint]struct{})
+		c := ns.Bucket(bucketAddrCredits).Cursor()
+		for _, pkScript := range pkScripts {
+			for _, prefix := range addrCreditIDs(pkScript) {
+				if _, ok := seenIDs[string(prefix)]; ok {
+					continue
+				}
+				seenIDs[string(prefix)] = struct{}{}
+
+				for k, v := c.Seek(prefix); bytes.HasPrefix(k, prefix); k, v = c.Next() {
+					var credit AddressCredit
+					err := readRawAddrCredit(k, v, &credit)
+					if err != nil {
+						return err
+					}
+					if _, ok := seenCredits[credit.OutPoint]; ok {
+						continue
+					}
+					seenCredits[credit.OutPoint] = struct{}{}
+					credits = append(credits, credit)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Stable(addressCreditsByHeight(credits))
+	return credits, nil
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr_test
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+	. "github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestAddressCredits(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("txstore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Create(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scriptA := []byte{0x51}
+	scriptB := []byte{0x52}
+
+	checkCredits := func(pkScript []byte, exp ...AddressCredit) {
+		credits, err := s.AddressCredits(pkScript)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(credits) != len(exp) {
+			t.Fatalf("AddressCredits: got %d credits, expected %d",
+				len(credits), len(exp))
+		}
+		for i := range credits {
+			if credits[i] != exp[i] {
+				t.Errorf("AddressCredits: credit %d is %v, "+
+					"expected %v", i, credits[i], exp[i])
+			}
+		}
+	}
+
+	fund := spendOutput(&wire.ShaHash{}, 0, 1e8, 2e8)
+	fund.TxOut[0].PkScript = scriptA
+	fund.TxOut[1].PkScript = scriptB
+	fundRec, err := NewTxRecordFromMsgTx(fund, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(fundRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := range fund.TxOut {
+		err := s.AddCredit(fundRec, nil, uint32(i), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	unmined := Block{Height: -1}
+	fundA := AddressCredit{
+		OutPoint: wire.OutPoint{Hash: fundRec.Hash, Index: 0},
+		Block:    unmined,
+		Amount:   1e8,
+	}
+	fundB := AddressCredit{
+		OutPoint: wire.OutPoint{Hash: fundRec.Hash, Index: 1},
+		Block:    unmined,
+		Amount:   2e8,
+	}
+	checkCredits(scriptA, fundA)
+	checkCredits(scriptB, fundB)
+	checkCredits([]byte{0x53})
+	credits, err := s.AddressCredits(scriptA, scriptB, scriptA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credits) != 2 {
+		t.Errorf("AddressCredits: got %d credits for multiple scripts, "+
+			"expected 2", len(credits))
+	}
+
+	// Mining the transaction records the block of its credits.
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(fundRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	fundA.Block = b100.Block
+	fundB.Block = b100.Block
+	checkCredits(scriptA, fundA)
+	checkCredits(scriptB, fundB)
+
+	spend := spendOutput(&fundRec.Hash, 0, 5e7)
+	spend.TxOut[0].PkScript = scriptA
+	spendRec, err := NewTxRecordFromMsgTx(spend, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b101 := makeBlockMeta(101)
+	if err := s.InsertTx(spendRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(spendRec, &b101, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	spendA := AddressCredit{
+		OutPoint: wire.OutPoint{Hash: spendRec.Hash},
+		Block:    b101.Block,
+		Amount:   5e7,
+	}
+	checkCredits(scriptA, fundA, spendA)
+
+	// Removing the block moves the credit back to unmined.
+	if err := s.Rollback(101); err != nil {
+		t.Fatal(err)
+	}
+	spendA.Block = unmined
+	checkCredits(scriptA, fundA, spendA)
+
+	// Credits of a transaction removed as a double spend are removed.
+	doubleSpendRec, err := NewTxRecordFromMsgTx(spendOutput(&fundRec.Hash, 0, 9e7), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(doubleSpendRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+	checkCredits(scriptA, fundA)
+
+	// Removing a block with a coinbase removes the coinbase credits.
+	cb := newCoinBase(25e8)
+	cb.TxOut[0].PkScript = scriptB
+	cbRec, err := NewTxRecordFromMsgTx(cb, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b102 := makeBlockMeta(102)
+	if err := s.InsertTx(cbRec, &b102); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(cbRec, &b102, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	cbB := AddressCredit{
+		OutPoint: wire.OutPoint{Hash: cbRec.Hash},
+		Block:    b102.Block,
+		Amount:   25e8,
+	}
+	checkCredits(scriptB, fundB, cbB)
+	if err := s.Rollback(102); err != nil {
+		t.Fatal(err)
+	}
+	checkCredits(scriptB, fundB)
+}
+
+// payToPubKeyHashScript returns the pay-to-pubkey-hash output script paying to
+// the serialized public key pubKey.
+func payToPubKeyHashScript(pubKey []byte) []byte {
+	script := []byte{0x76, 0xa9, 0x14} // OP_DUP OP_HASH160 OP_DATA_20
+	script = append(script, btcutil.Hash160(pubKey)...)
+	return append(script, 0x88, 0xac) // OP_EQUALVERIFY OP_CHECKSIG
+}
+
+func TestAddressCreditsByKey(t *testing.T) {
+	t.Parallel()
+
+	s, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pubKeyA, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	pubKeyB, _ := hex.DecodeString("02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5")
+	scriptA := payToPubKeyHashScript(pubKeyA)
+	scriptB := payToPubKeyHashScript(pubKeyB)
+
+	// A pay-to-pubkey coinbase output and a 1-of-2 bare multisig output.
+	cb := newCoinBase(25e8)
+	cb.TxOut[0].PkScript = append(append([]byte{0x21}, pubKeyA...), 0xac)
+	cbRec, err := NewTxRecordFromMsgTx(cb, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	multisig := spendOutput(&wire.ShaHash{}, 0, 1e8)
+	multisig.TxOut[0].PkScript = append(append(append(append(
+		[]byte{0x51, 0x21}, pubKeyA...), 0x21), pubKeyB...), 0x52, 0xae)
+	multisigRec, err := NewTxRecordFromMsgTx(multisig, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b100 := makeBlockMeta(100)
+	b101 := makeBlockMeta(101)
+	for _, r := range []struct {
+		rec   *TxRecord
+		block *BlockMeta
+	}{{cbRec, &b100}, {multisigRec, &b101}} {
+		if err := s.InsertTx(r.rec, r.block); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddCredit(r.rec, r.block, 0, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cbA := AddressCredit{
+		OutPoint: wire.OutPoint{Hash: cbRec.Hash},
+		Block:    b100.Block,
+		Amount:   25e8,
+	}
+	multisigAB := AddressCredit{
+		OutPoint: wire.OutPoint{Hash: multisigRec.Hash},
+		Block:    b101.Block,
+		Amount:   1e8,
+	}
+
+	checkCredits := func(pkScripts [][]byte, exp ...AddressCredit) {
+		credits, err := s.AddressCredits(pkScripts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(credits) != len(exp) {
+			t.Fatalf("AddressCredits: got %d credits, expected %d",
+				len(credits), len(exp))
+		}
+		for i := range credits {
+			if credits[i] != exp[i] {
+				t.Errorf("AddressCredits: credit %d is %v, "+
+					"expected %v", i, credits[i], exp[i])
+			}
+		}
+	}
+	checkCredits([][]byte{scriptA}, cbA, multisigAB)
+	checkCredits([][]byte{scriptB}, multisigAB)
+	checkCredits([][]byte{scriptB, scriptA}, cbA, multisigAB)
+
+	// Removing the blocks removes the coinbase credit from the index.
+	if err := s.Rollback(100); err != nil {
+		t.Fatal(err)
+	}
+	multisigAB.Block = Block{Height: -1}
+	checkCredits([][]byte{scriptA}, multisigAB)
+}
+
+func TestUpgradeAddressCredits(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("txstore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Create(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	script := []byte{0x51}
+	fund := spendOutput(&wire.ShaHash{}, 0, 1e8)
+	fund.TxOut[0].PkScript = script
+	fundRec, err := NewTxRecordFromMsgTx(fund, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(fundRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(fundRec, &b100, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	spend := spendOutput(&fundRec.Hash, 0, 5e7)
+	spend.TxOut[0].PkScript = script
+	spendRec, err := NewTxRecordFromMsgTx(spend, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(spendRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(spendRec, nil, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	exp, err := s.AddressCredits(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exp) != 2 {
+		t.Fatalf("AddressCredits: got %d credits, expected 2", len(exp))
+	}
+
+	// Revert the store to version 5, which did not index credits by
+	// address.
+	if err := revertStore(ns, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// The upgrade must index the existing mined and unmined credits.
+	s, err = Open(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credits, err := s.AddressCredits(script)
+	if err != nil {
+		t.Fatalf("AddressCredits after upgrade: %v", err)
+	}
+	if len(credits) != len(exp) {
+		t.Fatalf("AddressCredits: got %d credits after upgrade, "+
+			"expected %d", len(credits), len(exp))
+	}
+	for i := range credits {
+		if credits[i] != exp[i] {
+			t.Errorf("AddressCredits: credit %d is %v after upgrade, "+
+				"expected %v", i, credits[i], exp[i])
+		}
+	}
+}
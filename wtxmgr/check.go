@@ -0,0 +1,386 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// Problem describes an inconsistency found in the store by Check.
+type Problem struct {
+	Desc     string // Human readable description of the problem
+	Repaired bool   // Whether the problem was repaired
+}
+
+// String returns a human-readable description of the problem.
+func (p Problem) String() string {
+	if p.Repaired {
+		return p.Desc + " (repaired)"
+	}
+	return p.Desc
+}
+
+// checker records the problems found by Check.  Repairs are queued while
+// iterating over a bucket and run afterwards, since buckets may not be
+// modified while a cursor is iterating over them.
+type checker struct {
+	ns       walletdb.Bucket
+	repair   bool
+	problems []Problem
+	fixes    []func() error
+}
+
+func (c *checker) report(format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{Desc: fmt.Sprintf(format, args...)})
+}
+
+// reportRepairable records a problem which is repaired by fix.
+func (c *checker) reportRepairable(fix func() error, format string, args ...interface{}) {
+	c.problems = append(c.problems, Problem{
+		Desc:     fmt.Sprintf(format, args...),
+		Repaired: c.repair,
+	})
+	c.fixes = append(c.fixes, fix)
+}
+
+// runFixes runs all queued repairs if the checker is repairing the store.
+func (c *checker) runFixes() error {
+	fixes := c.fixes
+	c.fixes = nil
+	if !c.repair {
+		return nil
+	}
+	for _, fix := range fixes {
+		if err := fix(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check verifies the invariants of the store's mined transaction history:
+//
+//   - Every unspent index entry refers to an existing, unspent credit
+//   - Every unspent credit is recorded in the unspent index
+//   - Every debit refers to a credit of the same amount which is marked spent
+//     by the debit, and every spent credit refers to an existing debit
+//   - Every credit and debit belongs to an existing transaction record
+//   - Every transaction record is listed by the block record for its block,
+//     and every transaction listed by a block record exists
+//   - The mined balance is the total of all unspent mined credits
+//
+// When repair is true, problems which can be repaired from the remaining
+// records are fixed in the same database transaction used to check the store.
+// Records which refer to missing transactions, or missing block records, can
+// not be repaired and are only reported.  These require rebuilding the
+// transaction history with a rescan.
+func (s *Store) Check(repair bool) ([]Problem, error) {
+	var problems []Problem
+	check := func(ns walletdb.Bucket) error {
+		c := &checker{ns: ns, repair: repair}
+		steps := []func() error{
+			c.checkDebits,
+			c.checkUnspent,
+			c.checkCredits,
+			c.checkBlocks,
+			c.checkTxRecords,
+			c.checkMinedBalance,
+		}
+		for _, step := range steps {
+			if err := step(); err != nil {
+				return err
+			}
+			if err := c.runFixes(); err != nil {
+				return err
+			}
+		}
+		problems = c.problems
+		return nil
+	}
+
+	var err error
+	if repair {
+		err = scopedUpdate(s.namespace, check)
+	} else {
+		err = scopedView(s.namespace, check)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+// outPointString returns a string describing the outpoint of the credit or
+// debit with a raw credits or debits bucket key.
+func outPointString(k []byte) string {
+	var txHash wire.ShaHash
+	copy(txHash[:], k[:32])
+	return fmt.Sprintf("%v:%d", &txHash, byteOrder.Uint32(k[68:72]))
+}
+
+// checkDebits checks that every debit refers to a transaction record and to a
+// credit of the same amount which is marked spent by the debit.
+func (c *checker) checkDebits() error {
+	return c.ns.Bucket(bucketDebits).ForEach(func(k, v []byte) error {
+		if len(k) < 72 || len(v) < 80 {
+			c.report("debit %x has a malformed key or value", k)
+			return nil
+		}
+		if existsRawTxRecord(c.ns, k[:68]) == nil {
+			c.report("debit %v has no transaction record",
+				outPointString(k))
+		}
+
+		credKey := extractRawDebitCreditKey(v)
+		credVal := existsRawCredit(c.ns, credKey)
+		if len(credVal) < 9 {
+			c.report("debit %v spends missing credit %v",
+				outPointString(k), outPointString(credKey))
+			return nil
+		}
+		debitAmount := btcutil.Amount(byteOrder.Uint64(v))
+		creditAmount := btcutil.Amount(byteOrder.Uint64(credVal))
+		if debitAmount != creditAmount {
+			c.report("debit %v amount %v does not match credit %v "+
+				"amount %v", outPointString(k), debitAmount,
+				outPointString(credKey), creditAmount)
+		}
+
+		spent := credVal[8]&(1<<0) != 0
+		if spent && len(credVal) >= 81 && bytes.Equal(credVal[9:81], k) {
+			return nil
+		}
+		var spender indexedIncidence
+		copy(spender.txHash[:], k[:32])
+		spender.block.Height = int32(byteOrder.Uint32(k[32:36]))
+		copy(spender.block.Hash[:], k[36:68])
+		spender.index = byteOrder.Uint32(k[68:72])
+		credKey = append([]byte(nil), credKey...)
+		c.reportRepairable(func() error {
+			_, err := spendCredit(c.ns, credKey, &spender)
+			return err
+		}, "credit %v is not marked spent by debit %v",
+			outPointString(credKey), outPointString(k))
+		return nil
+	})
+}
+
+// checkUnspent checks that every unspent index entry refers to an existing
+// credit which is not spent by a mined transaction.
+func (c *checker) checkUnspent() error {
+	return c.ns.Bucket(bucketUnspent).ForEach(func(k, v []byte) error {
+		k = append([]byte(nil), k...)
+		remove := func() error { return deleteRawUnspent(c.ns, k) }
+
+		credKey := existsRawUnspent(c.ns, k)
+		if credKey == nil {
+			c.reportRepairable(remove, "unspent output %x has a "+
+				"malformed key or value", k)
+			return nil
+		}
+		credVal := existsRawCredit(c.ns, credKey)
+		if len(credVal) < 9 {
+			c.reportRepairable(remove, "unspent output %v refers "+
+				"to missing credit", outPointString(credKey))
+			return nil
+		}
+		if credVal[8]&(1<<0) != 0 {
+			c.reportRepairable(remove, "unspent output %v refers "+
+				"to spent credit", outPointString(credKey))
+		}
+		return nil
+	})
+}
+
+// checkCredits checks that every credit refers to a transaction record, that
+// spent credits refer to existing debits, and that unspent credits are
+// recorded in the unspent index.
+func (c *checker) checkCredits() error {
+	return c.ns.Bucket(bucketCredits).ForEach(func(k, v []byte) error {
+		if len(k) < 72 || len(v) < 9 {
+			c.report("credit %x has a malformed key or value", k)
+			return nil
+		}
+		if existsRawTxRecord(c.ns, k[:68]) == nil {
+			c.report("credit %v has no transaction record",
+				outPointString(k))
+		}
+
+		k = append([]byte(nil), k...)
+		var op wire.OutPoint
+		copy(op.Hash[:], k[:32])
+		op.Index = extractRawCreditIndex(k)
+		var block Block
+		block.Height = int32(byteOrder.Uint32(k[32:36]))
+		copy(block.Hash[:], k[36:68])
+
+		spent := v[8]&(1<<0) != 0
+		if spent {
+			if len(v) >= 81 && c.ns.Bucket(bucketDebits).Get(v[9:81]) != nil {
+				return nil
+			}
+			c.reportRepairable(func() error {
+				if _, err := unspendRawCredit(c.ns, k); err != nil {
+					return err
+				}
+				return putUnspent(c.ns, &op, &block)
+			}, "spent credit %v refers to missing debit",
+				outPointString(k))
+			return nil
+		}
+
+		_, credKey := existsUnspent(c.ns, &op)
+		if !bytes.Equal(credKey, k) {
+			c.reportRepairable(func() error {
+				return putUnspent(c.ns, &op, &block)
+			}, "unspent credit %v is missing from the unspent index",
+				outPointString(k))
+		}
+		return nil
+	})
+}
+
+// checkBlocks checks that every transaction listed by a block record exists.
+// Missing transactions are removed from the block record, and block records
+// with no remaining transactions are removed.
+func (c *checker) checkBlocks() error {
+	return c.ns.Bucket(bucketBlocks).ForEach(func(k, v []byte) error {
+		var rec blockRecord
+		if err := readRawBlockRecord(k, v, &rec); err != nil {
+			c.report("block record %x is malformed: %v", k, err)
+			return nil
+		}
+
+		var missing []string
+		newv := make([]byte, 44, len(v))
+		copy(newv, v[:44])
+		for i := range rec.transactions {
+			txHash := &rec.transactions[i]
+			if _, v := existsTxRecord(c.ns, txHash, &rec.Block); v != nil {
+				newv = append(newv, txHash[:]...)
+				continue
+			}
+			missing = append(missing, txHash.String())
+		}
+		if len(missing) == 0 {
+			return nil
+		}
+
+		// Repair all missing transactions of the block together by
+		// rewriting the block record with the remaining transactions.
+		k = append([]byte(nil), k...)
+		n := (len(newv) - 44) / wire.HashSize
+		byteOrder.PutUint32(newv[40:44], uint32(n))
+		c.reportRepairable(func() error {
+			if n == 0 {
+				err := c.ns.Bucket(bucketBlocks).Delete(k)
+				if err != nil {
+					str := "failed to delete block record"
+					return storeError(ErrDatabase, str, err)
+				}
+				return nil
+			}
+			return putRawBlockRecord(c.ns, k, newv)
+		}, "block %d lists missing transactions %v", rec.Height,
+			missing)
+		return nil
+	})
+}
+
+// checkTxRecords checks that every mined transaction record is listed by the
+// block record for its block.
+func (c *checker) checkTxRecords() error {
+	return c.ns.Bucket(bucketTxRecords).ForEach(func(k, v []byte) error {
+		var block Block
+		if err := readRawTxRecordBlock(k, &block); err != nil {
+			c.report("transaction record %x is malformed: %v", k, err)
+			return nil
+		}
+		var txHash wire.ShaHash
+		copy(txHash[:], k[:32])
+
+		blockKey, blockVal := existsBlockRecord(c.ns, block.Height)
+		var rec blockRecord
+		if err := readRawBlockRecord(blockKey, blockVal, &rec); err != nil {
+			c.report("transaction %v has no block record for block %d",
+				&txHash, block.Height)
+			return nil
+		}
+		if rec.Hash != block.Hash {
+			c.report("transaction %v block %v does not match block "+
+				"record hash %v at height %d", &txHash, &block.Hash,
+				&rec.Hash, block.Height)
+			return nil
+		}
+		for i := range rec.transactions {
+			if rec.transactions[i] == txHash {
+				return nil
+			}
+		}
+		c.reportRepairable(func() error {
+			// Read the block record again, since it may have been
+			// modified by an earlier repair.
+			blockKey, blockVal := existsBlockRecord(c.ns, block.Height)
+			newv, err := appendRawBlockRecord(blockVal, &txHash)
+			if err != nil {
+				return err
+			}
+			return putRawBlockRecord(c.ns, blockKey, newv)
+		}, "transaction %v is not listed by block %d", &txHash,
+			block.Height)
+		return nil
+	})
+}
+
+// checkMinedBalance checks that the mined balance is the total amount of all
+// unspent mined credits.
+func (c *checker) checkMinedBalance() error {
+	var total btcutil.Amount
+	err := c.ns.Bucket(bucketCredits).ForEach(func(k, v []byte) error {
+		amt, spent, err := fetchRawCreditAmountSpent(v)
+		if err != nil {
+			// Already reported by checkCredits.
+			return nil
+		}
+		if !spent {
+			total += amt
+		}
+		return nil
+	})
+	if err != nil {
+		str := "failed to iterate over credits"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	balance, err := fetchMinedBalance(c.ns)
+	if err != nil {
+		c.reportRepairable(func() error {
+			return putMinedBalance(c.ns, total)
+		}, "mined balance can not be read: %v", err)
+		return nil
+	}
+	if balance != total {
+		c.reportRepairable(func() error {
+			return putMinedBalance(c.ns, total)
+		}, "mined balance %v does not equal the total of unspent "+
+			"credits %v", balance, total)
+	}
+	return nil
+}
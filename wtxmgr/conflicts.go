@@ -0,0 +1,186 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// ConflictedTx describes an unmined transaction which was removed from the
+// store because it double spent an input of a mined transaction, spent an
+// output of another conflicted transaction, spent a coinbase output which was
+// removed by a reorganize, or was abandoned.  Coinbase transactions with
+// credits which are removed by a reorganize (orphaned) are also recorded as
+// conflicted transactions.  The block height of the embedded TxDetails is
+// always -1, and none of its credits are spent.
+type ConflictedTx struct {
+	TxDetails
+
+	// WinningTx is the hash of the mined transaction which the removed
+	// transaction, or the transaction it spends outputs of, conflicted
+	// with.  It is the zero hash for orphaned coinbase transactions and
+	// transactions removed because they spent a coinbase output removed
+	// by a reorganize.
+	WinningTx wire.ShaHash
+
+	// Height is the height of the block at which the conflict was
+	// detected, the height the store was synced to when the transaction
+	// was abandoned, or the height of the block which contained an
+	// orphaned coinbase.
+	Height int32
+
+	// Abandoned is the time the transaction, or the transaction it spends
+	// outputs of, was abandoned with AbandonTx.  It is zero for
+	// transactions which were not abandoned.
+	Abandoned time.Time
+}
+
+// readConflictedTx reads the conflicted transaction with hash txHash and the
+// conflicted bucket value v, including the time it was abandoned.
+func readConflictedTx(ns walletdb.Bucket, txHash *wire.ShaHash, v []byte, c *ConflictedTx) error {
+	err := readRawConflictedTx(txHash, v, c)
+	if err != nil {
+		return err
+	}
+	c.Abandoned, err = fetchRawAbandoned(ns, txHash[:])
+	if err != nil {
+		return err
+	}
+	c.Sent, err = fetchSentTxInfo(ns, txHash)
+	return err
+}
+
+// ConflictedTx returns the conflicted transaction with hash txHash, or nil if
+// no such transaction was removed from the store.  A transaction which is mined
+// or added as unmined after being removed is no longer conflicted.
+func (s *Store) ConflictedTx(txHash *wire.ShaHash) (*ConflictedTx, error) {
+	var conflicted *ConflictedTx
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		v := existsRawConflictedTx(ns, txHash[:])
+		if v == nil {
+			return nil
+		}
+		conflicted = new(ConflictedTx)
+		return readConflictedTx(ns, txHash, v, conflicted)
+	})
+	return conflicted, err
+}
+
+// ConflictedTxs returns every conflicted transaction, in increasing order of
+// the height at which the conflict was detected.
+func (s *Store) ConflictedTxs() ([]ConflictedTx, error) {
+	var conflicted []ConflictedTx
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		return ns.Bucket(bucketConflicted).ForEach(func(k, v []byte) error {
+			var txHash wire.ShaHash
+			err := readRawUnminedHash(k, &txHash)
+			if err != nil {
+				return err
+			}
+			var c ConflictedTx
+			err = readConflictedTx(ns, &txHash, v, &c)
+			if err != nil {
+				return err
+			}
+			conflicted = append(conflicted, c)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are ordered by transaction hash, so sort by height.  Insertion
+	// sort is used since there are usually few conflicts.
+	for i := 1; i < len(conflicted); i++ {
+		for j := i; j > 0 && conflicted[j].Height < conflicted[j-1].Height; j-- {
+			conflicted[j], conflicted[j-1] = conflicted[j-1], conflicted[j]
+		}
+	}
+	return conflicted, nil
+}
+
+// WalletConflicts returns the hashes of the transactions which conflict with
+// the transaction with hash txHash.  For a conflicted transaction, this is the
+// winning transaction.  For a mined transaction, these are the transactions
+// which were removed because they conflicted with it.
+func (s *Store) WalletConflicts(txHash *wire.ShaHash) ([]wire.ShaHash, error) {
+	var conflicts []wire.ShaHash
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		if v := existsRawConflictedTx(ns, txHash[:]); v != nil {
+			if len(v) < 32 {
+				str := "short conflicted transaction value"
+				return storeError(ErrData, str, nil)
+			}
+			var winner wire.ShaHash
+			copy(winner[:], v)
+			if winner != (wire.ShaHash{}) {
+				conflicts = append(conflicts, winner)
+			}
+		}
+
+		// Transactions which conflicted with this one are indexed by
+		// keys beginning with its hash.
+		c := ns.Bucket(bucketConflictWinner).Cursor()
+		for k, _ := c.Seek(txHash[:]); bytes.HasPrefix(k, txHash[:]); k, _ = c.Next() {
+			if len(k) != 64 {
+				str := "invalid conflict winner key"
+				return storeError(ErrData, str, nil)
+			}
+			var loser wire.ShaHash
+			copy(loser[:], k[32:64])
+			conflicts = append(conflicts, loser)
+		}
+		return nil
+	})
+	return conflicts, err
+}
+
+// AbandonTx removes an unmined transaction which is not expected to be mined,
+// such as a transaction paying too low a fee, along with every transaction
+// spending its outputs.  The outputs spent by the removed transactions are
+// freed to be spent again.  The removed transactions are saved as conflicted
+// transactions, without a winning transaction, recording syncHeight and the
+// time they were abandoned.  ErrInput is returned if the transaction is not an
+// unmined transaction of the store.
+//
+// Abandoning a transaction does not prevent it from being mined, in which case
+// it is recorded as a mined transaction once again.
+func (s *Store) AbandonTx(txHash *wire.ShaHash, syncHeight int32) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		v := existsRawUnmined(ns, txHash[:])
+		if v == nil {
+			str := fmt.Sprintf("transaction %v is not an unmined "+
+				"transaction", txHash)
+			return storeError(ErrInput, str, nil)
+		}
+		var rec TxRecord
+		err := readRawTxRecord(txHash, v, &rec)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Abandoning transaction %v", txHash)
+		return s.removeConflict(ns, &rec, &conflict{
+			height:    syncHeight,
+			abandoned: time.Unix(time.Now().Unix(), 0),
+		})
+	})
+}
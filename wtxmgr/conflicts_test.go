@@ -0,0 +1,390 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr_test
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	. "github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestConflictedTxs(t *testing.T) {
+	t.Parallel()
+
+	s, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fund := spendOutput(&wire.ShaHash{}, 0, 20e8, 10e8)
+	fundRec, err := NewTxRecordFromMsgTx(fund, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(fundRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	for i := range fund.TxOut {
+		if err := s.AddCredit(fundRec, &b100, uint32(i), false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Insert an unmined spend of the first output, and an unmined
+	// spend of its change output.
+	loserRec, err := NewTxRecordFromMsgTx(spendOutput(&fundRec.Hash, 0, 5e8, 14e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(loserRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(loserRec, nil, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	childRec, err := NewTxRecordFromMsgTx(spendOutput(&loserRec.Hash, 1, 13e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(childRec, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mine a double spend of the first output.  Both unmined
+	// transactions must be saved as conflicted.
+	winnerRec, err := NewTxRecordFromMsgTx(spendOutput(&fundRec.Hash, 0, 19e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b101 := makeBlockMeta(101)
+	if err := s.InsertTx(winnerRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+
+	unmined, err := s.UnminedTxs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unmined) != 0 {
+		t.Fatalf("UnminedTxs: %d transactions remain unmined", len(unmined))
+	}
+
+	loser, err := s.ConflictedTx(&loserRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loser == nil {
+		t.Fatal("ConflictedTx: double spend is not conflicted")
+	}
+	if loser.WinningTx != winnerRec.Hash || loser.Height != 101 {
+		t.Errorf("ConflictedTx: got winner %v at height %d, expected "+
+			"%v at height 101", loser.WinningTx, loser.Height,
+			winnerRec.Hash)
+	}
+	if loser.Block.Height != -1 || loser.Hash != loserRec.Hash {
+		t.Errorf("ConflictedTx: bad transaction details %v", loser.TxDetails)
+	}
+	expCredits := []CreditRecord{{Amount: 14e8, Index: 1, Change: true}}
+	expDebits := []DebitRecord{{Amount: 20e8, Index: 0}}
+	if len(loser.Credits) != 1 || loser.Credits[0] != expCredits[0] {
+		t.Errorf("ConflictedTx: credits %v, expected %v", loser.Credits,
+			expCredits)
+	}
+	if len(loser.Debits) != 1 || loser.Debits[0] != expDebits[0] {
+		t.Errorf("ConflictedTx: debits %v, expected %v", loser.Debits,
+			expDebits)
+	}
+
+	child, err := s.ConflictedTx(&childRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child == nil || child.WinningTx != winnerRec.Hash {
+		t.Fatalf("ConflictedTx: spend chain of double spend is not "+
+			"conflicted by %v", winnerRec.Hash)
+	}
+	if len(child.Debits) != 1 || child.Debits[0].Amount != 14e8 {
+		t.Errorf("ConflictedTx: spend chain debits %v", child.Debits)
+	}
+
+	all, err := s.ConflictedTxs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Errorf("ConflictedTxs: got %d transactions, expected 2", len(all))
+	}
+
+	conflicts, err := s.WalletConflicts(&winnerRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 2 {
+		t.Errorf("WalletConflicts: winner has %d conflicts, expected 2",
+			len(conflicts))
+	}
+	conflicts, err = s.WalletConflicts(&loserRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0] != winnerRec.Hash {
+		t.Errorf("WalletConflicts: loser conflicts %v, expected %v",
+			conflicts, winnerRec.Hash)
+	}
+
+	// Conflicted transactions are not included in the balance.
+	bal, err := s.Balance(1, 101)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bal != btcutil.Amount(10e8) {
+		t.Errorf("Balance: got %v, expected %v", bal, btcutil.Amount(10e8))
+	}
+
+	// Once a conflicted transaction is mined after all (for example,
+	// after a reorganize), it is no longer conflicted.
+	if err := s.Rollback(101); err != nil {
+		t.Fatal(err)
+	}
+	b101 = makeBlockMeta(101)
+	b101.Hash[1] = 1
+	if err := s.InsertTx(loserRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+	loser, err = s.ConflictedTx(&loserRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loser != nil {
+		t.Error("ConflictedTx: mined transaction is still conflicted")
+	}
+}
+
+func TestAbandonTx(t *testing.T) {
+	t.Parallel()
+
+	s, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fund := spendOutput(&wire.ShaHash{}, 0, 20e8)
+	fundRec, err := NewTxRecordFromMsgTx(fund, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(fundRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(fundRec, &b100, 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Insert an unmined spend of the output, and an unmined spend of its
+	// change output.
+	stuckRec, err := NewTxRecordFromMsgTx(spendOutput(&fundRec.Hash, 0, 5e8, 15e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(stuckRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(stuckRec, nil, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	childRec, err := NewTxRecordFromMsgTx(spendOutput(&stuckRec.Hash, 1, 14e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(childRec, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only unmined transactions may be abandoned.
+	err = s.AbandonTx(&fundRec.Hash, 100)
+	if serr, ok := err.(Error); !ok || serr.Code != ErrInput {
+		t.Errorf("AbandonTx: mined transaction gave error %v, expected "+
+			"%v", err, ErrInput)
+	}
+
+	if err := s.AbandonTx(&stuckRec.Hash, 100); err != nil {
+		t.Fatal(err)
+	}
+	unmined, err := s.UnminedTxs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unmined) != 0 {
+		t.Fatalf("UnminedTxs: %d transactions remain unmined", len(unmined))
+	}
+
+	// The spent output is freed.
+	unspent, err := s.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unspent) != 1 || unspent[0].OutPoint.Hash != fundRec.Hash {
+		t.Errorf("UnspentOutputs: abandoned transaction input was not "+
+			"freed: %v", unspent)
+	}
+
+	for _, rec := range []*TxRecord{stuckRec, childRec} {
+		c, err := s.ConflictedTx(&rec.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c == nil {
+			t.Fatalf("ConflictedTx: abandoned transaction %v not "+
+				"recorded", rec.Hash)
+		}
+		if c.Abandoned.IsZero() || c.WinningTx != (wire.ShaHash{}) ||
+			c.Height != 100 {
+			t.Errorf("ConflictedTx: abandoned transaction %v recorded "+
+				"as winner %v at height %d abandoned at %v", rec.Hash,
+				c.WinningTx, c.Height, c.Abandoned)
+		}
+	}
+
+	// Abandoning the transaction again is an error, since it is no longer
+	// unmined.
+	err = s.AbandonTx(&stuckRec.Hash, 100)
+	if serr, ok := err.(Error); !ok || serr.Code != ErrInput {
+		t.Errorf("AbandonTx: abandoned transaction gave error %v, "+
+			"expected %v", err, ErrInput)
+	}
+
+	// If the abandoned transaction is seen again, it is no longer
+	// abandoned.
+	if err := s.InsertTx(stuckRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.ConflictedTx(&stuckRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c != nil {
+		t.Error("ConflictedTx: reinserted transaction is still abandoned")
+	}
+}
+
+func TestUpgradeConflictedTxs(t *testing.T) {
+	t.Parallel()
+
+	// Version 2 stores did not save conflicted transactions.
+	ns, teardown, err := testOldStore(2)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflicted, err := s.ConflictedTxs()
+	if err != nil {
+		t.Fatalf("ConflictedTxs after upgrade: %v", err)
+	}
+	if len(conflicted) != 0 {
+		t.Errorf("ConflictedTxs: %d transactions after upgrade",
+			len(conflicted))
+	}
+	var zero wire.ShaHash
+	conflicts, err := s.WalletConflicts(&zero)
+	if err != nil || len(conflicts) != 0 {
+		t.Errorf("WalletConflicts after upgrade: %v %v", conflicts, err)
+	}
+}
+
+func TestOrphanedCoinbase(t *testing.T) {
+	t.Parallel()
+
+	s, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := newCoinBase(25e8, 1e8)
+	cbRec, err := NewTxRecordFromMsgTx(cb, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(cbRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(cbRec, &b100, 0, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// A coinbase without credits is not recorded when orphaned.
+	otherRec, err := NewTxRecordFromMsgTx(newCoinBase(25e8, 2e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b101 := makeBlockMeta(101)
+	if err := s.InsertTx(otherRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the blocks saves the coinbase with credits as a conflicted
+	// transaction.
+	if err := s.Rollback(100); err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := s.ConflictedTx(&cbRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orphan == nil {
+		t.Fatal("ConflictedTx: orphaned coinbase is not conflicted")
+	}
+	if orphan.Height != 100 || orphan.Block.Height != -1 ||
+		orphan.WinningTx != (wire.ShaHash{}) {
+		t.Errorf("ConflictedTx: orphaned coinbase has height %d, block "+
+			"height %d, winner %v", orphan.Height, orphan.Block.Height,
+			orphan.WinningTx)
+	}
+	if len(orphan.Credits) != 1 || orphan.Credits[0].Amount != 25e8 {
+		t.Errorf("ConflictedTx: orphaned coinbase credits %v",
+			orphan.Credits)
+	}
+	other, err := s.ConflictedTx(&otherRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other != nil {
+		t.Error("ConflictedTx: coinbase without credits is conflicted")
+	}
+
+	// Mining the coinbase again removes the conflicted record.
+	if err := s.InsertTx(cbRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	orphan, err = s.ConflictedTx(&cbRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if orphan != nil {
+		t.Error("ConflictedTx: mined coinbase is still conflicted")
+	}
+}
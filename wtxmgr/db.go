@@ -22,9 +22,12 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/walletdb"
+	"github.com/btcsuite/btcwallet/walletdb/migration"
 )
 
 // Naming
@@ -66,7 +69,7 @@ var byteOrder = binary.BigEndian
 // change.
 const (
 	// LatestVersion is the most recent store version.
-	LatestVersion = 1
+	LatestVersion = 6
 )
 
 // This package makes assumptions that the width of a wire.ShaHash is always 32
@@ -85,6 +88,13 @@ var (
 	bucketUnmined        = []byte("m")
 	bucketUnminedCredits = []byte("mc")
 	bucketUnminedInputs  = []byte("mi")
+	bucketLockedOutputs  = []byte("lo")
+	bucketReleasedLocks  = []byte("rl")
+	bucketConflicted     = []byte("cf")
+	bucketConflictWinner = []byte("cw")
+	bucketAbandoned      = []byte("ab")
+	bucketSentTxInfo     = []byte("si")
+	bucketAddrCredits    = []byte("ac")
 )
 
 // Root (namespace) bucket keys
@@ -672,21 +682,21 @@ func deleteRawCredit(ns walletdb.Bucket, k []byte) error {
 //
 // Example usage:
 //
-//   prefix := keyTxRecord(txHash, block)
-//   it := makeCreditIterator(ns, prefix)
-//   for it.next() {
-//           // Use it.elem
-//           // If necessary, read additional details from it.ck, it.cv
-//   }
-//   if it.err != nil {
-//           // Handle error
-//   }
+//	prefix := keyTxRecord(txHash, block)
+//	it := makeCreditIterator(ns, prefix)
+//	for it.next() {
+//	        // Use it.elem
+//	        // If necessary, read additional details from it.ck, it.cv
+//	}
+//	if it.err != nil {
+//	        // Handle error
+//	}
 //
 // The elem's Spent field is not set to true if the credit is spent by an
 // unmined transaction.  To check for this case:
 //
-//   k := canonicalOutPoint(&txHash, it.elem.Index)
-//   it.elem.Spent = existsRawUnminedInput(ns, k) != nil
+//	k := canonicalOutPoint(&txHash, it.elem.Index)
+//	it.elem.Spent = existsRawUnminedInput(ns, k) != nil
 type creditIterator struct {
 	c      walletdb.Cursor // Set to nil after final iteration
 	prefix []byte
@@ -907,15 +917,15 @@ func deleteRawDebit(ns walletdb.Bucket, k []byte) error {
 //
 // Example usage:
 //
-//   prefix := keyTxRecord(txHash, block)
-//   it := makeDebitIterator(ns, prefix)
-//   for it.next() {
-//           // Use it.elem
-//           // If necessary, read additional details from it.ck, it.cv
-//   }
-//   if it.err != nil {
-//           // Handle error
-//   }
+//	prefix := keyTxRecord(txHash, block)
+//	it := makeDebitIterator(ns, prefix)
+//	for it.next() {
+//	        // Use it.elem
+//	        // If necessary, read additional details from it.ck, it.cv
+//	}
+//	if it.err != nil {
+//	        // Handle error
+//	}
 type debitIterator struct {
 	c      walletdb.Cursor // Set to nil after final iteration
 	prefix []byte
@@ -1079,22 +1089,22 @@ func deleteRawUnminedCredit(ns walletdb.Bucket, k []byte) error {
 // unminedCreditIterator allows for cursor iteration over all credits, in order,
 // from a single unmined transaction.
 //
-//  Example usage:
+//	Example usage:
 //
-//   it := makeUnminedCreditIterator(ns, txHash)
-//   for it.next() {
-//           // Use it.elem, it.ck and it.cv
-//           // Optionally, use it.delete() to remove this k/v pair
-//   }
-//   if it.err != nil {
-//           // Handle error
-//   }
+//	 it := makeUnminedCreditIterator(ns, txHash)
+//	 for it.next() {
+//	         // Use it.elem, it.ck and it.cv
+//	         // Optionally, use it.delete() to remove this k/v pair
+//	 }
+//	 if it.err != nil {
+//	         // Handle error
+//	 }
 //
 // The spentness of the credit is not looked up for performance reasons (because
 // for unspent credits, it requires another lookup in another bucket).  If this
 // is needed, it may be checked like this:
 //
-//   spent := existsRawUnminedInput(ns, it.ck) != nil
+//	spent := existsRawUnminedInput(ns, it.ck) != nil
 type unminedCreditIterator struct {
 	c      walletdb.Cursor
 	prefix []byte
@@ -1194,6 +1204,503 @@ func deleteRawUnminedInput(ns walletdb.Bucket, k []byte) error {
 	return nil
 }
 
+// Outputs locked to prevent them from being spent by newly created
+// transactions are saved in the locked outputs bucket.  Locks may expire, after
+// which the output is no longer considered locked, and record the reason the
+// output was locked.
+//
+// The key is serialized as such:
+//
+//   [0:32]   Transaction hash (32 bytes)
+//   [32:36]  Output index (4 bytes)
+//
+// The value is serialized as such:
+//
+//   [0:8]    Expiry time (8 bytes, Unix seconds, or zero for no expiry)
+//   [8:]     Reason (remaining bytes)
+
+func valueLockedOutput(expiry time.Time, reason string) []byte {
+	v := make([]byte, 8+len(reason))
+	if !expiry.IsZero() {
+		byteOrder.PutUint64(v, uint64(expiry.Unix()))
+	}
+	copy(v[8:], reason)
+	return v
+}
+
+func readLockedOutput(k, v []byte, lock *LockedOutput) error {
+	err := readCanonicalOutPoint(k, &lock.OutPoint)
+	if err != nil {
+		return err
+	}
+	if len(v) < 8 {
+		str := "short locked output value"
+		return storeError(ErrData, str, nil)
+	}
+	lock.Expiry = time.Time{}
+	if expiry := int64(byteOrder.Uint64(v)); expiry != 0 {
+		lock.Expiry = time.Unix(expiry, 0)
+	}
+	lock.Reason = string(v[8:])
+	return nil
+}
+
+func putRawLockedOutput(ns walletdb.Bucket, k, v []byte) error {
+	err := ns.Bucket(bucketLockedOutputs).Put(k, v)
+	if err != nil {
+		str := "failed to put locked output"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func existsRawLockedOutput(ns walletdb.Bucket, k []byte) (v []byte) {
+	return ns.Bucket(bucketLockedOutputs).Get(k)
+}
+
+func deleteRawLockedOutput(ns walletdb.Bucket, k []byte) error {
+	err := ns.Bucket(bucketLockedOutputs).Delete(k)
+	if err != nil {
+		str := "failed to delete locked output"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// Locks of outputs spent by mined transactions are moved to the released locks
+// bucket, recording the height of the block of the spending transaction, so
+// they can be restored if the block is rolled back.
+//
+// The key is the same as that of the locked outputs bucket.
+//
+// The value is serialized as such:
+//
+//   [0:4]    Block height (4 bytes)
+//   [4:]     Locked output value
+
+func valueReleasedLock(height int32, lockVal []byte) []byte {
+	v := make([]byte, 4+len(lockVal))
+	byteOrder.PutUint32(v, uint32(height))
+	copy(v[4:], lockVal)
+	return v
+}
+
+func readReleasedLock(k, v []byte, lock *LockedOutput) (height int32, err error) {
+	if len(v) < 4 {
+		str := "short released lock value"
+		return 0, storeError(ErrData, str, nil)
+	}
+	return int32(byteOrder.Uint32(v)), readLockedOutput(k, v[4:], lock)
+}
+
+func putRawReleasedLock(ns walletdb.Bucket, k, v []byte) error {
+	err := ns.Bucket(bucketReleasedLocks).Put(k, v)
+	if err != nil {
+		str := "failed to put released lock"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func deleteRawReleasedLock(ns walletdb.Bucket, k []byte) error {
+	err := ns.Bucket(bucketReleasedLocks).Delete(k)
+	if err != nil {
+		str := "failed to delete released lock"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// Unmined transactions removed from the store because they double spend an
+// input of a mined transaction, or spend an output of such a transaction, are
+// saved in the conflicted bucket so their history is not lost.  The credits and
+// debits of the transaction at the time it was removed are saved with the
+// transaction, as they can no longer be looked up from the other buckets.
+//
+// The key is the transaction hash.
+//
+// The value is serialized as such:
+//
+//   [0:32]   Hash of the winning mined transaction (32 bytes, or all zero if
+//            there is none)
+//   [32:36]  Height of the block at which the conflict was detected (4 bytes)
+//   [36:40]  Number of credits (4 bytes)
+//   [40:]    For each credit:
+//              [0:4]   Output index (4 bytes)
+//              [4:12]  Amount (8 bytes)
+//              [12]    Flags (1 byte)
+//                        0x01: Change
+//   [...]    Number of debits (4 bytes)
+//   [...]    For each debit:
+//              [0:4]   Input index (4 bytes)
+//              [4:12]  Amount (8 bytes)
+//   [...]    Transaction record value (received time and serialized tx)
+
+func valueConflictedTx(c *ConflictedTx) ([]byte, error) {
+	recVal, err := valueTxRecord(&c.TxRecord)
+	if err != nil {
+		return nil, err
+	}
+	v := make([]byte, 44+13*len(c.Credits)+12*len(c.Debits)+len(recVal))
+	copy(v, c.WinningTx[:])
+	byteOrder.PutUint32(v[32:36], uint32(c.Height))
+	byteOrder.PutUint32(v[36:40], uint32(len(c.Credits)))
+	off := 40
+	for _, cred := range c.Credits {
+		byteOrder.PutUint32(v[off:off+4], cred.Index)
+		byteOrder.PutUint64(v[off+4:off+12], uint64(cred.Amount))
+		if cred.Change {
+			v[off+12] = 1 << 0
+		}
+		off += 13
+	}
+	byteOrder.PutUint32(v[off:off+4], uint32(len(c.Debits)))
+	off += 4
+	for _, deb := range c.Debits {
+		byteOrder.PutUint32(v[off:off+4], deb.Index)
+		byteOrder.PutUint64(v[off+4:off+12], uint64(deb.Amount))
+		off += 12
+	}
+	copy(v[off:], recVal)
+	return v, nil
+}
+
+func readRawConflictedTx(txHash *wire.ShaHash, v []byte, c *ConflictedTx) error {
+	if len(v) < 44 {
+		str := fmt.Sprintf("%s: short read (expected at least %d bytes, "+
+			"read %d)", bucketConflicted, 44, len(v))
+		return storeError(ErrData, str, nil)
+	}
+	copy(c.WinningTx[:], v)
+	c.Height = int32(byteOrder.Uint32(v[32:36]))
+	c.Block = BlockMeta{Block: Block{Height: -1}}
+
+	numCredits := int(byteOrder.Uint32(v[36:40]))
+	off := 40
+	if numCredits > (len(v)-off-4)/13 {
+		str := fmt.Sprintf("%s: credits exceed value length",
+			bucketConflicted)
+		return storeError(ErrData, str, nil)
+	}
+	c.Credits = make([]CreditRecord, numCredits)
+	for i := range c.Credits {
+		c.Credits[i] = CreditRecord{
+			Index:  byteOrder.Uint32(v[off : off+4]),
+			Amount: btcutil.Amount(byteOrder.Uint64(v[off+4 : off+12])),
+			Change: v[off+12]&(1<<0) != 0,
+		}
+		off += 13
+	}
+	numDebits := int(byteOrder.Uint32(v[off : off+4]))
+	off += 4
+	if numDebits > (len(v)-off)/12 {
+		str := fmt.Sprintf("%s: debits exceed value length",
+			bucketConflicted)
+		return storeError(ErrData, str, nil)
+	}
+	c.Debits = make([]DebitRecord, numDebits)
+	for i := range c.Debits {
+		c.Debits[i] = DebitRecord{
+			Index:  byteOrder.Uint32(v[off : off+4]),
+			Amount: btcutil.Amount(byteOrder.Uint64(v[off+4 : off+12])),
+		}
+		off += 12
+	}
+	return readRawTxRecord(txHash, v[off:], &c.TxRecord)
+}
+
+func putRawConflictedTx(ns walletdb.Bucket, k, v []byte) error {
+	err := ns.Bucket(bucketConflicted).Put(k, v)
+	if err != nil {
+		str := "failed to put conflicted transaction"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func existsRawConflictedTx(ns walletdb.Bucket, k []byte) (v []byte) {
+	return ns.Bucket(bucketConflicted).Get(k)
+}
+
+func deleteRawConflictedTx(ns walletdb.Bucket, k []byte) error {
+	err := ns.Bucket(bucketConflicted).Delete(k)
+	if err != nil {
+		str := "failed to delete conflicted transaction"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// Conflicted transactions with a winning transaction are indexed by the winner
+// in the conflict winners bucket, so the transactions which conflicted with a
+// mined transaction are found without reading every conflicted transaction.
+//
+// The key is serialized as such:
+//
+//   [0:32]   Hash of the winning mined transaction (32 bytes)
+//   [32:64]  Hash of the conflicted transaction (32 bytes)
+//
+// The value is empty.
+
+func keyConflictWinner(winner, conflicted *wire.ShaHash) []byte {
+	k := make([]byte, 64)
+	copy(k, winner[:])
+	copy(k[32:64], conflicted[:])
+	return k
+}
+
+func putRawConflictWinner(ns walletdb.Bucket, k []byte) error {
+	err := ns.Bucket(bucketConflictWinner).Put(k, nil)
+	if err != nil {
+		str := "failed to put conflict winner"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func deleteRawConflictWinner(ns walletdb.Bucket, k []byte) error {
+	err := ns.Bucket(bucketConflictWinner).Delete(k)
+	if err != nil {
+		str := "failed to delete conflict winner"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// Unmined transactions removed with AbandonTx, and the transactions spending
+// their outputs, are also saved in the conflicted bucket.  To record that they
+// were abandoned rather than double spent, the time each was abandoned is saved
+// in the abandoned bucket.
+//
+// The key is the transaction hash.
+//
+// The value is serialized as such:
+//
+//   [0:8]    Abandoned time (8 bytes, Unix seconds)
+
+func valueAbandoned(t time.Time) []byte {
+	v := make([]byte, 8)
+	byteOrder.PutUint64(v, uint64(t.Unix()))
+	return v
+}
+
+func fetchRawAbandoned(ns walletdb.Bucket, k []byte) (time.Time, error) {
+	v := ns.Bucket(bucketAbandoned).Get(k)
+	if v == nil {
+		return time.Time{}, nil
+	}
+	if len(v) < 8 {
+		str := fmt.Sprintf("%s: short read (expected %d bytes, read %d)",
+			bucketAbandoned, 8, len(v))
+		return time.Time{}, storeError(ErrData, str, nil)
+	}
+	return time.Unix(int64(byteOrder.Uint64(v)), 0), nil
+}
+
+func putRawAbandoned(ns walletdb.Bucket, k, v []byte) error {
+	err := ns.Bucket(bucketAbandoned).Put(k, v)
+	if err != nil {
+		str := "failed to put abandoned transaction"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+func deleteRawAbandoned(ns walletdb.Bucket, k []byte) error {
+	err := ns.Bucket(bucketAbandoned).Delete(k)
+	if err != nil {
+		str := "failed to delete abandoned transaction"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// Details of transactions created by the wallet, which are not otherwise
+// known from the transaction and its credits and debits, are saved in the sent
+// transaction info bucket when the transaction is created.  These are kept
+// regardless of whether the transaction is later mined, conflicted, or
+// abandoned.
+//
+// The key is the transaction hash.
+//
+// The value is serialized as such:
+//
+//   [0:8]    Fee (8 bytes)
+//   [8:12]   Change output index (4 bytes, or 0xffffffff if no change)
+//   [12:16]  Number of recipient outputs (4 bytes)
+//   [16:]    Output index of each recipient (4 bytes each)
+
+func valueSentTxInfo(info *SentTxInfo) []byte {
+	v := make([]byte, 16+4*len(info.Recipients))
+	byteOrder.PutUint64(v, uint64(info.Fee))
+	changeIndex := ^uint32(0)
+	if info.ChangeIndex >= 0 {
+		changeIndex = uint32(info.ChangeIndex)
+	}
+	byteOrder.PutUint32(v[8:12], changeIndex)
+	byteOrder.PutUint32(v[12:16], uint32(len(info.Recipients)))
+	for i, index := range info.Recipients {
+		byteOrder.PutUint32(v[16+4*i:], index)
+	}
+	return v
+}
+
+func readRawSentTxInfo(v []byte, info *SentTxInfo) error {
+	if len(v) < 16 {
+		str := fmt.Sprintf("%s: short read (expected at least %d bytes, "+
+			"read %d)", bucketSentTxInfo, 16, len(v))
+		return storeError(ErrData, str, nil)
+	}
+	info.Fee = btcutil.Amount(byteOrder.Uint64(v))
+	info.ChangeIndex = -1
+	if changeIndex := byteOrder.Uint32(v[8:12]); changeIndex != ^uint32(0) {
+		info.ChangeIndex = int(changeIndex)
+	}
+	n := int(byteOrder.Uint32(v[12:16]))
+	if n > (len(v)-16)/4 {
+		str := fmt.Sprintf("%s: recipients exceed value length",
+			bucketSentTxInfo)
+		return storeError(ErrData, str, nil)
+	}
+	info.Recipients = make([]uint32, n)
+	for i := range info.Recipients {
+		info.Recipients[i] = byteOrder.Uint32(v[16+4*i:])
+	}
+	return nil
+}
+
+// fetchSentTxInfo returns the saved sent transaction info of the transaction
+// with hash txHash, or nil if the transaction was not created by the wallet.
+func fetchSentTxInfo(ns walletdb.Bucket, txHash *wire.ShaHash) (*SentTxInfo, error) {
+	v := ns.Bucket(bucketSentTxInfo).Get(txHash[:])
+	if v == nil {
+		return nil, nil
+	}
+	info := new(SentTxInfo)
+	err := readRawSentTxInfo(v, info)
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+func putRawSentTxInfo(ns walletdb.Bucket, k, v []byte) error {
+	err := ns.Bucket(bucketSentTxInfo).Put(k, v)
+	if err != nil {
+		str := "failed to put sent transaction info"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// The address credits bucket indexes every mined and unmined credit by the
+// addresses its output script pays to, so the credits of a single address can
+// be found without scanning every transaction.  Entries are added with the
+// credit, updated when the transaction is mined or its block is removed by a
+// reorganize, and removed with the credit.  A credit has one entry for each
+// address ID returned by addrCreditIDs.
+//
+// The key is serialized as such:
+//
+//   [0:20]   Address ID (20 bytes)
+//   [20:52]  Transaction hash (32 bytes)
+//   [52:56]  Output index (4 bytes)
+//
+// The value is serialized as such:
+//
+//   [0:4]    Block height (4 bytes, or 0xffffffff if unmined)
+//   [4:36]   Block hash (32 bytes, zero if unmined)
+//   [36:44]  Amount (8 bytes)
+
+// addrCreditIDs returns the address IDs of the output script pkScript.  These
+// are the 20 byte hashes of each public key or script which the output pays
+// to, so pay-to-pubkey and bare multisig outputs share the IDs of the
+// pay-to-pubkey-hash scripts of their keys.  Scripts which do not pay to any
+// address use the Hash160 of the entire script.
+func addrCreditIDs(pkScript []byte) [][]byte {
+	// The chain parameters only determine the encoding of the addresses,
+	// which are not used.
+	_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScript,
+		&chaincfg.MainNetParams)
+	if err != nil || len(addrs) == 0 {
+		return [][]byte{btcutil.Hash160(pkScript)}
+	}
+	ids := make([][]byte, 0, len(addrs))
+	for _, addr := range addrs {
+		id := addr.ScriptAddress()
+		if len(id) != 20 {
+			// Public keys are identified by their hash.
+			id = btcutil.Hash160(id)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func keyAddrCredit(id []byte, txHash *wire.ShaHash, index uint32) []byte {
+	k := make([]byte, 56)
+	copy(k, id)
+	copy(k[20:52], txHash[:])
+	byteOrder.PutUint32(k[52:56], index)
+	return k
+}
+
+func valueAddrCredit(block *Block, amount btcutil.Amount) []byte {
+	v := make([]byte, 44)
+	byteOrder.PutUint32(v, uint32(block.Height))
+	copy(v[4:36], block.Hash[:])
+	byteOrder.PutUint64(v[36:44], uint64(amount))
+	return v
+}
+
+// putAddrCredit indexes output index of the transaction rec.  A nil block is
+// used for unmined credits.
+func putAddrCredit(ns walletdb.Bucket, rec *TxRecord, index uint32, block *Block, amount btcutil.Amount) error {
+	if block == nil {
+		block = &Block{Height: -1}
+	}
+	v := valueAddrCredit(block, amount)
+	for _, id := range addrCreditIDs(rec.MsgTx.TxOut[index].PkScript) {
+		k := keyAddrCredit(id, &rec.Hash, index)
+		err := ns.Bucket(bucketAddrCredits).Put(k, v)
+		if err != nil {
+			str := "failed to put address credit"
+			return storeError(ErrDatabase, str, err)
+		}
+	}
+	return nil
+}
+
+func readRawAddrCredit(k, v []byte, c *AddressCredit) error {
+	if len(k) < 56 {
+		str := "short address credit key"
+		return storeError(ErrData, str, nil)
+	}
+	if len(v) < 44 {
+		str := fmt.Sprintf("%s: short read (expected %d bytes, read %d)",
+			bucketAddrCredits, 44, len(v))
+		return storeError(ErrData, str, nil)
+	}
+	copy(c.OutPoint.Hash[:], k[20:52])
+	c.OutPoint.Index = byteOrder.Uint32(k[52:56])
+	c.Block.Height = int32(byteOrder.Uint32(v))
+	copy(c.Block.Hash[:], v[4:36])
+	c.Amount = btcutil.Amount(byteOrder.Uint64(v[36:44]))
+	return nil
+}
+
+func deleteAddrCredit(ns walletdb.Bucket, rec *TxRecord, index uint32) error {
+	for _, id := range addrCreditIDs(rec.MsgTx.TxOut[index].PkScript) {
+		k := keyAddrCredit(id, &rec.Hash, index)
+		err := ns.Bucket(bucketAddrCredits).Delete(k)
+		if err != nil {
+			str := "failed to delete address credit"
+			return storeError(ErrDatabase, str, err)
+		}
+	}
+	return nil
+}
+
 // openStore opens an existing transaction store from the passed namespace.  If
 // necessary, an already existing store is upgraded to newer db format.
 func openStore(namespace walletdb.Namespace) error {
@@ -1233,108 +1740,218 @@ func openStore(namespace walletdb.Namespace) error {
 
 	// Upgrade the tx store as needed, one version at a time, until
 	// LatestVersion is reached.  Versions are not skipped when performing
-	// database upgrades, and each upgrade is done in its own transaction.
-	//
-	// No upgrades yet.
-	//if version < LatestVersion {
-	//	err := scopedUpdate(namespace, func(ns walletdb.Bucket) error {
-	//	})
-	//	if err != nil {
-	//		// Handle err
-	//	}
-	//}
+	// database upgrades, and all upgrades are done in a single transaction.
+	_, err = migration.UpgradeNamespace(namespace, MigrationSchema(nil), false)
+	if err != nil {
+		const desc = "failed to upgrade existing store"
+		switch e := err.(type) {
+		case Error:
+			e.Desc = desc + ": " + e.Desc
+			return e
+		case migration.Error:
+			return storeError(ErrDatabase, desc+": "+e.Desc, e.Err)
+		}
+		return storeError(ErrDatabase, desc, err)
+	}
 
 	return nil
 }
 
-// createStore creates the tx store (with the latest db version) in the passed
-// namespace.  If a store already exists, ErrAlreadyExists is returned.
-func createStore(namespace walletdb.Namespace) error {
-	// Initialize the buckets and root bucket fields as needed.
-	err := scopedUpdate(namespace, func(ns walletdb.Bucket) error {
-		// Ensure that nothing currently exists in the namespace bucket.
-		ck, cv := ns.Cursor().First()
-		if ck != nil || cv != nil {
-			const str = "namespace is not empty"
-			return storeError(ErrAlreadyExists, str, nil)
-		}
+// MigrationSchema returns the migration schema of a transaction store in the
+// namespace with the passed key.  It is used to upgrade the store with
+// migration.Upgrade, along with the data of other packages, before it is
+// opened.  Upgrades from one version to the next are added to the migrations
+// of the schema when LatestVersion is increased.
+func MigrationSchema(namespaceKey []byte) *migration.Schema {
+	return &migration.Schema{
+		Name:          "wtxmgr",
+		Namespace:     namespaceKey,
+		LatestVersion: LatestVersion,
+		Version: func(tx walletdb.Tx) (uint32, error) {
+			// No version is recorded until the store is created.
+			v := tx.RootBucket().Get(rootVersion)
+			if len(v) != 4 {
+				return 0, nil
+			}
+			return byteOrder.Uint32(v), nil
+		},
+		SetVersion: func(tx walletdb.Tx, version uint32) error {
+			v := make([]byte, 4)
+			byteOrder.PutUint32(v, version)
+			err := tx.RootBucket().Put(rootVersion, v)
+			if err != nil {
+				str := "failed to store database version"
+				return storeError(ErrDatabase, str, err)
+			}
+			return nil
+		},
+		Migrations: []migration.Migration{{
+			Version: 2,
+			Name:    "add locked outputs and released locks buckets",
+			Migrate: upgradeToVersion2,
+		}, {
+			Version: 3,
+			Name:    "add conflicted transactions and conflict winners buckets",
+			Migrate: upgradeToVersion3,
+		}, {
+			Version: 4,
+			Name:    "add abandoned transactions bucket",
+			Migrate: upgradeToVersion4,
+		}, {
+			Version: 5,
+			Name:    "add sent transaction info bucket",
+			Migrate: upgradeToVersion5,
+		}, {
+			Version: 6,
+			Name:    "add address credits index",
+			Migrate: upgradeToVersion6,
+		}},
+	}
+}
+
+// upgradeToVersion2 upgrades the store from version 1 to version 2 by creating
+// the locked outputs and released locks buckets.  Outputs locked by earlier
+// versions were only held in memory, so there are no locks to save.
+func upgradeToVersion2(tx walletdb.Tx) error {
+	_, err := tx.RootBucket().CreateBucket(bucketLockedOutputs)
+	if err != nil {
+		str := "failed to create locked outputs bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	_, err = tx.RootBucket().CreateBucket(bucketReleasedLocks)
+	if err != nil {
+		str := "failed to create released locks bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
 
-		// Write the latest store version.
-		v := make([]byte, 4)
-		byteOrder.PutUint32(v, LatestVersion)
-		err := ns.Put(rootVersion, v)
-		if err != nil {
-			str := "failed to store latest database version"
-			return storeError(ErrDatabase, str, err)
-		}
+// upgradeToVersion3 upgrades the store from version 2 to version 3 by creating
+// the conflicted transactions and conflict winners buckets.  Conflicts were
+// previously removed without a record, so the buckets are left empty.
+func upgradeToVersion3(tx walletdb.Tx) error {
+	_, err := tx.RootBucket().CreateBucket(bucketConflicted)
+	if err != nil {
+		str := "failed to create conflicted transactions bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	_, err = tx.RootBucket().CreateBucket(bucketConflictWinner)
+	if err != nil {
+		str := "failed to create conflict winners bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
 
-		// Save the creation date of the store.
-		v = make([]byte, 8)
-		byteOrder.PutUint64(v, uint64(time.Now().Unix()))
-		err = ns.Put(rootCreateDate, v)
-		if err != nil {
-			str := "failed to store database creation time"
-			return storeError(ErrDatabase, str, err)
-		}
+// upgradeToVersion4 upgrades the store from version 3 to version 4 by creating
+// the abandoned transactions bucket.
+func upgradeToVersion4(tx walletdb.Tx) error {
+	_, err := tx.RootBucket().CreateBucket(bucketAbandoned)
+	if err != nil {
+		str := "failed to create abandoned transactions bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
 
-		// Write a zero balance.
-		v = make([]byte, 8)
-		err = ns.Put(rootMinedBalance, v)
-		if err != nil {
-			str := "failed to write zero balance"
-			return storeError(ErrDatabase, str, err)
-		}
+// upgradeToVersion5 upgrades the store from version 4 to version 5 by creating
+// the sent transaction info bucket.  The fees and recipients of transactions
+// created by earlier versions were not recorded, so the bucket is left empty.
+func upgradeToVersion5(tx walletdb.Tx) error {
+	_, err := tx.RootBucket().CreateBucket(bucketSentTxInfo)
+	if err != nil {
+		str := "failed to create sent transaction info bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
 
-		_, err = ns.CreateBucket(bucketBlocks)
-		if err != nil {
-			str := "failed to create blocks bucket"
-			return storeError(ErrDatabase, str, err)
-		}
+// upgradeToVersion6 upgrades the store from version 5 to version 6 by creating
+// the address credits bucket and indexing every existing mined and unmined
+// credit.
+func upgradeToVersion6(tx walletdb.Tx) error {
+	ns := tx.RootBucket()
+	_, err := ns.CreateBucket(bucketAddrCredits)
+	if err != nil {
+		str := "failed to create address credits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
 
-		_, err = ns.CreateBucket(bucketTxRecords)
+	err = ns.Bucket(bucketCredits).ForEach(func(k, v []byte) error {
+		if len(k) < 72 {
+			str := "short credit key"
+			return storeError(ErrData, str, nil)
+		}
+		var rec TxRecord
+		var block Block
+		copy(rec.Hash[:], k)
+		err := readRawTxRecordBlock(k, &block)
 		if err != nil {
-			str := "failed to create tx records bucket"
-			return storeError(ErrDatabase, str, err)
+			return err
 		}
-
-		_, err = ns.CreateBucket(bucketCredits)
+		recVal := existsRawTxRecord(ns, k[:68])
+		err = readRawTxRecord(&rec.Hash, recVal, &rec)
 		if err != nil {
-			str := "failed to create credits bucket"
-			return storeError(ErrDatabase, str, err)
+			return err
 		}
-
-		_, err = ns.CreateBucket(bucketDebits)
+		index := byteOrder.Uint32(k[68:72])
+		if int(index) >= len(rec.MsgTx.TxOut) {
+			str := "saved credit index exceeds number of outputs"
+			return storeError(ErrData, str, nil)
+		}
+		amount, err := fetchRawCreditAmount(v)
 		if err != nil {
-			str := "failed to create debits bucket"
-			return storeError(ErrDatabase, str, err)
+			return err
 		}
+		return putAddrCredit(ns, &rec, index, &block, amount)
+	})
+	if err != nil {
+		if _, ok := err.(Error); ok {
+			return err
+		}
+		str := "failed iterating credits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
 
-		_, err = ns.CreateBucket(bucketUnspent)
+	err = ns.Bucket(bucketUnminedCredits).ForEach(func(k, v []byte) error {
+		var rec TxRecord
+		err := readRawUnminedHash(k, &rec.Hash)
 		if err != nil {
-			str := "failed to create unspent bucket"
-			return storeError(ErrDatabase, str, err)
+			return err
 		}
-
-		_, err = ns.CreateBucket(bucketUnmined)
+		index, err := fetchRawUnminedCreditIndex(k)
 		if err != nil {
-			str := "failed to create unmined bucket"
-			return storeError(ErrDatabase, str, err)
+			return err
 		}
-
-		_, err = ns.CreateBucket(bucketUnminedCredits)
+		recVal := existsRawUnmined(ns, rec.Hash[:])
+		err = readRawTxRecord(&rec.Hash, recVal, &rec)
 		if err != nil {
-			str := "failed to create unmined credits bucket"
-			return storeError(ErrDatabase, str, err)
+			return err
 		}
-
-		_, err = ns.CreateBucket(bucketUnminedInputs)
+		if int(index) >= len(rec.MsgTx.TxOut) {
+			str := "saved credit index exceeds number of outputs"
+			return storeError(ErrData, str, nil)
+		}
+		amount, err := fetchRawUnminedCreditAmount(v)
 		if err != nil {
-			str := "failed to create unmined inputs bucket"
-			return storeError(ErrDatabase, str, err)
+			return err
 		}
-
-		return nil
+		return putAddrCredit(ns, &rec, index, nil, amount)
 	})
+	if err != nil {
+		if _, ok := err.(Error); ok {
+			return err
+		}
+		str := "failed iterating unmined credits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+	return nil
+}
+
+// createStore creates the tx store (with the latest db version) in the passed
+// namespace.  If a store already exists, ErrAlreadyExists is returned.
+func createStore(namespace walletdb.Namespace) error {
+	err := scopedUpdate(namespace, initStore)
 	if err != nil {
 		const desc = "failed to create new store"
 		if serr, ok := err.(Error); ok {
@@ -1347,6 +1964,136 @@ func createStore(namespace walletdb.Namespace) error {
 	return nil
 }
 
+// initStore initializes the buckets and root bucket fields of a store with the
+// latest db version in the passed namespace bucket.  If the namespace is not
+// empty, ErrAlreadyExists is returned.
+func initStore(ns walletdb.Bucket) error {
+	// Ensure that nothing currently exists in the namespace bucket.
+	ck, cv := ns.Cursor().First()
+	if ck != nil || cv != nil {
+		const str = "namespace is not empty"
+		return storeError(ErrAlreadyExists, str, nil)
+	}
+
+	// Write the latest store version.
+	v := make([]byte, 4)
+	byteOrder.PutUint32(v, LatestVersion)
+	err := ns.Put(rootVersion, v)
+	if err != nil {
+		str := "failed to store latest database version"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	// Save the creation date of the store.
+	v = make([]byte, 8)
+	byteOrder.PutUint64(v, uint64(time.Now().Unix()))
+	err = ns.Put(rootCreateDate, v)
+	if err != nil {
+		str := "failed to store database creation time"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	// Write a zero balance.
+	v = make([]byte, 8)
+	err = ns.Put(rootMinedBalance, v)
+	if err != nil {
+		str := "failed to write zero balance"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketBlocks)
+	if err != nil {
+		str := "failed to create blocks bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketTxRecords)
+	if err != nil {
+		str := "failed to create tx records bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketCredits)
+	if err != nil {
+		str := "failed to create credits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketDebits)
+	if err != nil {
+		str := "failed to create debits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketUnspent)
+	if err != nil {
+		str := "failed to create unspent bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketUnmined)
+	if err != nil {
+		str := "failed to create unmined bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketUnminedCredits)
+	if err != nil {
+		str := "failed to create unmined credits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketUnminedInputs)
+	if err != nil {
+		str := "failed to create unmined inputs bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketLockedOutputs)
+	if err != nil {
+		str := "failed to create locked outputs bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketReleasedLocks)
+	if err != nil {
+		str := "failed to create released locks bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketConflicted)
+	if err != nil {
+		str := "failed to create conflicted transactions bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketConflictWinner)
+	if err != nil {
+		str := "failed to create conflict winners bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketAbandoned)
+	if err != nil {
+		str := "failed to create abandoned transactions bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketSentTxInfo)
+	if err != nil {
+		str := "failed to create sent transaction info bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	_, err = ns.CreateBucket(bucketAddrCredits)
+	if err != nil {
+		str := "failed to create address credits bucket"
+		return storeError(ErrDatabase, str, err)
+	}
+
+	return nil
+}
+
 func scopedUpdate(ns walletdb.Namespace, f func(walletdb.Bucket) error) error {
 	tx, err := ns.Begin(true)
 	if err != nil {
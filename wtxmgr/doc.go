@@ -40,4 +40,10 @@
 // matching hash will be queried.  However, because transaction hashes may
 // collide with other transaction hashes, methods to query for specific
 // transactions in the chain (or unmined) are provided as well.
+//
+// Large numbers of transactions and unspent outputs may be iterated over with
+// the iterators returned by the Transactions and Unspent methods.  Iterators
+// read results in small batches rather than holding a database transaction
+// open, and provide page tokens which allow iteration to be resumed later, such
+// as by a later request of a paginated RPC.
 package wtxmgr
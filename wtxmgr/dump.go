@@ -0,0 +1,498 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// Dump is a logical description of a transaction store, read with ReadDump and
+// restored with WriteDump.  It holds the transactions of the store and their
+// credits, along with the information recorded about them.  Which credits are
+// spent, the debits of mined and unmined transactions, and the balance and
+// indexes of the store are derived from these when a dump is restored.
+type Dump struct {
+	// Transactions are the mined transactions, in the order they appear
+	// in their blocks and in increasing block height, followed by the
+	// unmined transactions.
+	Transactions []TxDump `json:"transactions"`
+
+	// Conflicted are the conflicted transactions, in increasing order of
+	// the height at which the conflict was detected.
+	Conflicted []ConflictedTxDump `json:"conflicted"`
+
+	// LockedOutputs are all locked outputs, including any expired locks
+	// which have not been removed.
+	LockedOutputs []LockedOutputDump `json:"lockedOutputs"`
+
+	// ReleasedLocks are the locks released by mined transactions, which
+	// are restored if the block of the transaction is rolled back.
+	ReleasedLocks []ReleasedLockDump `json:"releasedLocks"`
+}
+
+// TxDump describes a transaction of a dump.  Hash is only written to make
+// dumps easier to read, and is checked against the transaction when a dump is
+// restored.
+type TxDump struct {
+	Hash     string       `json:"hash"`
+	Tx       string       `json:"tx"`
+	Received int64        `json:"received"`
+	Block    *BlockDump   `json:"block,omitempty"`
+	Credits  []CreditDump `json:"credits"`
+	Sent     *SentDump    `json:"sent,omitempty"`
+}
+
+// BlockDump describes the block of a mined transaction of a dump.
+type BlockDump struct {
+	Height int32  `json:"height"`
+	Hash   string `json:"hash"`
+	Time   int64  `json:"time"`
+}
+
+// CreditDump describes a credit of a transaction of a dump.  The amount is the
+// value of the transaction output, except for conflicted transactions, for
+// which it is the amount recorded when the transaction was removed.
+type CreditDump struct {
+	Index  uint32         `json:"index"`
+	Amount btcutil.Amount `json:"amount"`
+	Change bool           `json:"change"`
+}
+
+// DebitDump describes a debit of a conflicted transaction of a dump.
+type DebitDump struct {
+	Index  uint32         `json:"index"`
+	Amount btcutil.Amount `json:"amount"`
+}
+
+// SentDump describes the sent transaction info of a transaction of a dump.
+type SentDump struct {
+	Fee         btcutil.Amount `json:"fee"`
+	ChangeIndex int            `json:"changeIndex"`
+	Recipients  []uint32       `json:"recipients"`
+}
+
+// ConflictedTxDump describes a conflicted transaction of a dump.  The block of
+// the embedded TxDump is always nil.
+type ConflictedTxDump struct {
+	TxDump
+	Debits    []DebitDump `json:"debits"`
+	WinningTx string      `json:"winningTx,omitempty"`
+	Height    int32       `json:"height"`
+	Abandoned int64       `json:"abandoned,omitempty"`
+}
+
+// LockedOutputDump describes a locked output of a dump.  Expiry is zero for
+// locks which never expire.
+type LockedOutputDump struct {
+	Hash   string `json:"hash"`
+	Index  uint32 `json:"index"`
+	Expiry int64  `json:"expiry,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// ReleasedLockDump describes a lock released by a transaction mined in the
+// block at Height.
+type ReleasedLockDump struct {
+	LockedOutputDump
+	Height int32 `json:"height"`
+}
+
+// dumpLock returns the dump description of a lock.
+func dumpLock(lock *LockedOutput) LockedOutputDump {
+	ld := LockedOutputDump{
+		Hash:   lock.OutPoint.Hash.String(),
+		Index:  lock.OutPoint.Index,
+		Reason: lock.Reason,
+	}
+	if !lock.Expiry.IsZero() {
+		ld.Expiry = lock.Expiry.Unix()
+	}
+	return ld
+}
+
+// keyValue returns the key and value of the lock described by a dump.
+func (l *LockedOutputDump) keyValue() (k, v []byte, err error) {
+	hash, err := wire.NewShaHashFromStr(l.Hash)
+	if err != nil {
+		str := fmt.Sprintf("invalid locked output hash %q", l.Hash)
+		return nil, nil, storeError(ErrInput, str, err)
+	}
+	var expiry time.Time
+	if l.Expiry != 0 {
+		expiry = time.Unix(l.Expiry, 0)
+	}
+	return canonicalOutPoint(hash, l.Index), valueLockedOutput(expiry, l.Reason), nil
+}
+
+// dumpTx returns the dump description of a transaction and its credits.
+func dumpTx(ns walletdb.Bucket, details *TxDetails) (*TxDump, error) {
+	var buf bytes.Buffer
+	buf.Grow(details.MsgTx.SerializeSize())
+	err := details.MsgTx.Serialize(&buf)
+	if err != nil {
+		str := fmt.Sprintf("unable to serialize transaction %v",
+			details.Hash)
+		return nil, storeError(ErrData, str, err)
+	}
+	t := &TxDump{
+		Hash:     details.Hash.String(),
+		Tx:       hex.EncodeToString(buf.Bytes()),
+		Received: details.Received.Unix(),
+		Credits:  make([]CreditDump, len(details.Credits)),
+	}
+	if details.Block.Height != -1 {
+		t.Block = &BlockDump{
+			Height: details.Block.Height,
+			Hash:   details.Block.Hash.String(),
+			Time:   details.Block.Time.Unix(),
+		}
+	}
+	for i, c := range details.Credits {
+		t.Credits[i] = CreditDump{c.Index, c.Amount, c.Change}
+	}
+	sent, err := fetchSentTxInfo(ns, &details.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if sent != nil {
+		t.Sent = &SentDump{sent.Fee, sent.ChangeIndex, sent.Recipients}
+	}
+	return t, nil
+}
+
+// record returns the transaction record described by a dump.
+func (t *TxDump) record() (*TxRecord, error) {
+	serializedTx, err := hex.DecodeString(t.Tx)
+	if err != nil {
+		str := fmt.Sprintf("invalid transaction %v", t.Hash)
+		return nil, storeError(ErrInput, str, err)
+	}
+	rec, err := NewTxRecord(serializedTx, time.Unix(t.Received, 0))
+	if err != nil {
+		return nil, err
+	}
+	if t.Hash != "" && t.Hash != rec.Hash.String() {
+		str := fmt.Sprintf("transaction %v has hash %v", t.Hash,
+			rec.Hash)
+		return nil, storeError(ErrInput, str, nil)
+	}
+	for _, c := range t.Credits {
+		if int(c.Index) >= len(rec.MsgTx.TxOut) {
+			str := fmt.Sprintf("credit %d of transaction %v does "+
+				"not exist", c.Index, rec.Hash)
+			return nil, storeError(ErrInput, str, nil)
+		}
+	}
+	return rec, nil
+}
+
+// blockMeta returns the block described by a dump.
+func (b *BlockDump) blockMeta() (*BlockMeta, error) {
+	hash, err := wire.NewShaHashFromStr(b.Hash)
+	if err != nil {
+		str := fmt.Sprintf("invalid block hash %q", b.Hash)
+		return nil, storeError(ErrInput, str, err)
+	}
+	return &BlockMeta{
+		Block: Block{Hash: *hash, Height: b.Height},
+		Time:  time.Unix(b.Time, 0),
+	}, nil
+}
+
+// ReadDump returns the dump of the transaction store in the namespace accessed
+// by tx.  ErrNoExists is returned when no store has been created, and
+// ErrUnknownVersion when the store is not at the latest version and must be
+// opened to upgrade it before it can be dumped.
+func ReadDump(tx walletdb.Tx) (*Dump, error) {
+	ns := tx.RootBucket()
+	v := ns.Get(rootVersion)
+	if len(v) != 4 {
+		str := "no transaction store exists in namespace"
+		return nil, storeError(ErrNoExists, str, nil)
+	}
+	if version := byteOrder.Uint32(v); version != LatestVersion {
+		str := fmt.Sprintf("the store must be upgraded from version %d "+
+			"to version %d before it is dumped", version,
+			LatestVersion)
+		return nil, storeError(ErrUnknownVersion, str, nil)
+	}
+
+	// The store methods used to read and write a dump only access the
+	// passed namespace bucket, so a Store without a namespace is used.
+	var s Store
+	d := &Dump{
+		Transactions:  []TxDump{},
+		Conflicted:    []ConflictedTxDump{},
+		LockedOutputs: []LockedOutputDump{},
+		ReleasedLocks: []ReleasedLockDump{},
+	}
+	addTxs := func(details []TxDetails) (bool, error) {
+		for i := range details {
+			t, err := dumpTx(ns, &details[i])
+			if err != nil {
+				return false, err
+			}
+			d.Transactions = append(d.Transactions, *t)
+		}
+		return false, nil
+	}
+	_, err := s.rangeBlockTransactions(ns, 0, -1, addTxs)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.rangeUnminedTransactions(ns, addTxs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = ns.Bucket(bucketConflicted).ForEach(func(k, v []byte) error {
+		var txHash wire.ShaHash
+		err := readRawUnminedHash(k, &txHash)
+		if err != nil {
+			return err
+		}
+		var c ConflictedTx
+		err = readConflictedTx(ns, &txHash, v, &c)
+		if err != nil {
+			return err
+		}
+		t, err := dumpTx(ns, &c.TxDetails)
+		if err != nil {
+			return err
+		}
+		cd := ConflictedTxDump{
+			TxDump: *t,
+			Debits: make([]DebitDump, len(c.Debits)),
+			Height: c.Height,
+		}
+		for i, deb := range c.Debits {
+			cd.Debits[i] = DebitDump{deb.Index, deb.Amount}
+		}
+		if c.WinningTx != (wire.ShaHash{}) {
+			cd.WinningTx = c.WinningTx.String()
+		}
+		if !c.Abandoned.IsZero() {
+			cd.Abandoned = c.Abandoned.Unix()
+		}
+		d.Conflicted = append(d.Conflicted, cd)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Keys are ordered by transaction hash, so sort by height.  Insertion
+	// sort is used since there are usually few conflicts.
+	for i := 1; i < len(d.Conflicted); i++ {
+		for j := i; j > 0 && d.Conflicted[j].Height < d.Conflicted[j-1].Height; j-- {
+			d.Conflicted[j], d.Conflicted[j-1] = d.Conflicted[j-1], d.Conflicted[j]
+		}
+	}
+
+	err = ns.Bucket(bucketLockedOutputs).ForEach(func(k, v []byte) error {
+		var lock LockedOutput
+		err := readLockedOutput(k, v, &lock)
+		if err != nil {
+			return err
+		}
+		d.LockedOutputs = append(d.LockedOutputs, dumpLock(&lock))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = ns.Bucket(bucketReleasedLocks).ForEach(func(k, v []byte) error {
+		var lock LockedOutput
+		height, err := readReleasedLock(k, v, &lock)
+		if err != nil {
+			return err
+		}
+		d.ReleasedLocks = append(d.ReleasedLocks,
+			ReleasedLockDump{dumpLock(&lock), height})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// WriteDump creates a transaction store in the empty namespace accessed by tx
+// from a dump, at the latest version.  The transactions of the dump are
+// inserted and their credits added as if they were seen again in the same
+// order, so the store is left as it was when it was dumped.  ErrAlreadyExists
+// is returned if the namespace is not empty, and ErrInput if the dump does not
+// describe a valid store.
+func WriteDump(tx walletdb.Tx, d *Dump) error {
+	ns := tx.RootBucket()
+	if err := initStore(ns); err != nil {
+		return err
+	}
+
+	var s Store
+	var unmined []*TxDump
+	height := int32(0)
+	for i := range d.Transactions {
+		t := &d.Transactions[i]
+		if t.Block == nil {
+			unmined = append(unmined, t)
+			continue
+		}
+
+		// Mined transactions must be inserted in block order so the
+		// outputs they spend have already been added as credits.
+		if t.Block.Height < height {
+			str := fmt.Sprintf("transaction %v is not in block "+
+				"order", t.Hash)
+			return storeError(ErrInput, str, nil)
+		}
+		height = t.Block.Height
+		block, err := t.Block.blockMeta()
+		if err != nil {
+			return err
+		}
+		err = s.writeDumpTx(ns, t, block)
+		if err != nil {
+			return err
+		}
+	}
+	for _, t := range unmined {
+		err := s.writeDumpTx(ns, t, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range d.Conflicted {
+		c := &d.Conflicted[i]
+		if c.Block != nil {
+			str := fmt.Sprintf("conflicted transaction %v is mined",
+				c.Hash)
+			return storeError(ErrInput, str, nil)
+		}
+		rec, err := c.record()
+		if err != nil {
+			return err
+		}
+		details := TxDetails{
+			TxRecord: *rec,
+			Block:    BlockMeta{Block: Block{Height: -1}},
+			Credits:  make([]CreditRecord, len(c.Credits)),
+			Debits:   make([]DebitRecord, len(c.Debits)),
+		}
+		for i, cred := range c.Credits {
+			details.Credits[i] = CreditRecord{
+				Index:  cred.Index,
+				Amount: cred.Amount,
+				Change: cred.Change,
+			}
+		}
+		for i, deb := range c.Debits {
+			details.Debits[i] = DebitRecord{
+				Index:  deb.Index,
+				Amount: deb.Amount,
+			}
+		}
+		conf := conflict{height: c.Height}
+		if c.WinningTx != "" {
+			conf.winner, err = wire.NewShaHashFromStr(c.WinningTx)
+			if err != nil {
+				str := fmt.Sprintf("invalid winning transaction "+
+					"hash %q", c.WinningTx)
+				return storeError(ErrInput, str, err)
+			}
+		}
+		if c.Abandoned != 0 {
+			conf.abandoned = time.Unix(c.Abandoned, 0)
+		}
+		err = putConflictedTx(ns, &details, &conf)
+		if err != nil {
+			return err
+		}
+		err = putDumpSentTxInfo(ns, &rec.Hash, c.Sent)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range d.LockedOutputs {
+		k, v, err := d.LockedOutputs[i].keyValue()
+		if err != nil {
+			return err
+		}
+		err = putRawLockedOutput(ns, k, v)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range d.ReleasedLocks {
+		l := &d.ReleasedLocks[i]
+		k, v, err := l.keyValue()
+		if err != nil {
+			return err
+		}
+		err = putRawReleasedLock(ns, k, valueReleasedLock(l.Height, v))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeDumpTx inserts a mined or unmined transaction of a dump, adds its
+// credits, and saves its sent transaction info.
+func (s *Store) writeDumpTx(ns walletdb.Bucket, t *TxDump, block *BlockMeta) error {
+	rec, err := t.record()
+	if err != nil {
+		return err
+	}
+	if block == nil {
+		err = s.insertMemPoolTx(ns, rec)
+	} else {
+		err = s.insertMinedTx(ns, rec, block)
+	}
+	if err != nil {
+		return err
+	}
+	for _, c := range t.Credits {
+		err := s.addCredit(ns, rec, block, c.Index, c.Change)
+		if err != nil {
+			return err
+		}
+	}
+	return putDumpSentTxInfo(ns, &rec.Hash, t.Sent)
+}
+
+// putDumpSentTxInfo saves the sent transaction info of a transaction of a
+// dump, if any.
+func putDumpSentTxInfo(ns walletdb.Bucket, txHash *wire.ShaHash, sent *SentDump) error {
+	if sent == nil {
+		return nil
+	}
+	info := SentTxInfo{
+		Fee:         sent.Fee,
+		ChangeIndex: sent.ChangeIndex,
+		Recipients:  sent.Recipients,
+	}
+	return putRawSentTxInfo(ns, txHash[:], valueSentTxInfo(&info))
+}
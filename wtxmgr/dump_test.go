@@ -0,0 +1,217 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	. "github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestDump(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("txstore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Create(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a mined credit spent by a mined transaction with change, an
+	// unmined spend of the change, an abandoned transaction, sent info,
+	// and locks.
+	cbRec, err := NewTxRecordFromMsgTx(newCoinBase(50e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(cbRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(cbRec, &b100, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	spendRec, err := NewTxRecordFromMsgTx(spendOutput(&cbRec.Hash, 0, 10e8, 39e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	releasedOp := wire.OutPoint{Hash: cbRec.Hash, Index: 0}
+	if err := s.LockOutput(&releasedOp, time.Time{}, "released"); err != nil {
+		t.Fatal(err)
+	}
+	b101 := makeBlockMeta(101)
+	if err := s.InsertTx(spendRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(spendRec, &b101, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	info := SentTxInfo{Fee: 1e8, ChangeIndex: 1, Recipients: []uint32{0}}
+	if err := s.RecordSentTx(&spendRec.Hash, &info); err != nil {
+		t.Fatal(err)
+	}
+	unminedRec, err := NewTxRecordFromMsgTx(spendOutput(&spendRec.Hash, 1, 5e8, 33e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(unminedRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(unminedRec, nil, 1, true); err != nil {
+		t.Fatal(err)
+	}
+	abandonRec, err := NewTxRecordFromMsgTx(spendOutput(&unminedRec.Hash, 1, 32e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(abandonRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AbandonTx(&abandonRec.Hash, 101); err != nil {
+		t.Fatal(err)
+	}
+	lockedOp := wire.OutPoint{Hash: unminedRec.Hash, Index: 1}
+	expiry := time.Unix(time.Now().Unix()+3600, 0)
+	if err := s.LockOutput(&lockedOp, expiry, "dump test"); err != nil {
+		t.Fatal(err)
+	}
+
+	var d *Dump
+	err = ns.View(func(tx walletdb.Tx) error {
+		var err error
+		d, err = ReadDump(tx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Transactions) != 3 || len(d.Conflicted) != 1 ||
+		len(d.LockedOutputs) != 1 || len(d.ReleasedLocks) != 1 {
+		t.Fatalf("ReadDump: dumped %d transactions, %d conflicted "+
+			"transactions, %d locked outputs, and %d released locks, "+
+			"expected 3, 1, 1, and 1", len(d.Transactions),
+			len(d.Conflicted), len(d.LockedOutputs),
+			len(d.ReleasedLocks))
+	}
+
+	// Restore the dump, after encoding it as JSON, to a new namespace.
+	out, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var restored Dump
+	if err := json.Unmarshal(out, &restored); err != nil {
+		t.Fatal(err)
+	}
+	ns2, err := db.Namespace([]byte("restored"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ns2.Update(func(tx walletdb.Tx) error {
+		return WriteDump(tx, &restored)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := Open(ns2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The restored store must dump identically, pass its checks, and
+	// report the same balances, unspent outputs, and details.
+	var d2 *Dump
+	err = ns2.View(func(tx walletdb.Tx) error {
+		var err error
+		d2, err = ReadDump(tx)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d, d2) {
+		t.Errorf("ReadDump: restored store dumps as %+v, expected %+v",
+			d2, d)
+	}
+	problems, err := s2.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Check: restored store has problems: %v", problems)
+	}
+	for _, minConf := range []int32{0, 1} {
+		bal, err := s.Balance(minConf, 101)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bal2, err := s2.Balance(minConf, 101)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bal != bal2 {
+			t.Errorf("Balance: restored store has %d conf balance %v, "+
+				"expected %v", minConf, bal2, bal)
+		}
+	}
+	unspent, err := s.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	unspent2, err := s2.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(unspent, unspent2) {
+		t.Errorf("UnspentOutputs: restored store has %v, expected %v",
+			unspent2, unspent)
+	}
+	for _, rec := range []*TxRecord{cbRec, spendRec, unminedRec} {
+		details, err := s.TxDetails(&rec.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		details2, err := s2.TxDetails(&rec.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(details, details2) {
+			t.Errorf("TxDetails: restored transaction %v has details "+
+				"%+v, expected %+v", rec.Hash, details2, details)
+		}
+	}
+
+	// A dump may only be restored to an empty namespace.
+	err = ns2.Update(func(tx walletdb.Tx) error {
+		return WriteDump(tx, &restored)
+	})
+	if serr, ok := err.(Error); !ok || serr.Code != ErrAlreadyExists {
+		t.Errorf("WriteDump: restoring to a store gave error %v, "+
+			"expected %v", err, ErrAlreadyExists)
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// BlockBalance describes the balance of mined outputs after every transaction
+// in a block has been applied.
+type BlockBalance struct {
+	Block   BlockMeta
+	Balance btcutil.Amount
+}
+
+// BalanceAt returns the total value of all mined credits which were not spent
+// by any transaction mined at or before height.  Unlike Balance, this is not a
+// spendable balance: unmined transactions are not considered, and coinbase
+// credits are included regardless of their maturity at that height.  It is
+// intended for reporting how the wallet's balance changed over time.
+func (s *Store) BalanceAt(height int32) (btcutil.Amount, error) {
+	var bal btcutil.Amount
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		var err error
+		bal, err = s.balanceAt(ns, height)
+		return err
+	})
+	return bal, err
+}
+
+func (s *Store) balanceAt(ns walletdb.Bucket, height int32) (btcutil.Amount, error) {
+	var bal btcutil.Amount
+	err := s.rangeBalances(ns, 0, height, nil, func(b BlockBalance) (bool, error) {
+		bal = b.Balance
+		return false, nil
+	})
+	return bal, err
+}
+
+// RangeBalances runs the function f with the balance (as returned by
+// BalanceAt) following each block between heights begin and end, inclusive,
+// in increasing height order.  Only blocks containing at least one wallet
+// transaction are recorded by the store, so the balance is unchanged at every
+// height skipped between two calls to f.
+//
+// The first call to f is always with the opening balance following the block
+// at height begin.  If the store did not record this block, only the height of
+// the block is set, and the hash and time must be found by the caller.
+//
+// If include is non-nil, only credits paying to output scripts for which it
+// returns true, and debits spending these credits, change the balance.  This
+// may be used to report the history of a subset of the wallet, such as a
+// single account.
+//
+// The function f may return an error which, if non-nil, is propagated to the
+// caller.  Returning true stops the iteration early.
+func (s *Store) RangeBalances(begin, end int32, include func(pkScript []byte) bool,
+	f func(BlockBalance) (bool, error)) error {
+
+	return scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		return s.rangeBalances(ns, begin, end, include, f)
+	})
+}
+
+func (s *Store) rangeBalances(ns walletdb.Bucket, begin, end int32, include func([]byte) bool,
+	f func(BlockBalance) (bool, error)) error {
+
+	if end < begin {
+		return nil
+	}
+
+	// The balance at any height depends on every earlier block, so all
+	// blocks are read beginning from the genesis block, but f is only
+	// called for blocks in the requested range.
+	var bal btcutil.Amount
+	opened := false
+	open := func() (bool, error) {
+		opened = true
+		b := BlockBalance{
+			Block:   BlockMeta{Block: Block{Height: begin}},
+			Balance: bal,
+		}
+		return f(b)
+	}
+	blockIt := makeBlockIterator(ns, 0)
+	for blockIt.next() {
+		block := &blockIt.elem
+
+		if block.Height > end {
+			break
+		}
+		if block.Height > begin && !opened {
+			brk, err := open()
+			if err != nil || brk {
+				return err
+			}
+		}
+
+		for i := range block.transactions {
+			txHash := &block.transactions[i]
+			k := keyTxRecord(txHash, &block.Block)
+			v := existsRawTxRecord(ns, k)
+			if v == nil {
+				str := fmt.Sprintf("missing transaction %v for "+
+					"block %v", txHash, block.Height)
+				return storeError(ErrData, str, nil)
+			}
+
+			credIter := makeCreditIterator(ns, k)
+			for credIter.next() {
+				if include != nil {
+					pkScript, err := fetchRawTxRecordPkScript(k,
+						v, credIter.elem.Index)
+					if err != nil {
+						return err
+					}
+					if !include(pkScript) {
+						continue
+					}
+				}
+				bal += credIter.elem.Amount
+			}
+			if credIter.err != nil {
+				return credIter.err
+			}
+
+			debIter := makeDebitIterator(ns, k)
+			for debIter.next() {
+				if include != nil {
+					credKey := extractRawDebitCreditKey(debIter.cv)
+					prevKey := credKey[:68]
+					prevVal := existsRawTxRecord(ns, prevKey)
+					if prevVal == nil {
+						str := "missing transaction record " +
+							"for debited credit"
+						return storeError(ErrData, str, nil)
+					}
+					prevIndex := byteOrder.Uint32(credKey[68:72])
+					pkScript, err := fetchRawTxRecordPkScript(
+						prevKey, prevVal, prevIndex)
+					if err != nil {
+						return err
+					}
+					if !include(pkScript) {
+						continue
+					}
+				}
+				bal -= debIter.elem.Amount
+			}
+			if debIter.err != nil {
+				return debIter.err
+			}
+		}
+
+		if block.Height < begin {
+			continue
+		}
+		opened = true
+		b := BlockBalance{
+			Block: BlockMeta{
+				Block: block.Block,
+				Time:  block.Time,
+			},
+			Balance: bal,
+		}
+		brk, err := f(b)
+		if err != nil || brk {
+			return err
+		}
+	}
+	if blockIt.err != nil {
+		return blockIt.err
+	}
+	if !opened {
+		_, err := open()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	. "github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestBalanceHistory(t *testing.T) {
+	t.Parallel()
+
+	s, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scriptA := []byte{0x01}
+	scriptB := []byte{0x02}
+
+	// Receive two outputs to different scripts in block 100, spend the
+	// first in block 105 with an output paying back to the second script,
+	// and spend the remaining output in an unmined transaction.
+	b100 := makeBlockMeta(100)
+	recvTx := spendOutput(&wire.ShaHash{}, 0, 3e8, 2e8)
+	recvTx.TxOut[0].PkScript = scriptA
+	recvTx.TxOut[1].PkScript = scriptB
+	recvRec, err := NewTxRecordFromMsgTx(recvTx, b100.Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b105 := makeBlockMeta(105)
+	spendTx := spendOutput(&recvRec.Hash, 0, 1e8)
+	spendTx.TxOut[0].PkScript = scriptB
+	spendRec, err := NewTxRecordFromMsgTx(spendTx, b105.Time)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unminedRec, err := NewTxRecordFromMsgTx(spendOutput(&recvRec.Hash, 1, 1e8), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.InsertTx(recvRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < 2; i++ {
+		if err := s.AddCredit(recvRec, &b100, i, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.InsertTx(spendRec, &b105); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddCredit(spendRec, &b105, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(unminedRec, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	balanceAtTests := []struct {
+		height  int32
+		balance btcutil.Amount
+	}{
+		{99, 0},
+		{100, 5e8},
+		{104, 5e8},
+		{105, 3e8},
+		{200, 3e8},
+	}
+	for _, test := range balanceAtTests {
+		bal, err := s.BalanceAt(test.height)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bal != test.balance {
+			t.Errorf("BalanceAt(%d): got %v, expected %v",
+				test.height, bal, test.balance)
+		}
+	}
+
+	includeScript := func(script []byte) func([]byte) bool {
+		return func(pkScript []byte) bool {
+			return bytes.Equal(pkScript, script)
+		}
+	}
+	opening := func(height int32, balance btcutil.Amount) BlockBalance {
+		return BlockBalance{
+			Block:   BlockMeta{Block: Block{Height: height}},
+			Balance: balance,
+		}
+	}
+	rangeTests := []struct {
+		desc       string
+		begin, end int32
+		include    func([]byte) bool
+		history    []BlockBalance
+	}{
+		{
+			desc:  "all blocks",
+			begin: 0,
+			end:   200,
+			history: []BlockBalance{
+				opening(0, 0),
+				{Block: b100, Balance: 5e8},
+				{Block: b105, Balance: 3e8},
+			},
+		},
+		{
+			desc:  "later blocks",
+			begin: 101,
+			end:   200,
+			history: []BlockBalance{
+				opening(101, 5e8),
+				{Block: b105, Balance: 3e8},
+			},
+		},
+		{
+			desc:  "earlier blocks",
+			begin: 0,
+			end:   104,
+			history: []BlockBalance{
+				opening(0, 0),
+				{Block: b100, Balance: 5e8},
+			},
+		},
+		{
+			desc:  "recorded first block",
+			begin: 100,
+			end:   104,
+			history: []BlockBalance{
+				{Block: b100, Balance: 5e8},
+			},
+		},
+		{
+			desc:  "no recorded blocks",
+			begin: 101,
+			end:   104,
+			history: []BlockBalance{
+				opening(101, 5e8),
+			},
+		},
+		{
+			desc:    "first script",
+			begin:   0,
+			end:     200,
+			include: includeScript(scriptA),
+			history: []BlockBalance{
+				opening(0, 0),
+				{Block: b100, Balance: 3e8},
+				{Block: b105, Balance: 0},
+			},
+		},
+		{
+			desc:    "second script",
+			begin:   0,
+			end:     200,
+			include: includeScript(scriptB),
+			history: []BlockBalance{
+				opening(0, 0),
+				{Block: b100, Balance: 2e8},
+				{Block: b105, Balance: 3e8},
+			},
+		},
+	}
+	for _, test := range rangeTests {
+		var history []BlockBalance
+		err := s.RangeBalances(test.begin, test.end, test.include,
+			func(b BlockBalance) (bool, error) {
+				history = append(history, b)
+				return false, nil
+			})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(history, test.history) {
+			t.Errorf("RangeBalances (%s): got %v, expected %v",
+				test.desc, history, test.history)
+		}
+	}
+
+	// Returning true from the function stops the iteration.
+	calls := 0
+	err = s.RangeBalances(0, 200, nil, func(BlockBalance) (bool, error) {
+		calls++
+		return true, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Errorf("RangeBalances: function called %d times after "+
+			"returning true, expected 1", calls)
+	}
+}
@@ -0,0 +1,501 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// iteratorBatchSize is the number of results an iterator reads from the
+// database in a single view.  Views are never held open between batches, so
+// iterating does not block writes to the store for longer than it takes to
+// read one batch.
+const iteratorBatchSize = 100
+
+// PageToken records the position of an iterator between two results.  An
+// iterator created with the token of a previous iterator resumes with the
+// result following the one the token was taken at, which allows callers to
+// page through large numbers of results over multiple requests.  Tokens are
+// opaque printable strings.  The empty token begins iteration at the first
+// result.
+//
+// Tokens remain valid as the store is modified.  Results added before the
+// position of a token are not returned by the resumed iterator, and results
+// removed after it are not returned either.
+type PageToken string
+
+// Token kinds and iteration phases encoded in page tokens.
+const (
+	pageTokenVersion = 1
+
+	tokenKindTx      = 't'
+	tokenKindUnspent = 'u'
+
+	phaseMined   = 0
+	phaseUnmined = 1
+	phaseDone    = 2
+)
+
+// iterPosition is the decoded position of a page token.  For transaction
+// iterators, height and key record the block height and hash of the last
+// returned mined transaction, or the hash of the last returned unmined
+// transaction.  For unspent output iterators, key records the outpoint key of
+// the last returned credit.  When key is nil, iteration of the phase begins at
+// its first result.
+type iterPosition struct {
+	phase  byte
+	height int32
+	key    []byte
+}
+
+func (p *iterPosition) token(kind byte) PageToken {
+	b := make([]byte, 3, 7+len(p.key))
+	b[0] = pageTokenVersion
+	b[1] = kind
+	b[2] = p.phase
+	if kind == tokenKindTx && p.phase == phaseMined {
+		var height [4]byte
+		byteOrder.PutUint32(height[:], uint32(p.height))
+		b = append(b, height[:]...)
+	}
+	b = append(b, p.key...)
+	return PageToken(hex.EncodeToString(b))
+}
+
+func decodePageToken(token PageToken, kind byte) (*iterPosition, error) {
+	b, err := hex.DecodeString(string(token))
+	if err != nil {
+		str := "invalid page token"
+		return nil, storeError(ErrInput, str, err)
+	}
+	if len(b) < 3 || b[0] != pageTokenVersion || b[1] != kind ||
+		b[2] > phaseDone {
+		str := "invalid page token"
+		return nil, storeError(ErrInput, str, nil)
+	}
+	p := &iterPosition{phase: b[2]}
+	b = b[3:]
+	keySize := 36
+	if kind == tokenKindTx {
+		keySize = 32
+		if p.phase == phaseMined {
+			if len(b) < 4 {
+				str := "invalid page token"
+				return nil, storeError(ErrInput, str, nil)
+			}
+			p.height = int32(byteOrder.Uint32(b))
+			b = b[4:]
+		}
+	}
+	if len(b) != 0 && len(b) != keySize {
+		str := "invalid page token"
+		return nil, storeError(ErrInput, str, nil)
+	}
+	if len(b) != 0 {
+		p.key = b
+	}
+	return p, nil
+}
+
+// TxIterator iterates over the details of recorded transactions in the order
+// described by Store.Transactions.  Transactions are read in batches, each in
+// a separate database view.
+type TxIterator struct {
+	s *Store
+
+	// Range of block heights to iterate over.  Heights of -1 have been
+	// replaced by the highest possible height.
+	begin, end   int32
+	forward      bool
+	unminedFirst bool
+	unminedLast  bool
+
+	pos       iterPosition // Position after the last read result
+	results   []TxDetails
+	positions []iterPosition // Position after each read result
+	i         int
+	token     PageToken
+	err       error
+}
+
+// Transactions returns an iterator over the details of all transactions mined
+// in blocks over the height range [begin,end], beginning after the position of
+// token.  The special height -1 may be used to also include unmined
+// transactions.  If the end height comes before the begin height, blocks and
+// the transactions in each block are iterated in reverse order and unmined
+// transactions (if any) are iterated first.  Otherwise, unmined transactions
+// (if any) are iterated last.  Unmined transactions are iterated in order of
+// their hashes.
+//
+// Resuming iteration with a token requires passing the same range.  An
+// iterator returns no results if the token can not be decoded, in which case
+// its Err method returns an ErrInput error.
+func (s *Store) Transactions(begin, end int32, token PageToken) *TxIterator {
+	it := &TxIterator{
+		s:            s,
+		begin:        begin,
+		end:          end,
+		unminedFirst: begin < 0,
+		unminedLast:  begin >= 0 && end < 0,
+		i:            -1,
+		token:        token,
+	}
+
+	// Mempool height is considered a high bound.
+	if it.begin < 0 {
+		it.begin = int32(^uint32(0) >> 1)
+	}
+	if it.end < 0 {
+		it.end = int32(^uint32(0) >> 1)
+	}
+	it.forward = it.begin < it.end
+
+	if token != "" {
+		pos, err := decodePageToken(token, tokenKindTx)
+		if err != nil {
+			it.err = err
+			it.pos.phase = phaseDone
+			return it
+		}
+		it.pos = *pos
+		return it
+	}
+	if it.unminedFirst {
+		it.pos = iterPosition{phase: phaseUnmined}
+	} else {
+		it.pos = iterPosition{phase: phaseMined, height: it.begin}
+	}
+	return it
+}
+
+// Next advances the iterator to the next transaction, returning false when
+// there are no more transactions or an error occurred.
+func (it *TxIterator) Next() bool {
+	if it.i+1 < len(it.results) {
+		it.i++
+		return true
+	}
+	if it.err != nil || it.pos.phase == phaseDone {
+		return false
+	}
+
+	it.results = nil
+	it.positions = nil
+	it.i = -1
+	err := scopedView(it.s.namespace, func(ns walletdb.Bucket) error {
+		for len(it.results) < iteratorBatchSize && it.pos.phase != phaseDone {
+			var err error
+			switch it.pos.phase {
+			case phaseMined:
+				err = it.readMined(ns)
+			case phaseUnmined:
+				err = it.readUnmined(ns)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		it.err = err
+		it.results = nil
+		return false
+	}
+	if len(it.results) == 0 {
+		return false
+	}
+	it.i = 0
+	return true
+}
+
+// Details returns the details of the current transaction.  The details are
+// only valid until the next call to Next.
+func (it *TxIterator) Details() *TxDetails {
+	return &it.results[it.i]
+}
+
+// Token returns a token positioned after the current transaction, or the token
+// the iterator was created with if Next has not returned true.
+func (it *TxIterator) Token() PageToken {
+	if it.i < 0 {
+		return it.token
+	}
+	return it.positions[it.i].token(tokenKindTx)
+}
+
+// Err returns the error, if any, which stopped iteration.
+func (it *TxIterator) Err() error {
+	return it.err
+}
+
+func (it *TxIterator) add(details *TxDetails, pos iterPosition) {
+	it.results = append(it.results, *details)
+	it.positions = append(it.positions, pos)
+	it.pos = pos
+}
+
+// readMined reads mined transactions, beginning after the current position,
+// until the batch is full or no transactions remain in the range.
+func (it *TxIterator) readMined(ns walletdb.Bucket) error {
+	blockIter := makeBlockIterator(ns, it.pos.height)
+	advance, inRange := blockIter.next, func(h int32) bool {
+		return h <= it.end
+	}
+	if !it.forward {
+		advance, inRange = blockIter.prev, func(h int32) bool {
+			return h >= it.end
+		}
+	}
+
+	for advance() {
+		block := &blockIter.elem
+		if !inRange(block.Height) {
+			break
+		}
+
+		// Transactions of each block are iterated in reverse when
+		// iterating blocks in reverse.
+		n := len(block.transactions)
+		txAt := func(i int) *wire.ShaHash {
+			if it.forward {
+				return &block.transactions[i]
+			}
+			return &block.transactions[n-1-i]
+		}
+
+		// Skip past the last returned transaction when resuming in the
+		// same block.
+		start := 0
+		if block.Height == it.pos.height && it.pos.key != nil {
+			for i := 0; i < n; i++ {
+				if bytes.Equal(txAt(i)[:], it.pos.key) {
+					start = i + 1
+					break
+				}
+			}
+		}
+
+		for i := start; i < n; i++ {
+			if len(it.results) == iteratorBatchSize {
+				return nil
+			}
+			txHash := txAt(i)
+			k := keyTxRecord(txHash, &block.Block)
+			v := existsRawTxRecord(ns, k)
+			if v == nil {
+				str := "missing transaction " + txHash.String() +
+					" for block"
+				return storeError(ErrData, str, nil)
+			}
+			details, err := it.s.minedTxDetails(ns, txHash, k, v)
+			if err != nil {
+				return err
+			}
+			it.add(details, iterPosition{
+				phase:  phaseMined,
+				height: block.Height,
+				key:    append([]byte(nil), txHash[:]...),
+			})
+		}
+	}
+	if blockIter.err != nil {
+		return blockIter.err
+	}
+
+	if it.unminedLast {
+		it.pos = iterPosition{phase: phaseUnmined}
+	} else {
+		it.pos = iterPosition{phase: phaseDone}
+	}
+	return nil
+}
+
+// readUnmined reads unmined transactions, beginning after the current
+// position, until the batch is full or no unmined transactions remain.
+func (it *TxIterator) readUnmined(ns walletdb.Bucket) error {
+	c := ns.Bucket(bucketUnmined).Cursor()
+	var k, v []byte
+	if it.pos.key == nil {
+		k, v = c.First()
+	} else {
+		k, v = c.Seek(it.pos.key)
+		if bytes.Equal(k, it.pos.key) {
+			k, v = c.Next()
+		}
+	}
+
+	for ; k != nil; k, v = c.Next() {
+		if len(it.results) == iteratorBatchSize {
+			return nil
+		}
+		if len(k) < 32 {
+			str := "short key in unmined bucket"
+			return storeError(ErrData, str, nil)
+		}
+		var txHash wire.ShaHash
+		copy(txHash[:], k)
+		details, err := it.s.unminedTxDetails(ns, &txHash, v)
+		if err != nil {
+			return err
+		}
+		it.add(details, iterPosition{
+			phase: phaseUnmined,
+			key:   append([]byte(nil), k...),
+		})
+	}
+
+	if it.unminedFirst {
+		it.pos = iterPosition{phase: phaseMined, height: it.begin}
+	} else {
+		it.pos = iterPosition{phase: phaseDone}
+	}
+	return nil
+}
+
+// UnspentIterator iterates over the unspent outputs of the store in the order
+// described by Store.Unspent.  Outputs are read in batches, each in a separate
+// database view.
+type UnspentIterator struct {
+	s *Store
+
+	pos       iterPosition // Position after the last read result
+	results   []Credit
+	positions []iterPosition // Position after each read result
+	i         int
+	token     PageToken
+	err       error
+}
+
+// Unspent returns an iterator over the outputs returned by UnspentOutputs,
+// beginning after the position of token.  Mined outputs are iterated first,
+// followed by unmined outputs, each in order of their outpoints.  Outputs spent
+// by unmined transactions are not included.
+//
+// An iterator returns no results if the token can not be decoded, in which case
+// its Err method returns an ErrInput error.
+func (s *Store) Unspent(token PageToken) *UnspentIterator {
+	it := &UnspentIterator{
+		s:     s,
+		i:     -1,
+		token: token,
+	}
+	if token != "" {
+		pos, err := decodePageToken(token, tokenKindUnspent)
+		if err != nil {
+			it.err = err
+			it.pos.phase = phaseDone
+			return it
+		}
+		it.pos = *pos
+	}
+	return it
+}
+
+// Next advances the iterator to the next unspent output, returning false when
+// there are no more outputs or an error occurred.
+func (it *UnspentIterator) Next() bool {
+	if it.i+1 < len(it.results) {
+		it.i++
+		return true
+	}
+	if it.err != nil || it.pos.phase == phaseDone {
+		return false
+	}
+
+	it.results = nil
+	it.positions = nil
+	it.i = -1
+	err := scopedView(it.s.namespace, func(ns walletdb.Bucket) error {
+		for len(it.results) < iteratorBatchSize && it.pos.phase != phaseDone {
+			bucket := bucketUnspent
+			next := phaseUnmined
+			if it.pos.phase == phaseUnmined {
+				bucket = bucketUnminedCredits
+				next = phaseDone
+			}
+
+			c := ns.Bucket(bucket).Cursor()
+			var k, v []byte
+			if it.pos.key == nil {
+				k, v = c.First()
+			} else {
+				k, v = c.Seek(it.pos.key)
+				if bytes.Equal(k, it.pos.key) {
+					k, v = c.Next()
+				}
+			}
+			for ; k != nil; k, v = c.Next() {
+				if len(it.results) == iteratorBatchSize {
+					return nil
+				}
+				if existsRawUnminedInput(ns, k) != nil {
+					// Output is spent by an unmined
+					// transaction.
+					continue
+				}
+				var cred *Credit
+				var err error
+				if it.pos.phase == phaseMined {
+					cred, err = minedUnspentCredit(ns, k, v)
+				} else {
+					cred, err = unminedUnspentCredit(ns, k)
+				}
+				if err != nil {
+					return err
+				}
+				it.results = append(it.results, *cred)
+				it.pos.key = append([]byte(nil), k...)
+				it.positions = append(it.positions, it.pos)
+			}
+			it.pos = iterPosition{phase: byte(next)}
+		}
+		return nil
+	})
+	if err != nil {
+		it.err = err
+		it.results = nil
+		return false
+	}
+	if len(it.results) == 0 {
+		return false
+	}
+	it.i = 0
+	return true
+}
+
+// Credit returns the current unspent output.  The credit is only valid until
+// the next call to Next.
+func (it *UnspentIterator) Credit() *Credit {
+	return &it.results[it.i]
+}
+
+// Token returns a token positioned after the current output, or the token the
+// iterator was created with if Next has not returned true.
+func (it *UnspentIterator) Token() PageToken {
+	if it.i < 0 {
+		return it.token
+	}
+	return it.positions[it.i].token(tokenKindUnspent)
+}
+
+// Err returns the error, if any, which stopped iteration.
+func (it *UnspentIterator) Err() error {
+	return it.err
+}
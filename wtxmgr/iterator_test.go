@@ -0,0 +1,206 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr_test
+
+import (
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestIterators(t *testing.T) {
+	t.Parallel()
+
+	s, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insertTx := func(tx *wire.MsgTx, height int32) *TxRecord {
+		rec, err := NewTxRecordFromMsgTx(tx, timeNow())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var block *BlockMeta
+		if height != -1 {
+			b := makeBlockMeta(height)
+			block = &b
+		}
+		if err := s.InsertTx(rec, block); err != nil {
+			t.Fatal(err)
+		}
+		for i := range tx.TxOut {
+			err := s.AddCredit(rec, block, uint32(i), false)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec
+	}
+
+	// Record coinbases in several blocks, with multiple transactions in
+	// some blocks, and unmined transactions spending some of their
+	// outputs.
+	cb1 := insertTx(newCoinBase(1e8, 2e8), 100)
+	cb2 := insertTx(newCoinBase(3e8), 101)
+	cb3 := insertTx(newCoinBase(4e8, 5e8), 101)
+	insertTx(spendOutput(&cb1.Hash, 0, 9e7), 101)
+	insertTx(newCoinBase(6e8), 103)
+	insertTx(spendOutput(&cb2.Hash, 0, 2e8, 9e7), -1)
+	insertTx(spendOutput(&cb3.Hash, 1, 4e8), -1)
+
+	// rangeHashes returns the hashes of every transaction in the order
+	// they are expected to be iterated in.
+	rangeHashes := func(begin, end int32) []wire.ShaHash {
+		// Blocks are iterated in reverse unless the begin height,
+		// using the highest height for mempool, is lower than the end.
+		reverse := !(begin >= 0 && (end < 0 || begin < end))
+
+		var hashes []wire.ShaHash
+		err := s.RangeTransactions(begin, end, func(details []TxDetails) (bool, error) {
+			for i := range details {
+				d := &details[i]
+				if reverse && d.Block.Height != -1 {
+					// Transactions in each block are
+					// iterated in reverse when iterating
+					// blocks in reverse.
+					d = &details[len(details)-1-i]
+				}
+				hashes = append(hashes, d.Hash)
+			}
+			return false, nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hashes
+	}
+
+	tests := []struct {
+		begin, end int32
+	}{
+		{-1, 0},
+		{0, -1},
+		{101, 103},
+		{103, 101},
+		{-1, 102},
+		{101, 101},
+	}
+	for _, test := range tests {
+		exp := rangeHashes(test.begin, test.end)
+
+		// Iterate over all transactions, and check that resuming with
+		// the token of each transaction continues with the next.
+		var got []wire.ShaHash
+		var tokens []PageToken
+		it := s.Transactions(test.begin, test.end, "")
+		for it.Next() {
+			got = append(got, it.Details().Hash)
+			tokens = append(tokens, it.Token())
+		}
+		if err := it.Err(); err != nil {
+			t.Fatalf("Transactions(%d, %d): %v", test.begin, test.end, err)
+		}
+		if len(got) != len(exp) {
+			t.Fatalf("Transactions(%d, %d): got %d transactions, expected %d",
+				test.begin, test.end, len(got), len(exp))
+		}
+		for i := range got {
+			if got[i] != exp[i] {
+				t.Errorf("Transactions(%d, %d): transaction %d is %v, expected %v",
+					test.begin, test.end, i, got[i], exp[i])
+			}
+		}
+		for i, token := range tokens {
+			it := s.Transactions(test.begin, test.end, token)
+			n := i + 1
+			for it.Next() {
+				if n >= len(exp) || it.Details().Hash != exp[n] {
+					t.Errorf("Transactions(%d, %d): resuming after "+
+						"transaction %d returned %v out of order",
+						test.begin, test.end, i, it.Details().Hash)
+					break
+				}
+				n++
+			}
+			if err := it.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if n != len(exp) && !t.Failed() {
+				t.Errorf("Transactions(%d, %d): resuming after "+
+					"transaction %d returned %d transactions, expected %d",
+					test.begin, test.end, i, n-i-1, len(exp)-i-1)
+			}
+		}
+	}
+
+	// Check that every unspent output is iterated over exactly once, and
+	// that resuming with the token of each output continues with the next.
+	unspent, err := s.UnspentOutputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expUnspent := make(map[wire.OutPoint]struct{})
+	for i := range unspent {
+		expUnspent[unspent[i].OutPoint] = struct{}{}
+	}
+	var gotUnspent []wire.OutPoint
+	var tokens []PageToken
+	it := s.Unspent("")
+	for it.Next() {
+		op := it.Credit().OutPoint
+		if _, ok := expUnspent[op]; !ok {
+			t.Errorf("Unspent: unexpected output %v", op)
+		}
+		delete(expUnspent, op)
+		gotUnspent = append(gotUnspent, op)
+		tokens = append(tokens, it.Token())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for op := range expUnspent {
+		t.Errorf("Unspent: missing output %v", op)
+	}
+	for i, token := range tokens {
+		it := s.Unspent(token)
+		n := i + 1
+		for it.Next() {
+			if n >= len(gotUnspent) || it.Credit().OutPoint != gotUnspent[n] {
+				t.Errorf("Unspent: resuming after output %d returned %v "+
+					"out of order", i, it.Credit().OutPoint)
+				break
+			}
+			n++
+		}
+		if err := it.Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Invalid tokens must be reported.
+	for _, token := range []PageToken{"zz", "01", tokens[0]} {
+		it := s.Transactions(-1, 0, token)
+		if it.Next() {
+			t.Errorf("Transactions: iterated with invalid token %q", token)
+		}
+		if serr, ok := it.Err().(Error); !ok || serr.Code != ErrInput {
+			t.Errorf("Transactions: unexpected error for invalid "+
+				"token %q: %v", token, it.Err())
+		}
+	}
+}
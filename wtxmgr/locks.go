@@ -0,0 +1,246 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// LockedOutput describes an output locked with LockOutput.
+type LockedOutput struct {
+	OutPoint wire.OutPoint
+	Expiry   time.Time // Zero if the lock never expires
+	Reason   string
+}
+
+// Expired returns whether the lock has expired at time t.
+func (l *LockedOutput) Expired(t time.Time) bool {
+	return !l.Expiry.IsZero() && !t.Before(l.Expiry)
+}
+
+// LockOutput locks an output so it is not used as an input of newly created
+// transactions until it is unlocked, the lock expires, or the output is spent
+// by a mined transaction.  A lock released by a mined transaction is restored
+// if the transaction's block is rolled back.  A zero expiry time locks the
+// output until it is unlocked or spent.  Locking an already locked output
+// replaces the expiry and reason of the existing lock.
+//
+// The expiry is recorded with a precision of one second.
+func (s *Store) LockOutput(op *wire.OutPoint, expiry time.Time, reason string) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		k := canonicalOutPoint(&op.Hash, op.Index)
+		v := valueLockedOutput(expiry, reason)
+		return putRawLockedOutput(ns, k, v)
+	})
+}
+
+// UnlockOutput removes the lock of an output.  Unlocking an output which is
+// not locked is not an error.  A lock released by a mined transaction is
+// removed as well, so it is not restored if the transaction is rolled back.
+func (s *Store) UnlockOutput(op *wire.OutPoint) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		k := canonicalOutPoint(&op.Hash, op.Index)
+		err := deleteRawLockedOutput(ns, k)
+		if err != nil {
+			return err
+		}
+		return deleteRawReleasedLock(ns, k)
+	})
+}
+
+// UnlockAllOutputs removes the lock of every locked output, including the
+// locks released by mined transactions.
+func (s *Store) UnlockAllOutputs() error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		buckets := []struct {
+			name []byte
+			desc string
+		}{
+			{bucketLockedOutputs, "locked outputs"},
+			{bucketReleasedLocks, "released locks"},
+		}
+		for _, b := range buckets {
+			err := ns.DeleteBucket(b.name)
+			if err != nil {
+				str := "failed to delete " + b.desc + " bucket"
+				return storeError(ErrDatabase, str, err)
+			}
+			_, err = ns.CreateBucket(b.name)
+			if err != nil {
+				str := "failed to create " + b.desc + " bucket"
+				return storeError(ErrDatabase, str, err)
+			}
+		}
+		return nil
+	})
+}
+
+// releaseLockedOutput moves the lock of an output spent by a transaction mined
+// at height to the released locks, so it is restored by restoreReleasedLocks
+// if the block is rolled back.  Outputs which are not locked are ignored.
+func releaseLockedOutput(ns walletdb.Bucket, k []byte, height int32) error {
+	v := existsRawLockedOutput(ns, k)
+	if v == nil {
+		return nil
+	}
+	err := putRawReleasedLock(ns, k, valueReleasedLock(height, v))
+	if err != nil {
+		return err
+	}
+	return deleteRawLockedOutput(ns, k)
+}
+
+// restoreReleasedLocks restores the locks released by transactions mined at or
+// above height.  An output which has been locked again since keeps its newer
+// lock.
+func restoreReleasedLocks(ns walletdb.Bucket, height int32) error {
+	// Keys are collected first since a bucket may not be modified while
+	// iterating over it with ForEach.
+	type released struct {
+		k, v []byte
+	}
+	var restore []released
+	err := ns.Bucket(bucketReleasedLocks).ForEach(func(k, v []byte) error {
+		var lock LockedOutput
+		releaseHeight, err := readReleasedLock(k, v, &lock)
+		if err != nil {
+			return err
+		}
+		if releaseHeight >= height {
+			restore = append(restore, released{
+				k: append([]byte(nil), k...),
+				v: append([]byte(nil), v[4:]...),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, r := range restore {
+		if existsRawLockedOutput(ns, r.k) == nil {
+			err := putRawLockedOutput(ns, r.k, r.v)
+			if err != nil {
+				return err
+			}
+		}
+		err := deleteRawReleasedLock(ns, r.k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsLockedOutput returns whether an output is locked by a lock which has not
+// expired at time t.
+func (s *Store) IsLockedOutput(op *wire.OutPoint, t time.Time) (bool, error) {
+	var locked bool
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		k := canonicalOutPoint(&op.Hash, op.Index)
+		v := existsRawLockedOutput(ns, k)
+		if v == nil {
+			return nil
+		}
+		var lock LockedOutput
+		err := readLockedOutput(k, v, &lock)
+		if err != nil {
+			return err
+		}
+		locked = !lock.Expired(t)
+		return nil
+	})
+	return locked, err
+}
+
+// LockedOutputs returns every output locked by a lock which has not expired at
+// time t, in order of their outpoints.
+func (s *Store) LockedOutputs(t time.Time) ([]LockedOutput, error) {
+	var locks []LockedOutput
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		return ns.Bucket(bucketLockedOutputs).ForEach(func(k, v []byte) error {
+			var lock LockedOutput
+			err := readLockedOutput(k, v, &lock)
+			if err != nil {
+				return err
+			}
+			if !lock.Expired(t) {
+				locks = append(locks, lock)
+			}
+			return nil
+		})
+	})
+	return locks, err
+}
+
+// RemoveExpiredLocks removes every lock which has expired at time t, returning
+// the number of removed locks.  Expired locks do not lock their outputs even
+// before they are removed.  Expired locks released by mined transactions are
+// removed as well, but are not counted.
+func (s *Store) RemoveExpiredLocks(t time.Time) (int, error) {
+	var removed int
+	err := scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		// Keys are collected first since a bucket may not be modified
+		// while iterating over it with ForEach.
+		var expired, expiredReleased [][]byte
+		err := ns.Bucket(bucketLockedOutputs).ForEach(func(k, v []byte) error {
+			var lock LockedOutput
+			err := readLockedOutput(k, v, &lock)
+			if err != nil {
+				return err
+			}
+			if lock.Expired(t) {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		err = ns.Bucket(bucketReleasedLocks).ForEach(func(k, v []byte) error {
+			var lock LockedOutput
+			_, err := readReleasedLock(k, v, &lock)
+			if err != nil {
+				return err
+			}
+			if lock.Expired(t) {
+				expiredReleased = append(expiredReleased,
+					append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			err := deleteRawLockedOutput(ns, k)
+			if err != nil {
+				return err
+			}
+		}
+		for _, k := range expiredReleased {
+			err := deleteRawReleasedLock(ns, k)
+			if err != nil {
+				return err
+			}
+		}
+		removed = len(expired)
+		return nil
+	})
+	return removed, err
+}
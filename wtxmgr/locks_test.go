@@ -0,0 +1,209 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	. "github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestLockedOutputs(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("txstore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Create(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb := newCoinBase(1e8, 2e8, 3e8)
+	cbRec, err := NewTxRecordFromMsgTx(cb, timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(cbRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	for i := range cb.TxOut {
+		err := s.AddCredit(cbRec, &b100, uint32(i), false)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Unix(time.Now().Unix(), 0)
+	op0 := wire.OutPoint{Hash: cbRec.Hash, Index: 0}
+	op1 := wire.OutPoint{Hash: cbRec.Hash, Index: 1}
+	op2 := wire.OutPoint{Hash: cbRec.Hash, Index: 2}
+	if err := s.LockOutput(&op0, time.Time{}, "order 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LockOutput(&op1, now.Add(time.Hour), "order 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LockOutput(&op2, now.Add(time.Minute), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	checkLocked := func(at time.Time, exp ...LockedOutput) {
+		locks, err := s.LockedOutputs(at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(locks) != len(exp) {
+			t.Fatalf("LockedOutputs: got %d locks, expected %d",
+				len(locks), len(exp))
+		}
+		for i := range locks {
+			if locks[i].OutPoint != exp[i].OutPoint ||
+				!locks[i].Expiry.Equal(exp[i].Expiry) ||
+				locks[i].Reason != exp[i].Reason {
+				t.Errorf("LockedOutputs: lock %d is %v, expected %v",
+					i, locks[i], exp[i])
+			}
+			locked, err := s.IsLockedOutput(&exp[i].OutPoint, at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !locked {
+				t.Errorf("IsLockedOutput: %v is not locked",
+					exp[i].OutPoint)
+			}
+		}
+	}
+
+	lock0 := LockedOutput{OutPoint: op0, Reason: "order 1"}
+	lock1 := LockedOutput{OutPoint: op1, Expiry: now.Add(time.Hour), Reason: "order 2"}
+	lock2 := LockedOutput{OutPoint: op2, Expiry: now.Add(time.Minute)}
+	checkLocked(now, lock0, lock1, lock2)
+
+	// Expired locks no longer lock their outputs, and are removed by
+	// RemoveExpiredLocks.
+	later := now.Add(2 * time.Minute)
+	checkLocked(later, lock0, lock1)
+	locked, err := s.IsLockedOutput(&op2, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if locked {
+		t.Errorf("IsLockedOutput: expired lock of %v still locks output", op2)
+	}
+	n, err := s.RemoveExpiredLocks(later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("RemoveExpiredLocks: removed %d locks, expected 1", n)
+	}
+	checkLocked(now, lock0, lock1)
+
+	// Locks must be saved in the database.
+	s, err = Open(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now, lock0, lock1)
+
+	// Spending an output in an unmined transaction does not unlock it, but
+	// mining the spending transaction does, until the block is rolled
+	// back.
+	spendRec, err := NewTxRecordFromMsgTx(spendOutput(&cbRec.Hash, 0, 9e7), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.InsertTx(spendRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now, lock0, lock1)
+	b101 := makeBlockMeta(101)
+	if err := s.InsertTx(spendRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now, lock1)
+	if err := s.Rollback(101); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now, lock0, lock1)
+	if err := s.InsertTx(spendRec, &b101); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now, lock1)
+
+	// Unlocking a released lock prevents it from being restored.
+	if err := s.UnlockOutput(&op0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Rollback(101); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now, lock1)
+
+	if err := s.UnlockOutput(&op1); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now)
+
+	if err := s.LockOutput(&op1, time.Time{}, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UnlockAllOutputs(); err != nil {
+		t.Fatal(err)
+	}
+	checkLocked(now)
+}
+
+func TestUpgradeLockedOutputs(t *testing.T) {
+	t.Parallel()
+
+	// Version 1 stores did not save locked outputs.
+	ns, teardown, err := testOldStore(1)
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op := wire.OutPoint{Index: 1}
+	if err := s.LockOutput(&op, time.Time{}, ""); err != nil {
+		t.Fatalf("LockOutput after upgrade: %v", err)
+	}
+	err = ns.View(func(tx walletdb.Tx) error {
+		v := tx.RootBucket().Get([]byte("vers"))
+		if len(v) != 4 || v[3] != LatestVersion {
+			t.Errorf("Upgraded version is %x, expected %d", v,
+				LatestVersion)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
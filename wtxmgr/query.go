@@ -50,6 +50,10 @@ type TxDetails struct {
 	Block   BlockMeta
 	Credits []CreditRecord
 	Debits  []DebitRecord
+
+	// Sent is the information recorded with RecordSentTx when the wallet
+	// created the transaction, or nil for transactions created elsewhere.
+	Sent *SentTxInfo
 }
 
 // minedTxDetails fetches the TxDetails for the mined transaction with hash
@@ -101,7 +105,15 @@ func (s *Store) minedTxDetails(ns walletdb.Bucket, txHash *wire.ShaHash, recKey,
 
 		details.Debits = append(details.Debits, debIter.elem)
 	}
-	return &details, debIter.err
+	if debIter.err != nil {
+		return nil, debIter.err
+	}
+
+	details.Sent, err = fetchSentTxInfo(ns, txHash)
+	if err != nil {
+		return nil, err
+	}
+	return &details, nil
 }
 
 // unminedTxDetails fetches the TxDetails for the unmined transaction with the
@@ -169,6 +181,10 @@ func (s *Store) unminedTxDetails(ns walletdb.Bucket, txHash *wire.ShaHash, v []b
 		})
 	}
 
+	details.Sent, err = fetchSentTxInfo(ns, txHash)
+	if err != nil {
+		return nil, err
+	}
 	return &details, nil
 }
 
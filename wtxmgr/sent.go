@@ -0,0 +1,70 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcwallet/walletdb"
+)
+
+// SentTxInfo describes details of a transaction created by the wallet which
+// can not be determined from the transaction and the wallet's credits and
+// debits alone, such as the fee when not every previous output is known.
+type SentTxInfo struct {
+	// Fee is the total input value minus the total output value.
+	Fee btcutil.Amount
+
+	// ChangeIndex is the output index of the change output, or -1 if the
+	// transaction has no change.
+	ChangeIndex int
+
+	// Recipients are the output indexes of each output paying an intended
+	// recipient, in increasing order.
+	Recipients []uint32
+}
+
+// RecordSentTx saves the sent transaction info of a transaction created by the
+// wallet.  The transaction must have already been inserted into the store.  The
+// info is kept if the transaction is later mined, conflicted, or abandoned, and
+// is returned in the Sent field of its TxDetails.
+func (s *Store) RecordSentTx(txHash *wire.ShaHash, info *SentTxInfo) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		if existsRawUnmined(ns, txHash[:]) == nil {
+			_, v := latestTxRecord(ns, txHash)
+			if v == nil {
+				str := fmt.Sprintf("transaction %v is not "+
+					"recorded by the store", txHash)
+				return storeError(ErrInput, str, nil)
+			}
+		}
+		return putRawSentTxInfo(ns, txHash[:], valueSentTxInfo(info))
+	})
+}
+
+// InsertSentTx records an unmined transaction created by the wallet along
+// with its sent transaction info.  Both are written in a single database
+// transaction, so the transaction is never recorded without its info.
+func (s *Store) InsertSentTx(rec *TxRecord, info *SentTxInfo) error {
+	return scopedUpdate(s.namespace, func(ns walletdb.Bucket) error {
+		err := s.insertMemPoolTx(ns, rec)
+		if err != nil {
+			return err
+		}
+		return putRawSentTxInfo(ns, rec.Hash[:], valueSentTxInfo(info))
+	})
+}
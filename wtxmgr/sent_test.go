@@ -0,0 +1,150 @@
+// Copyright (c) 2015 The btcsuite developers
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+package wtxmgr_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcwallet/walletdb"
+	. "github.com/btcsuite/btcwallet/wtxmgr"
+)
+
+func TestSentTxInfo(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("txstore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Create(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Sent transaction info can not be recorded for unknown transactions.
+	sendRec, err := NewTxRecordFromMsgTx(spendOutput(&wire.ShaHash{}, 0, 3e7, 6e7), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := SentTxInfo{Fee: 1e7, ChangeIndex: 1, Recipients: []uint32{0}}
+	err = s.RecordSentTx(&sendRec.Hash, &info)
+	if serr, ok := err.(Error); !ok || serr.Code != ErrInput {
+		t.Fatalf("RecordSentTx of unknown transaction: got error %v, "+
+			"expected ErrInput", err)
+	}
+
+	checkSent := func(exp *SentTxInfo) {
+		details, err := s.TxDetails(&sendRec.Hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if details == nil {
+			t.Fatal("TxDetails: transaction not found")
+		}
+		if !reflect.DeepEqual(details.Sent, exp) {
+			t.Errorf("TxDetails: sent info is %v, expected %v",
+				details.Sent, exp)
+		}
+	}
+
+	if err := s.InsertTx(sendRec, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkSent(nil)
+	if err := s.RecordSentTx(&sendRec.Hash, &info); err != nil {
+		t.Fatal(err)
+	}
+	checkSent(&info)
+
+	// The info is kept when the transaction is mined and when the block
+	// is removed by a reorganize.
+	b100 := makeBlockMeta(100)
+	if err := s.InsertTx(sendRec, &b100); err != nil {
+		t.Fatal(err)
+	}
+	checkSent(&info)
+	if err := s.Rollback(100); err != nil {
+		t.Fatal(err)
+	}
+	checkSent(&info)
+
+	// Transactions without change record a change index of -1, and the
+	// info is kept for abandoned transactions.
+	info = SentTxInfo{Fee: 2e6, ChangeIndex: -1, Recipients: []uint32{1, 0}}
+	if err := s.RecordSentTx(&sendRec.Hash, &info); err != nil {
+		t.Fatal(err)
+	}
+	checkSent(&info)
+	if err := s.AbandonTx(&sendRec.Hash, 100); err != nil {
+		t.Fatal(err)
+	}
+	conflicted, err := s.ConflictedTx(&sendRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conflicted == nil {
+		t.Fatal("ConflictedTx: abandoned transaction not found")
+	}
+	if !reflect.DeepEqual(conflicted.Sent, &info) {
+		t.Errorf("ConflictedTx: sent info is %v, expected %v",
+			conflicted.Sent, &info)
+	}
+}
+
+func TestInsertSentTx(t *testing.T) {
+	t.Parallel()
+
+	s, teardown, err := testStore()
+	defer teardown()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sendRec, err := NewTxRecordFromMsgTx(spendOutput(&wire.ShaHash{}, 0, 3e7, 6e7), timeNow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := SentTxInfo{Fee: 1e7, ChangeIndex: 1, Recipients: []uint32{0}}
+	if err := s.InsertSentTx(sendRec, &info); err != nil {
+		t.Fatal(err)
+	}
+
+	unmined, err := s.UnminedTxs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(unmined) != 1 || unmined[0].TxSha() != sendRec.Hash {
+		t.Errorf("UnminedTxs: got %v, expected only %v", unmined,
+			sendRec.Hash)
+	}
+	details, err := s.TxDetails(&sendRec.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details == nil {
+		t.Fatal("TxDetails: transaction not found")
+	}
+	if !reflect.DeepEqual(details.Sent, &info) {
+		t.Errorf("TxDetails: sent info is %v, expected %v",
+			details.Sent, &info)
+	}
+}
@@ -269,6 +269,10 @@ func (s *Store) moveMinedTx(ns walletdb.Bucket, rec *TxRecord, recKey, recVal []
 		if err != nil {
 			return err
 		}
+		err = putAddrCredit(ns, rec, index, &block.Block, amount)
+		if err != nil {
+			return err
+		}
 		err = putUnspent(ns, &cred.outPoint, &block.Block)
 		if err != nil {
 			return err
@@ -311,6 +315,30 @@ func (s *Store) insertMinedTx(ns walletdb.Bucket, rec *TxRecord, block *BlockMet
 		return nil
 	}
 
+	// Outputs spent by a mined transaction can no longer be spent by
+	// newly created transactions, so release any of their locks.  The
+	// locks are restored if the block is rolled back.
+	for _, input := range rec.MsgTx.TxIn {
+		prevOut := &input.PreviousOutPoint
+		err := releaseLockedOutput(ns,
+			canonicalOutPoint(&prevOut.Hash, prevOut.Index),
+			block.Height)
+		if err != nil {
+			return err
+		}
+	}
+
+	// A conflicted or abandoned transaction which was mined after all is
+	// no longer conflicted.
+	err := deleteConflictedTx(ns, &rec.Hash)
+	if err != nil {
+		return err
+	}
+	err = deleteRawAbandoned(ns, rec.Hash[:])
+	if err != nil {
+		return err
+	}
+
 	// If the exact tx (not a double spend) is already included but
 	// unconfirmed, move it to a block.
 	v = existsRawUnmined(ns, rec.Hash[:])
@@ -323,7 +351,7 @@ func (s *Store) insertMinedTx(ns walletdb.Bucket, rec *TxRecord, block *BlockMet
 	// from the unconfirmed set.  This also handles removing unconfirmed
 	// transaction spend chains if any other unconfirmed transactions spend
 	// outputs of the removed double spend.
-	err := s.removeDoubleSpends(ns, rec)
+	err = s.removeDoubleSpends(ns, rec, block.Height)
 	if err != nil {
 		return err
 	}
@@ -426,9 +454,14 @@ func (s *Store) AddCredit(rec *TxRecord, block *BlockMeta, index uint32, change
 
 func (s *Store) addCredit(ns walletdb.Bucket, rec *TxRecord, block *BlockMeta, index uint32, change bool) error {
 	if block == nil {
+		txOutAmt := btcutil.Amount(rec.MsgTx.TxOut[index].Value)
 		k := canonicalOutPoint(&rec.Hash, index)
-		v := valueUnminedCredit(btcutil.Amount(rec.MsgTx.TxOut[index].Value), change)
-		return putRawUnminedCredit(ns, k, v)
+		v := valueUnminedCredit(txOutAmt, change)
+		err := putRawUnminedCredit(ns, k, v)
+		if err != nil {
+			return err
+		}
+		return putAddrCredit(ns, rec, index, nil, txOutAmt)
 	}
 
 	k, v := existsCredit(ns, &rec.Hash, index, &block.Block)
@@ -455,6 +488,10 @@ func (s *Store) addCredit(ns walletdb.Bucket, rec *TxRecord, block *BlockMeta, i
 	if err != nil {
 		return err
 	}
+	err = putAddrCredit(ns, rec, index, &block.Block, txOutAmt)
+	if err != nil {
+		return err
+	}
 
 	minedBalance, err := fetchMinedBalance(ns)
 	if err != nil {
@@ -517,8 +554,22 @@ func (s *Store) rollback(ns walletdb.Bucket, height int32) error {
 			// Handle coinbase transactions specially since they are
 			// not moved to the unconfirmed store.  A coinbase cannot
 			// contain any debits, but all credits should be removed
-			// and the mined balance decremented.
+			// and the mined balance decremented.  The coinbase is
+			// saved as a conflicted transaction instead.
 			if blockchain.IsCoinBaseTx(&rec.MsgTx) {
+				details, err := s.minedTxDetails(ns, txHash,
+					recKey, recVal)
+				if err != nil {
+					return err
+				}
+				if len(details.Credits) != 0 {
+					err = putConflictedTx(ns, details,
+						&conflict{height: b.Height})
+					if err != nil {
+						return err
+					}
+				}
+
 				op := wire.OutPoint{Hash: rec.Hash}
 				for i, output := range rec.MsgTx.TxOut {
 					k, v := existsCredit(ns, &rec.Hash,
@@ -542,6 +593,10 @@ func (s *Store) rollback(ns walletdb.Bucket, height int32) error {
 					if err != nil {
 						return err
 					}
+					err = deleteAddrCredit(ns, &rec, uint32(i))
+					if err != nil {
+						return err
+					}
 				}
 
 				continue
@@ -636,6 +691,10 @@ func (s *Store) rollback(ns walletdb.Bucket, height int32) error {
 				if err != nil {
 					return err
 				}
+				err = putAddrCredit(ns, &rec, uint32(i), nil, amt)
+				if err != nil {
+					return err
+				}
 
 				err = deleteRawCredit(ns, k)
 				if err != nil {
@@ -662,6 +721,13 @@ func (s *Store) rollback(ns walletdb.Bucket, height int32) error {
 		return it.err
 	}
 
+	// Locks released by transactions of the removed blocks lock their
+	// outputs again.
+	err = restoreReleasedLocks(ns, height)
+	if err != nil {
+		return err
+	}
+
 	for _, op := range coinBaseCredits {
 		opKey := canonicalOutPoint(&op.Hash, op.Index)
 		unminedKey := existsRawUnminedInput(ns, opKey)
@@ -676,7 +742,8 @@ func (s *Store) rollback(ns walletdb.Bucket, height int32) error {
 
 			log.Debugf("Transaction %v spends a removed coinbase "+
 				"output -- removing as well", unminedRec.Hash)
-			err = s.removeConflict(ns, &unminedRec)
+			err = s.removeConflict(ns, &unminedRec,
+				&conflict{height: height})
 			if err != nil {
 				return err
 			}
@@ -701,47 +768,17 @@ func (s *Store) UnspentOutputs() ([]Credit, error) {
 func (s *Store) unspentOutputs(ns walletdb.Bucket) ([]Credit, error) {
 	var unspent []Credit
 
-	var op wire.OutPoint
-	var block Block
 	err := ns.Bucket(bucketUnspent).ForEach(func(k, v []byte) error {
-		err := readCanonicalOutPoint(k, &op)
-		if err != nil {
-			return err
-		}
 		if existsRawUnminedInput(ns, k) != nil {
 			// Output is spent by an unmined transaction.
 			// Skip this k/v pair.
 			return nil
 		}
-		err = readUnspentBlock(v, &block)
-		if err != nil {
-			return err
-		}
-
-		blockTime, err := fetchBlockTime(ns, block.Height)
-		if err != nil {
-			return err
-		}
-		// TODO(jrick): reading the entire transaction should
-		// be avoidable.  Creating the credit only requires the
-		// output amount and pkScript.
-		rec, err := fetchTxRecord(ns, &op.Hash, &block)
+		cred, err := minedUnspentCredit(ns, k, v)
 		if err != nil {
 			return err
 		}
-		txOut := rec.MsgTx.TxOut[op.Index]
-		cred := Credit{
-			OutPoint: op,
-			BlockMeta: BlockMeta{
-				Block: block,
-				Time:  blockTime,
-			},
-			Amount:       btcutil.Amount(txOut.Value),
-			PkScript:     txOut.PkScript,
-			Received:     rec.Received,
-			FromCoinBase: blockchain.IsCoinBaseTx(&rec.MsgTx),
-		}
-		unspent = append(unspent, cred)
+		unspent = append(unspent, *cred)
 		return nil
 	})
 	if err != nil {
@@ -758,33 +795,11 @@ func (s *Store) unspentOutputs(ns walletdb.Bucket) ([]Credit, error) {
 			// Skip to next unmined credit.
 			return nil
 		}
-
-		err := readCanonicalOutPoint(k, &op)
+		cred, err := unminedUnspentCredit(ns, k)
 		if err != nil {
 			return err
 		}
-
-		// TODO(jrick): Reading/parsing the entire transaction record
-		// just for the output amount and script can be avoided.
-		recVal := existsRawUnmined(ns, op.Hash[:])
-		var rec TxRecord
-		err = readRawTxRecord(&op.Hash, recVal, &rec)
-		if err != nil {
-			return err
-		}
-
-		txOut := rec.MsgTx.TxOut[op.Index]
-		cred := Credit{
-			OutPoint: op,
-			BlockMeta: BlockMeta{
-				Block: Block{Height: -1},
-			},
-			Amount:       btcutil.Amount(txOut.Value),
-			PkScript:     txOut.PkScript,
-			Received:     rec.Received,
-			FromCoinBase: blockchain.IsCoinBaseTx(&rec.MsgTx),
-		}
-		unspent = append(unspent, cred)
+		unspent = append(unspent, *cred)
 		return nil
 	})
 	if err != nil {
@@ -798,6 +813,78 @@ func (s *Store) unspentOutputs(ns walletdb.Bucket) ([]Credit, error) {
 	return unspent, nil
 }
 
+// minedUnspentCredit returns the credit for the unspent bucket k/v pair of a
+// mined output.
+func minedUnspentCredit(ns walletdb.Bucket, k, v []byte) (*Credit, error) {
+	var op wire.OutPoint
+	err := readCanonicalOutPoint(k, &op)
+	if err != nil {
+		return nil, err
+	}
+	var block Block
+	err = readUnspentBlock(v, &block)
+	if err != nil {
+		return nil, err
+	}
+
+	blockTime, err := fetchBlockTime(ns, block.Height)
+	if err != nil {
+		return nil, err
+	}
+	// TODO(jrick): reading the entire transaction should
+	// be avoidable.  Creating the credit only requires the
+	// output amount and pkScript.
+	rec, err := fetchTxRecord(ns, &op.Hash, &block)
+	if err != nil {
+		return nil, err
+	}
+	txOut := rec.MsgTx.TxOut[op.Index]
+	cred := &Credit{
+		OutPoint: op,
+		BlockMeta: BlockMeta{
+			Block: block,
+			Time:  blockTime,
+		},
+		Amount:       btcutil.Amount(txOut.Value),
+		PkScript:     txOut.PkScript,
+		Received:     rec.Received,
+		FromCoinBase: blockchain.IsCoinBaseTx(&rec.MsgTx),
+	}
+	return cred, nil
+}
+
+// unminedUnspentCredit returns the credit for the unmined credits bucket key k
+// of an unmined output.
+func unminedUnspentCredit(ns walletdb.Bucket, k []byte) (*Credit, error) {
+	var op wire.OutPoint
+	err := readCanonicalOutPoint(k, &op)
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO(jrick): Reading/parsing the entire transaction record
+	// just for the output amount and script can be avoided.
+	recVal := existsRawUnmined(ns, op.Hash[:])
+	var rec TxRecord
+	err = readRawTxRecord(&op.Hash, recVal, &rec)
+	if err != nil {
+		return nil, err
+	}
+
+	txOut := rec.MsgTx.TxOut[op.Index]
+	cred := &Credit{
+		OutPoint: op,
+		BlockMeta: BlockMeta{
+			Block: Block{Height: -1},
+		},
+		Amount:       btcutil.Amount(txOut.Value),
+		PkScript:     txOut.PkScript,
+		Received:     rec.Received,
+		FromCoinBase: blockchain.IsCoinBaseTx(&rec.MsgTx),
+	}
+	return cred, nil
+}
+
 // Balance returns the spendable wallet balance (total value of all unspent
 // transaction outputs) given a minimum of minConf confirmations, calculated
 // at a current chain height of curHeight.  Coinbase outputs are only included
@@ -934,3 +1021,65 @@ func (s *Store) balance(ns walletdb.Bucket, minConf int32, syncHeight int32) (bt
 
 	return bal, nil
 }
+
+// ImmatureBalance returns the total value of unspent coinbase credits which
+// have not reached maturity for a chain at height syncHeight.  These credits
+// are excluded from every balance returned by Balance.
+func (s *Store) ImmatureBalance(syncHeight int32) (btcutil.Amount, error) {
+	var amt btcutil.Amount
+	err := scopedView(s.namespace, func(ns walletdb.Bucket) error {
+		var err error
+		amt, err = s.immatureBalance(ns, syncHeight)
+		return err
+	})
+	return amt, err
+}
+
+func (s *Store) immatureBalance(ns walletdb.Bucket, syncHeight int32) (btcutil.Amount, error) {
+	var bal btcutil.Amount
+
+	// Only coinbases mined in the last CoinbaseMaturity-1 blocks are
+	// immature.
+	lastHeight := syncHeight - blockchain.CoinbaseMaturity + 1
+	blockIt := makeReverseBlockIterator(ns)
+	for blockIt.prev() {
+		block := &blockIt.elem
+
+		if block.Height <= lastHeight {
+			break
+		}
+
+		for i := range block.transactions {
+			txHash := &block.transactions[i]
+			rec, err := fetchTxRecord(ns, txHash, &block.Block)
+			if err != nil {
+				return 0, err
+			}
+			if !blockchain.IsCoinBaseTx(&rec.MsgTx) {
+				continue
+			}
+			numOuts := uint32(len(rec.MsgTx.TxOut))
+			for i := uint32(0); i < numOuts; i++ {
+				// Credits spent by an unmined transaction are
+				// not included in any balance.
+				opKey := canonicalOutPoint(txHash, i)
+				if existsRawUnminedInput(ns, opKey) != nil {
+					continue
+				}
+
+				_, v := existsCredit(ns, txHash, i, &block.Block)
+				if v == nil {
+					continue
+				}
+				amt, spent, err := fetchRawCreditAmountSpent(v)
+				if err != nil {
+					return 0, err
+				}
+				if !spent {
+					bal += amt
+				}
+			}
+		}
+	}
+	return bal, blockIt.err
+}
@@ -16,10 +16,9 @@ package wtxmgr_test
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/hex"
-	"io/ioutil"
-	"os"
-	"path/filepath"
+	"fmt"
 	"testing"
 	"time"
 
@@ -27,7 +26,7 @@ import (
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
 	"github.com/btcsuite/btcwallet/walletdb"
-	_ "github.com/btcsuite/btcwallet/walletdb/bdb"
+	_ "github.com/btcsuite/btcwallet/walletdb/mem"
 	. "github.com/btcsuite/btcwallet/wtxmgr"
 )
 
@@ -57,36 +56,79 @@ var (
 )
 
 func testDB() (walletdb.DB, func(), error) {
-	tmpDir, err := ioutil.TempDir("", "wtxmgr_test")
-	if err != nil {
-		return nil, func() {}, err
-	}
-	db, err := walletdb.Create("bdb", filepath.Join(tmpDir, "db"))
-	return db, func() { os.RemoveAll(tmpDir) }, err
+	db, err := walletdb.Create("mem")
+	return db, func() {}, err
 }
 
 func testStore() (*Store, func(), error) {
-	tmpDir, err := ioutil.TempDir("", "wtxmgr_test")
+	db, err := walletdb.Create("mem")
 	if err != nil {
 		return nil, func() {}, err
 	}
-	db, err := walletdb.Create("bdb", filepath.Join(tmpDir, "db"))
+	teardown := func() {
+		db.Close()
+	}
+	ns, err := db.Namespace([]byte("txstore"))
 	if err != nil {
-		teardown := func() {
-			os.RemoveAll(tmpDir)
-		}
 		return nil, teardown, err
 	}
+	s, err := Create(ns)
+	return s, teardown, err
+}
+
+// versionBuckets are the buckets added by each version of the store after the
+// first, using the bucket names of the database format.  Every version which
+// adds buckets must be listed here for revertStore to write older stores.
+var versionBuckets = map[uint32][]string{
+	2: {"lo", "rl"},
+	3: {"cf", "cw"},
+	4: {"ab"},
+	5: {"si"},
+	6: {"ac"},
+}
+
+// revertStore reverts a latest version store in ns to an earlier version by
+// removing the buckets added by every later version and recording the earlier
+// version number.
+func revertStore(ns walletdb.Namespace, version uint32) error {
+	return ns.Update(func(tx walletdb.Tx) error {
+		root := tx.RootBucket()
+		for v := version + 1; v <= LatestVersion; v++ {
+			buckets, ok := versionBuckets[v]
+			if !ok {
+				return fmt.Errorf("buckets added by version %d "+
+					"are unknown", v)
+			}
+			for _, b := range buckets {
+				if err := root.DeleteBucket([]byte(b)); err != nil {
+					return err
+				}
+			}
+		}
+		var vers [4]byte
+		binary.BigEndian.PutUint32(vers[:], version)
+		return root.Put([]byte("vers"), vers[:])
+	})
+}
+
+// testOldStore creates a store written by an earlier version and returns its
+// namespace without opening it, so the next Open performs the upgrades.
+func testOldStore(version uint32) (walletdb.Namespace, func(), error) {
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		return nil, func() {}, err
+	}
 	teardown := func() {
 		db.Close()
-		os.RemoveAll(tmpDir)
 	}
 	ns, err := db.Namespace([]byte("txstore"))
 	if err != nil {
 		return nil, teardown, err
 	}
-	s, err := Create(ns)
-	return s, teardown, err
+	if _, err := Create(ns); err != nil {
+		return nil, teardown, err
+	}
+	return ns, teardown, revertStore(ns, version)
 }
 
 func serializeTx(tx *btcutil.Tx) []byte {
@@ -716,6 +758,24 @@ func TestCoinbases(t *testing.T) {
 		t.Fatal("Failed balance checks after inserting coinbase")
 	}
 
+	// The credits are reported as immature until the coinbase matures.
+	immatureTests := []balTest{
+		{height: b100.Height, bal: 50e8},
+		{height: b100.Height + blockchain.CoinbaseMaturity - 2, bal: 50e8},
+		{height: b100.Height + blockchain.CoinbaseMaturity - 1, bal: 0},
+	}
+	for i, tst := range immatureTests {
+		bal, err := s.ImmatureBalance(tst.height)
+		if err != nil {
+			t.Fatalf("Immature balance test %d: Store.ImmatureBalance "+
+				"failed: %v", i, err)
+		}
+		if bal != tst.bal {
+			t.Errorf("Immature balance test %d: Got %v Expected %v",
+				i, bal, tst.bal)
+		}
+	}
+
 	// Spend an output from the coinbase tx in an unmined transaction when
 	// the next block will mature the coinbase.
 	spenderATime := time.Now()
@@ -1211,3 +1271,114 @@ func TestInsertUnserializedTx(t *testing.T) {
 		t.Fatal("Serialized txs for coinbase spender do not match")
 	}
 }
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	db, err := walletdb.Create("mem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ns, err := db.Namespace([]byte("txstore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Create(ns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Insert a credit which is spent by a mined transaction, and the
+	// credit of the spending transaction.
+	recvRec, err := NewTxRecord(TstRecvSerializedTx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.InsertTx(recvRec, TstRecvTxBlockDetails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.AddCredit(recvRec, TstRecvTxBlockDetails, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spendingRec, err := NewTxRecord(TstSpendingSerializedTx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.InsertTx(spendingRec, TstSignedTxBlockDetails)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.AddCredit(spendingRec, TstSignedTxBlockDetails, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := s.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("unexpected problems in consistent store: %v", problems)
+	}
+
+	// Corrupt the store by removing the unspent index entry for the
+	// unspent credit and by changing the mined balance.  These use the
+	// bucket and key names of the database format.
+	err = ns.Update(func(tx walletdb.Tx) error {
+		ns := tx.RootBucket()
+		c := ns.Bucket([]byte("u")).Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return ns.Put([]byte("bal"), make([]byte, 8))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err = s.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	for _, p := range problems {
+		if p.Repaired {
+			t.Errorf("problem repaired without repair: %v", p)
+		}
+	}
+	problems, err = s.Check(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	for _, p := range problems {
+		if !p.Repaired {
+			t.Errorf("problem not repaired: %v", p)
+		}
+	}
+
+	problems, err = s.Check(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("unexpected problems in repaired store: %v", problems)
+	}
+	bal, err := s.Balance(1, TstSignedTxBlockDetails.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedBal := btcutil.Amount(TstSpendingTx.MsgTx().TxOut[0].Value)
+	if bal != expectedBal {
+		t.Fatalf("bad balance: %v != %v", bal, expectedBal)
+	}
+}
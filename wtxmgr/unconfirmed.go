@@ -17,6 +17,8 @@
 package wtxmgr
 
 import (
+	"time"
+
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcwallet/walletdb"
 )
@@ -40,6 +42,17 @@ func (s *Store) insertMemPoolTx(ns walletdb.Bucket, rec *TxRecord) error {
 		return err
 	}
 
+	// A previously conflicted or abandoned transaction is no longer
+	// conflicted once it is relayed again.
+	err = deleteConflictedTx(ns, &rec.Hash)
+	if err != nil {
+		return err
+	}
+	err = deleteRawAbandoned(ns, rec.Hash[:])
+	if err != nil {
+		return err
+	}
+
 	for _, input := range rec.MsgTx.TxIn {
 		prevOut := &input.PreviousOutPoint
 		k := canonicalOutPoint(&prevOut.Hash, prevOut.Index)
@@ -58,8 +71,9 @@ func (s *Store) insertMemPoolTx(ns walletdb.Bucket, rec *TxRecord) error {
 // removeDoubleSpends checks for any unmined transactions which would introduce
 // a double spend if tx was added to the store (either as a confirmed or unmined
 // transaction).  Each conflicting transaction and all transactions which spend
-// it are recursively removed.
-func (s *Store) removeDoubleSpends(ns walletdb.Bucket, rec *TxRecord) error {
+// it are recursively removed and saved as conflicted by rec, detected in the
+// block at height.
+func (s *Store) removeDoubleSpends(ns walletdb.Bucket, rec *TxRecord, height int32) error {
 	for _, input := range rec.MsgTx.TxIn {
 		prevOut := &input.PreviousOutPoint
 		prevOutKey := canonicalOutPoint(&prevOut.Hash, prevOut.Index)
@@ -76,7 +90,8 @@ func (s *Store) removeDoubleSpends(ns walletdb.Bucket, rec *TxRecord) error {
 
 			log.Debugf("Removing double spending transaction %v",
 				doubleSpend.Hash)
-			err = s.removeConflict(ns, &doubleSpend)
+			err = s.removeConflict(ns, &doubleSpend,
+				&conflict{winner: &rec.Hash, height: height})
 			if err != nil {
 				return err
 			}
@@ -85,11 +100,102 @@ func (s *Store) removeDoubleSpends(ns walletdb.Bucket, rec *TxRecord) error {
 	return nil
 }
 
+// conflict describes why transactions are removed by removeConflict.
+type conflict struct {
+	winner    *wire.ShaHash // Winning mined transaction, or nil
+	height    int32         // Height at which the conflict was detected
+	abandoned time.Time     // Time abandoned with AbandonTx, or zero
+}
+
+// putConflictedTx saves the details of a transaction which is being removed
+// from the store in the conflicted bucket, and in the abandoned bucket if it
+// was abandoned.  The details must be looked up before any of the credits or
+// debits of the transaction are removed.
+func putConflictedTx(ns walletdb.Bucket, details *TxDetails, c *conflict) error {
+	conflicted := ConflictedTx{TxDetails: *details, Height: c.height}
+	if c.winner != nil {
+		conflicted.WinningTx = *c.winner
+	}
+	for i := range conflicted.Credits {
+		conflicted.Credits[i].Spent = false
+	}
+	v, err := valueConflictedTx(&conflicted)
+	if err != nil {
+		return err
+	}
+
+	// Remove any earlier record of the transaction so the index of its
+	// previous winner is not left behind.
+	err = deleteConflictedTx(ns, &details.Hash)
+	if err != nil {
+		return err
+	}
+	err = putRawConflictedTx(ns, details.Hash[:], v)
+	if err != nil {
+		return err
+	}
+	if c.winner != nil {
+		k := keyConflictWinner(c.winner, &details.Hash)
+		err = putRawConflictWinner(ns, k)
+		if err != nil {
+			return err
+		}
+	}
+	if !c.abandoned.IsZero() {
+		v := valueAbandoned(c.abandoned)
+		err = putRawAbandoned(ns, details.Hash[:], v)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteConflictedTx removes the record of a conflicted transaction, and its
+// entry in the conflict winners index.  Removing a transaction which is not
+// conflicted is not an error.
+func deleteConflictedTx(ns walletdb.Bucket, txHash *wire.ShaHash) error {
+	v := existsRawConflictedTx(ns, txHash[:])
+	if v == nil {
+		return nil
+	}
+	if len(v) < 32 {
+		str := "short conflicted transaction value"
+		return storeError(ErrData, str, nil)
+	}
+	var winner wire.ShaHash
+	copy(winner[:], v)
+	if winner != (wire.ShaHash{}) {
+		err := deleteRawConflictWinner(ns, keyConflictWinner(&winner, txHash))
+		if err != nil {
+			return err
+		}
+	}
+	return deleteRawConflictedTx(ns, txHash[:])
+}
+
 // removeConflict removes an unmined transaction record and all spend chains
 // deriving from it from the store.  This is designed to remove transactions
 // that would otherwise result in double spend conflicts if left in the store,
-// and to remove transactions that spend coinbase transactions on reorgs.
-func (s *Store) removeConflict(ns walletdb.Bucket, rec *TxRecord) error {
+// to remove transactions that spend coinbase transactions on reorgs, and to
+// remove abandoned transactions.
+//
+// Each removed transaction is saved in the conflicted bucket, recording the
+// hash of the winning transaction and the height at which the conflict was
+// detected.  Abandoned transactions are also saved in the abandoned bucket.
+func (s *Store) removeConflict(ns walletdb.Bucket, rec *TxRecord, c *conflict) error {
+	// Save the details of the transaction before anything is removed, since
+	// its credits and debits can not be looked up afterwards.
+	details, err := s.unminedTxDetails(ns, &rec.Hash,
+		existsRawUnmined(ns, rec.Hash[:]))
+	if err != nil {
+		return err
+	}
+	err = putConflictedTx(ns, details, c)
+	if err != nil {
+		return err
+	}
+
 	// For each potential credit for this record, each spender (if any) must
 	// be recursively removed as well.  Once the spenders are removed, the
 	// credit is deleted.
@@ -108,7 +214,7 @@ func (s *Store) removeConflict(ns walletdb.Bucket, rec *TxRecord) error {
 
 			log.Debugf("Transaction %v is part of a removed conflict "+
 				"chain -- removing as well", spender.Hash)
-			err = s.removeConflict(ns, &spender)
+			err = s.removeConflict(ns, &spender, c)
 			if err != nil {
 				return err
 			}
@@ -117,6 +223,10 @@ func (s *Store) removeConflict(ns walletdb.Bucket, rec *TxRecord) error {
 		if err != nil {
 			return err
 		}
+		err = deleteAddrCredit(ns, rec, i)
+		if err != nil {
+			return err
+		}
 	}
 
 	// If this tx spends any previous credits (either mined or unmined), set